@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chaos makes responses misbehave at random, each fault with its own
+// probability between 0 and 1.
+type chaos struct {
+	delayProb     float64
+	delay         time.Duration
+	errorProb     float64
+	truncateProb  float64
+	malformedProb float64
+}
+
+var (
+	chaosCfg chaos
+	// chaosRand draws the dice; tests replace it.
+	chaosRand = rand.Float64
+)
+
+// malformedLines are exposition lines a parser has to reject or skip.
+var malformedLines = []string{
+	`chaos_unterminated{label="oops 1`,
+	`chaos_no_value`,
+	`chaos_bad_value{a="b"} not-a-number`,
+	`{} 42`,
+	`chaos_extra_fields 1 2 3 4`,
+	`chaos bad name 7`,
+	`# TYPE chaos_bad_type histogramish`,
+}
+
+func roll(prob float64) bool {
+	return prob > 0 && chaosRand() < prob
+}
+
+func (c chaos) validate() error {
+	for name, p := range map[string]float64{
+		"-chaos-delay-prob":     c.delayProb,
+		"-chaos-500-prob":       c.errorProb,
+		"-chaos-truncate-prob":  c.truncateProb,
+		"-chaos-malformed-prob": c.malformedProb,
+	} {
+		if p < 0 || p > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %g", name, p)
+		}
+	}
+	if c.delay < 0 {
+		return fmt.Errorf("-chaos-delay must not be negative")
+	}
+	return nil
+}
+
+// serve writes body through the configured faults. A truncated body still
+// announces its full length, so the client sees the connection cut short.
+func (c chaos) serve(w http.ResponseWriter, r *http.Request, contentType, body string) {
+	if roll(c.delayProb) {
+		select {
+		case <-time.After(c.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if roll(c.errorProb) {
+		http.Error(w, "chaos: internal server error", http.StatusInternalServerError)
+		return
+	}
+	if roll(c.malformedProb) {
+		body = injectMalformed(body)
+	}
+	w.Header().Set("Content-Type", contentType)
+	if roll(c.truncateProb) && len(body) > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		body = body[:rand.Intn(len(body))]
+	}
+	fmt.Fprint(w, body)
+}
+
+// injectMalformed puts one to three malformed lines at random line breaks.
+func injectMalformed(body string) string {
+	lines := strings.SplitAfter(body, "\n")
+	for range 1 + rand.Intn(3) {
+		i := rand.Intn(len(lines) + 1)
+		bad := malformedLines[rand.Intn(len(malformedLines))] + "\n"
+		lines = append(lines[:i], append([]string{bad}, lines[i:]...)...)
+	}
+	return strings.Join(lines, "")
+}
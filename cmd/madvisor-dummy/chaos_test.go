@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosValidate(t *testing.T) {
+	if err := (chaos{delayProb: 0.5, errorProb: 1, delay: time.Second}).validate(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+	for _, c := range []chaos{{errorProb: 1.5}, {truncateProb: -0.1}, {delay: -time.Second}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v should be rejected", c)
+		}
+	}
+}
+
+func TestInjectMalformed(t *testing.T) {
+	body := "a 1\nb 2\n"
+	out := injectMalformed(body)
+	bad := 0
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		for _, m := range malformedLines {
+			if line == m {
+				bad++
+			}
+		}
+	}
+	if bad < 1 || bad > 3 {
+		t.Errorf("injected %d malformed lines into %q", bad, out)
+	}
+	if !strings.Contains(out, "a 1\n") || !strings.Contains(out, "b 2\n") {
+		t.Errorf("original lines lost: %q", out)
+	}
+}
+
+func TestChaosServe(t *testing.T) {
+	defer func(f func() float64) { chaosRand = f }(chaosRand)
+	chaosRand = func() float64 { return 0.5 }
+	body := strings.Repeat("metric 1\n", 100)
+
+	serve := func(c chaos) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c.serve(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil), textContentType, body)
+		return rec
+	}
+	if rec := serve(chaos{errorProb: 0.4}); rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Error("faults below the roll should not fire")
+	}
+	if rec := serve(chaos{errorProb: 0.6}); rec.Code != http.StatusInternalServerError {
+		t.Errorf("500 fault: got %d", rec.Code)
+	}
+	if rec := serve(chaos{malformedProb: 1}); len(rec.Body.String()) <= len(body) {
+		t.Error("malformed fault should inject lines")
+	}
+	start := time.Now()
+	serve(chaos{delayProb: 1, delay: 20 * time.Millisecond})
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("delay fault should hold the response")
+	}
+}
+
+func TestChaosTruncate(t *testing.T) {
+	body := strings.Repeat("metric 1\n", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chaos{truncateProb: 1}.serve(w, r, textContentType, body)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	got, err := io.ReadAll(resp.Body)
+	if err == nil || len(got) >= len(body) {
+		t.Errorf("truncated body read %d of %d bytes, err %v", len(got), len(body), err)
+	}
+}
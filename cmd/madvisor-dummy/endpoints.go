@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Behaviors a virtual target can have.
+const (
+	behaviorNormal      = "normal"
+	behaviorSlow        = "slow"
+	behaviorFlapping    = "flapping"
+	behaviorCardinality = "cardinality"
+)
+
+// allEndpoints is what -endpoints all expands to: one target per behavior.
+const allEndpoints = "normal@:8080,slow@:8081,flapping@:8082,cardinality@:8083"
+
+var (
+	// slowDelay is how long a slow target takes to answer, plus up to half
+	// again of jitter.
+	slowDelay = 2 * time.Second
+	// flapPeriod is one up/down cycle of a flapping target: it answers for
+	// the first half and fails with 503 for the second.
+	flapPeriod = 30 * time.Second
+	// cardinalitySeries is how many extra series a cardinality target has.
+	cardinalitySeries = 5000
+)
+
+var regions = []string{"eu-west-1", "us-east-1", "us-west-2", "ap-south-1"}
+
+// endpoint is one virtual target with its own metrics.
+type endpoint struct {
+	behavior string
+	addr     string
+	m        *metrics
+}
+
+// parseEndpoints reads a comma-separated list of behavior@addr, or all.
+func parseEndpoints(spec string) ([]*endpoint, error) {
+	if spec == "all" {
+		spec = allEndpoints
+	}
+	var eps []*endpoint
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		behavior, addr, ok := strings.Cut(part, "@")
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("endpoint %q: want behavior@addr", part)
+		}
+		switch behavior {
+		case behaviorNormal, behaviorSlow, behaviorFlapping, behaviorCardinality:
+		default:
+			return nil, fmt.Errorf("endpoint %q: behavior must be normal, slow, flapping or cardinality", part)
+		}
+		if seen[behavior] {
+			return nil, fmt.Errorf("endpoint %q: %s is listed twice", part, behavior)
+		}
+		seen[behavior] = true
+		ep := &endpoint{behavior: behavior, addr: addr, m: &metrics{}}
+		if behavior == behaviorCardinality {
+			ep.m.cardinality = cardinalitySeries
+		}
+		eps = append(eps, ep)
+	}
+	if len(eps) == 0 {
+		return nil, fmt.Errorf("no endpoints in %q", spec)
+	}
+	return eps, nil
+}
+
+// up reports whether a flapping target answers at now.
+func up(now time.Time) bool {
+	return now.UnixNano()%int64(flapPeriod) < int64(flapPeriod)/2
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch e.behavior {
+	case behaviorSlow:
+		delay := slowDelay + time.Duration(rand.Int63n(int64(slowDelay)/2+1))
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	case behaviorFlapping:
+		if !up(time.Now()) {
+			http.Error(w, "flapping: down", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if openMetrics {
+		chaosCfg.serve(w, r, openMetricsContentType, e.m.renderOpenMetrics())
+		return
+	}
+	chaosCfg.serve(w, r, textContentType, e.m.render())
+}
+
+// muxes builds one handler per listen address. Each serves its first
+// endpoint on /metrics and every endpoint on /<behavior>/metrics, so all
+// targets are also reachable through a single port.
+func muxes(eps []*endpoint) map[string]*http.ServeMux {
+	out := map[string]*http.ServeMux{}
+	for _, ep := range eps {
+		if _, ok := out[ep.addr]; ok {
+			continue
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", ep)
+		for _, other := range eps {
+			mux.Handle("/"+other.behavior+"/metrics", other)
+		}
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, "ok")
+		})
+		out[ep.addr] = mux
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	eps, err := parseEndpoints("all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 4 || eps[1].behavior != behaviorSlow || eps[1].addr != ":8081" {
+		t.Fatalf("all = %+v", eps)
+	}
+	if eps[3].m.cardinality != cardinalitySeries || eps[0].m.cardinality != 0 {
+		t.Error("only the cardinality target should have session series")
+	}
+
+	for _, spec := range []string{"", "normal", "noisy@:8080", "slow@:8081,slow@:8082"} {
+		if _, err := parseEndpoints(spec); err == nil {
+			t.Errorf("parseEndpoints(%q) should fail", spec)
+		}
+	}
+}
+
+func TestFlappingUp(t *testing.T) {
+	start := time.Unix(0, 0)
+	if !up(start) || !up(start.Add(flapPeriod/2-time.Second)) {
+		t.Error("flapping target should be up in the first half of the period")
+	}
+	if up(start.Add(flapPeriod/2)) || up(start.Add(flapPeriod-time.Second)) {
+		t.Error("flapping target should be down in the second half of the period")
+	}
+}
+
+func TestCardinalityTick(t *testing.T) {
+	m := &metrics{cardinality: 50}
+	m.tick()
+	m.tick()
+	if len(m.sessions) != 50 {
+		t.Fatalf("sessions = %d, want 50", len(m.sessions))
+	}
+	out := m.render()
+	if n := strings.Count(out, "session_bytes{"); n != 50 {
+		t.Errorf("rendered %d session series, want 50", n)
+	}
+	if strings.Count(out, "# TYPE session_bytes") != 1 {
+		t.Error("session_bytes should have one TYPE line")
+	}
+}
+
+func TestMuxes(t *testing.T) {
+	defer func(d time.Duration) { slowDelay = d }(slowDelay)
+	slowDelay = time.Millisecond
+	eps, err := parseEndpoints("normal@:8080,slow@:8081,cardinality@:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ep := range eps {
+		ep.m.tick()
+	}
+	ms := muxes(eps)
+	if len(ms) != 2 {
+		t.Fatalf("muxes = %d, want one per address", len(ms))
+	}
+
+	get := func(addr, path string) (int, string) {
+		rec := httptest.NewRecorder()
+		ms[addr].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+	if code, body := get(":8080", "/metrics"); code != http.StatusOK || strings.Contains(body, "session_bytes") {
+		t.Errorf(":8080/metrics should serve the normal target, got %d", code)
+	}
+	if _, body := get(":8080", "/cardinality/metrics"); !strings.Contains(body, "session_bytes") {
+		t.Error(":8080/cardinality/metrics should serve the cardinality target")
+	}
+	if code, body := get(":8081", "/metrics"); code != http.StatusOK || !strings.Contains(body, "http_requests_total") {
+		t.Errorf(":8081/metrics should serve the slow target, got %d", code)
+	}
+	if code, _ := get(":8081", "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d", code)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"math"
@@ -18,19 +19,24 @@ var (
 )
 
 type series struct {
-	name   string
-	labels map[string]string
-	value  float64
+	name    string
+	labels  map[string]string
+	value   float64
 	counter bool
+	// created is when a counter started; exemplar is the trace of its last
+	// increment, for -openmetrics output.
+	created  time.Time
+	exemplar exemplar
 }
 
 type metrics struct {
 	mu     sync.RWMutex
 	series []series
+	// cardinality adds that many session series on top of the usual ones.
+	cardinality int
+	sessions    []series
 }
 
-var m = &metrics{}
-
 func labelsStr(labels map[string]string) string {
 	if len(labels) == 0 {
 		return ""
@@ -50,7 +56,8 @@ func (m *metrics) tick() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	t := float64(time.Now().UnixMilli()) / 1000.0
+	now := time.Now()
+	t := float64(now.UnixMilli()) / 1000.0
 
 	methods := []string{"GET", "POST", "PUT", "DELETE"}
 	paths := []string{"/api/users", "/api/orders", "/api/products", "/healthz"}
@@ -70,7 +77,9 @@ func (m *metrics) tick() {
 			for i := range m.series {
 				s := &m.series[i]
 				if s.name == "http_requests_total" && s.labels["method"] == method && s.labels["path"] == path {
-					s.value += math.Max(0, base*rand.Float64())
+					inc := math.Max(0, base*rand.Float64())
+					s.value += inc
+					s.exemplar = newExemplar(inc, now)
 				}
 			}
 			ss = append(ss, series{
@@ -123,6 +132,8 @@ func (m *metrics) tick() {
 		for i := range ss {
 			if ss[i].counter {
 				ss[i].value = math.Max(0, 10*rand.Float64())
+				ss[i].created = now
+				ss[i].exemplar = newExemplar(ss[i].value, now)
 			}
 		}
 		m.series = ss
@@ -135,6 +146,15 @@ func (m *metrics) tick() {
 			}
 		}
 	}
+
+	m.sessions = m.sessions[:0]
+	for i := range m.cardinality {
+		m.sessions = append(m.sessions, series{
+			name:   "session_bytes",
+			labels: map[string]string{"session": fmt.Sprintf("s%05d", i), "region": regions[i%len(regions)]},
+			value:  gauge(t+float64(i), 4096, 2048, 20, 512),
+		})
+	}
 }
 
 func labelsMatch(a, b map[string]string) bool {
@@ -149,21 +169,26 @@ func labelsMatch(a, b map[string]string) bool {
 	return true
 }
 
-func (m *metrics) render() string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var b strings.Builder
-
+// families groups the series by metric name, in first-seen order.
+func (m *metrics) families() ([]string, map[string][]series) {
 	grouped := map[string][]series{}
 	order := []string{}
-	for _, s := range m.series {
+	for _, s := range append(m.series[:len(m.series):len(m.series)], m.sessions...) {
 		if _, ok := grouped[s.name]; !ok {
 			order = append(order, s.name)
 		}
 		grouped[s.name] = append(grouped[s.name], s)
 	}
+	return order, grouped
+}
 
+func (m *metrics) render() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var b strings.Builder
+
+	order, grouped := m.families()
 	for _, name := range order {
 		ss := grouped[name]
 		mtype := "gauge"
@@ -181,26 +206,45 @@ func (m *metrics) render() string {
 }
 
 func main() {
+	listen := flag.String("listen", ":8080", "address to serve /metrics on")
+	spec := flag.String("endpoints", "", "virtual targets as behavior@addr,... (normal, slow, flapping, cardinality), or all")
+	flag.BoolVar(&openMetrics, "openmetrics", false, "serve the OpenMetrics format with exemplars, # UNIT lines and _created series")
+	flag.Float64Var(&chaosCfg.delayProb, "chaos-delay-prob", 0, "probability of delaying a response by -chaos-delay")
+	flag.DurationVar(&chaosCfg.delay, "chaos-delay", 5*time.Second, "how long a chaos delay lasts, e.g. past the scraper's timeout")
+	flag.Float64Var(&chaosCfg.errorProb, "chaos-500-prob", 0, "probability of answering 500 Internal Server Error")
+	flag.Float64Var(&chaosCfg.truncateProb, "chaos-truncate-prob", 0, "probability of cutting the response body short")
+	flag.Float64Var(&chaosCfg.malformedProb, "chaos-malformed-prob", 0, "probability of injecting malformed metric lines")
+	flag.Parse()
+
+	if err := chaosCfg.validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *spec == "" {
+		*spec = behaviorNormal + "@" + *listen
+	}
+	eps, err := parseEndpoints(*spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
 		for range ticker.C {
-			m.tick()
+			for _, ep := range eps {
+				ep.m.tick()
+			}
 		}
 	}()
 
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-		fmt.Fprint(w, m.render())
-	})
-
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprint(w, "ok")
-	})
-
-	addr := ":8080"
 	log.Printf("madvisor-dummy %s (commit=%s branch=%s)", version, commit, branch)
-	log.Printf("madvisor-dummy serving metrics on %s/metrics", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	for _, ep := range eps {
+		log.Printf("madvisor-dummy serving %s metrics on %s/metrics and /%s/metrics", ep.behavior, ep.addr, ep.behavior)
+	}
+	errs := make(chan error)
+	for addr, mux := range muxes(eps) {
+		go func() { errs <- http.ListenAndServe(addr, mux) }()
+	}
+	log.Fatal(<-errs)
 }
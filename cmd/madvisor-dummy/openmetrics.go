@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// openMetrics switches /metrics to the OpenMetrics format (-openmetrics).
+var openMetrics bool
+
+// units are the # UNIT lines written for families whose name ends in one.
+var units = map[string]string{
+	"cpu_usage_percent":      "percent",
+	"memory_usage_megabytes": "megabytes",
+	"session_bytes":          "bytes",
+}
+
+// exemplar links a counter increment to a made-up trace.
+type exemplar struct {
+	traceID string
+	value   float64
+	at      time.Time
+}
+
+func newExemplar(value float64, at time.Time) exemplar {
+	return exemplar{traceID: fmt.Sprintf("%016x", rand.Uint64()), value: value, at: at}
+}
+
+func omTime(t time.Time) string {
+	return fmt.Sprintf("%.3f", float64(t.UnixMilli())/1000)
+}
+
+// renderOpenMetrics writes the series in the OpenMetrics text format:
+// counter families named without _total, # UNIT lines, a _created sample
+// per counter series and an exemplar on its last increment.
+func (m *metrics) renderOpenMetrics() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var b strings.Builder
+
+	order, grouped := m.families()
+	for _, name := range order {
+		ss := grouped[name]
+		mtype, family := "gauge", name
+		if ss[0].counter {
+			mtype, family = "counter", strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", family, mtype)
+		if unit, ok := units[family]; ok {
+			fmt.Fprintf(&b, "# UNIT %s %s\n", family, unit)
+		}
+		fmt.Fprintf(&b, "# HELP %s Synthetic %s metric.\n", family, mtype)
+		for _, s := range ss {
+			fmt.Fprintf(&b, "%s%s %.4f", s.name, labelsStr(s.labels), s.value)
+			if ex := s.exemplar; s.counter && ex.traceID != "" {
+				fmt.Fprintf(&b, ` # {trace_id="%s"} %.4f %s`, ex.traceID, ex.value, omTime(ex.at))
+			}
+			b.WriteString("\n")
+			if s.counter && !s.created.IsZero() {
+				fmt.Fprintf(&b, "%s_created%s %s\n", family, labelsStr(s.labels), omTime(s.created))
+			}
+		}
+	}
+	b.WriteString("# EOF\n")
+
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRenderOpenMetrics(t *testing.T) {
+	m := &metrics{cardinality: 2}
+	m.tick()
+	m.tick()
+	out := m.renderOpenMetrics()
+
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Error("OpenMetrics output must end with # EOF")
+	}
+	for _, want := range []string{
+		"# TYPE http_requests counter\n",
+		"# TYPE cpu_usage_percent gauge\n",
+		"# UNIT cpu_usage_percent percent\n",
+		"# UNIT session_bytes bytes\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q", want)
+		}
+	}
+	if strings.Contains(out, "# UNIT http_request_duration_ms") {
+		t.Error("families whose name does not end in a unit should have no # UNIT line")
+	}
+
+	totals, created, exemplars := 0, 0, 0
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "http_requests_total{"):
+			totals++
+			if strings.Contains(line, ` # {trace_id="`) {
+				exemplars++
+			}
+		case strings.HasPrefix(line, "http_requests_created{"):
+			created++
+		case strings.Contains(line, " # {"):
+			t.Errorf("only counters should carry exemplars: %q", line)
+		}
+	}
+	if totals != 16 || created != 16 || exemplars != 16 {
+		t.Errorf("got %d _total, %d _created, %d exemplars, want 16 each", totals, created, exemplars)
+	}
+}
+
+var updateFixture = flag.Bool("update", false, "rewrite the viewer's OpenMetrics fixture")
+
+// openMetricsFixture is a capture of renderOpenMetrics that the viewer's
+// exporter corpus parses, so its exemplars stay covered there.
+const openMetricsFixture = "../madvisor/testdata/exporters/madvisor_dummy.prom"
+
+func TestOpenMetricsFixture(t *testing.T) {
+	m := &metrics{}
+	m.tick()
+	m.tick()
+	out := m.renderOpenMetrics()
+	if *updateFixture {
+		if err := os.WriteFile(openMetricsFixture, []byte(out), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := os.ReadFile(openMetricsFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Values, trace IDs and label order change per run; the families don't.
+	if got, want := metadataLines(string(b)), metadataLines(out); got != want {
+		t.Errorf("%s is out of date (run go test -update):\n got  %s\n want %s", openMetricsFixture, got, want)
+	}
+}
+
+func metadataLines(s string) string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, " | ")
+}
+
+func TestServeOpenMetrics(t *testing.T) {
+	defer func() { openMetrics = false }()
+	ep := &endpoint{behavior: behaviorNormal, m: &metrics{}}
+	ep.m.tick()
+
+	for _, om := range []bool{false, true} {
+		openMetrics = om
+		rec := httptest.NewRecorder()
+		ep.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		ct := rec.Header().Get("Content-Type")
+		eof := strings.HasSuffix(rec.Body.String(), "# EOF\n")
+		if om && (ct != openMetricsContentType || !eof) {
+			t.Errorf("-openmetrics served %q, eof=%v", ct, eof)
+		}
+		if !om && (ct != textContentType || eof) {
+			t.Errorf("default served %q, eof=%v", ct, eof)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mum4k/termdash/keyboard"
+)
+
+const defaultActionTimeout = time.Minute
+
+var globalActions map[keyboard.Key]compiledAction
+
+// ActionConfig binds a function or Ctrl key to a shell command or a webhook,
+// run with the dashboard's selection as context.
+type ActionConfig struct {
+	Key     string        `yaml:"key"`
+	Name    string        `yaml:"name"`
+	Command string        `yaml:"command"`
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type compiledAction struct {
+	key     string
+	name    string
+	command string
+	url     string
+	timeout time.Duration
+}
+
+var fnKeys = []keyboard.Key{
+	keyboard.KeyF1, keyboard.KeyF2, keyboard.KeyF3, keyboard.KeyF4, keyboard.KeyF5, keyboard.KeyF6,
+	keyboard.KeyF7, keyboard.KeyF8, keyboard.KeyF9, keyboard.KeyF10, keyboard.KeyF11, keyboard.KeyF12,
+}
+
+// ctrlKeys leaves out ctrl+c and the Ctrl keys terminals send for
+// Backspace, Tab, newline and Enter (h, i, j, m).
+var ctrlKeys = map[string]keyboard.Key{
+	"a": keyboard.KeyCtrlA, "b": keyboard.KeyCtrlB, "d": keyboard.KeyCtrlD, "e": keyboard.KeyCtrlE,
+	"f": keyboard.KeyCtrlF, "g": keyboard.KeyCtrlG, "k": keyboard.KeyCtrlK,
+	"l": keyboard.KeyCtrlL, "n": keyboard.KeyCtrlN, "o": keyboard.KeyCtrlO, "p": keyboard.KeyCtrlP,
+	"q": keyboard.KeyCtrlQ, "r": keyboard.KeyCtrlR, "s": keyboard.KeyCtrlS, "t": keyboard.KeyCtrlT,
+	"u": keyboard.KeyCtrlU, "v": keyboard.KeyCtrlV, "w": keyboard.KeyCtrlW, "x": keyboard.KeyCtrlX,
+	"y": keyboard.KeyCtrlY, "z": keyboard.KeyCtrlZ,
+}
+
+func parseActionKey(s string) (keyboard.Key, error) {
+	k := strings.ToLower(strings.TrimSpace(s))
+	if n, err := strconv.Atoi(strings.TrimPrefix(k, "f")); strings.HasPrefix(k, "f") && err == nil && n >= 1 && n <= len(fnKeys) {
+		return fnKeys[n-1], nil
+	}
+	if l, ok := strings.CutPrefix(k, "ctrl+"); ok {
+		if key, ok := ctrlKeys[l]; ok {
+			return key, nil
+		}
+	}
+	return 0, fmt.Errorf("key %q: want F1-F12 or ctrl+a-z (not ctrl+c, h, i, j or m)", s)
+}
+
+func compileActions(cfgs []ActionConfig) (map[keyboard.Key]compiledAction, error) {
+	out := make(map[keyboard.Key]compiledAction, len(cfgs))
+	for i, c := range cfgs {
+		k, err := parseActionKey(c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("actions[%d]: %w", i, err)
+		}
+		if (c.Command == "") == (c.URL == "") {
+			return nil, fmt.Errorf("actions[%d]: exactly one of command and url is required", i)
+		}
+		if _, dup := out[k]; dup {
+			return nil, fmt.Errorf("actions[%d]: %s is bound twice", i, c.Key)
+		}
+		a := compiledAction{key: c.Key, name: c.Name, command: c.Command, url: c.URL, timeout: c.Timeout}
+		if a.name == "" {
+			a.name = c.Command + c.URL
+		}
+		if a.timeout <= 0 {
+			a.timeout = defaultActionTimeout
+		}
+		out[k] = a
+	}
+	return out, nil
+}
+
+// actionContext is the selection an action runs against.
+type actionContext struct {
+	Metric string            `json:"metric"`
+	Series string            `json:"series,omitempty"`
+	Target string            `json:"target,omitempty"`
+	Group  string            `json:"group,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  *float64          `json:"value,omitempty"`
+}
+
+func selectionContext(ui *uiState, st *store) actionContext {
+	c := actionContext{Metric: ui.selectedKey(), Group: ui.group()}
+	s := selectedSeries(ui, st)
+	if s == nil {
+		return c
+	}
+	c.Series, c.Target, c.Labels = s.key, replicaOf(s), s.labels
+	if v := s.last(); s.count() > 0 {
+		c.Value = &v
+	}
+	return c
+}
+
+var envName = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// env exposes the selection as MADVISOR_* variables, one per label as
+// MADVISOR_LABEL_<NAME>.
+func (c actionContext) env() []string {
+	env := []string{
+		"MADVISOR_METRIC=" + c.Metric,
+		"MADVISOR_SERIES=" + c.Series,
+		"MADVISOR_TARGET=" + c.Target,
+		"MADVISOR_GROUP=" + c.Group,
+	}
+	if c.Value != nil {
+		env = append(env, "MADVISOR_VALUE="+strconv.FormatFloat(*c.Value, 'g', -1, 64))
+	}
+	keys := make([]string, 0, len(c.Labels))
+	for k := range c.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, "MADVISOR_LABEL_"+envName.ReplaceAllString(strings.ToUpper(k), "_")+"="+c.Labels[k])
+	}
+	return env
+}
+
+// run executes the action and describes the outcome in one line: the exit
+// status and last line of output of a command, or the webhook's response.
+func (a compiledAction) run(ctx context.Context, c actionContext) string {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	prefix := a.key + " " + a.name + ": "
+	if a.url != "" {
+		body, _ := json.Marshal(c)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+		if err != nil {
+			return prefix + err.Error()
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return prefix + err.Error()
+		}
+		resp.Body.Close()
+		return prefix + resp.Status
+	}
+	cmd := shellCommand(ctx, a.command)
+	cmd.Env = append(os.Environ(), c.env()...)
+	// Children of the shell may hold its output open past a timeout.
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	status := "done"
+	if err != nil {
+		status = err.Error()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		status = "timed out after " + a.timeout.String()
+	}
+	if last := lastLine(string(out)); last != "" {
+		status += " · " + last
+	}
+	return prefix + status
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// runAction starts the action bound to k, if any, in the background.
+func runAction(ctx context.Context, k keyboard.Key, ui *uiState, st *store) bool {
+	a, ok := globalActions[k]
+	if !ok {
+		return false
+	}
+	c := selectionContext(ui, st)
+	ui.setMessage(a.key + " " + a.name + ": running")
+	go func() { ui.setMessage(a.run(ctx, c)) }()
+	return true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/keyboard"
+)
+
+func TestParseActionKey(t *testing.T) {
+	for in, want := range map[string]keyboard.Key{"F1": keyboard.KeyF1, "f12": keyboard.KeyF12, "ctrl+a": keyboard.KeyCtrlA, "Ctrl+R": keyboard.KeyCtrlR} {
+		if k, err := parseActionKey(in); err != nil || k != want {
+			t.Errorf("parseActionKey(%q) = %v, %v", in, k, err)
+		}
+	}
+	for _, in := range []string{"F0", "F13", "x", "ctrl+h", "ctrl+j", "ctrl+c", "ctrl+ab", "alt+a"} {
+		if _, err := parseActionKey(in); err == nil {
+			t.Errorf("parseActionKey(%q) should fail", in)
+		}
+	}
+}
+
+func TestCompileActions(t *testing.T) {
+	got, err := compileActions([]ActionConfig{{Key: "F5", Command: "kubectl rollout restart deploy/foo"}, {Key: "ctrl+p", Name: "page", URL: "http://hooks/x", Timeout: time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := got[keyboard.KeyF5]; a.name != "kubectl rollout restart deploy/foo" || a.timeout != defaultActionTimeout {
+		t.Errorf("F5 = %+v, want the command as its name and the default timeout", a)
+	}
+	if a := got[keyboard.KeyCtrlP]; a.name != "page" || a.timeout != time.Second {
+		t.Errorf("ctrl+p = %+v", a)
+	}
+
+	for _, cfgs := range [][]ActionConfig{
+		{{Key: "F1"}},
+		{{Key: "F1", Command: "a", URL: "http://b"}},
+		{{Key: "F1", Command: "a"}, {Key: "f1", Command: "b"}},
+		{{Key: "q", Command: "a"}},
+	} {
+		if _, err := compileActions(cfgs); err == nil {
+			t.Errorf("compileActions(%+v) should fail", cfgs)
+		}
+	}
+}
+
+func TestActionContextEnv(t *testing.T) {
+	v := 0.5
+	c := actionContext{Metric: "up", Series: `up{instance="a:1"}`, Target: "a:1", Labels: map[string]string{"instance": "a:1", "k8s.pod-name": "web-0"}, Value: &v}
+	env := c.env()
+	for _, want := range []string{"MADVISOR_METRIC=up", "MADVISOR_TARGET=a:1", "MADVISOR_VALUE=0.5", "MADVISOR_LABEL_INSTANCE=a:1", "MADVISOR_LABEL_K8S_POD_NAME=web-0"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env %v missing %s", env, want)
+		}
+	}
+}
+
+func TestActionCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	c := actionContext{Metric: "http_requests_total", Labels: map[string]string{"pod": "web-0"}}
+	a := compiledAction{key: "F5", name: "restart", command: `echo working; echo "$MADVISOR_METRIC $MADVISOR_LABEL_POD"`, timeout: time.Second}
+	if got := a.run(context.Background(), c); got != "F5 restart: done · http_requests_total web-0" {
+		t.Errorf("run = %q", got)
+	}
+	a.command = "echo nope >&2; exit 3"
+	if got := a.run(context.Background(), c); got != "F5 restart: exit status 3 · nope" {
+		t.Errorf("failing run = %q", got)
+	}
+	a.command, a.timeout = "sleep 5", 50*time.Millisecond
+	if got := a.run(context.Background(), c); !strings.Contains(got, "timed out after 50ms") {
+		t.Errorf("slow run = %q", got)
+	}
+}
+
+func TestActionWebhook(t *testing.T) {
+	var got actionContext
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	a := compiledAction{key: "F6", name: "page", url: srv.URL, timeout: time.Second}
+	if msg := a.run(context.Background(), actionContext{Metric: "up", Target: "a:1"}); msg != "F6 page: 202 Accepted" {
+		t.Errorf("run = %q", msg)
+	}
+	if got.Metric != "up" || got.Target != "a:1" {
+		t.Errorf("webhook received %+v", got)
+	}
+}
+
+func TestRunActionUsesSelection(t *testing.T) {
+	defer func(a map[keyboard.Key]compiledAction) { globalActions = a }(globalActions)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	globalActions = map[keyboard.Key]compiledAction{keyboard.KeyF2: {key: "F2", name: "hook", url: srv.URL, timeout: time.Second}}
+
+	st := newStore()
+	st.ingest("a:1", "queue_depth", map[string]string{"queue": "jobs"}, "", "gauge", 7, time.Now())
+	ui := &uiState{}
+	ui.setKeys([]string{"queue_depth"})
+	if c := selectionContext(ui, st); c.Metric != "queue_depth" || c.Labels["queue"] != "jobs" || c.Value == nil || *c.Value != 7 {
+		t.Errorf("selection = %+v", c)
+	}
+	if runAction(context.Background(), keyboard.KeyF3, ui, st) {
+		t.Error("an unbound key should not run an action")
+	}
+	if !runAction(context.Background(), keyboard.KeyF2, ui, st) {
+		t.Fatal("F2 should run its action")
+	}
+	waitFor(t, func() bool {
+		ui.mu.Lock()
+		defer ui.mu.Unlock()
+		return ui.message == "F2 hook: 200 OK"
+	})
+}
+
+func TestActionsConfig(t *testing.T) {
+	cfg, err := loadUnitsConfig([]byte("actions:\n  - key: F5\n    name: restart\n    command: kubectl rollout restart deploy/$MADVISOR_LABEL_DEPLOYMENT\n    timeout: 2m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := mergeUnits(&UnitsConfig{}, cfg)
+	if len(merged.Actions) != 1 || merged.Actions[0].Timeout != 2*time.Minute {
+		t.Errorf("actions = %+v", merged.Actions)
+	}
+}
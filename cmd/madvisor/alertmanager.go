@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+var globalAlertmanager *alertmanager
+
+const (
+	alertmanagerInterval = 30 * time.Second
+	alertmanagerTimeout  = 10 * time.Second
+	alertmanagerPath     = "/api/v2/alerts?active=true&silenced=false&inhibited=false"
+)
+
+// amAlert is the subset of an Alertmanager v2 alert that the panel shows.
+type amAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+}
+
+func (a amAlert) name() string {
+	if n := a.Labels["alertname"]; n != "" {
+		return n
+	}
+	return "(unnamed)"
+}
+
+func (a amAlert) summary() string {
+	for _, k := range []string{"summary", "description", "message"} {
+		if v := a.Annotations[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type alertmanager struct {
+	url     string
+	client  *http.Client
+	targets []target
+
+	mu        sync.Mutex
+	alerts    []amAlert
+	lastErr   error
+	fetchedAt time.Time
+}
+
+func newAlertmanager(url string, targets []target) *alertmanager {
+	return &alertmanager{
+		url:     strings.TrimSuffix(url, "/"),
+		client:  &http.Client{Timeout: alertmanagerTimeout},
+		targets: targets,
+	}
+}
+
+func (am *alertmanager) attach(ctx context.Context, st *store) {
+	go am.run(ctx, st)
+}
+
+func (am *alertmanager) run(ctx context.Context, st *store) {
+	ticker := time.NewTicker(alertmanagerInterval)
+	defer ticker.Stop()
+	for {
+		alerts, err := am.fetch(ctx)
+		am.mu.Lock()
+		am.lastErr = err
+		if err == nil {
+			am.alerts = relevantAlerts(alerts, st.activeTargets(am.targets))
+			am.fetchedAt = time.Now()
+		}
+		am.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (am *alertmanager) fetch(ctx context.Context) ([]amAlert, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, am.url+alertmanagerPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	var alerts []amAlert
+	if err := json.NewDecoder(resp.Body).Decode(&alerts); err != nil {
+		return nil, fmt.Errorf("decode alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+// relevantAlerts keeps alerts whose instance label names a scraped target
+// (by host:port or bare host) or whose job label names a target group.
+func relevantAlerts(alerts []amAlert, targets []target) []amAlert {
+	addrs, hosts, groups := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, t := range targets {
+		addrs[t.addr] = true
+		hosts[hostOf(t.addr)] = true
+		if t.group != "" {
+			groups[t.group] = true
+		}
+	}
+	var out []amAlert
+	for _, a := range alerts {
+		inst, job := a.Labels["instance"], a.Labels["job"]
+		if (inst != "" && (addrs[inst] || hosts[hostOf(inst)])) || (job != "" && groups[job]) {
+			out = append(out, a)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartsAt.After(out[j].StartsAt) })
+	return out
+}
+
+func hostOf(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+func (am *alertmanager) snapshot() ([]amAlert, time.Time, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.alerts, am.fetchedAt, am.lastErr
+}
+
+func (am *alertmanager) firing() int {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return len(am.alerts)
+}
+
+func renderAlertmanager(w *text.Text, am *alertmanager, now time.Time) {
+	alerts, at, err := am.snapshot()
+	header := fmt.Sprintf("\n Alertmanager %s — %d firing", am.url, len(alerts))
+	if !at.IsZero() {
+		header += fmt.Sprintf(", updated %s ago", formatRelDuration(now.Sub(at)))
+	}
+	w.Write(header+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+	if err != nil {
+		w.Write("  "+err.Error()+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+	}
+	for _, a := range alerts {
+		where := a.Labels["instance"]
+		if where == "" {
+			where = "job=" + a.Labels["job"]
+		}
+		w.Write(fmt.Sprintf(" %-28s", truncateText(a.name(), 28)), text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+		w.Write(fmt.Sprintf(" %-10s", a.Labels["severity"]), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write(fmt.Sprintf(" %-24s", truncateText(where, 24)), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		w.Write(fmt.Sprintf(" for %s", formatRelDuration(now.Sub(a.StartsAt))), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		if s := a.summary(); s != "" {
+			w.Write("  "+s, text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		}
+		w.Write("\n")
+	}
+}
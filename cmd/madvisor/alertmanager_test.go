@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestRelevantAlerts(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alerts := []amAlert{
+		{Labels: map[string]string{"alertname": "HighLatency", "instance": "web-1:8080"}, StartsAt: t0},
+		{Labels: map[string]string{"alertname": "NodeDown", "instance": "db-1:9100"}, StartsAt: t0.Add(time.Minute)},
+		{Labels: map[string]string{"alertname": "Other", "instance": "elsewhere:80"}},
+		{Labels: map[string]string{"alertname": "JobErrors", "job": "api"}, StartsAt: t0.Add(2 * time.Minute)},
+		{Labels: map[string]string{"alertname": "NoLabels"}},
+	}
+	targets := []target{{addr: "web-1:8080"}, {addr: "db-1:8080"}, {addr: "api-1:8080", group: "api"}}
+
+	got := relevantAlerts(alerts, targets)
+	var names []string
+	for _, a := range got {
+		names = append(names, a.name())
+	}
+	want := []string{"JobErrors", "NodeDown", "HighLatency"}
+	if len(names) != len(want) {
+		t.Fatalf("relevant = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("relevant = %v, want %v (newest first)", names, want)
+		}
+	}
+}
+
+func TestAlertSummary(t *testing.T) {
+	a := amAlert{Annotations: map[string]string{"description": "long", "summary": "short"}}
+	if a.summary() != "short" || a.name() != "(unnamed)" {
+		t.Errorf("summary=%q name=%q", a.summary(), a.name())
+	}
+}
+
+func TestAlertmanagerFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" || r.URL.Query().Get("silenced") != "false" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[{"labels":{"alertname":"Up","instance":"web-1:8080","severity":"page"},"annotations":{"summary":"it is down"},"startsAt":"2024-01-01T00:00:00Z","status":{"state":"active"}}]`))
+	}))
+	defer srv.Close()
+
+	am := newAlertmanager(srv.URL+"/", []target{{addr: "web-1:8080"}})
+	alerts, err := am.fetch(context.Background())
+	if err != nil || len(alerts) != 1 || alerts[0].Labels["severity"] != "page" || alerts[0].summary() != "it is down" {
+		t.Fatalf("fetch = %+v, %v", alerts, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	st := newStore()
+	am.attach(ctx, st)
+	deadline := time.Now().Add(2 * time.Second)
+	for am.firing() != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if am.firing() != 1 {
+		t.Fatalf("firing = %d after poll", am.firing())
+	}
+
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderAlertmanager(w, am, time.Now())
+}
+
+func TestAlertmanagerFetchError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	if _, err := newAlertmanager(srv.URL, nil).fetch(context.Background()); err == nil {
+		t.Error("non-2xx response should fail")
+	}
+}
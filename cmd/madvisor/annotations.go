@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const maxAnnotations = 64
+
+type annotation struct {
+	Time time.Time
+	Text string
+}
+
+func (st *store) annotate(text string) {
+	st.annotateAt(text, time.Now())
+}
+
+func (st *store) annotateAt(text string, t time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.annotations = append(st.annotations, annotation{Time: t, Text: text})
+	if len(st.annotations) > maxAnnotations {
+		st.annotations = st.annotations[len(st.annotations)-maxAnnotations:]
+	}
+	if st.observe != nil {
+		st.observe(sample{Time: t, Annotation: text})
+	}
+}
+
+func (st *store) annotationsBetween(from, to time.Time) []annotation {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var out []annotation
+	for _, a := range st.annotations {
+		if !a.Time.Before(from) && !a.Time.After(to) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (st *store) setPaused(paused bool) bool {
+	st.mu.Lock()
+	st.paused = paused
+	st.mu.Unlock()
+	if paused {
+		st.annotate("paused")
+	} else {
+		st.annotate("resumed")
+	}
+	return paused
+}
+
+func (st *store) togglePaused() bool {
+	return st.setPaused(!st.isPaused())
+}
+
+func (st *store) isPaused() bool {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.paused
+}
+
+func changeRateWindow(st *store, change func() time.Duration) {
+	before := rateWindowGet()
+	change()
+	if after := rateWindowGet(); after != before {
+		st.annotate(fmt.Sprintf("rate %s→%s", before, after))
+	}
+}
+
+func annotationLabels(anns []annotation, from, to time.Time, n int) map[int]string {
+	if len(anns) == 0 || n < 2 || !to.After(from) {
+		return nil
+	}
+	span := float64(to.Sub(from))
+	labels := map[int]string{}
+	for _, a := range anns {
+		if a.Time.Before(from) || a.Time.After(to) {
+			continue
+		}
+		idx := int(math.Round(float64(a.Time.Sub(from)) / span * float64(n-1)))
+		if prev, ok := labels[idx]; ok {
+			labels[idx] = prev + "," + a.Text
+		} else {
+			labels[idx] = "▼" + a.Text
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
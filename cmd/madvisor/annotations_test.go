@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotationLabels(t *testing.T) {
+	from := time.Unix(1000, 0)
+	to := from.Add(10 * time.Second)
+	anns := []annotation{
+		{Time: from.Add(-time.Second), Text: "old"},
+		{Time: from.Add(5 * time.Second), Text: "rate 5s→10s"},
+		{Time: from.Add(5 * time.Second), Text: "paused"},
+		{Time: to, Text: "resumed"},
+	}
+	got := annotationLabels(anns, from, to, 11)
+	want := map[int]string{5: "▼rate 5s→10s,paused", 10: "▼resumed"}
+	if len(got) != len(want) {
+		t.Fatalf("annotationLabels = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("label[%d] = %q, want %q", k, got[k], v)
+		}
+	}
+	if annotationLabels(nil, from, to, 11) != nil {
+		t.Error("no annotations should give nil labels")
+	}
+}
+
+func TestChangeRateWindowAnnotates(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	rateWindowSet(5 * time.Second)
+
+	st := newStore()
+	changeRateWindow(st, rateWindowUp)
+	anns := st.annotationsBetween(time.Time{}, time.Now())
+	if len(anns) != 1 || anns[0].Text != "rate 5s→10s" {
+		t.Errorf("annotations = %+v", anns)
+	}
+
+	rateWindowSet(rateWindowSteps[len(rateWindowSteps)-1])
+	changeRateWindow(st, rateWindowUp)
+	if n := len(st.annotationsBetween(time.Time{}, time.Now())); n != 1 {
+		t.Errorf("unchanged window should not annotate, got %d annotations", n)
+	}
+}
+
+func TestPauseDropsSamples(t *testing.T) {
+	st := newStore()
+	st.update("m", nil, "", "gauge", 1)
+	if !st.togglePaused() {
+		t.Fatal("togglePaused should pause")
+	}
+	st.update("m", nil, "", "gauge", 2)
+	if got := st.get("m").count(); got != 1 {
+		t.Errorf("count while paused = %d, want 1", got)
+	}
+	st.togglePaused()
+	st.update("m", nil, "", "gauge", 3)
+	if got := st.get("m").last(); got != 3 {
+		t.Errorf("last after resume = %v, want 3", got)
+	}
+	anns := st.annotationsBetween(time.Time{}, time.Now())
+	if len(anns) != 2 || anns[0].Text != "paused" || anns[1].Text != "resumed" {
+		t.Errorf("annotations = %+v", anns)
+	}
+}
+
+func TestAnnotationsCapped(t *testing.T) {
+	st := newStore()
+	for i := 0; i < maxAnnotations+10; i++ {
+		st.annotate("x")
+	}
+	if n := len(st.annotationsBetween(time.Time{}, time.Now())); n != maxAnnotations {
+		t.Errorf("annotations = %d, want %d", n, maxAnnotations)
+	}
+}
+
+func TestAnnotationsRecordedAndReplayed(t *testing.T) {
+	var buf bytes.Buffer
+	rec := newRecorder(&buf)
+	st := newStore()
+	st.observe = rec.write
+	st.update("m", nil, "", "gauge", 1)
+	st.annotate("paused")
+	if !strings.Contains(buf.String(), `"annotation":"paused"`) {
+		t.Fatalf("recording missing annotation: %s", buf.String())
+	}
+
+	samples, err := readSamples(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lastValues(samples)) != 1 {
+		t.Errorf("annotations should not count as series: %v", lastValues(samples))
+	}
+
+	out := newStore()
+	replay(t.Context(), samples, 1000, out)
+	anns := out.annotationsBetween(time.Time{}, time.Now().Add(time.Minute))
+	if len(anns) != 1 || anns[0].Text != "paused" {
+		t.Errorf("replayed annotations = %+v", anns)
+	}
+	if len(out.names()) != 1 {
+		t.Errorf("replayed names = %v", out.names())
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var globalBacklogs []compiledBacklog
+
+// BacklogRule pairs an enqueued counter with a processed counter and derives
+// the gauge Name = Enqueued - Processed, i.e. the work still outstanding.
+// Series are paired on identical labels, or on just the On labels (summing
+// the rest away) when given.
+type BacklogRule struct {
+	Name      string   `yaml:"name"`
+	Enqueued  string   `yaml:"enqueued"`
+	Processed string   `yaml:"processed"`
+	On        []string `yaml:"on"`
+}
+
+type compiledBacklog struct {
+	name      string
+	enqueued  string
+	processed string
+	on        []string
+}
+
+func compileBacklogs(cfgs []BacklogRule) ([]compiledBacklog, error) {
+	out := make([]compiledBacklog, 0, len(cfgs))
+	for i, c := range cfgs {
+		if c.Name == "" || c.Enqueued == "" || c.Processed == "" {
+			return nil, fmt.Errorf("backlogs[%d]: name, enqueued and processed are required", i)
+		}
+		if c.Name == c.Enqueued || c.Name == c.Processed {
+			return nil, fmt.Errorf("backlogs[%d]: name %q must differ from the paired counters", i, c.Name)
+		}
+		out = append(out, compiledBacklog{name: c.Name, enqueued: c.Enqueued, processed: c.Processed, on: c.On})
+	}
+	return out, nil
+}
+
+// pairLabels is the label set an enqueued/processed series is paired on.
+// The target's group and instance labels are always kept so the derived
+// series stays in its group and does not collide across replicas.
+func (b compiledBacklog) pairLabels(labels map[string]string) map[string]string {
+	out := map[string]string{}
+	if len(b.on) == 0 {
+		for k, v := range labels {
+			out[k] = v
+		}
+		return out
+	}
+	for _, l := range append([]string{groupLabel, instanceLabel}, b.on...) {
+		if v, ok := labels[l]; ok {
+			out[l] = v
+		}
+	}
+	return out
+}
+
+type backlogPair struct {
+	labels    map[string]string
+	enqueued  float64
+	processed float64
+	have      [2]bool
+}
+
+// deriveBacklogs records the outstanding gauges for the samples src reported
+// at t. A pair missing either side in that scrape is skipped rather than
+// derived from a stale value.
+func deriveBacklogs(st *store, src string, t time.Time) {
+	if len(globalBacklogs) == 0 {
+		return
+	}
+	type derived struct {
+		rule   compiledBacklog
+		labels map[string]string
+		value  float64
+	}
+	var out []derived
+	st.mu.RLock()
+	for _, b := range globalBacklogs {
+		pairs := map[string]*backlogPair{}
+		var order []string
+		for _, s := range st.series {
+			side := 0
+			switch {
+			case s.name == b.processed:
+				side = 1
+			case s.name != b.enqueued:
+				continue
+			}
+			if s.source != src || s.count() == 0 || !s.lastTime().Equal(t) {
+				continue
+			}
+			labels := b.pairLabels(s.labels)
+			key := seriesKey(b.name, labels)
+			p := pairs[key]
+			if p == nil {
+				p = &backlogPair{labels: labels}
+				pairs[key] = p
+				order = append(order, key)
+			}
+			if side == 0 {
+				p.enqueued += s.last()
+			} else {
+				p.processed += s.last()
+			}
+			p.have[side] = true
+		}
+		for _, key := range order {
+			if p := pairs[key]; p.have[0] && p.have[1] {
+				out = append(out, derived{rule: b, labels: p.labels, value: p.enqueued - p.processed})
+			}
+		}
+	}
+	st.mu.RUnlock()
+
+	for _, d := range out {
+		help := fmt.Sprintf("%s - %s (derived backlog)", d.rule.enqueued, d.rule.processed)
+		st.ingest(src, d.rule.name, d.labels, help, "gauge", d.value, t)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func withBacklogs(t *testing.T, rules ...BacklogRule) {
+	t.Helper()
+	old := globalBacklogs
+	t.Cleanup(func() { globalBacklogs = old })
+	compiled, err := compileBacklogs(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	globalBacklogs = compiled
+}
+
+func TestDeriveBacklogs(t *testing.T) {
+	withBacklogs(t, BacklogRule{Name: "jobs_outstanding", Enqueued: "jobs_enqueued_total", Processed: "jobs_processed_total"})
+	st := newStore()
+	now := time.Now()
+	for _, q := range []string{"a", "b"} {
+		st.ingest("w:1", "jobs_enqueued_total", map[string]string{"queue": q}, "", "counter", 100, now)
+	}
+	st.ingest("w:1", "jobs_processed_total", map[string]string{"queue": "a"}, "", "counter", 60, now)
+	st.ingest("w:1", "jobs_processed_total", map[string]string{"queue": "b"}, "", "counter", 100, now.Add(-time.Minute))
+	deriveBacklogs(st, "w:1", now)
+
+	list := st.seriesForName("jobs_outstanding")
+	if len(list) != 1 {
+		t.Fatalf("derived %d series, want only queue a (b's processed sample is stale)", len(list))
+	}
+	s := list[0]
+	if s.labels["queue"] != "a" || s.last() != 40 || s.detectedType() != "gauge" || s.source != "w:1" {
+		t.Errorf("derived = %v %v %s from %s, want queue=a 40 gauge from w:1", s.labels, s.last(), s.detectedType(), s.source)
+	}
+}
+
+func TestDeriveBacklogsOn(t *testing.T) {
+	withBacklogs(t, BacklogRule{Name: "jobs_outstanding", Enqueued: "jobs_enqueued_total", Processed: "jobs_processed_total", On: []string{"queue"}})
+	st := newStore()
+	now := time.Now()
+	st.ingest("w:1", "jobs_enqueued_total", map[string]string{"queue": "a", "producer": "x", groupLabel: "prod"}, "", "counter", 30, now)
+	st.ingest("w:1", "jobs_enqueued_total", map[string]string{"queue": "a", "producer": "y", groupLabel: "prod"}, "", "counter", 20, now)
+	st.ingest("w:1", "jobs_processed_total", map[string]string{"queue": "a", "worker": "1", groupLabel: "prod"}, "", "counter", 45, now)
+	st.ingest("w:2", "jobs_enqueued_total", map[string]string{"queue": "a"}, "", "counter", 1000, now)
+	deriveBacklogs(st, "w:1", now)
+
+	s := st.get(seriesKey("jobs_outstanding", map[string]string{"queue": "a", groupLabel: "prod"}))
+	if s == nil || s.last() != 5 {
+		t.Fatalf("derived = %+v, want 50 - 45 = 5 summed on queue, keeping the group", s)
+	}
+}
+
+func TestCompileBacklogs(t *testing.T) {
+	for _, r := range []BacklogRule{
+		{Enqueued: "a", Processed: "b"},
+		{Name: "a", Enqueued: "a", Processed: "b"},
+	} {
+		if _, err := compileBacklogs([]BacklogRule{r}); err == nil {
+			t.Errorf("compileBacklogs(%+v) succeeded, want error", r)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+var (
+	flagBaseline   string
+	globalBaseline *baselineRun
+)
+
+// baselineRun replays a recording into a store of its own alongside the
+// live scrape, at the same pace, so every metric can be compared with the
+// baseline run at the same elapsed time: a load test against the last one.
+type baselineRun struct {
+	path    string
+	samples []sample
+	length  time.Duration
+
+	mu     sync.Mutex
+	parent context.Context
+	cancel context.CancelFunc
+	st     *store
+	start  time.Time
+}
+
+func loadBaseline(path string) (*baselineRun, error) {
+	samples, err := loadSamples(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("recording %q is empty", path)
+	}
+	return &baselineRun{
+		path:    path,
+		samples: samples,
+		length:  samples[len(samples)-1].Time.Sub(samples[0].Time),
+		st:      newStore(),
+	}, nil
+}
+
+// attach starts the replay with the dashboard.
+func (b *baselineRun) attach(ctx context.Context, st *store) {
+	b.mu.Lock()
+	b.parent = ctx
+	b.mu.Unlock()
+	b.restart(time.Now())
+}
+
+// restart replays the baseline from its beginning as of now, to line it up
+// with a load test started after madvisor.
+func (b *baselineRun) restart(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.parent == nil {
+		return
+	}
+	if b.cancel != nil {
+		b.cancel()
+	}
+	ctx, cancel := context.WithCancel(b.parent)
+	st := newStore()
+	b.cancel, b.st, b.start = cancel, st, now
+	go replay(ctx, b.samples, 1, st)
+}
+
+func (b *baselineRun) state() (*store, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.st, b.start
+}
+
+// buildBaseline compares every metric in names that both the live targets
+// and the baseline report, like the canary comparison does between groups.
+func buildBaseline(live, base *store, names []string, window time.Duration) []canaryRow {
+	var rows []canaryRow
+	for _, name := range names {
+		rows = append(rows, compareMetric(name, base.seriesForName(name), live.seriesForName(name), window)...)
+	}
+	sortCanaryRows(rows)
+	return rows
+}
+
+func renderBaseline(w *text.Text, st *store, b *baselineRun, names []string, now time.Time) {
+	w.Reset()
+	if b == nil {
+		w.Write("  no baseline loaded\n", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write("  e.g. madvisor record -o run1.ndjson during one load test, then madvisor --baseline run1.ndjson during the next", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		return
+	}
+	base, start := b.state()
+	elapsed := now.Sub(start).Round(time.Second)
+	w.Write(fmt.Sprintf(" live vs %s at +%s of %s over %s", filepath.Base(b.path), elapsed, b.length.Round(time.Second), rateWindowGet()),
+		text.WriteCellOpts(cell.FgColor(cell.ColorCyan), cell.Bold()))
+	if elapsed > b.length {
+		w.Write("   baseline ended, comparing with its last values\n", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	} else {
+		w.Write("   :baseline restart lines it up with a new run\n", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+	}
+	rows := buildBaseline(st, base, names, rateWindowGet())
+	if len(rows) == 0 {
+		w.Write("  no metric is reported by both the live targets and the baseline yet", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+	w.Write(fmt.Sprintf(" %-*s %-6s %*s %*s  %s\n", matrixLabelWidth, "metric", "", matrixCellWidth, "baseline", matrixCellWidth, "live", "delta"),
+		text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	for i, r := range rows {
+		if i == canaryMaxRows {
+			w.Write(fmt.Sprintf("  ↓ %d more\n", len(rows)-i), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+			break
+		}
+		w.Write(fmt.Sprintf(" %-*s %-6s", matrixLabelWidth, truncateText(metricAlias(r.name), matrixLabelWidth), r.kind), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		w.Write(fmt.Sprintf(" %*s %*s", matrixCellWidth, truncateText(r.format(r.baseline), matrixCellWidth), matrixCellWidth, truncateText(r.format(r.canary), matrixCellWidth)),
+			text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		w.Write("  "+r.formatDelta()+"\n", text.WriteCellOpts(cell.FgColor(canaryColor(r.level))))
+	}
+}
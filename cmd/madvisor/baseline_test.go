@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestBuildBaseline(t *testing.T) {
+	t0 := time.Now().Add(-10 * time.Second)
+	live, base := newStore(), newStore()
+	for _, s := range []struct {
+		st  *store
+		inc float64
+		mem float64
+	}{{base, 100, 200}, {live, 150, 210}} {
+		s.st.ingest("app:8080", "http_requests_total", nil, "", "counter", 0, t0)
+		s.st.ingest("app:8080", "http_requests_total", nil, "", "counter", s.inc, t0.Add(10*time.Second))
+		s.st.ingest("app:8080", "memory_bytes", nil, "", "gauge", s.mem, t0)
+	}
+	live.ingest("app:8080", "only_live", nil, "", "gauge", 1, t0)
+
+	rows := buildBaseline(live, base, []string{"http_requests_total", "memory_bytes", "only_live"}, time.Minute)
+	if len(rows) != 2 {
+		t.Fatalf("rows = %+v, want the two metrics both sides report", rows)
+	}
+	rate := rows[0]
+	if rate.name != "http_requests_total" || rate.kind != "rate" || math.Abs(rate.baseline-10) > 1e-9 || math.Abs(rate.canary-15) > 1e-9 || rate.level != canarySignificant {
+		t.Errorf("rate row = %+v, want 10/s baseline vs 15/s live first, significant", rate)
+	}
+	if got := rate.formatDelta(); got != "+50.0% ×1.50" {
+		t.Errorf("delta = %q", got)
+	}
+	if mem := rows[1]; mem.name != "memory_bytes" || mem.level != canaryOK {
+		t.Errorf("memory row = %+v, want a 5%% difference marked ok", mem)
+	}
+}
+
+func TestBaselineReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run1.ndjson")
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.WriteFile(path, []byte(
+		`{"t":"`+t0.Format(time.RFC3339)+`","name":"queue_depth","type":"gauge","value":3}`+"\n"+
+			`{"t":"`+t0.Add(time.Hour).Format(time.RFC3339)+`","name":"queue_depth","type":"gauge","value":9}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := loadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.length != time.Hour {
+		t.Errorf("length = %s, want 1h", b.length)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.attach(ctx, newStore())
+	first, _ := b.state()
+	waitFor(t, func() bool { return len(first.seriesForName("queue_depth")) == 1 })
+
+	b.restart(time.Now())
+	second, start := b.state()
+	if second == first || time.Since(start) > time.Minute {
+		t.Error("restart should replay into a fresh store from now")
+	}
+	waitFor(t, func() bool { return len(second.seriesForName("queue_depth")) == 1 })
+	if v := second.seriesForName("queue_depth")[0].last(); v != 3 {
+		t.Errorf("baseline at +0s = %g, want the first sample", v)
+	}
+
+	live := newStore()
+	live.ingest("app:8080", "queue_depth", nil, "", "gauge", 6, time.Now())
+	if rows := buildBaseline(live, second, []string{"queue_depth"}, time.Minute); len(rows) != 1 || rows[0].formatDelta() != "+100.0% ×2.00" {
+		t.Errorf("rows = %+v, want live at twice the baseline", rows)
+	}
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderBaseline(w, live, b, []string{"queue_depth"}, time.Now())
+	renderBaseline(w, live, nil, []string{"queue_depth"}, time.Now())
+
+	empty := filepath.Join(t.TempDir(), "empty.ndjson")
+	os.WriteFile(empty, nil, 0o644)
+	if _, err := loadBaseline(empty); err == nil {
+		t.Error("an empty recording should be rejected")
+	}
+}
+
+func TestToggleBaselineClosesOtherPanels(t *testing.T) {
+	u := &uiState{}
+	u.togglePanel(panelCanary)
+	if !u.togglePanel(panelBaseline) || u.panel() == panelCanary {
+		t.Error("baseline panel should replace the canary panel")
+	}
+	u.togglePanel(panelTargets)
+	if u.panel() == panelBaseline {
+		t.Error("targets panel should close the baseline panel")
+	}
+}
+
+func TestScriptBaseline(t *testing.T) {
+	if _, err := parseScriptLine(1, "baseline rewind"); err == nil {
+		t.Error("baseline should only accept restart")
+	}
+	cmd, err := parseScriptLine(1, "baseline restart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(b *baselineRun) { globalBaseline = b }(globalBaseline)
+	globalBaseline = nil
+	if errs := runScript([]scriptCmd{cmd}, &uiState{}, newStore()); len(errs) != 1 {
+		t.Errorf("restart without --baseline: errs = %v", errs)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var bulkCommands = map[string]bool{
+	"export":    true,
+	"clip":      true,
+	"transform": true,
+	"unit":      true,
+}
+
+// runBulk applies a per-chart command to every metric left by the current
+// filter. Exports go to one file per metric, in the given directory or
+// --export-dir; clip switches every chart on unless all already are; unit
+// overrides the unit of every metric.
+func runBulk(c scriptCmd, ui *uiState, st *store) error {
+	filtered, _, _, _, _ := ui.snapshot()
+	if len(filtered) == 0 {
+		return fmt.Errorf("no metrics match the filter")
+	}
+	arg := ""
+	if len(c.args) > 0 {
+		arg = c.args[0]
+	}
+
+	switch c.name {
+	case "export":
+		f := strings.Fields(arg)
+		dir := flagExportDir
+		if len(f) == 2 {
+			dir = f[1]
+		}
+		now := time.Now()
+		n := 0
+		for _, name := range filtered {
+			list := filterGroup(st.seriesForName(name), ui.group())
+			if len(list) == 0 {
+				continue
+			}
+			if err := exportChartFile(filepath.Join(dir, exportFileName(name, f[0], now)), f[0], name, list, st); err != nil {
+				return err
+			}
+			n++
+		}
+		ui.setMessage(fmt.Sprintf("exported %d charts to %s", n, dir))
+	case "clip":
+		on := false
+		for _, name := range filtered {
+			if !ui.clipEnabled(name) {
+				on = true
+				break
+			}
+		}
+		for _, name := range filtered {
+			if ui.clipEnabled(name) != on {
+				ui.toggleClip(name)
+			}
+		}
+		state := "off"
+		if on {
+			state = "on"
+		}
+		ui.setMessage(fmt.Sprintf("clipping %s for %d charts", state, len(filtered)))
+	case "transform":
+		t, _ := parseTransform(arg)
+		for _, name := range filtered {
+			ui.setTransform(name, t)
+		}
+		ui.setMessage(fmt.Sprintf("transform %s for %d charts", t, len(filtered)))
+	case "unit":
+		for _, name := range filtered {
+			overrideUnit(name, arg)
+		}
+		ui.setMessage(unitMessage(fmt.Sprintf("%d metrics", len(filtered)), arg))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func bulkFixture() (*uiState, *store) {
+	st := newStore()
+	for _, n := range []string{"http_errors_total", "http_requests_total", "mem_bytes"} {
+		st.update(n, nil, "", "gauge", 1)
+		st.update(n, nil, "", "gauge", 2)
+	}
+	ui := &uiState{}
+	ui.setKeys(st.names())
+	ui.setFilter("^http_")
+	return ui, st
+}
+
+func TestParseScriptAll(t *testing.T) {
+	if _, err := parseScript(strings.NewReader("all export csv /tmp\nall clip\nall transform negate\nall unit bytes\nunit clear\n")); err != nil {
+		t.Fatalf("parseScript: %v", err)
+	}
+	for _, src := range []string{"all rate 10s\n", "all export gif\n", "all\n", "all nope\n", "all unit furlongs\n"} {
+		if _, err := parseScript(strings.NewReader(src)); err == nil {
+			t.Errorf("parseScript(%q) should fail", src)
+		}
+	}
+}
+
+func TestRunBulkExport(t *testing.T) {
+	ui, st := bulkFixture()
+	dir := t.TempDir()
+	cmds, err := parseScript(strings.NewReader("all export csv " + dir + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, st); len(errs) != 0 {
+		t.Fatalf("runScript: %v", errs)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if len(files) != 2 {
+		t.Fatalf("exported %v, want one CSV per filtered metric", files)
+	}
+	for _, f := range files {
+		if !strings.Contains(filepath.Base(f), "http_") {
+			t.Errorf("exported %s, which the filter excludes", f)
+		}
+		if data, _ := os.ReadFile(f); !strings.HasPrefix(string(data), "series,timestamp,value,annotation\n") {
+			t.Errorf("%s = %q", f, data)
+		}
+	}
+	if msg := ui.currentMessage(); msg != "exported 2 charts to "+dir {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestRunBulkClipAndTransform(t *testing.T) {
+	ui, st := bulkFixture()
+	ui.toggleClip("http_errors_total")
+
+	if err := runBulk(scriptCmd{name: "clip"}, ui, st); err != nil {
+		t.Fatal(err)
+	}
+	if !ui.clipEnabled("http_errors_total") || !ui.clipEnabled("http_requests_total") || ui.clipEnabled("mem_bytes") {
+		t.Error("clip should turn on for every filtered chart only")
+	}
+	runBulk(scriptCmd{name: "clip"}, ui, st)
+	if ui.clipEnabled("http_errors_total") || ui.clipEnabled("http_requests_total") {
+		t.Error("clip on all-enabled charts should turn them off")
+	}
+
+	runBulk(scriptCmd{name: "transform", args: []string{"negate"}}, ui, st)
+	if ui.transformFor("http_requests_total") != transformNegate || ui.transformFor("mem_bytes") != transformNone {
+		t.Error("transform should apply to the filtered charts only")
+	}
+
+	ui.setFilter("nothing-matches")
+	if err := runBulk(scriptCmd{name: "clip"}, ui, st); err == nil {
+		t.Error("bulk with an empty filter result should fail")
+	}
+}
+
+func TestRunBulkUnit(t *testing.T) {
+	defer overrideUnit("http_errors_total", "clear")
+	defer overrideUnit("http_requests_total", "clear")
+	ui, st := bulkFixture()
+	cmds, err := parseScript(strings.NewReader("all unit bytes\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, st); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	for name, want := range map[string]string{"http_errors_total": "bytes", "http_requests_total": "bytes", "mem_bytes": "bytes"} {
+		if m := matchUnit(name); m == nil || m.Unit != want {
+			t.Errorf("unit of %s = %+v, want %s", name, m, want)
+		}
+	}
+	if m := matchUnit("http_errors_total"); !m.Override || m.source() != "(set with unit)" {
+		t.Errorf("http_errors_total = %+v, want the override", m)
+	}
+	if m := matchUnit("mem_bytes"); m.Override {
+		t.Error("the filter excludes mem_bytes, which should keep its pattern unit")
+	}
+	if msg := ui.currentMessage(); msg != "2 metrics shown in bytes" {
+		t.Errorf("message = %q", msg)
+	}
+
+	ui.setFilter("")
+	ui.selectName("http_errors_total")
+	runCommandLine("unit clear", ui, st)
+	if m := matchUnit("http_errors_total"); m != nil && m.Override {
+		t.Errorf("unit clear left %+v", m)
+	}
+}
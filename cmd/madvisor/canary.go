@@ -0,0 +1,388 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	canaryBaseline = "baseline"
+	canaryCanary   = "canary"
+
+	canaryQuantile = 0.99
+	canaryMaxRows  = 20
+)
+
+// errorLabels are the labels whose value classifies a request counter as
+// an error (5xx status or an explicit failure outcome).
+var errorLabels = []string{"code", "status", "status_code", "outcome", "result"}
+
+const (
+	canaryNoData = iota - 1
+	canaryOK
+	canaryWatch
+	canarySignificant
+)
+
+type canaryRow struct {
+	name     string
+	kind     string
+	baseline float64
+	canary   float64
+	delta    float64
+	level    int
+}
+
+type canaryReport struct {
+	baselineReplicas int
+	canaryReplicas   int
+	rows             []canaryRow
+}
+
+func replicaOf(s *metricSeries) string {
+	if s.source != "" {
+		return s.source
+	}
+	return s.labels[instanceLabel]
+}
+
+func isErrorSeries(labels map[string]string) bool {
+	for _, l := range errorLabels {
+		v, ok := labels[l]
+		if !ok {
+			continue
+		}
+		v = strings.ToLower(v)
+		return strings.HasPrefix(v, "5") || v == "error" || v == "failure" || v == "failed" || v == "fail"
+	}
+	return false
+}
+
+func hasErrorLabel(list []*metricSeries) bool {
+	for _, s := range list {
+		for _, l := range errorLabels {
+			if _, ok := s.labels[l]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// perReplica sums value over each replica's series, giving one sample per
+// replica so groups of different sizes compare fairly.
+func perReplica(list []*metricSeries, value func(*metricSeries) float64) []float64 {
+	sums := map[string]float64{}
+	var order []string
+	for _, s := range list {
+		v := value(s)
+		if math.IsNaN(v) {
+			continue
+		}
+		r := replicaOf(s)
+		if _, ok := sums[r]; !ok {
+			order = append(order, r)
+		}
+		sums[r] += v
+	}
+	out := make([]float64, len(order))
+	for i, r := range order {
+		out[i] = sums[r]
+	}
+	return out
+}
+
+func meanVar(xs []float64) (float64, float64) {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	var ss float64
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return mean, ss / float64(len(xs)-1)
+}
+
+func relDelta(base, canary float64) float64 {
+	switch {
+	case base == canary:
+		return 0
+	case base == 0:
+		return math.Inf(int(math.Copysign(1, canary)))
+	}
+	return (canary - base) / math.Abs(base)
+}
+
+// compareReplicas compares the per-replica means of the two groups. A
+// difference under 10% is fine; a larger one is significant when Welch's t
+// clears 2 (or, with a single replica on either side, when it exceeds 50%),
+// and otherwise worth watching as it may be replica noise.
+func compareReplicas(base, canary []float64) canaryRow {
+	if len(base) == 0 || len(canary) == 0 {
+		return canaryRow{baseline: math.NaN(), canary: math.NaN(), level: canaryNoData}
+	}
+	mb, vb := meanVar(base)
+	mc, vc := meanVar(canary)
+	row := canaryRow{baseline: mb, canary: mc, delta: relDelta(mb, mc)}
+	switch d := math.Abs(row.delta); {
+	case d < 0.1:
+		row.level = canaryOK
+	case len(base) < 2 || len(canary) < 2:
+		row.level = canaryWatch
+		if d >= 0.5 {
+			row.level = canarySignificant
+		}
+	default:
+		row.level = canarySignificant
+		if se := math.Sqrt(vb/float64(len(base)) + vc/float64(len(canary))); se > 0 && math.Abs(mc-mb)/se < 2 {
+			row.level = canaryWatch
+		}
+	}
+	return row
+}
+
+func compareQuantiles(base, canary float64, okB, okC bool) canaryRow {
+	if !okB || !okC {
+		return canaryRow{baseline: math.NaN(), canary: math.NaN(), level: canaryNoData}
+	}
+	row := canaryRow{baseline: base, canary: canary, delta: relDelta(base, canary)}
+	switch d := row.delta; {
+	case d >= 0.25:
+		row.level = canarySignificant
+	case d >= 0.1:
+		row.level = canaryWatch
+	}
+	return row
+}
+
+func errorRatio(list []*metricSeries, window time.Duration) (float64, bool) {
+	var errs, total float64
+	for _, s := range list {
+		r := s.rate(window)
+		total += r
+		if isErrorSeries(s.labels) {
+			errs += r
+		}
+	}
+	if total <= 0 {
+		return 0, false
+	}
+	return errs / total, true
+}
+
+// compareErrors compares error ratios in absolute terms: delta is the
+// difference in percentage points.
+func compareErrors(base, canary []*metricSeries, window time.Duration) canaryRow {
+	rb, okB := errorRatio(base, window)
+	rc, okC := errorRatio(canary, window)
+	if !okB || !okC {
+		return canaryRow{baseline: math.NaN(), canary: math.NaN(), level: canaryNoData}
+	}
+	row := canaryRow{baseline: rb, canary: rc, delta: (rc - rb) * 100}
+	switch {
+	case row.delta >= 1:
+		row.level = canarySignificant
+	case row.delta >= 0.1:
+		row.level = canaryWatch
+	}
+	return row
+}
+
+// buildCanary compares every metric in names reported by both the baseline
+// and the canary group: request rates and gauge levels per replica, p99 of
+// histograms and summaries, and the error ratio of counters carrying a
+// status label. Rows are ordered most significant first.
+func buildCanary(all []*metricSeries, names []string, window time.Duration) canaryReport {
+	var rep canaryReport
+	byName := map[string][2][]*metricSeries{}
+	replicas := [2]map[string]bool{{}, {}}
+	for _, s := range all {
+		i := 0
+		switch s.labels[groupLabel] {
+		case canaryBaseline:
+		case canaryCanary:
+			i = 1
+		default:
+			continue
+		}
+		replicas[i][replicaOf(s)] = true
+		pair := byName[s.name]
+		pair[i] = append(pair[i], s)
+		byName[s.name] = pair
+	}
+	rep.baselineReplicas, rep.canaryReplicas = len(replicas[0]), len(replicas[1])
+
+	for _, name := range names {
+		pair := byName[name]
+		rep.rows = append(rep.rows, compareMetric(name, pair[0], pair[1], window)...)
+	}
+	sortCanaryRows(rep.rows)
+	return rep
+}
+
+// compareMetric compares one metric between two sets of series, as
+// buildCanary describes. It gives no rows unless both sides report it.
+func compareMetric(name string, base, canary []*metricSeries, window time.Duration) []canaryRow {
+	if len(base) == 0 || len(canary) == 0 {
+		return nil
+	}
+	var rows []canaryRow
+	mtype := base[0].mtype
+	switch detectMetricType(name, mtype) {
+	case "histogram":
+		switch {
+		case strings.HasSuffix(name, "_bucket"):
+			qb, okB := bucketQuantile(base, canaryQuantile, window)
+			qc, okC := bucketQuantile(canary, canaryQuantile, window)
+			row := compareQuantiles(qb, qc, okB, okC)
+			row.name, row.kind = strings.TrimSuffix(name, "_bucket"), "p99"
+			rows = append(rows, row)
+		case strings.HasSuffix(name, "_count"):
+			row := compareReplicas(perReplica(base, rateValue(window)), perReplica(canary, rateValue(window)))
+			row.name, row.kind = name, "rate"
+			rows = append(rows, row)
+		}
+	case "summary":
+		if strings.HasSuffix(name, "_sum") || strings.HasSuffix(name, "_count") {
+			return nil
+		}
+		qb, okB := summaryQuantile(base, canaryQuantile)
+		qc, okC := summaryQuantile(canary, canaryQuantile)
+		row := compareQuantiles(qb, qc, okB, okC)
+		row.name, row.kind = name, "p99"
+		rows = append(rows, row)
+	case "counter":
+		row := compareReplicas(perReplica(base, rateValue(window)), perReplica(canary, rateValue(window)))
+		row.name, row.kind = name, "rate"
+		rows = append(rows, row)
+		if hasErrorLabel(base) || hasErrorLabel(canary) {
+			row := compareErrors(base, canary, window)
+			row.name, row.kind = name, "errors"
+			rows = append(rows, row)
+		}
+	default:
+		row := compareReplicas(perReplica(base, watchValue), perReplica(canary, watchValue))
+		row.name, row.kind = name, "value"
+		if shouldRateType(name, mtype) {
+			row.kind = "rate"
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// sortCanaryRows puts the most significant differences first.
+func sortCanaryRows(rows []canaryRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.level != b.level {
+			return a.level > b.level
+		}
+		return math.Abs(a.delta) > math.Abs(b.delta)
+	})
+}
+
+func rateValue(window time.Duration) func(*metricSeries) float64 {
+	return func(s *metricSeries) float64 {
+		if s.count() == 0 {
+			return math.NaN()
+		}
+		return s.rate(window)
+	}
+}
+
+// summaryQuantile averages the latest value of the series reporting the
+// q quantile across a group.
+func summaryQuantile(list []*metricSeries, q float64) (float64, bool) {
+	var sum float64
+	n := 0
+	for _, s := range list {
+		if s.count() == 0 || s.labels["quantile"] != fmt.Sprint(q) {
+			continue
+		}
+		if v := s.last(); !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+func canaryColor(level int) cell.Color {
+	switch level {
+	case canaryNoData:
+		return cell.ColorNumber(245)
+	case canaryWatch:
+		return cell.ColorYellow
+	case canarySignificant:
+		return cell.ColorRed
+	}
+	return cell.ColorGreen
+}
+
+func (r canaryRow) format(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "—"
+	case r.kind == "errors":
+		return fmt.Sprintf("%.2f%%", v*100)
+	case r.kind == "rate":
+		return formatGeneric(v) + "/s"
+	}
+	return formatValue(r.name, v)
+}
+
+func (r canaryRow) formatDelta() string {
+	switch {
+	case r.level == canaryNoData:
+		return "no traffic"
+	case r.kind == "errors":
+		return fmt.Sprintf("%+.2fpp", r.delta)
+	case math.IsInf(r.delta, 0):
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%% ×%.2f", r.delta*100, 1+r.delta)
+}
+
+func renderCanary(w *text.Text, st *store, names []string) {
+	w.Reset()
+	rep := buildCanary(st.snapshot(), names, rateWindowGet())
+	if rep.baselineReplicas == 0 || rep.canaryReplicas == 0 {
+		w.Write(fmt.Sprintf("  comparison needs targets in the %q and %q groups\n", canaryBaseline, canaryCanary), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write("  e.g. --targets 'baseline=app-1:8080,app-2:8080;canary=app-3:8080'", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		return
+	}
+	w.Write(fmt.Sprintf(" %s (%d) vs %s (%d) over %s", canaryBaseline, rep.baselineReplicas, canaryCanary, rep.canaryReplicas, rateWindowGet()),
+		text.WriteCellOpts(cell.FgColor(cell.ColorCyan), cell.Bold()))
+	w.Write("   rates and levels per replica, p99 and errors per group\n", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+	if len(rep.rows) == 0 {
+		w.Write("  no metric is reported by both groups", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+	w.Write(fmt.Sprintf(" %-*s %-6s %*s %*s  %s\n", matrixLabelWidth, "metric", "", matrixCellWidth, canaryBaseline, matrixCellWidth, canaryCanary, "delta"),
+		text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	for i, r := range rep.rows {
+		if i == canaryMaxRows {
+			w.Write(fmt.Sprintf("  ↓ %d more\n", len(rep.rows)-i), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+			break
+		}
+		w.Write(fmt.Sprintf(" %-*s %-6s", matrixLabelWidth, truncateText(metricAlias(r.name), matrixLabelWidth), r.kind), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		w.Write(fmt.Sprintf(" %*s %*s", matrixCellWidth, truncateText(r.format(r.baseline), matrixCellWidth), matrixCellWidth, truncateText(r.format(r.canary), matrixCellWidth)),
+			text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		w.Write("  "+r.formatDelta()+"\n", text.WriteCellOpts(cell.FgColor(canaryColor(r.level))))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+// canaryStore records two scrapes 10s apart of a request counter per
+// replica, increasing by the given amounts for 200 and 500 responses.
+func canaryStore(t *testing.T, replicas map[string][2]float64) *store {
+	t.Helper()
+	st := newStore()
+	t0 := time.Now().Add(-10 * time.Second)
+	for addr, inc := range replicas {
+		group := canaryBaseline
+		if addr[0] == 'c' {
+			group = canaryCanary
+		}
+		for i, code := range []string{"200", "500"} {
+			labels := func() map[string]string { return map[string]string{"code": code, groupLabel: group} }
+			st.ingest(addr, "http_requests_total", labels(), "", "counter", 1000, t0)
+			st.ingest(addr, "http_requests_total", labels(), "", "counter", 1000+inc[i], t0.Add(10*time.Second))
+		}
+		st.ingest(addr, "queue_depth", map[string]string{groupLabel: group}, "", "gauge", 5, t0)
+	}
+	return st
+}
+
+func canaryRowFor(rep canaryReport, name, kind string) (canaryRow, bool) {
+	for _, r := range rep.rows {
+		if r.name == name && r.kind == kind {
+			return r, true
+		}
+	}
+	return canaryRow{}, false
+}
+
+func TestBuildCanary(t *testing.T) {
+	st := canaryStore(t, map[string][2]float64{
+		"b1:1": {100, 0},
+		"b2:1": {100, 0},
+		"c1:1": {95, 10},
+	})
+	rep := buildCanary(st.snapshot(), st.names(), time.Minute)
+	if rep.baselineReplicas != 2 || rep.canaryReplicas != 1 {
+		t.Errorf("replicas = %d/%d, want 2/1", rep.baselineReplicas, rep.canaryReplicas)
+	}
+
+	rate, ok := canaryRowFor(rep, "http_requests_total", "rate")
+	if !ok || math.Abs(rate.baseline-10) > 1e-9 || math.Abs(rate.canary-10.5) > 1e-9 || rate.level != canaryOK {
+		t.Errorf("rate row = %+v, want 10/s vs 10.5/s per replica, ok", rate)
+	}
+	errs, ok := canaryRowFor(rep, "http_requests_total", "errors")
+	if !ok || errs.baseline != 0 || math.Abs(errs.canary-10.0/105) > 1e-9 || errs.level != canarySignificant {
+		t.Errorf("errors row = %+v, want 0%% vs 9.52%%, significant", errs)
+	}
+	if rep.rows[0] != errs {
+		t.Errorf("first row = %+v, want the error ratio", rep.rows[0])
+	}
+	if gauge, ok := canaryRowFor(rep, "queue_depth", "value"); !ok || gauge.delta != 0 {
+		t.Errorf("gauge row = %+v, want no delta", gauge)
+	}
+}
+
+func TestCompareReplicas(t *testing.T) {
+	tests := []struct {
+		name         string
+		base, canary []float64
+		want         int
+	}{
+		{"close", []float64{10, 10}, []float64{10.5}, canaryOK},
+		{"single replica", []float64{10}, []float64{13}, canaryWatch},
+		{"single replica far off", []float64{10}, []float64{20}, canarySignificant},
+		{"within replica noise", []float64{5, 15, 10}, []float64{14, 8, 14}, canaryWatch},
+		{"consistent shift", []float64{10, 10.1, 9.9}, []float64{13, 13.1, 12.9}, canarySignificant},
+		{"missing", nil, []float64{1}, canaryNoData},
+	}
+	for _, tt := range tests {
+		if got := compareReplicas(tt.base, tt.canary).level; got != tt.want {
+			t.Errorf("%s: level = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCanaryNeedsBothGroups(t *testing.T) {
+	st := newStore()
+	st.ingest("a:1", "up", map[string]string{groupLabel: canaryBaseline}, "", "gauge", 1, time.Now())
+	if rep := buildCanary(st.snapshot(), st.names(), time.Minute); rep.canaryReplicas != 0 || len(rep.rows) != 0 {
+		t.Errorf("report = %+v, want no canary and no rows", rep)
+	}
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderCanary(w, st, st.names())
+}
+
+func TestToggleCanaryClosesOtherPanels(t *testing.T) {
+	u := &uiState{}
+	u.togglePanel(panelAlerts)
+	if !u.togglePanel(panelCanary) || u.panel() == panelAlerts {
+		t.Error("canary panel should replace the alerts panel")
+	}
+	u.togglePanel(panelInfo)
+	if u.panel() == panelCanary {
+		t.Error("info panel should close the canary panel")
+	}
+}
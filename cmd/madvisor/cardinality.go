@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	cardinalityTopValues = 5
+	cardinalityBarWidth  = 20
+)
+
+type valueCount struct {
+	value  string
+	series int
+}
+
+// topLabelValues counts the series carrying each value of key and returns the
+// n most common values plus the number of distinct values left out.
+func topLabelValues(list []*metricSeries, key string, n int) ([]valueCount, int) {
+	counts := map[string]int{}
+	for _, s := range list {
+		if v, ok := s.labels[key]; ok {
+			counts[v]++
+		}
+	}
+	out := make([]valueCount, 0, len(counts))
+	for v, c := range counts {
+		out = append(out, valueCount{value: v, series: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].series != out[j].series {
+			return out[i].series > out[j].series
+		}
+		return out[i].value < out[j].value
+	})
+	if len(out) > n {
+		return out[:n], len(out) - n
+	}
+	return out, 0
+}
+
+func renderCardinality(w *text.Text, name string, seriesList []*metricSeries) {
+	w.Reset()
+
+	if name == "" || len(seriesList) == 0 {
+		w.Write("  select a metric name", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+
+	cards := labelCardinality(seriesList)
+	w.Write(fmt.Sprintf(" %s — %d series, %d label key(s)\n", name, len(seriesList), len(cards)),
+		text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+	if len(cards) == 0 {
+		w.Write("  no labels", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+
+	for i, c := range cards {
+		color := cell.ColorWhite
+		note := ""
+		if i == 0 && c.values > 1 {
+			color = cell.ColorRed
+			note = "  ← drives cardinality"
+		}
+		w.Write(fmt.Sprintf("\n %-20s", truncateText(c.key, 20)), text.WriteCellOpts(cell.FgColor(color)))
+		w.Write(fmt.Sprintf(" %d distinct%s\n", c.values, note), text.WriteCellOpts(cell.FgColor(color)))
+
+		top, rest := topLabelValues(seriesList, c.key, cardinalityTopValues)
+		for _, vc := range top {
+			share := float64(vc.series) / float64(len(seriesList))
+			bar := strings.Repeat("█", max(1, int(share*cardinalityBarWidth)))
+			w.Write(fmt.Sprintf("   %-28s", truncateText(vc.value, 28)), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+			w.Write(fmt.Sprintf(" %5.1f%% ", share*100), text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+			w.Write(bar+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+		}
+		if rest > 0 {
+			w.Write(fmt.Sprintf("   … %d more value(s)\n", rest), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestTopLabelValues(t *testing.T) {
+	var list []*metricSeries
+	for i, path := range []string{"/a", "/a", "/a", "/b", "/b", "/c", "/d"} {
+		list = append(list, newTestSeries("m", map[string]string{"path": path, "id": string(rune('a' + i))}))
+	}
+	list = append(list, newTestSeries("m", map[string]string{"id": "z"}))
+
+	top, rest := topLabelValues(list, "path", 2)
+	want := []valueCount{{"/a", 3}, {"/b", 2}}
+	if len(top) != len(want) || top[0] != want[0] || top[1] != want[1] {
+		t.Errorf("top = %+v, want %+v", top, want)
+	}
+	if rest != 2 {
+		t.Errorf("rest = %d, want 2", rest)
+	}
+
+	top, rest = topLabelValues(list, "path", 10)
+	if len(top) != 4 || rest != 0 {
+		t.Errorf("untruncated: %d values, rest %d", len(top), rest)
+	}
+	if top, _ := topLabelValues(list, "missing", 5); len(top) != 0 {
+		t.Errorf("missing key = %+v", top)
+	}
+}
+
+func TestCardinalityToggleIsExclusive(t *testing.T) {
+	ui := &uiState{}
+	ui.togglePanel(panelTargets)
+	if !ui.togglePanel(panelCards) || ui.panel() == panelTargets {
+		t.Fatal("cardinality panel should replace the targets panel")
+	}
+	ui.togglePanel(panelInfo)
+	if ui.panel() == panelCards {
+		t.Error("info panel should close the cardinality panel")
+	}
+}
+
+func TestRenderCardinality(t *testing.T) {
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderCardinality(w, "", nil)
+	renderCardinality(w, "m", []*metricSeries{newTestSeries("m", nil)})
+	renderCardinality(w, "m", []*metricSeries{
+		newTestSeries("m", map[string]string{"pod": "a"}),
+		newTestSeries("m", map[string]string{"pod": "b"}),
+	})
+}
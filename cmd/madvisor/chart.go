@@ -0,0 +1,292 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	clipLowPercentile  = 1
+	clipHighPercentile = 99
+	gapFactor          = 1.5
+	maxResampleSlots   = 1 << 13
+
+	defaultMaxChartSeries = 20
+)
+
+// --- chart data ---
+
+func chartData(cs *metricSeries) ([]float64, []time.Time) {
+	if cs.shouldRate() {
+		times := cs.timeSlice()
+		if len(times) < 2 {
+			return nil, nil
+		}
+		return cs.rateSlice(rateWindowGet()), times[1:]
+	}
+	if isTimestampMetric(cs.name) {
+		nowSec := float64(time.Now().Unix())
+		raw := cs.slice()
+		data := make([]float64, len(raw))
+		for j, v := range raw {
+			if v > 0 {
+				data[j] = nowSec - v
+			}
+		}
+		return data, cs.timeSlice()
+	}
+	return cs.slice(), cs.timeSlice()
+}
+
+// --- time-grid resampling ---
+
+func resample(values []float64, times []time.Time, step time.Duration) ([]float64, int) {
+	return resampleTiered(values, times, step, nil)
+}
+
+func resampleTiered(values []float64, times []time.Time, step time.Duration, resolution func(time.Time) time.Duration) ([]float64, int) {
+	if len(values) < 2 || len(values) != len(times) || step <= 0 {
+		return values, 0
+	}
+	span := times[len(times)-1].Sub(times[0])
+	if span <= 0 {
+		return values, 0
+	}
+	if slots := int(span/step) + 1; slots > maxResampleSlots {
+		step = span / time.Duration(maxResampleSlots-1)
+	}
+	maxGap := time.Duration(float64(step) * gapFactor)
+
+	n := int(span/step) + 1
+	out := make([]float64, n)
+	gaps := 0
+	j := 0
+	for k := 0; k < n; k++ {
+		t := times[0].Add(time.Duration(k) * step)
+		for j < len(times)-2 && !times[j+1].After(t) {
+			j++
+		}
+		t0, t1 := times[j], times[j+1]
+		limit := maxGap
+		if resolution != nil {
+			limit = max(limit, time.Duration(float64(max(resolution(t0), resolution(t1)))*gapFactor))
+		}
+		switch {
+		case t1.Sub(t0) > limit && t.After(t0) && t.Before(t1):
+			out[k] = math.NaN()
+			gaps++
+		case !t1.After(t0):
+			out[k] = values[j+1]
+		default:
+			frac := float64(t.Sub(t0)) / float64(t1.Sub(t0))
+			if frac > 1 {
+				frac = 1
+			}
+			out[k] = values[j] + (values[j+1]-values[j])*frac
+		}
+	}
+	return out, gaps
+}
+
+// envelope fits data into width points for a chart that many pixels wide.
+// Each pair of points covers an equal run of data with its minimum and
+// maximum, in the order they occurred, so a spike narrower than a pixel is
+// still drawn; naive decimation would skip it. Runs with no values stay NaN
+// so gaps survive. Data that already fits is returned as is.
+func envelope(data []float64, width int) []float64 {
+	buckets := width / 2
+	if buckets < 1 || len(data) <= width {
+		return data
+	}
+	out := make([]float64, 0, buckets*2)
+	for b := 0; b < buckets; b++ {
+		lo, hi := b*len(data)/buckets, (b+1)*len(data)/buckets
+		minIdx, maxIdx := -1, -1
+		for i := lo; i < hi; i++ {
+			v := data[i]
+			if math.IsNaN(v) {
+				continue
+			}
+			if minIdx < 0 || v < data[minIdx] {
+				minIdx = i
+			}
+			if maxIdx < 0 || v > data[maxIdx] {
+				maxIdx = i
+			}
+		}
+		switch {
+		case minIdx < 0:
+			out = append(out, math.NaN(), math.NaN())
+		case minIdx <= maxIdx:
+			out = append(out, data[minIdx], data[maxIdx])
+		default:
+			out = append(out, data[maxIdx], data[minIdx])
+		}
+	}
+	return out
+}
+
+// chartPlotWidth is the braille pixel width of the chart on a terminal
+// termWidth columns wide, for envelope. A forecast is fitted to and drawn on
+// evenly spaced samples, so it keeps every point (0).
+func chartPlotWidth(termWidth int, fm forecastModel) int {
+	if fm != forecastOff {
+		return 0
+	}
+	return max((termWidth*70/100-12)*2, 2)
+}
+
+// padTrailingGap appends a NaN sample at failedAt, the series' target's last
+// failed scrape, when that came well after the last sample, so an ongoing
+// outage shows as a gap instead of the chart quietly ending at the last
+// successful scrape.
+func padTrailingGap(values []float64, times []time.Time, failedAt time.Time, step time.Duration) ([]float64, []time.Time) {
+	if len(values) == 0 || len(values) != len(times) || failedAt.Sub(times[len(times)-1]) <= time.Duration(float64(step)*gapFactor) {
+		return values, times
+	}
+	values = append(values[:len(values):len(values)], math.NaN())
+	times = append(times[:len(times):len(times)], failedAt)
+	return values, times
+}
+
+// gapLabels marks the start of every run of missing points on the x axis,
+// leaving slots that already carry an annotation untouched.
+func gapLabels(data []float64, labels map[int]string) map[int]string {
+	for i, v := range data {
+		if !math.IsNaN(v) || (i > 0 && math.IsNaN(data[i-1])) {
+			continue
+		}
+		if labels == nil {
+			labels = map[int]string{}
+		}
+		if _, ok := labels[i]; !ok {
+			labels[i] = "✕gap"
+		}
+	}
+	return labels
+}
+
+// --- outlier clipping ---
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*frac
+}
+
+func clipBounds(datasets [][]float64, loPct, hiPct float64) (float64, float64, bool) {
+	var all []float64
+	for _, d := range datasets {
+		for _, v := range d {
+			if !math.IsNaN(v) {
+				all = append(all, v)
+			}
+		}
+	}
+	if len(all) < 2 {
+		return 0, 0, false
+	}
+	sort.Float64s(all)
+	lo := percentile(all, loPct)
+	hi := percentile(all, hiPct)
+	if lo >= hi {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+func clipData(data []float64, lo, hi float64) (clipped []float64, marks []float64, n int) {
+	clipped = make([]float64, len(data))
+	marks = make([]float64, len(data))
+	for i := range marks {
+		marks[i] = math.NaN()
+	}
+	var hits []int
+	for i, v := range data {
+		switch {
+		case math.IsNaN(v):
+			clipped[i] = v
+		case v < lo:
+			clipped[i] = lo
+			hits = append(hits, i)
+		case v > hi:
+			clipped[i] = hi
+			hits = append(hits, i)
+		default:
+			clipped[i] = v
+		}
+	}
+	for _, i := range hits {
+		marks[i] = clipped[i]
+		if i > 0 {
+			marks[i-1] = clipped[i-1]
+		} else if len(data) > 1 {
+			marks[1] = clipped[1]
+		}
+	}
+	return clipped, marks, len(hits)
+}
+
+// --- series limiting ---
+
+var maxChartSeries = defaultMaxChartSeries
+
+func topSeries(list []*metricSeries, k int, value func(*metricSeries) float64) ([]*metricSeries, []*metricSeries) {
+	if k <= 0 || len(list) <= k {
+		return list, nil
+	}
+	ranked := append([]*metricSeries{}, list...)
+	score := func(s *metricSeries) float64 {
+		v := math.Abs(value(s))
+		if math.IsNaN(v) {
+			return -1
+		}
+		return v
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return score(ranked[i]) > score(ranked[j])
+	})
+	top := ranked[:k]
+	sort.SliceStable(top, func(i, j int) bool { return top[i].key < top[j].key })
+	return top, ranked[k:]
+}
+
+func sumAligned(datasets [][]float64) []float64 {
+	n := 0
+	for _, d := range datasets {
+		n = max(n, len(d))
+	}
+	if n == 0 {
+		return nil
+	}
+	out := make([]float64, n)
+	seen := make([]bool, n)
+	for _, d := range datasets {
+		off := n - len(d)
+		for i, v := range d {
+			if math.IsNaN(v) {
+				continue
+			}
+			out[off+i] += v
+			seen[off+i] = true
+		}
+	}
+	for i := range out {
+		if !seen[i] {
+			out[i] = math.NaN()
+		}
+	}
+	return out
+}
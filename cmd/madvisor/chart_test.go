@@ -0,0 +1,323 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// --- chartData tests ---
+
+func TestChartDataGauge(t *testing.T) {
+	s := newTestSeries("queue_depth", nil)
+	s.push(1)
+	s.push(2)
+
+	got, times := chartData(s)
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("chartData(gauge) = %v, want [1 2]", got)
+	}
+	if len(times) != len(got) {
+		t.Errorf("chartData(gauge) times len = %d, want %d", len(times), len(got))
+	}
+}
+
+func TestChartDataCounterRates(t *testing.T) {
+	s := newTestSeries("req_total", nil)
+	s.mtype = "counter"
+	base := time.Now()
+	s.pushAt(0, base)
+	s.pushAt(10, base.Add(time.Second))
+
+	got, times := chartData(s)
+	if len(got) != 1 {
+		t.Fatalf("chartData(counter) len = %d, want 1", len(got))
+	}
+	if len(times) != 1 || !times[0].Equal(base.Add(time.Second)) {
+		t.Errorf("chartData(counter) times = %v, want rate aligned to the newer sample", times)
+	}
+}
+
+// --- resample tests ---
+
+func TestResampleRegular(t *testing.T) {
+	base := time.Now()
+	values := []float64{1, 2, 3, 4}
+	times := []time.Time{base, base.Add(time.Second), base.Add(2 * time.Second), base.Add(3 * time.Second)}
+
+	got, gaps := resample(values, times, time.Second)
+	if gaps != 0 {
+		t.Errorf("gaps = %d, want 0", gaps)
+	}
+	if len(got) != 4 {
+		t.Fatalf("len = %d, want 4", len(got))
+	}
+	for i, want := range values {
+		if math.Abs(got[i]-want) > 1e-9 {
+			t.Errorf("got[%d] = %v, want %v", i, got[i], want)
+		}
+	}
+}
+
+func TestResampleJitter(t *testing.T) {
+	base := time.Now()
+	values := []float64{0, 10, 20}
+	times := []time.Time{base, base.Add(1400 * time.Millisecond), base.Add(2 * time.Second)}
+
+	got, gaps := resample(values, times, time.Second)
+	if gaps != 0 {
+		t.Errorf("gaps = %d, want 0 for jitter below the gap threshold", gaps)
+	}
+	if len(got) != 3 {
+		t.Fatalf("len = %d, want 3", len(got))
+	}
+	if math.Abs(got[1]-10/1.4) > 1e-9 {
+		t.Errorf("got[1] = %v, want interpolated %v", got[1], 10/1.4)
+	}
+}
+
+func TestResampleGap(t *testing.T) {
+	base := time.Now()
+	values := []float64{1, 2, 3}
+	times := []time.Time{base, base.Add(time.Second), base.Add(5 * time.Second)}
+
+	got, gaps := resample(values, times, time.Second)
+	if len(got) != 6 {
+		t.Fatalf("len = %d, want 6 slots over 5s", len(got))
+	}
+	if gaps != 3 {
+		t.Errorf("gaps = %d, want 3 missed scrapes", gaps)
+	}
+	for i := 2; i <= 4; i++ {
+		if !math.IsNaN(got[i]) {
+			t.Errorf("got[%d] = %v, want NaN gap marker", i, got[i])
+		}
+	}
+	if got[5] != 3 {
+		t.Errorf("got[5] = %v, want 3", got[5])
+	}
+}
+
+func TestPadTrailingGap(t *testing.T) {
+	base := time.Now()
+	values := []float64{1, 2}
+	times := []time.Time{base, base.Add(time.Second)}
+
+	if v, _ := padTrailingGap(values, times, base.Add(2*time.Second), time.Second); len(v) != 2 {
+		t.Errorf("len = %d, want no padding one scrape after the last sample", len(v))
+	}
+	v, ts := padTrailingGap(values, times, base.Add(5*time.Second), time.Second)
+	if len(v) != 3 || !math.IsNaN(v[2]) || !ts[2].Equal(base.Add(5*time.Second)) {
+		t.Fatalf("padded = %v at %v, want a NaN sample at the failed scrape", v, ts)
+	}
+	if len(values) != 2 {
+		t.Error("padding must not modify the input")
+	}
+
+	got, gaps := resample(v, ts, time.Second)
+	if len(got) != 6 || gaps != 3 {
+		t.Fatalf("resampled = %v (gaps %d), want 6 slots with 3 gaps", got, gaps)
+	}
+	for i := 2; i < 6; i++ {
+		if !math.IsNaN(got[i]) {
+			t.Errorf("got[%d] = %v, want NaN for the ongoing outage", i, got[i])
+		}
+	}
+}
+
+func TestGapLabels(t *testing.T) {
+	nan := math.NaN()
+	data := []float64{1, nan, nan, 2, nan, 3}
+	got := gapLabels(data, map[int]string{4: "▼paused"})
+	want := map[int]string{1: "✕gap", 4: "▼paused"}
+	if len(got) != len(want) {
+		t.Fatalf("labels = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("labels[%d] = %q, want %q", k, got[k], v)
+		}
+	}
+	if gapLabels([]float64{1, 2}, nil) != nil {
+		t.Error("no gaps should leave labels nil")
+	}
+}
+
+func TestResampleCapsSlots(t *testing.T) {
+	base := time.Now()
+	values := []float64{1, 2}
+	times := []time.Time{base, base.Add(time.Hour)}
+
+	got, _ := resample(values, times, time.Second)
+	if len(got) > maxResampleSlots {
+		t.Errorf("len = %d, want <= %d", len(got), maxResampleSlots)
+	}
+}
+
+func TestResampleTooFew(t *testing.T) {
+	got, gaps := resample([]float64{1}, []time.Time{time.Now()}, time.Second)
+	if len(got) != 1 || gaps != 0 {
+		t.Errorf("resample single = %v, %d; want passthrough", got, gaps)
+	}
+}
+
+// --- clipping tests ---
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{100, 5},
+		{25, 2},
+		{90, 4.6},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); !math.IsNaN(got) {
+		t.Errorf("percentile(nil) = %v, want NaN", got)
+	}
+}
+
+func TestClipBounds(t *testing.T) {
+	data := make([]float64, 100)
+	for i := range data {
+		data[i] = float64(i)
+	}
+	data[50] = 1e9
+
+	lo, hi, ok := clipBounds([][]float64{data}, clipLowPercentile, clipHighPercentile)
+	if !ok {
+		t.Fatal("clipBounds ok = false, want true")
+	}
+	if lo <= 0 || lo > 2 {
+		t.Errorf("lo = %v, want ~1", lo)
+	}
+	if hi >= 1e9 || hi < 98 {
+		t.Errorf("hi = %v, want below the spike", hi)
+	}
+}
+
+func TestClipBoundsFlat(t *testing.T) {
+	if _, _, ok := clipBounds([][]float64{{5, 5, 5}}, 1, 99); ok {
+		t.Error("clipBounds on flat data should report !ok")
+	}
+	if _, _, ok := clipBounds([][]float64{{5}}, 1, 99); ok {
+		t.Error("clipBounds on a single point should report !ok")
+	}
+}
+
+func TestClipData(t *testing.T) {
+	data := []float64{1, 2, 100, 3, math.NaN(), -50}
+	clipped, marks, n := clipData(data, 0, 10)
+
+	if n != 2 {
+		t.Errorf("clipped count = %d, want 2", n)
+	}
+	want := []float64{1, 2, 10, 3, math.NaN(), 0}
+	for i := range want {
+		if math.IsNaN(want[i]) {
+			if !math.IsNaN(clipped[i]) {
+				t.Errorf("clipped[%d] = %v, want NaN", i, clipped[i])
+			}
+			continue
+		}
+		if clipped[i] != want[i] {
+			t.Errorf("clipped[%d] = %v, want %v", i, clipped[i], want[i])
+		}
+	}
+	if marks[2] != 10 || marks[1] != 2 {
+		t.Errorf("marks around spike = %v, want segment 2→10 marked", marks[1:3])
+	}
+	if !math.IsNaN(marks[0]) || !math.IsNaN(marks[3]) {
+		t.Errorf("unclipped points should not be marked: %v", marks)
+	}
+}
+
+func TestTopSeries(t *testing.T) {
+	var list []*metricSeries
+	for i, v := range []float64{5, -50, 1, 20, math.NaN()} {
+		s := newTestSeries("m", map[string]string{"i": strconv.Itoa(i)})
+		s.push(v)
+		list = append(list, s)
+	}
+	top, rest := topSeries(list, 2, func(s *metricSeries) float64 { return s.last() })
+	if len(top) != 2 || top[0].labels["i"] != "1" || top[1].labels["i"] != "3" {
+		t.Errorf("top = %v, %v", top[0].key, top[1].key)
+	}
+	if len(rest) != 3 || rest[2].labels["i"] != "4" {
+		t.Errorf("rest = %d series, NaN should rank last", len(rest))
+	}
+
+	if top, rest := topSeries(list, 0, nil); len(top) != len(list) || rest != nil {
+		t.Error("k=0 should not limit")
+	}
+	if top, rest := topSeries(list[:2], 5, nil); len(top) != 2 || rest != nil {
+		t.Error("fewer series than k should not limit")
+	}
+}
+
+func TestSumAligned(t *testing.T) {
+	got := sumAligned([][]float64{{1, 2, 3}, {10, math.NaN()}, {}})
+	if len(got) != 3 || got[0] != 1 || got[1] != 12 || got[2] != 3 {
+		t.Errorf("sumAligned = %v, want [1 12 3]", got)
+	}
+	got = sumAligned([][]float64{{math.NaN()}})
+	if len(got) != 1 || !math.IsNaN(got[0]) {
+		t.Errorf("all-NaN column = %v, want NaN", got)
+	}
+	if sumAligned(nil) != nil {
+		t.Error("no datasets should give nil")
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	data := make([]float64, 100)
+	for i := range data {
+		data[i] = 1
+	}
+	data[37] = 50
+	data[38] = -5
+	for i := 60; i < 80; i++ {
+		data[i] = math.NaN()
+	}
+	got := envelope(data, 20)
+	if len(got) != 20 {
+		t.Fatalf("len = %d, want 20", len(got))
+	}
+	// Bucket 3 covers 30–39: the spike and the dip, in order.
+	if got[6] != 50 || got[7] != -5 {
+		t.Errorf("spike bucket = %v, %v; want 50, -5", got[6], got[7])
+	}
+	if got[0] != 1 || got[1] != 1 {
+		t.Errorf("flat bucket = %v, %v", got[0], got[1])
+	}
+	if !math.IsNaN(got[12]) || !math.IsNaN(got[15]) || math.IsNaN(got[16]) {
+		t.Errorf("gap buckets = %v, want 60–79 NaN", got[12:17])
+	}
+	if got := envelope(data[:20], 20); len(got) != 20 {
+		t.Errorf("fitting data len = %d, want it untouched", len(got))
+	}
+	if got := envelope(data, 0); len(got) != 100 {
+		t.Errorf("width 0 len = %d, want every point", len(got))
+	}
+}
+
+func TestChartPlotWidth(t *testing.T) {
+	if got := chartPlotWidth(160, forecastOff); got != 200 {
+		t.Errorf("chartPlotWidth(160) = %d, want 200", got)
+	}
+	if got := chartPlotWidth(10, forecastOff); got != 2 {
+		t.Errorf("chartPlotWidth(10) = %d, want the minimum 2", got)
+	}
+	if got := chartPlotWidth(160, forecastLinear); got != 0 {
+		t.Errorf("chartPlotWidth with a forecast = %d, want 0", got)
+	}
+}
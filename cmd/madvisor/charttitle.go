@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// flagTitleWidth caps chart titles: 0 fits them to the chart border, a
+// positive value is a fixed maximum in characters and -1 never shortens them.
+var flagTitleWidth = 0
+
+// chartTitleWidth is the room for a title on the chart border of a
+// terminal termWidth columns wide (the chart column is 70% of it).
+func chartTitleWidth(termWidth int) int {
+	switch {
+	case flagTitleWidth < 0:
+		return 0
+	case flagTitleWidth > 0:
+		return flagTitleWidth
+	}
+	return max(termWidth*70/100-4, 1)
+}
+
+// distinguishingLabels orders s's visible label keys by how many distinct
+// values they take across siblings, most first; keys shared by every sibling
+// come last. Ties keep alphabetical order.
+func distinguishingLabels(s *metricSeries, siblings []*metricSeries) []string {
+	keys := make([]string, 0, len(s.labels))
+	for k := range s.labels {
+		if !labelHidden(s.name, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	distinct := make(map[string]int, len(keys))
+	for _, k := range keys {
+		vals := map[string]bool{}
+		for _, o := range siblings {
+			vals[o.labels[k]] = true
+		}
+		distinct[k] = len(vals)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return distinct[keys[i]] > distinct[keys[j]] })
+	return keys
+}
+
+// fitSeriesName shortens s's display name to at most width characters (no
+// limit when width <= 0). The metric name is kept and labels are added in
+// distinguishingLabels order while they fit; leftovers are shown as "…".
+func fitSeriesName(s *metricSeries, siblings []*metricSeries, width int) string {
+	full := s.displayName()
+	if width <= 0 || utf8.RuneCountInString(full) <= width {
+		return full
+	}
+	name := metricAlias(s.name)
+	// used counts the name, braces and trailing "…"; each label adds
+	// itself and a comma.
+	used := utf8.RuneCountInString(name) + 3
+	if used > width {
+		return truncateText(name, max(width, 1))
+	}
+	var parts []string
+	for _, k := range distinguishingLabels(s, siblings) {
+		part := fmt.Sprintf(`%s="%s"`, k, s.labels[k])
+		n := utf8.RuneCountInString(part) + 1
+		if len(parts) == 0 && used+n > width {
+			// A long first value is cut rather than dropped, since it is the
+			// label that tells this series apart.
+			if room := width - used - len(k) - len(`="",`); room >= 4 {
+				parts = append(parts, fmt.Sprintf(`%s="%s"`, k, truncateText(s.labels[k], room)))
+			}
+			break
+		}
+		if used+n > width {
+			break
+		}
+		parts = append(parts, part)
+		used += n
+	}
+	return name + "{" + strings.Join(append(parts, "…"), ",") + "}"
+}
+
+// fitChartTitle shortens the display name of cs inside title, a chart title
+// built around it, so the whole title fits width. Titles without a single
+// series are cut at the end.
+func fitChartTitle(title string, cs *metricSeries, siblings []*metricSeries, width int) string {
+	if width <= 0 || utf8.RuneCountInString(title) <= width {
+		return title
+	}
+	if cs != nil {
+		full := cs.displayName()
+		if strings.Contains(title, full) {
+			budget := max(width-(utf8.RuneCountInString(title)-utf8.RuneCountInString(full)), 1)
+			title = strings.Replace(title, full, fitSeriesName(cs, siblings, budget), 1)
+		}
+	}
+	if utf8.RuneCountInString(title) > width {
+		title = truncateText(title, max(width-1, 1)) + " "
+	}
+	return title
+}
+
+// fullChartTitle is the untruncated title of the last chart drawn, which L
+// shows in the status bar.
+type fullChartTitle struct{ p atomic.Pointer[string] }
+
+// fit records title in full, as a copy the render loop does not rewrite, and
+// returns it fitted to width.
+func (f *fullChartTitle) fit(title string, cs *metricSeries, siblings []*metricSeries, width int) string {
+	full := title
+	f.p.Store(&full)
+	return fitChartTitle(title, cs, siblings, width)
+}
+
+func (f *fullChartTitle) message() (string, bool) {
+	title := f.p.Load()
+	if title == nil {
+		return "", false
+	}
+	return "chart:" + strings.TrimRight(*title, " "), true
+}
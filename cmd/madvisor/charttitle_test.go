@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func titleSeries(labels ...map[string]string) []*metricSeries {
+	out := make([]*metricSeries, len(labels))
+	for i, l := range labels {
+		out[i] = &metricSeries{name: "http_requests_total", labels: l}
+	}
+	return out
+}
+
+func TestFitSeriesName(t *testing.T) {
+	list := titleSeries(
+		map[string]string{"cluster": "prod-eu-west-1", "namespace": "checkout", "pod": "api-7d9f8c6b5-x2k4p", "code": "200"},
+		map[string]string{"cluster": "prod-eu-west-1", "namespace": "checkout", "pod": "api-7d9f8c6b5-q8n3z", "code": "500"},
+		map[string]string{"cluster": "prod-eu-west-1", "namespace": "checkout", "pod": "api-7d9f8c6b5-m1c7d", "code": "200"},
+	)
+	s := list[0]
+	tests := []struct {
+		width int
+		want  string
+	}{
+		{0, s.displayName()},
+		{200, s.displayName()},
+		{60, `http_requests_total{pod="api-7d9f8c6b5-x2k4p",code="200",…}`},
+		{50, `http_requests_total{pod="api-7d9f8c6b5-x2k4p",…}`},
+		{35, `http_requests_total{pod="api-7…",…}`},
+		{22, `http_requests_total{…}`},
+		{10, `http_requ…`},
+	}
+	for _, tt := range tests {
+		got := fitSeriesName(s, list, tt.width)
+		if got != tt.want {
+			t.Errorf("width %d: got %s, want %s", tt.width, got, tt.want)
+		}
+		if tt.width > 0 && utf8.RuneCountInString(got) > tt.width {
+			t.Errorf("width %d: %q is %d long", tt.width, got, utf8.RuneCountInString(got))
+		}
+	}
+}
+
+func TestDistinguishingLabels(t *testing.T) {
+	list := titleSeries(
+		map[string]string{"a": "x", "b": "1", "c": "p"},
+		map[string]string{"a": "x", "b": "2", "c": "q"},
+		map[string]string{"a": "x", "b": "3", "c": "q"},
+	)
+	if got := strings.Join(distinguishingLabels(list[0], list), ","); got != "b,c,a" {
+		t.Errorf("order = %s, want b,c,a", got)
+	}
+}
+
+func TestFitChartTitle(t *testing.T) {
+	list := titleSeries(
+		map[string]string{"instance": "10.0.0.1:9100", "job": "node"},
+		map[string]string{"instance": "10.0.0.2:9100", "job": "node"},
+	)
+	cs := list[1]
+	title := " " + cs.displayName() + " [rate/s] [gaps: 2] "
+	if got := fitChartTitle(title, cs, list, 0); got != title {
+		t.Errorf("unlimited width changed the title: %q", got)
+	}
+	got := fitChartTitle(title, cs, list, 70)
+	if want := ` http_requests_total{instance="10.0.0.2:9100",…} [rate/s] [gaps: 2] `; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := fitChartTitle(" gauge very_long_metric_name (3 series) [gaps: 2] ", nil, nil, 20); utf8.RuneCountInString(got) > 20 || !strings.HasSuffix(got, "… ") {
+		t.Errorf("multi-series title = %q", got)
+	}
+}
+
+func TestChartTitleWidth(t *testing.T) {
+	defer func(w int) { flagTitleWidth = w }(flagTitleWidth)
+	for _, c := range []struct{ flag, term, want int }{{0, 200, 136}, {50, 200, 50}, {-1, 200, 0}, {0, 3, 1}} {
+		flagTitleWidth = c.flag
+		if got := chartTitleWidth(c.term); got != c.want {
+			t.Errorf("chartTitleWidth(%d) with --title-width %d = %d, want %d", c.term, c.flag, got, c.want)
+		}
+	}
+}
+
+func TestFullChartTitleKeepsUntruncated(t *testing.T) {
+	list := titleSeries(
+		map[string]string{"instance": "10.0.0.1:9100", "job": "node"},
+		map[string]string{"instance": "10.0.0.2:9100", "job": "node"},
+	)
+	var titles fullChartTitle
+	if _, ok := titles.message(); ok {
+		t.Error("no chart drawn yet, L should have nothing to show")
+	}
+	title := " " + list[1].displayName() + " [rate/s] [gaps: 2] "
+	fitted := titles.fit(title, list[1], list, 40)
+	if fitted == title {
+		t.Fatalf("title should have been shortened: %q", fitted)
+	}
+	msg, ok := titles.message()
+	if want := "chart:" + strings.TrimRight(title, " "); !ok || msg != want {
+		t.Errorf("L shows %q, want the untruncated %q", msg, want)
+	}
+}
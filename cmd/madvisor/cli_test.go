@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func executeCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newRootCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestBindEnv(t *testing.T) {
+	t.Setenv("METRIC_TARGETS", "env:1")
+	t.Setenv("RATE_WINDOW", "30s")
+
+	var targets, rw string
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVar(&targets, "targets", "", "")
+	fs.StringVar(&rw, "rate-window", "", "")
+	if err := fs.Parse([]string{"--rate-window", "10s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bindEnv(fs); err != nil {
+		t.Fatalf("bindEnv: %v", err)
+	}
+	if targets != "env:1" {
+		t.Errorf("targets = %q, want env:1 from METRIC_TARGETS", targets)
+	}
+	if rw != "10s" {
+		t.Errorf("rate-window = %q, want 10s (flag overrides env)", rw)
+	}
+}
+
+func TestCLIVersion(t *testing.T) {
+	out, err := executeCmd(t, "--version")
+	if err != nil {
+		t.Fatalf("--version: %v", err)
+	}
+	if !strings.HasPrefix(out, "madvisor "+version+" (commit=") {
+		t.Errorf("--version = %q", out)
+	}
+}
+
+func TestCLIPatternsTest(t *testing.T) {
+	defer initPatterns("")
+
+	out, err := executeCmd(t, "patterns", "test", "node_memory_bytes", "queue_depth")
+	if err != nil {
+		t.Fatalf("patterns test: %v", err)
+	}
+	if !strings.Contains(out, "node_memory_bytes | bytes | _bytes$") {
+		t.Errorf("patterns test output =\n%s", out)
+	}
+	if !strings.Contains(out, "queue_depth       | -") {
+		t.Errorf("unmatched metric should show -, got\n%s", out)
+	}
+}
+
+func TestCLIPatternsListUsesUserFile(t *testing.T) {
+	defer initPatterns("")
+
+	path := filepath.Join(t.TempDir(), "p.yaml")
+	yaml := "units:\n  - unit: ops\n    suffix: \" [ops]\"\n    matchers:\n      - \"_ops$\"\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := executeCmd(t, "--patterns", path, "patterns", "list")
+	if err != nil {
+		t.Fatalf("patterns list: %v", err)
+	}
+	if !strings.Contains(out, "_ops$") || !strings.Contains(out, "_bytes$") {
+		t.Errorf("patterns list should include user and built-in patterns:\n%s", out)
+	}
+}
+
+func TestCLIPatternsDefault(t *testing.T) {
+	out, err := executeCmd(t, "patterns", "default")
+	if err != nil {
+		t.Fatalf("patterns default: %v", err)
+	}
+	if !strings.HasPrefix(out, "units:") {
+		t.Errorf("patterns default = %q", out)
+	}
+}
+
+func TestCLISnapshotDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, samples ...sample) string {
+		var b bytes.Buffer
+		rec := newRecorder(&b)
+		for _, s := range samples {
+			rec.write(s)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	now := time.Now()
+	a := write("a.ndjson", sample{Time: now, Name: "up", Value: 1})
+	b := write("b.ndjson", sample{Time: now, Name: "up", Value: 0})
+
+	out, err := executeCmd(t, "snapshot", "diff", a, b)
+	if err != nil {
+		t.Fatalf("snapshot diff: %v", err)
+	}
+	if !strings.Contains(out, "-100.0%") {
+		t.Errorf("snapshot diff output =\n%s", out)
+	}
+}
+
+func TestCLIRateWindowFlag(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	os.Unsetenv("RATE_WINDOW")
+
+	if _, err := executeCmd(t, "--rate-window", "30s", "patterns", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if got := rateWindowGet(); got != 30*time.Second {
+		t.Errorf("rate window = %s, want 30s", got)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+)
+
+func (u *uiState) startCommand() {
+	u.startCommandWith("")
+}
+
+// startCommandWith opens the command line with input already typed, for keys
+// that are shortcuts to a command taking arguments.
+func (u *uiState) startCommandWith(input string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.cmdMode = true
+	u.cmdInput = input
+}
+
+func (u *uiState) commandPrompt() (bool, string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.cmdMode, u.cmdInput
+}
+
+func (u *uiState) addCommandChar(ch rune) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.cmdInput += string(ch)
+}
+
+func (u *uiState) backspaceCommand() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.cmdInput) > 0 {
+		u.cmdInput = u.cmdInput[:len(u.cmdInput)-1]
+	}
+}
+
+func (u *uiState) cancelCommand() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.cmdMode = false
+	u.cmdInput = ""
+}
+
+func (u *uiState) commitCommand() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	line := strings.TrimSpace(u.cmdInput)
+	u.cmdMode = false
+	u.cmdInput = ""
+	return line
+}
+
+// runCommandLine runs one :command using the startup script command set, so
+// everything an init script can do is also available interactively.
+func runCommandLine(line string, ui *uiState, st *store) {
+	if line == "" {
+		return
+	}
+	ui.setMessage(":" + line)
+	cmds, err := parseScript(strings.NewReader(line))
+	if err != nil {
+		ui.setMessage(":" + strings.TrimPrefix(err.Error(), "line 1: "))
+		return
+	}
+	if errs := runScript(cmds, ui, st); len(errs) > 0 {
+		ui.setMessage(":" + strings.TrimPrefix(errs[0].Error(), "line 1: "))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandPrompt(t *testing.T) {
+	ui := &uiState{}
+	ui.startCommand()
+	for _, r := range "rate 30sx" {
+		ui.addCommandChar(r)
+	}
+	ui.backspaceCommand()
+	if mode, input := ui.commandPrompt(); !mode || input != "rate 30s" {
+		t.Errorf("prompt = %v, %q", mode, input)
+	}
+	if line := ui.commitCommand(); line != "rate 30s" {
+		t.Errorf("commit = %q", line)
+	}
+	if mode, _ := ui.commandPrompt(); mode {
+		t.Error("commit should leave command mode")
+	}
+	ui.startCommand()
+	ui.addCommandChar('x')
+	ui.cancelCommand()
+	if mode, input := ui.commandPrompt(); mode || input != "" {
+		t.Errorf("cancel left %v, %q", mode, input)
+	}
+}
+
+func TestRunCommandLine(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	st := newStore()
+	ui := &uiState{}
+
+	runCommandLine("rate 30s", ui, st)
+	if got := rateWindowGet(); got != 30*time.Second {
+		t.Errorf("rate window = %s, want 30s", got)
+	}
+	if msg := ui.currentMessage(); msg != ":rate 30s" {
+		t.Errorf("message = %q", msg)
+	}
+
+	defer setTheme("default")
+	runCommandLine("theme solarized", ui, st)
+	if themeName() != "solarized" {
+		t.Errorf("theme = %s, want solarized", themeName())
+	}
+	runCommandLine("theme neon", ui, st)
+	if msg := ui.currentMessage(); !strings.HasPrefix(msg, ":unknown theme") {
+		t.Errorf("message = %q, want unknown theme error", msg)
+	}
+	runCommandLine("zoom 2x", ui, st)
+	if msg := ui.currentMessage(); !strings.HasPrefix(msg, ":unknown command") {
+		t.Errorf("message = %q, want unknown command error", msg)
+	}
+
+	runCommandLine("target add web=app:8080", ui, st)
+	if got := st.activeTargets(nil); len(got) != 1 || got[0].addr != "app:8080" || got[0].group != "web" {
+		t.Errorf("active targets = %v", got)
+	}
+	if msg := ui.currentMessage(); msg != "scraping app:8080" {
+		t.Errorf("message = %q", msg)
+	}
+	runCommandLine("annotate deploy v2.3", ui, st)
+	if anns := st.annotationsBetween(time.Now().Add(-time.Minute), time.Now()); len(anns) == 0 || anns[len(anns)-1].Text != "deploy v2.3" {
+		t.Errorf("annotations = %v, want the :annotate text", anns)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// metricRules are the runtime allowlist and denylist of metric names set by
+// the allow and deny commands. Patterns match the whole name. An excluded
+// metric is no longer stored, and compact frees the series it already has.
+type metricRules struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+var (
+	runtimeRules   atomic.Pointer[metricRules]
+	runtimeRulesMu sync.Mutex
+)
+
+func (r *metricRules) excludes(name string) bool {
+	for _, re := range r.deny {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	if len(r.allow) == 0 {
+		return false
+	}
+	for _, re := range r.allow {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func metricExcluded(name string) bool {
+	r := runtimeRules.Load()
+	return r != nil && r.excludes(name)
+}
+
+func compileMetricRule(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid metric pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// setMetricRule adds re to the allow or deny list, or clears that list when
+// re is nil.
+func setMetricRule(list string, re *regexp.Regexp) {
+	runtimeRulesMu.Lock()
+	defer runtimeRulesMu.Unlock()
+	var r metricRules
+	if old := runtimeRules.Load(); old != nil {
+		r = *old
+	}
+	rules := &r.deny
+	if list == "allow" {
+		rules = &r.allow
+	}
+	if re == nil {
+		*rules = nil
+	} else {
+		*rules = append((*rules)[:len(*rules):len(*rules)], re)
+	}
+	runtimeRules.Store(&r)
+}
+
+// compact frees the series of metrics the runtime rules exclude, reporting
+// how many series and metrics were freed.
+func (st *store) compact() (series, metrics int) {
+	names := map[string]bool{}
+	series = st.dropSeries(func(s *metricSeries) bool {
+		if metricExcluded(s.name) {
+			names[s.name] = true
+			return true
+		}
+		return false
+	})
+	return series, len(names)
+}
+
+func compactMessage(rule string, series, metrics int) string {
+	if series == 0 {
+		return rule + ": nothing stored to free"
+	}
+	return fmt.Sprintf("%s: freed %d series of %d metric(s)", rule, series, metrics)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func withoutMetricRules(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		setMetricRule("allow", nil)
+		setMetricRule("deny", nil)
+	})
+}
+
+func TestMetricRules(t *testing.T) {
+	withoutMetricRules(t)
+	deny, _ := compileMetricRule("go_.*")
+	setMetricRule("deny", deny)
+	if !metricExcluded("go_goroutines") || metricExcluded("http_requests_total") || metricExcluded("cargo_total") {
+		t.Error("deny go_.* should exclude only go_ metrics, matching the whole name")
+	}
+	allow, _ := compileMetricRule("http_.*|go_gc_.*")
+	setMetricRule("allow", allow)
+	if metricExcluded("http_requests_total") || !metricExcluded("process_cpu_seconds_total") || !metricExcluded("go_gc_duration_seconds") {
+		t.Error("allow should exclude unlisted metrics, with deny winning over allow")
+	}
+	setMetricRule("deny", nil)
+	if metricExcluded("go_gc_duration_seconds") || !metricExcluded("up") {
+		t.Error("clearing deny should leave the allowlist in place")
+	}
+	if _, err := compileMetricRule("("); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	withoutMetricRules(t)
+	st := newStore()
+	now := time.Now()
+	for _, pod := range []string{"a", "b"} {
+		st.ingest("a:1", "go_goroutines", map[string]string{"pod": pod}, "", "gauge", 1, now)
+		st.ingest("a:1", "go_threads", map[string]string{"pod": pod}, "", "gauge", 1, now)
+		st.ingest("a:1", "http_requests_total", map[string]string{"pod": pod}, "", "counter", 1, now)
+	}
+	deny, _ := compileMetricRule("go_.*")
+	setMetricRule("deny", deny)
+	if n, m := st.compact(); n != 4 || m != 2 {
+		t.Fatalf("compact freed %d series of %d metrics, want 4 of 2", n, m)
+	}
+	if got := st.names(); len(got) != 1 || got[0] != "http_requests_total" {
+		t.Errorf("names after compaction = %v", got)
+	}
+	st.ingest("a:1", "go_goroutines", map[string]string{"pod": "a"}, "", "gauge", 1, now)
+	if st.seriesCount("go_goroutines") != 0 {
+		t.Error("denied metric stored again")
+	}
+	if n, _ := st.compact(); n != 0 {
+		t.Errorf("second compaction freed %d series", n)
+	}
+}
+
+func TestDenyScriptCommand(t *testing.T) {
+	withoutMetricRules(t)
+	if _, err := parseScriptLine(1, "deny [a-"); err == nil {
+		t.Error("invalid deny pattern accepted")
+	}
+	st := newStore()
+	st.update("go_goroutines", nil, "", "gauge", 1)
+	st.update("up", nil, "", "gauge", 1)
+	ui := &uiState{}
+	cmds, err := parseScript(strings.NewReader("deny go_goroutines\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runScript(cmds, ui, st)
+	if ui.message != "deny go_goroutines: freed 1 series of 1 metric(s)" {
+		t.Errorf("message = %q", ui.message)
+	}
+	if keys := ui.allKeys; len(keys) != 1 || keys[0] != "up" {
+		t.Errorf("metric list = %v, want only up", keys)
+	}
+	cmds, _ = parseScript(strings.NewReader("deny clear\n"))
+	runScript(cmds, ui, st)
+	st.update("go_goroutines", nil, "", "gauge", 1)
+	if st.seriesCount("go_goroutines") != 1 {
+		t.Error("deny clear should store the metric again")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata golden files")
+
+type corpusSample struct {
+	name   string
+	labels map[string]string
+	mtype  string
+	value  float64
+}
+
+func (s corpusSample) String() string {
+	keys := make([]string, 0, len(s.labels))
+	for k := range s.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + strconv.Quote(s.labels[k])
+	}
+	mtype := s.mtype
+	if mtype == "" {
+		mtype = "-"
+	}
+	return fmt.Sprintf("%s{%s} %s %s", s.name, strings.Join(parts, ","), mtype, strconv.FormatFloat(s.value, 'g', -1, 64))
+}
+
+func parseCorpus(t *testing.T, file string) []corpusSample {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "exporters", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var out []corpusSample
+	ps, err := scanExposition(f, func(name string, labels map[string]string, help, mtype string, val float64) {
+		out = append(out, corpusSample{name: name, labels: labels, mtype: mtype, value: val})
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.skipped > 0 {
+		t.Errorf("%s: %d line(s) skipped: %v", file, ps.skipped, ps.examples)
+	}
+	return out
+}
+
+func TestExporterCorpus(t *testing.T) {
+	tests := []struct {
+		file    string
+		samples int
+		types   map[string]int
+		labels  []corpusSample
+	}{
+		{
+			file:    "node_exporter.prom",
+			samples: 35,
+			types:   map[string]int{"summary": 7, "gauge": 12, "counter": 16},
+			labels: []corpusSample{
+				{name: "node_filesystem_avail_bytes", labels: map[string]string{"mountpoint": "/media/usb stick"}, value: 7.812e9},
+				{name: "node_uname_info", labels: map[string]string{"version": "#14~22.04.1-Ubuntu SMP PREEMPT_DYNAMIC Mon Nov 20 18:15:30 UTC 2", "domainname": "(none)"}, value: 1},
+				{name: "go_gc_duration_seconds_count", mtype: "summary", value: 3547},
+			},
+		},
+		{
+			file:    "kube_state_metrics.prom",
+			samples: 19,
+			types:   map[string]int{"gauge": 17, "counter": 2},
+			labels: []corpusSample{
+				{name: "kube_pod_annotations", labels: map[string]string{"annotation_kubectl_kubernetes_io_last_applied_configuration": `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"api"}}`}, value: 1},
+				{name: "kube_pod_labels", labels: map[string]string{"label_tier": "backend, public", "label_app": "api"}, value: 1},
+				{name: "kube_node_status_condition", labels: map[string]string{"status": "unknown"}, value: 0},
+			},
+		},
+		{
+			file:    "nginx.prom",
+			samples: 18,
+			types:   map[string]int{"counter": 5, "gauge": 6, "histogram": 7},
+			labels: []corpusSample{
+				{name: "nginx_ingress_controller_requests", labels: map[string]string{"path": "/search{q}", "status": "200"}, value: 2791},
+				{name: "nginx_ingress_controller_request_duration_seconds_bucket", mtype: "histogram", labels: map[string]string{"le": "+Inf"}, value: 2791},
+				{name: "nginx_ingress_controller_request_duration_seconds_sum", mtype: "histogram", value: 41.27},
+			},
+		},
+		{
+			file:    "madvisor_dummy.prom",
+			samples: 42,
+			types:   map[string]int{"counter": 16, "gauge": 26},
+			labels: []corpusSample{
+				{name: "http_requests_total", mtype: "counter", labels: map[string]string{"method": "GET", "path": "/api/users"}, value: 44.2829},
+				{name: "cpu_usage_percent", mtype: "gauge", labels: map[string]string{"env": "prod"}, value: 30.7417},
+			},
+		},
+		{
+			file:    "envoy.prom",
+			samples: 27,
+			types:   map[string]int{"gauge": 7, "counter": 6, "histogram": 14},
+			labels: []corpusSample{
+				{name: "envoy_server_live", labels: map[string]string{}, value: 1},
+				{name: "envoy_cluster_upstream_rq", labels: map[string]string{"envoy_response_code": "503"}, value: 224},
+				{name: "envoy_cluster_manager_cds_version", value: 1.4739210453410462e+19},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got := parseCorpus(t, tt.file)
+			if len(got) != tt.samples {
+				t.Errorf("samples = %d, want %d", len(got), tt.samples)
+			}
+			types := map[string]int{}
+			for _, s := range got {
+				types[s.mtype]++
+			}
+			if fmt.Sprint(types) != fmt.Sprint(tt.types) {
+				t.Errorf("types = %v, want %v", types, tt.types)
+			}
+			for _, want := range tt.labels {
+				if !corpusHas(got, want) {
+					t.Errorf("no sample matching %s", want)
+				}
+			}
+			checkGolden(t, strings.TrimSuffix(tt.file, ".prom")+".golden", got)
+		})
+	}
+}
+
+// corpusHas reports whether a sample with want's name and value carries at
+// least want's labels (and want's type, when given).
+func corpusHas(got []corpusSample, want corpusSample) bool {
+	for _, s := range got {
+		if s.name != want.name || s.value != want.value || (want.mtype != "" && s.mtype != want.mtype) {
+			continue
+		}
+		ok := true
+		for k, v := range want.labels {
+			if s.labels[k] != v {
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func checkGolden(t *testing.T, file string, got []corpusSample) {
+	t.Helper()
+	var b strings.Builder
+	for _, s := range got {
+		b.WriteString(s.String())
+		b.WriteByte('\n')
+	}
+	path := filepath.Join("testdata", "exporters", file)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if b.String() != string(want) {
+		gotLines, wantLines := strings.Split(b.String(), "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+			var g, w string
+			if i < len(gotLines) {
+				g = gotLines[i]
+			}
+			if i < len(wantLines) {
+				w = wantLines[i]
+			}
+			if g != w {
+				t.Fatalf("%s differs at line %d:\n got  %s\n want %s\n(run go test -update if the change is intended)", file, i+1, g, w)
+			}
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+const (
+	defaultDBInterval = 5 * time.Second
+	dbTimeout         = 3 * time.Second
+
+	redisHelp    = "Field of the Redis INFO command."
+	postgresHelp = "Column of a Postgres pg_stat_* view."
+	mysqlHelp    = "Variable of MySQL SHOW GLOBAL STATUS."
+)
+
+var flagDB string
+
+// dbSource is one --db database: addr is its DSN without credentials, used
+// as the target address, and dsn what is connected to.
+type dbSource struct {
+	kind string
+	addr string
+	dsn  string
+}
+
+// parseDBSources parses the comma-separated --db DSNs: redis://, postgres://
+// (or postgresql://) and mysql:// URLs with optional user:password@ and
+// database path. Ports default to 6379, 5432 and 3306.
+func parseDBSources(spec string) ([]dbSource, error) {
+	var out []dbSource
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("database %q: want a URL such as redis://host:6379", s)
+		}
+		kind, port := u.Scheme, ""
+		switch kind {
+		case "redis":
+			port = "6379"
+		case "postgres", "postgresql":
+			kind, port = "postgres", "5432"
+		case "mysql":
+			port = "3306"
+		default:
+			return nil, fmt.Errorf("database %q: unsupported scheme %q, want redis, postgres or mysql", s, u.Scheme)
+		}
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), port)
+		}
+		src := dbSource{kind: kind, addr: kind + "://" + u.Host, dsn: u.String()}
+		if kind == "mysql" {
+			cfg := mysql.NewConfig()
+			cfg.Net, cfg.Addr, cfg.DBName = "tcp", u.Host, strings.TrimPrefix(u.Path, "/")
+			cfg.User = u.User.Username()
+			cfg.Passwd, _ = u.User.Password()
+			cfg.Timeout = dbTimeout
+			src.dsn = cfg.FormatDSN()
+		}
+		out = append(out, src)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no databases given")
+	}
+	return out, nil
+}
+
+func dbTargets(srcs []dbSource) []target {
+	out := make([]target, len(srcs))
+	for i, s := range srcs {
+		out[i] = target{addr: s.addr}
+	}
+	return out
+}
+
+type dbSample struct {
+	name   string
+	labels map[string]string
+	help   string
+	mtype  string
+	value  float64
+}
+
+// runDBs polls every database every defaultDBInterval, recording each poll
+// as a scrape of its target so failures show in the targets panel and up.
+func runDBs(ctx context.Context, srcs []dbSource, st *store) {
+	for _, src := range srcs {
+		go func(src dbSource) {
+			var db *sql.DB
+			ticker := time.NewTicker(defaultDBInterval)
+			defer ticker.Stop()
+			for {
+				var samples []dbSample
+				var err error
+				qctx, cancel := context.WithTimeout(ctx, dbTimeout)
+				switch src.kind {
+				case "redis":
+					samples, err = pollRedis(qctx, src.dsn)
+				default:
+					if db == nil {
+						db, err = openDB(src)
+					}
+					if db != nil {
+						samples, err = pollSQL(qctx, src.kind, db)
+					}
+				}
+				cancel()
+				ingestDBSamples(st, src, samples, err)
+				select {
+				case <-ctx.Done():
+					if db != nil {
+						db.Close()
+					}
+					return
+				case <-ticker.C:
+				}
+			}
+		}(src)
+	}
+}
+
+func openDB(src dbSource) (*sql.DB, error) {
+	driver := "mysql"
+	if src.kind == "postgres" {
+		driver = "postgres"
+	}
+	db, err := sql.Open(driver, src.dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func ingestDBSamples(st *store, src dbSource, samples []dbSample, err error) {
+	now := time.Now()
+	tgt := target{addr: src.addr}
+	if err == nil && len(samples) == 0 {
+		err = fmt.Errorf("no statistics returned")
+	}
+	for _, s := range samples {
+		labels := s.labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[instanceLabel] = src.addr
+		name, labels, keep := applyRelabel(globalRelabel, src.addr, s.name, labels)
+		if keep {
+			st.ingest(src.addr, name, labels, s.help, s.mtype, s.value, now)
+		}
+	}
+	st.scrapeDone(tgt, err, now)
+}
+
+// redisCounters are the INFO fields that only grow besides the total_ ones,
+// and redisTotalGauges the total_ ones that do not.
+var (
+	redisCounters = map[string]bool{
+		"keyspace_hits": true, "keyspace_misses": true, "expired_keys": true, "evicted_keys": true,
+		"rejected_connections": true, "sync_full": true, "sync_partial_ok": true, "sync_partial_err": true,
+		"used_cpu_sys": true, "used_cpu_user": true, "used_cpu_sys_children": true, "used_cpu_user_children": true,
+	}
+	redisTotalGauges = map[string]bool{"total_system_memory": true, "total_blocking_keys": true, "total_blocking_keys_on_nokey": true}
+)
+
+// pollRedis sends INFO (after AUTH and SELECT when the DSN has a password or
+// database) over the Redis protocol.
+func pollRedis(ctx context.Context, dsn string) ([]dbSample, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	if pass, ok := u.User.Password(); ok {
+		args := []string{"AUTH", pass}
+		if user := u.User.Username(); user != "" {
+			args = []string{"AUTH", user, pass}
+		}
+		if _, err := redisCommand(conn, r, args...); err != nil {
+			return nil, err
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err := redisCommand(conn, r, "SELECT", db); err != nil {
+			return nil, err
+		}
+	}
+	info, err := redisCommand(conn, r, "INFO")
+	if err != nil {
+		return nil, err
+	}
+	return append(parseRedisInfo(info), dbSample{name: "redis_up", help: redisHelp, mtype: "gauge", value: 1}), nil
+}
+
+// redisCommand writes one command and reads a simple or bulk string reply.
+func redisCommand(conn net.Conn, r *bufio.Reader, args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return "", err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis %s: %s", args[0], line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("redis %s: bad reply %q", args[0], line)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return "", fmt.Errorf("redis %s: unexpected reply %q", args[0], line)
+}
+
+// parseRedisInfo turns INFO's numeric fields into redis_<field> series and
+// the keyspace lines (db0:keys=1,expires=0,avg_ttl=0) into redis_db_<field>
+// series labelled with the database.
+func parseRedisInfo(info string) []dbSample {
+	var out []dbSample
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		key, val, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(key, "db") && strings.Contains(val, "=") {
+			for _, kv := range strings.Split(val, ",") {
+				k, v, _ := strings.Cut(kv, "=")
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					out = append(out, dbSample{name: "redis_db_" + k, labels: map[string]string{"db": key}, help: redisHelp, mtype: "gauge", value: f})
+				}
+			}
+			continue
+		}
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			continue
+		}
+		mtype := "gauge"
+		if strings.HasPrefix(key, "total_") && !redisTotalGauges[key] || redisCounters[key] {
+			mtype = "counter"
+		}
+		out = append(out, dbSample{name: "redis_" + key, help: redisHelp, mtype: mtype, value: f})
+	}
+	return out
+}
+
+const (
+	pgStatDatabaseQuery = `SELECT datname, numbackends, xact_commit, xact_rollback, blks_read, blks_hit,
+	tup_returned, tup_fetched, tup_inserted, tup_updated, tup_deleted, conflicts, deadlocks, temp_bytes
+	FROM pg_stat_database WHERE datname IS NOT NULL`
+	pgStatActivityQuery = `SELECT coalesce(state, 'unknown'), count(*) FROM pg_stat_activity GROUP BY 1`
+	mysqlStatusQuery    = `SHOW GLOBAL STATUS`
+)
+
+// pollSQL reads pg_stat_database and pg_stat_activity, or SHOW GLOBAL
+// STATUS, named like postgres_exporter and mysqld_exporter so their pattern
+// packs apply.
+func pollSQL(ctx context.Context, kind string, db *sql.DB) ([]dbSample, error) {
+	if kind == "mysql" {
+		rows, err := db.QueryContext(ctx, mysqlStatusQuery)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var out []dbSample
+		for rows.Next() {
+			var name, value string
+			if err := rows.Scan(&name, &value); err != nil {
+				return nil, err
+			}
+			if s, ok := mysqlStatusSample(name, value); ok {
+				out = append(out, s)
+			}
+		}
+		out = append(out, dbSample{name: "mysql_up", help: mysqlHelp, mtype: "gauge", value: 1})
+		return out, rows.Err()
+	}
+
+	rows, err := db.QueryContext(ctx, pgStatDatabaseQuery)
+	if err != nil {
+		return nil, err
+	}
+	cols, _ := rows.Columns()
+	var out []dbSample
+	for rows.Next() {
+		var datname string
+		vals := make([]sql.NullFloat64, len(cols)-1)
+		dest := []any{&datname}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for i, v := range vals {
+			if v.Valid {
+				out = append(out, pgStatDatabaseSample(cols[i+1], datname, v.Float64))
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows, err = db.QueryContext(ctx, pgStatActivityQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		var n float64
+		if err := rows.Scan(&state, &n); err != nil {
+			return nil, err
+		}
+		out = append(out, dbSample{name: "pg_stat_activity_count", labels: map[string]string{"state": state}, help: postgresHelp, mtype: "gauge", value: n})
+	}
+	out = append(out, dbSample{name: "pg_up", help: postgresHelp, mtype: "gauge", value: 1})
+	return out, rows.Err()
+}
+
+func pgStatDatabaseSample(col, datname string, v float64) dbSample {
+	mtype := "counter"
+	if col == "numbackends" {
+		mtype = "gauge"
+	}
+	return dbSample{name: "pg_stat_database_" + col, labels: map[string]string{"datname": datname}, help: postgresHelp, mtype: mtype, value: v}
+}
+
+// mysqlGaugePrefixes mark the SHOW GLOBAL STATUS variables that go up and
+// down; the rest count events.
+var mysqlGaugePrefixes = []string{"threads_", "open_", "innodb_buffer_pool_pages_", "innodb_buffer_pool_bytes_", "innodb_row_lock_current_waits", "max_used_connections", "uptime", "slave_open_temp_tables", "innodb_page_size"}
+
+func mysqlStatusSample(name, value string) (dbSample, bool) {
+	key := strings.ToLower(name)
+	switch value {
+	case "ON":
+		return dbSample{name: "mysql_global_status_" + key, help: mysqlHelp, mtype: "gauge", value: 1}, true
+	case "OFF":
+		return dbSample{name: "mysql_global_status_" + key, help: mysqlHelp, mtype: "gauge", value: 0}, true
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return dbSample{}, false
+	}
+	mtype := "counter"
+	for _, p := range mysqlGaugePrefixes {
+		if strings.HasPrefix(key, p) {
+			mtype = "gauge"
+			break
+		}
+	}
+	return dbSample{name: "mysql_global_status_" + key, help: mysqlHelp, mtype: mtype, value: v}, true
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDBSources(t *testing.T) {
+	srcs, err := parseDBSources("redis://:pw@cache, postgres://app:secret@db/shop?sslmode=disable,mysql://root:pw@mysql:3307/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dbSource{
+		{kind: "redis", addr: "redis://cache:6379", dsn: "redis://:pw@cache:6379"},
+		{kind: "postgres", addr: "postgres://db:5432", dsn: "postgres://app:secret@db:5432/shop?sslmode=disable"},
+		{kind: "mysql", addr: "mysql://mysql:3307", dsn: "root:pw@tcp(mysql:3307)/?timeout=3s"},
+	}
+	if len(srcs) != len(want) {
+		t.Fatalf("parseDBSources = %+v", srcs)
+	}
+	for i := range want {
+		if srcs[i] != want[i] {
+			t.Errorf("source %d = %+v, want %+v", i, srcs[i], want[i])
+		}
+	}
+	for _, bad := range []string{"", "cache:6379", "mongodb://db"} {
+		if _, err := parseDBSources(bad); err == nil {
+			t.Errorf("parseDBSources(%q) accepted", bad)
+		}
+	}
+}
+
+func TestParseRedisInfo(t *testing.T) {
+	info := "# Clients\r\nconnected_clients:3\r\nredis_version:7.2.4\r\n# Stats\r\ntotal_commands_processed:120\r\nkeyspace_hits:9\r\ntotal_system_memory:1024\r\n# Keyspace\r\ndb0:keys=5,expires=1,avg_ttl=0\r\n"
+	got := map[string]string{}
+	for _, s := range parseRedisInfo(info) {
+		got[s.name+s.labels["db"]] = fmt.Sprintf("%s %g", s.mtype, s.value)
+	}
+	want := map[string]string{
+		"redis_connected_clients":        "gauge 3",
+		"redis_total_commands_processed": "counter 120",
+		"redis_keyspace_hits":            "counter 9",
+		"redis_total_system_memory":      "gauge 1024",
+		"redis_db_keysdb0":               "gauge 5",
+		"redis_db_expiresdb0":            "gauge 1",
+		"redis_db_avg_ttldb0":            "gauge 0",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("parseRedisInfo =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestPollRedis(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var cmds []string
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			var n int
+			fmt.Sscanf(line, "*%d", &n)
+			var args []string
+			for i := 0; i < n*2; i++ {
+				a, _ := r.ReadString('\n')
+				if i%2 == 1 {
+					args = append(args, strings.TrimSpace(a))
+				}
+			}
+			cmds = append(cmds, strings.Join(args, " "))
+			switch args[0] {
+			case "AUTH":
+				conn.Write([]byte("+OK\r\n"))
+			case "INFO":
+				body := "# Clients\r\nconnected_clients:2\r\n"
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(body), body)
+			default:
+				conn.Write([]byte("-ERR unknown command\r\n"))
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	samples, err := pollRedis(ctx, "redis://:pw@"+ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].name != "redis_connected_clients" || samples[0].value != 2 || samples[1].name != "redis_up" {
+		t.Errorf("pollRedis = %+v", samples)
+	}
+	if strings.Join(cmds, ";") != "AUTH pw;INFO" {
+		t.Errorf("commands = %q", cmds)
+	}
+}
+
+func TestSQLSamples(t *testing.T) {
+	if s := pgStatDatabaseSample("xact_commit", "shop", 5); s.name != "pg_stat_database_xact_commit" || s.mtype != "counter" || s.labels["datname"] != "shop" {
+		t.Errorf("pgStatDatabaseSample = %+v", s)
+	}
+	if s := pgStatDatabaseSample("numbackends", "shop", 5); s.mtype != "gauge" {
+		t.Errorf("numbackends type = %s, want gauge", s.mtype)
+	}
+	tests := []struct {
+		name, value, metric, mtype string
+		ok                         bool
+	}{
+		{"Threads_connected", "4", "mysql_global_status_threads_connected", "gauge", true},
+		{"Questions", "1200", "mysql_global_status_questions", "counter", true},
+		{"Rpl_semi_sync_master_status", "ON", "mysql_global_status_rpl_semi_sync_master_status", "gauge", true},
+		{"Ssl_cipher", "TLS_AES_256", "", "", false},
+	}
+	for _, tt := range tests {
+		s, ok := mysqlStatusSample(tt.name, tt.value)
+		if ok != tt.ok || s.name != tt.metric || s.mtype != tt.mtype {
+			t.Errorf("mysqlStatusSample(%s, %s) = %+v, %v", tt.name, tt.value, s, ok)
+		}
+	}
+}
+
+func TestIngestDBSamples(t *testing.T) {
+	st := newStore()
+	src := dbSource{kind: "redis", addr: "redis://cache:6379"}
+	ingestDBSamples(st, src, []dbSample{{name: "redis_connected_clients", mtype: "gauge", value: 3}}, nil)
+	ingestDBSamples(st, src, nil, fmt.Errorf("connection refused"))
+	h := st.targetHealth(src.addr)
+	if h.ok != 1 || h.failed != 1 {
+		t.Errorf("health = %d ok, %d failed", h.ok, h.failed)
+	}
+	if s := st.seriesForName("redis_connected_clients"); len(s) != 1 || s[0].labels[instanceLabel] != src.addr {
+		t.Errorf("series = %+v, want one with the instance label", s)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// flatDelta is the change, in percent, below which a series counts as flat.
+const flatDelta = 1
+
+// seriesDelta is the percent change over window: of the value for gauges
+// and of the rate for counters, comparing the last sample with the one a
+// window earlier. It is not ok until the series spans a window, or when the
+// earlier value is zero.
+func seriesDelta(s *metricSeries, window time.Duration) (float64, bool) {
+	n := s.count()
+	if n < 2 {
+		return 0, false
+	}
+	values, times := s.slice(), s.timeSlice()
+	end := n - 1
+	cutoff := times[end].Add(-window)
+	prev := -1
+	for i := end - 1; i >= 0; i-- {
+		if !times[i].After(cutoff) {
+			prev = i
+			break
+		}
+	}
+	if prev < 0 {
+		return 0, false
+	}
+	cur, old := values[end], values[prev]
+	if s.shouldRate() {
+		cur = windowRate(values, times, end, window, s.resets)
+		old = windowRate(values, times, prev, window, s.resets)
+	}
+	if math.IsNaN(cur) || math.IsNaN(old) {
+		return 0, false
+	}
+	if old == 0 {
+		return 0, cur == 0
+	}
+	return (cur - old) / math.Abs(old) * 100, true
+}
+
+// deltaCell is the series table's trend arrow with the percent change.
+func deltaCell(pct float64, ok bool) string {
+	switch {
+	case !ok:
+		return ""
+	case math.Abs(pct) < flatDelta:
+		return "→"
+	case pct > 999:
+		return "↑ >999%"
+	case pct > 0:
+		return fmt.Sprintf("↑ %.0f%%", pct)
+	}
+	return fmt.Sprintf("↓ %.0f%%", -pct)
+}
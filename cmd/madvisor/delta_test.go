@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSeriesDelta(t *testing.T) {
+	base := time.Now()
+	gauge := newTestSeries("queue_depth", nil)
+	gauge.mtype = "gauge"
+	for i, v := range []float64{100, 100, 100, 110, 112} {
+		gauge.pushAt(v, base.Add(time.Duration(i)*time.Second))
+	}
+	if pct, ok := seriesDelta(gauge, 3*time.Second); !ok || math.Abs(pct-12) > 1e-9 {
+		t.Errorf("gauge delta over 3s = %v, %v, want +12%%", pct, ok)
+	}
+	if _, ok := seriesDelta(gauge, time.Minute); ok {
+		t.Error("delta over a window longer than the history should not be shown")
+	}
+
+	counter := newTestSeries("requests_total", nil)
+	counter.mtype = "counter"
+	v := 0.0
+	for i := 0; i < 7; i++ {
+		step := 10.0
+		if i > 3 {
+			step = 5
+		}
+		if i > 0 {
+			v += step
+		}
+		counter.pushAt(v, base.Add(time.Duration(i)*time.Second))
+	}
+	if pct, ok := seriesDelta(counter, 3*time.Second); !ok || math.Abs(pct+50) > 1e-9 {
+		t.Errorf("counter delta = %v, %v, want the rate halved (-50%%)", pct, ok)
+	}
+}
+
+func TestDeltaCell(t *testing.T) {
+	for _, tt := range []struct {
+		pct  float64
+		ok   bool
+		want string
+	}{
+		{12.4, true, "↑ 12%"},
+		{-3, true, "↓ 3%"},
+		{0.4, true, "→"},
+		{5000, true, "↑ >999%"},
+		{0, false, ""},
+	} {
+		if got := deltaCell(tt.pct, tt.ok); got != tt.want {
+			t.Errorf("deltaCell(%v, %v) = %q, want %q", tt.pct, tt.ok, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var globalDisplay []compiledDisplay
+
+// DisplayRule is the presentation layer of the patterns file: how a metric
+// is named, whether it is listed, which labels are shown and how it is
+// charted by default.
+type DisplayRule struct {
+	Metric     string   `yaml:"metric"`
+	Name       string   `yaml:"name"`
+	Hide       bool     `yaml:"hide"`
+	HideLabels []string `yaml:"hide_labels"`
+	Chart      string   `yaml:"chart"`
+}
+
+type compiledDisplay struct {
+	re         *regexp.Regexp
+	name       string
+	hide       bool
+	hideLabels map[string]bool
+	chart      string
+}
+
+func compileDisplay(cfgs []DisplayRule) ([]compiledDisplay, error) {
+	out := make([]compiledDisplay, 0, len(cfgs))
+	for i, c := range cfgs {
+		if c.Metric == "" {
+			return nil, fmt.Errorf("display[%d]: metric is required", i)
+		}
+		re, err := regexp.Compile(c.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("display[%d]: compile metric pattern %q: %w", i, c.Metric, err)
+		}
+		switch c.Chart {
+		case "", "rate", "raw", "log":
+		default:
+			return nil, fmt.Errorf("display[%d]: chart %q, want rate, raw or log", i, c.Chart)
+		}
+		d := compiledDisplay{re: re, name: c.Name, hide: c.Hide, chart: c.Chart}
+		if len(c.HideLabels) > 0 {
+			d.hideLabels = map[string]bool{}
+			for _, l := range c.HideLabels {
+				d.hideLabels[l] = true
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// metricAlias is the display name for a metric: the first matching rule's
+// name, with $1-style references to the metric pattern's groups expanded.
+func metricAlias(name string) string {
+	for _, d := range globalDisplay {
+		if d.name == "" {
+			continue
+		}
+		if m := d.re.FindStringSubmatchIndex(name); m != nil {
+			return string(d.re.ExpandString(nil, d.name, name, m))
+		}
+	}
+	return name
+}
+
+func metricHidden(name string) bool {
+	for _, d := range globalDisplay {
+		if d.hide && d.re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func labelHidden(name, label string) bool {
+	for _, d := range globalDisplay {
+		if d.hideLabels[label] && d.re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func chartModeFor(name string) string {
+	for _, d := range globalDisplay {
+		if d.chart != "" && d.re.MatchString(name) {
+			return d.chart
+		}
+	}
+	return ""
+}
+
+func defaultTransform(name string) chartTransform {
+	if chartModeFor(name) == "log" {
+		return transformLog
+	}
+	return transformNone
+}
+
+// withoutHidden drops hidden metrics from names unless show is set, and
+// reports how many were dropped.
+func withoutHidden(names []string, show bool) ([]string, int) {
+	if show || len(globalDisplay) == 0 {
+		return names, 0
+	}
+	out := names[:0:0]
+	for _, n := range names {
+		if !metricHidden(n) {
+			out = append(out, n)
+		}
+	}
+	return out, len(names) - len(out)
+}
+
+func (u *uiState) toggleShowHidden() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.showHidden = !u.showHidden
+	return u.showHidden
+}
+
+func (u *uiState) showHiddenEnabled() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.showHidden
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func withDisplay(t *testing.T, rules []DisplayRule) {
+	t.Helper()
+	compiled, err := compileDisplay(rules)
+	if err != nil {
+		t.Fatalf("compileDisplay: %v", err)
+	}
+	old := globalDisplay
+	globalDisplay = compiled
+	t.Cleanup(func() { globalDisplay = old })
+}
+
+func TestCompileDisplayErrors(t *testing.T) {
+	bad := []DisplayRule{
+		{Name: "x"},
+		{Metric: "("},
+		{Metric: "x", Chart: "bar"},
+	}
+	for _, b := range bad {
+		if _, err := compileDisplay([]DisplayRule{b}); err == nil {
+			t.Errorf("compileDisplay(%+v) should fail", b)
+		}
+	}
+}
+
+func TestMetricAlias(t *testing.T) {
+	withDisplay(t, []DisplayRule{
+		{Metric: "^go_memstats_alloc_bytes$", Name: "heap alloc"},
+		{Metric: "^node_(.+)_bytes$", Name: "node ${1}"},
+		{Metric: "^node_", Hide: true},
+	})
+	tests := map[string]string{
+		"go_memstats_alloc_bytes":       "heap alloc",
+		"node_memory_MemFree_bytes":     "node memory_MemFree",
+		"go_memstats_alloc_bytes_total": "go_memstats_alloc_bytes_total",
+	}
+	for in, want := range tests {
+		if got := metricAlias(in); got != want {
+			t.Errorf("metricAlias(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWithoutHidden(t *testing.T) {
+	withDisplay(t, []DisplayRule{{Metric: "^go_gc_", Hide: true}})
+	names := []string{"go_gc_duration_seconds", "go_goroutines", "go_gc_cycles_total"}
+	got, n := withoutHidden(names, false)
+	if !reflect.DeepEqual(got, []string{"go_goroutines"}) || n != 2 {
+		t.Errorf("withoutHidden = %v, %d", got, n)
+	}
+	if got, n := withoutHidden(names, true); len(got) != 3 || n != 0 {
+		t.Errorf("withoutHidden(show) = %v, %d", got, n)
+	}
+	if len(names) != 3 || names[1] != "go_goroutines" {
+		t.Errorf("input modified: %v", names)
+	}
+}
+
+func TestDisplayNameHidesLabels(t *testing.T) {
+	withDisplay(t, []DisplayRule{{Metric: "^kube_pod_info$", Name: "pod info", HideLabels: []string{"uid", "pod_template_hash"}}})
+	s := &metricSeries{name: "kube_pod_info", labels: map[string]string{"pod": "a", "uid": "123", "pod_template_hash": "x"}}
+	if got := s.displayName(); got != `pod info{pod="a"}` {
+		t.Errorf("displayName() = %q", got)
+	}
+	if got := labelKeys([]*metricSeries{s}); !reflect.DeepEqual(got, []string{"pod"}) {
+		t.Errorf("labelKeys = %v", got)
+	}
+	other := &metricSeries{name: "up", labels: map[string]string{"uid": "1"}}
+	if got := other.displayName(); got != `up{uid="1"}` {
+		t.Errorf("unrelated displayName() = %q", got)
+	}
+}
+
+func TestChartModeDefaults(t *testing.T) {
+	withDisplay(t, []DisplayRule{
+		{Metric: "^process_start_total$", Chart: "raw"},
+		{Metric: "^queue_depth$", Chart: "rate"},
+		{Metric: "^latency_seconds$", Chart: "log"},
+	})
+	if shouldRateType("process_start_total", "counter") {
+		t.Error("chart: raw should stop counters being rated")
+	}
+	if !shouldRateType("queue_depth", "gauge") {
+		t.Error("chart: rate should rate a gauge")
+	}
+
+	ui := &uiState{}
+	if tf := ui.transformFor("latency_seconds"); tf != transformLog {
+		t.Errorf("transformFor = %v, want log10 default", tf)
+	}
+	ui.setTransform("latency_seconds", transformNone)
+	if tf := ui.transformFor("latency_seconds"); tf != transformNone {
+		t.Errorf("after choosing none, transformFor = %v", tf)
+	}
+	ui.setTransform("latency_seconds", transformLog)
+	if tf := ui.transformFor("latency_seconds"); tf != transformLog {
+		t.Errorf("after restoring, transformFor = %v", tf)
+	}
+}
+
+func TestTransformLog(t *testing.T) {
+	out, _ := applyTransform(transformLog, []float64{100, 0, -1, 1}, nil)
+	if out[0] != 2 || !math.IsNaN(out[1]) || !math.IsNaN(out[2]) || out[3] != 0 {
+		t.Errorf("log10 = %v", out)
+	}
+}
+
+func TestDisplayFromPatternsFile(t *testing.T) {
+	cfg, err := loadUnitsConfig([]byte(`
+display:
+  - metric: "^go_memstats_alloc_bytes$"
+    name: heap alloc
+    hide_labels: [instance]
+    chart: raw
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := mergeUnits(&UnitsConfig{}, cfg)
+	if len(merged.Display) != 1 || merged.Display[0].Name != "heap alloc" || merged.Display[0].Chart != "raw" {
+		t.Errorf("merged display = %+v", merged.Display)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envoyScheme      = "envoy"
+	defaultEnvoyPort = "15000"
+	envoyStatsPath   = "/stats?format=prometheus"
+	envoyClusterPath = "/clusters"
+
+	envoyClusterLabel = "envoy_cluster_name"
+	upstreamHostLabel = "upstream_host"
+	envoyHostPrefix   = "envoy_cluster_upstream_host_"
+	envoyHostHelp     = "Per upstream host stat from the Envoy admin /clusters endpoint."
+)
+
+// envoyHostStats maps the per-host stats of /clusters to their metric type;
+// the rest (weight, region, ...) are not charted.
+var envoyHostStats = map[string]string{
+	"cx_active":       "gauge",
+	"rq_active":       "gauge",
+	"cx_total":        "counter",
+	"cx_connect_fail": "counter",
+	"rq_total":        "counter",
+	"rq_success":      "counter",
+	"rq_error":        "counter",
+	"rq_timeout":      "counter",
+	"success_rate":    "gauge",
+}
+
+// scrapeEnvoyClusters adds the per upstream host stats of an envoy:// target
+// to a scrape, labelled with the cluster and host they belong to, so that a
+// failing endpoint behind a cluster stands out. A failed request leaves the
+// scrape of /stats as it was.
+func scrapeEnvoyClusters(client *http.Client, tgt target, st *store, now time.Time) {
+	req, err := newScrapeRequest(tgt)
+	if err != nil {
+		return
+	}
+	req.URL.Path, req.URL.RawQuery = envoyClusterPath, ""
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return
+	}
+	parseEnvoyClusters(bufio.NewScanner(limitBody(resp.Body, maxScrapeBytes)), func(name string, labels map[string]string, mtype string, val float64) {
+		labels = tgt.attachLabels(labels)
+		name, labels, keep := applyRelabel(globalRelabel, tgt.addr, name, labels)
+		if keep {
+			st.ingest(tgt.key(), name, labels, envoyHostHelp, mtype, val, now)
+		}
+	})
+}
+
+// parseEnvoyClusters reads the text form of /clusters, lines of
+// cluster::host:port::stat::value, into envoy_cluster_upstream_host_<stat>
+// samples plus a 0/1 envoy_cluster_upstream_host_healthy from health_flags.
+// Cluster-wide lines (cluster::default_priority::...) are skipped, and an
+// unknown success_rate (-1) is left out.
+func parseEnvoyClusters(sc *bufio.Scanner, fn func(name string, labels map[string]string, mtype string, val float64)) {
+	for sc.Scan() {
+		line := sc.Text()
+		cluster, rest, ok := strings.Cut(line, "::")
+		if !ok {
+			continue
+		}
+		i := strings.LastIndex(rest, "::")
+		if i < 0 {
+			continue
+		}
+		rest, value := rest[:i], rest[i+2:]
+		i = strings.LastIndex(rest, "::")
+		if i < 0 {
+			continue
+		}
+		host, stat := rest[:i], rest[i+2:]
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			continue
+		}
+		labels := map[string]string{envoyClusterLabel: cluster, upstreamHostLabel: host}
+		if stat == "health_flags" {
+			healthy := 0.0
+			if value == "healthy" {
+				healthy = 1
+			}
+			fn(envoyHostPrefix+"healthy", labels, "gauge", healthy)
+			continue
+		}
+		mtype, ok := envoyHostStats[stat]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || stat == "success_rate" && v < 0 {
+			continue
+		}
+		fn(envoyHostPrefix+stat, labels, mtype, v)
+	}
+}
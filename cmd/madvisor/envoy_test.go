@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const envoyClusters = `outbound|9080||reviews.default.svc.cluster.local::default_priority::max_connections::1024
+outbound|9080||reviews.default.svc.cluster.local::added_via_api::true
+outbound|9080||reviews.default.svc.cluster.local::10.1.2.3:9080::cx_active::2
+outbound|9080||reviews.default.svc.cluster.local::10.1.2.3:9080::rq_error::7
+outbound|9080||reviews.default.svc.cluster.local::10.1.2.3:9080::health_flags::/failed_outlier_check
+outbound|9080||reviews.default.svc.cluster.local::10.1.2.3:9080::success_rate::-1
+outbound|9080||reviews.default.svc.cluster.local::10.1.2.3:9080::region::
+backend::[fd00::1]:8080::health_flags::healthy
+backend::[fd00::1]:8080::success_rate::99.5
+`
+
+func TestParseEnvoyClusters(t *testing.T) {
+	var got []string
+	parseEnvoyClusters(bufio.NewScanner(strings.NewReader(envoyClusters)), func(name string, labels map[string]string, mtype string, val float64) {
+		got = append(got, fmt.Sprintf("%s{%s,%s} %s %g", name, labels[envoyClusterLabel], labels[upstreamHostLabel], mtype, val))
+	})
+	want := []string{
+		"envoy_cluster_upstream_host_cx_active{outbound|9080||reviews.default.svc.cluster.local,10.1.2.3:9080} gauge 2",
+		"envoy_cluster_upstream_host_rq_error{outbound|9080||reviews.default.svc.cluster.local,10.1.2.3:9080} counter 7",
+		"envoy_cluster_upstream_host_healthy{outbound|9080||reviews.default.svc.cluster.local,10.1.2.3:9080} gauge 0",
+		"envoy_cluster_upstream_host_healthy{backend,[fd00::1]:8080} gauge 1",
+		"envoy_cluster_upstream_host_success_rate{backend,[fd00::1]:8080} gauge 99.5",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parseEnvoyClusters =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestScrapeEnvoyTarget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stats":
+			if r.URL.Query().Get("format") != "prometheus" {
+				http.Error(w, "text stats", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, `envoy_cluster_upstream_cx_active{envoy_cluster_name="backend"} 3`)
+		case "/clusters":
+			fmt.Fprint(w, envoyClusters)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	tgt, err := parseTarget("envoy://" + strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := newStore()
+	scrapeTarget(&http.Client{Timeout: time.Second}, tgt, st)
+	if h := st.targetHealth(tgt.key()); h.ok != 1 {
+		t.Fatalf("scrape failed: %s", h.lastErr)
+	}
+	if n := st.seriesCount("envoy_cluster_upstream_cx_active"); n != 1 {
+		t.Errorf("stats series = %d, want 1", n)
+	}
+	if n := st.seriesCount("envoy_cluster_upstream_host_healthy"); n != 2 {
+		t.Errorf("upstream host health series = %d, want one per host", n)
+	}
+}
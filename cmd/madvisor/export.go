@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+const (
+	exportWidth  = 10 * vg.Inch
+	exportHeight = 5 * vg.Inch
+)
+
+var exportPalette = []color.Color{
+	color.RGBA{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	color.RGBA{R: 0x17, G: 0xbe, B: 0xcf, A: 0xff},
+	color.RGBA{R: 0xe3, G: 0x77, B: 0xc2, A: 0xff},
+	color.RGBA{R: 0xbc, G: 0xbd, B: 0x22, A: 0xff},
+	color.RGBA{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	color.RGBA{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	color.RGBA{R: 0x7f, G: 0x7f, B: 0x7f, A: 0xff},
+}
+
+type seriesStats struct {
+	min, max, avg, last float64
+	n                   int
+}
+
+func statsOf(data []float64) (seriesStats, bool) {
+	st := seriesStats{min: math.Inf(1), max: math.Inf(-1)}
+	sum := 0.0
+	for _, v := range data {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		st.min = math.Min(st.min, v)
+		st.max = math.Max(st.max, v)
+		sum += v
+		st.last = v
+		st.n++
+	}
+	if st.n == 0 {
+		return seriesStats{}, false
+	}
+	st.avg = sum / float64(st.n)
+	return st, true
+}
+
+type chartLine struct {
+	label  string
+	values []float64
+	times  []time.Time
+}
+
+func chartUnit(cs *metricSeries) string {
+	return chartUnitFor(cs.name, cs.shouldRate())
+}
+
+func chartUnitFor(name string, rated bool) string {
+	switch {
+	case rated:
+		return "rate/s over " + rateWindowGet().String()
+	case isTimestampMetric(name):
+		return "age (s)"
+	}
+	return strings.TrimSpace(unitSuffix(name))
+}
+
+func seriesLines(list []*metricSeries) []chartLine {
+	lines := make([]chartLine, 0, len(list))
+	for _, cs := range list {
+		data, times := chartData(cs)
+		lines = append(lines, chartLine{label: cs.displayName(), values: data, times: times})
+	}
+	return lines
+}
+
+func renderChartImage(w io.Writer, format, title, yLabel string, lines []chartLine, anns []annotation) error {
+	p := plot.New()
+	p.Title.Text = title
+	p.X.Label.Text = "time"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "15:04:05"}
+	p.Y.Label.Text = yLabel
+	p.Legend.Top = true
+	p.Legend.Left = true
+	p.Add(plotter.NewGrid())
+
+	var from, to time.Time
+	for i, cl := range lines {
+		var pts plotter.XYs
+		for j, v := range cl.values {
+			if j >= len(cl.times) || math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			t := cl.times[j]
+			pts = append(pts, plotter.XY{X: unixSeconds(t), Y: v})
+			if from.IsZero() || t.Before(from) {
+				from = t
+			}
+			if t.After(to) {
+				to = t
+			}
+		}
+		if len(pts) == 0 {
+			continue
+		}
+		line, err := plotter.NewLine(pts)
+		if err != nil {
+			return fmt.Errorf("series %s: %w", cl.label, err)
+		}
+		line.Color = exportPalette[i%len(exportPalette)]
+		line.Width = vg.Points(1.5)
+		p.Add(line)
+
+		label := cl.label
+		if s, ok := statsOf(cl.values); ok {
+			label += fmt.Sprintf("  min %s  avg %s  max %s  last %s",
+				formatGeneric(s.min), formatGeneric(s.avg), formatGeneric(s.max), formatGeneric(s.last))
+		}
+		p.Legend.Add(label, line)
+	}
+
+	for _, a := range anns {
+		if a.Time.Before(from) || a.Time.After(to) {
+			continue
+		}
+		x := unixSeconds(a.Time)
+		marker, err := plotter.NewLine(plotter.XYs{{X: x, Y: p.Y.Min}, {X: x, Y: p.Y.Max}})
+		if err != nil {
+			continue
+		}
+		marker.Color = color.Gray{Y: 0x60}
+		marker.Dashes = []vg.Length{vg.Points(4), vg.Points(3)}
+		p.Add(marker)
+		lbl, err := plotter.NewLabels(plotter.XYLabels{
+			XYs:    []plotter.XY{{X: x, Y: p.Y.Max}},
+			Labels: []string{a.Text},
+		})
+		if err == nil {
+			for i := range lbl.TextStyle {
+				lbl.TextStyle[i].Rotation = math.Pi / 2
+				lbl.TextStyle[i].XAlign = draw.XRight
+			}
+			p.Add(lbl)
+		}
+	}
+
+	wt, err := p.WriterTo(exportWidth, exportHeight, format)
+	if err != nil {
+		return err
+	}
+	_, err = wt.WriteTo(w)
+	return err
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func exportFileName(name, format string, now time.Time) string {
+	base := unsafeFileChars.ReplaceAllString(name, "_")
+	if base == "" {
+		base = "chart"
+	}
+	return fmt.Sprintf("madvisor-%s-%s.%s", base, now.Format("20060102-150405"), format)
+}
+
+func exportChart(dir, format, name string, list []*metricSeries, st *store) (string, error) {
+	path := filepath.Join(dir, exportFileName(name, format, time.Now()))
+	if err := exportChartFile(path, format, name, list, st); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func exportChartFile(path, format, name string, list []*metricSeries, st *store) error {
+	if len(list) == 0 {
+		return fmt.Errorf("nothing to export")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "csv":
+		lines := exportLines(list)
+		err = writeChartCSV(f, lines, linesAnnotations(st, lines))
+	case "json":
+		err = writeChartJSON(f, list, linesAnnotations(st, exportLines(list)))
+	default:
+		title := name
+		if len(list) == 1 {
+			title = list[0].displayName()
+		}
+		now := time.Now()
+		anns := st.annotationsBetween(now.Add(-ringSize*scrapeInterval*2), now)
+		err = renderChartImage(f, format, title, chartUnit(list[0]), seriesLines(list), anns)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// linesAnnotations are the annotations made over the span of lines.
+func linesAnnotations(st *store, lines []chartLine) []annotation {
+	var from, to time.Time
+	for _, l := range lines {
+		if len(l.times) == 0 {
+			continue
+		}
+		if first := l.times[0]; from.IsZero() || first.Before(from) {
+			from = first
+		}
+		if last := l.times[len(l.times)-1]; last.After(to) {
+			to = last
+		}
+	}
+	if from.IsZero() {
+		return nil
+	}
+	return st.annotationsBetween(from, to)
+}
+
+// writeChartCSV writes one row per sample, then one per annotation with
+// only its timestamp and text.
+func writeChartCSV(w io.Writer, lines []chartLine, anns []annotation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"series", "timestamp", "value", "annotation"})
+	for _, l := range lines {
+		for i, v := range l.values {
+			if i >= len(l.times) {
+				break
+			}
+			cw.Write([]string{l.label, formatExportTime(l.times[i]), formatExportValue(v), ""})
+		}
+	}
+	for _, a := range anns {
+		cw.Write([]string{"", formatExportTime(a.Time), "", a.Text})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportStore() *store {
+	st := newStore()
+	base := time.Now().Add(-10 * time.Second)
+	for i := 0; i < 10; i++ {
+		st.updateAt("temp_celsius", map[string]string{"zone": "a"}, "", "gauge", float64(20+i), base.Add(time.Duration(i)*time.Second))
+		st.updateAt("temp_celsius", map[string]string{"zone": "b"}, "", "gauge", float64(30-i), base.Add(time.Duration(i)*time.Second))
+	}
+	st.annotateAt("paused", base.Add(5*time.Second))
+	return st
+}
+
+func TestStatsOf(t *testing.T) {
+	s, ok := statsOf([]float64{3, math.NaN(), 1, 5})
+	if !ok || s.min != 1 || s.max != 5 || s.avg != 3 || s.last != 5 || s.n != 3 {
+		t.Errorf("statsOf = %+v, %v", s, ok)
+	}
+	if _, ok := statsOf([]float64{math.NaN()}); ok {
+		t.Error("statsOf(all NaN) should report no data")
+	}
+}
+
+func TestRenderChartImageSVG(t *testing.T) {
+	st := exportStore()
+	var buf bytes.Buffer
+	anns := st.annotationsBetween(time.Time{}, time.Now())
+	if err := renderChartImage(&buf, "svg", "temp_celsius", "", seriesLines(st.seriesForName("temp_celsius")), anns); err != nil {
+		t.Fatalf("renderChartImage: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"<svg", "temp_celsius", "zone=", "paused"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("svg output missing %q", want)
+		}
+	}
+}
+
+func TestRenderChartImagePNG(t *testing.T) {
+	st := exportStore()
+	var buf bytes.Buffer
+	if err := renderChartImage(&buf, "png", "temp", "", seriesLines(st.seriesForName("temp_celsius")), nil); err != nil {
+		t.Fatalf("renderChartImage: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("\x89PNG")) {
+		t.Error("output is not a PNG")
+	}
+}
+
+func TestExportChart(t *testing.T) {
+	st := exportStore()
+	dir := t.TempDir()
+	path, err := exportChart(dir, "svg", "temp_celsius", st.seriesForName("temp_celsius"), st)
+	if err != nil {
+		t.Fatalf("exportChart: %v", err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasSuffix(path, ".svg") {
+		t.Errorf("path = %q", path)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+		t.Errorf("exported file missing or empty: %v", err)
+	}
+
+	if _, err := exportChart(dir, "svg", "none", nil, st); err == nil {
+		t.Error("exporting an empty chart should fail")
+	}
+	if _, err := exportChart(dir, "bmp", "temp_celsius", st.seriesForName("temp_celsius"), st); err == nil {
+		t.Error("unsupported format should fail")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("failed exports should not leave files, got %d entries", len(entries))
+	}
+}
+
+func TestExportCSVAnnotations(t *testing.T) {
+	st := exportStore()
+	path := filepath.Join(t.TempDir(), "out.csv")
+	if err := exportChartFile(path, "csv", "temp_celsius", st.seriesForName("temp_celsius"), st); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if lines[0] != "series,timestamp,value,annotation" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, ",") || !strings.HasSuffix(last, ",,paused") {
+		t.Errorf("last row = %q, want the paused annotation", last)
+	}
+}
+
+func TestExportJSONAnnotations(t *testing.T) {
+	st := exportStore()
+	path := filepath.Join(t.TempDir(), "out.json")
+	if err := exportChartFile(path, "json", "temp_celsius", st.seriesForName("temp_celsius"), st); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	var got struct {
+		Annotations []struct {
+			T    string
+			Text string
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Annotations) != 1 || got.Annotations[0].Text != "paused" || got.Annotations[0].T == "" {
+		t.Errorf("annotations = %+v, want the paused annotation", got.Annotations)
+	}
+}
+
+func TestExportFileName(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	got := exportFileName("http_requests{code=200}", "png", now)
+	if got != "madvisor-http_requests_code_200_-20240501-123000.png" {
+		t.Errorf("exportFileName = %q", got)
+	}
+}
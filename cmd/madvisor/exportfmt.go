@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	exportTimeRFC3339 = "rfc3339"
+	exportTimeUnix    = "unix"
+	exportTimeUnixMs  = "unix-ms"
+)
+
+var (
+	flagExportPrecision = -1
+	flagExportTime      = exportTimeRFC3339
+)
+
+func checkExportTime(format string) error {
+	switch format {
+	case exportTimeRFC3339, exportTimeUnix, exportTimeUnixMs:
+		return nil
+	}
+	return fmt.Errorf("--export-time %q: want %s, %s or %s", format, exportTimeRFC3339, exportTimeUnix, exportTimeUnixMs)
+}
+
+// formatExportValue prints a value for CSV/JSON exports: full float64
+// precision unless --export-precision asks for fewer significant digits.
+// Unlike formatValue it never humanizes units.
+func formatExportValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', flagExportPrecision, 64)
+}
+
+func formatExportTime(t time.Time) string {
+	switch flagExportTime {
+	case exportTimeUnix:
+		return strconv.FormatFloat(unixSeconds(t), 'f', -1, 64)
+	case exportTimeUnixMs:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// exportLines is seriesLines for data exports: series are identified by
+// their full name and labels rather than the display alias, so aliases and
+// hidden labels never leak into exported data.
+func exportLines(list []*metricSeries) []chartLine {
+	lines := make([]chartLine, 0, len(list))
+	for _, cs := range list {
+		data, times := chartData(cs)
+		lines = append(lines, chartLine{label: cs.key, values: data, times: times})
+	}
+	return lines
+}
+
+type exportPoint struct {
+	T json.RawMessage `json:"t"`
+	V json.RawMessage `json:"v"`
+}
+
+type exportSeries struct {
+	Series string            `json:"series"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Unit   string            `json:"unit,omitempty"`
+	Points []exportPoint     `json:"points"`
+}
+
+type exportAnnotation struct {
+	T    json.RawMessage `json:"t"`
+	Text string          `json:"text"`
+}
+
+// exportTimeJSON is formatExportTime as a JSON value.
+func exportTimeJSON(t time.Time) json.RawMessage {
+	s := formatExportTime(t)
+	if flagExportTime == exportTimeRFC3339 {
+		s = strconv.Quote(s)
+	}
+	return json.RawMessage(s)
+}
+
+// writeChartJSON writes the chart's series with their points, and the
+// annotations made over them; NaN and infinite values, which JSON cannot
+// carry, are left out.
+func writeChartJSON(w io.Writer, list []*metricSeries, anns []annotation) error {
+	out := struct {
+		Series      []exportSeries     `json:"series"`
+		Annotations []exportAnnotation `json:"annotations,omitempty"`
+	}{Series: []exportSeries{}}
+	for i, l := range exportLines(list) {
+		s := exportSeries{Series: l.label, Name: list[i].name, Labels: list[i].labels, Unit: chartUnit(list[i]), Points: []exportPoint{}}
+		for j, v := range l.values {
+			if j >= len(l.times) {
+				break
+			}
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			s.Points = append(s.Points, exportPoint{T: exportTimeJSON(l.times[j]), V: json.RawMessage(formatExportValue(v))})
+		}
+		out.Series = append(out.Series, s)
+	}
+	for _, a := range anns {
+		out.Annotations = append(out.Annotations, exportAnnotation{T: exportTimeJSON(a.Time), Text: a.Text})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
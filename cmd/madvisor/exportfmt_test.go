@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withExportOptions(t *testing.T, precision int, timeFormat string) {
+	t.Helper()
+	p, f := flagExportPrecision, flagExportTime
+	t.Cleanup(func() { flagExportPrecision, flagExportTime = p, f })
+	flagExportPrecision, flagExportTime = precision, timeFormat
+}
+
+func TestFormatExportValue(t *testing.T) {
+	withExportOptions(t, -1, exportTimeRFC3339)
+	if got := formatExportValue(1234567.891234567); got != "1.234567891234567e+06" {
+		t.Errorf("full precision = %q", got)
+	}
+	flagExportPrecision = 3
+	if got := formatExportValue(1234567.891234567); got != "1.23e+06" {
+		t.Errorf("3 digits = %q", got)
+	}
+}
+
+func TestFormatExportTime(t *testing.T) {
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.FixedZone("CET", 3600))
+	tests := map[string]string{
+		exportTimeRFC3339: "2026-03-01T11:00:00.5Z",
+		exportTimeUnix:    "1772362800.5",
+		exportTimeUnixMs:  "1772362800500",
+	}
+	for format, want := range tests {
+		withExportOptions(t, -1, format)
+		if got := formatExportTime(ts); got != want {
+			t.Errorf("%s: got %q, want %q", format, got, want)
+		}
+	}
+	if err := checkExportTime("iso"); err == nil {
+		t.Error("checkExportTime(iso) should fail")
+	}
+}
+
+func TestExportIgnoresDisplayRules(t *testing.T) {
+	withExportOptions(t, -1, exportTimeRFC3339)
+	old := globalDisplay
+	defer func() { globalDisplay = old }()
+	globalDisplay, _ = compileDisplay([]DisplayRule{{Metric: "^temp_celsius$", Name: "Temperature", HideLabels: []string{"sensor"}}})
+
+	st := newStore()
+	now := time.Now()
+	labels := map[string]string{"sensor": "a"}
+	st.updateAt("temp_celsius", labels, "", "gauge", 21.123456789012, now.Add(-time.Second))
+	st.updateAt("temp_celsius", labels, "", "gauge", 21.5, now)
+	list := st.seriesForName("temp_celsius")
+
+	var csvBuf bytes.Buffer
+	if err := writeChartCSV(&csvBuf, exportLines(list), nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(csvBuf.String(), "temp_celsius{sensor=a},") || !strings.Contains(csvBuf.String(), ",21.123456789012,\n") {
+		t.Errorf("csv = %q, want the raw series key and full precision", csvBuf.String())
+	}
+
+	var jsonBuf bytes.Buffer
+	if err := writeChartJSON(&jsonBuf, list, nil); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Series []struct {
+			Series string
+			Labels map[string]string
+			Points []struct {
+				T string
+				V float64
+			}
+		}
+	}
+	if err := json.Unmarshal(jsonBuf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", jsonBuf.String(), err)
+	}
+	if len(got.Series) != 1 || got.Series[0].Labels["sensor"] != "a" || len(got.Series[0].Points) != 2 || got.Series[0].Points[0].V != 21.123456789012 {
+		t.Errorf("json = %s", jsonBuf.String())
+	}
+	if _, err := time.Parse(time.RFC3339Nano, got.Series[0].Points[0].T); err != nil {
+		t.Errorf("timestamp %q is not RFC3339: %v", got.Series[0].Points[0].T, err)
+	}
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exprSource is the store source of expression results. Selectors skip it,
+// so a result never feeds back into the expression that produced it.
+const (
+	exprSource = "expr"
+	exprHelp   = "ad-hoc expression (temporary)"
+)
+
+// exprQuery is a parsed PromQL-lite expression: selectors, rate(), sum, avg,
+// min, max and count by, and + - * / between vectors and scalars.
+type exprQuery struct {
+	text string
+	root exprNode
+}
+
+// name is the metric the result is recorded under: the expression text
+// behind "= ", so a bare selector does not collide with the series it reads
+// and results sort together at the top of the metric list.
+func (q *exprQuery) name() string {
+	return "= " + q.text
+}
+
+type exprSample struct {
+	labels map[string]string
+	value  float64
+}
+
+// exprValue is a node's result: num for scalar nodes, vec for vector ones.
+type exprValue struct {
+	num float64
+	vec []exprSample
+}
+
+type exprNode interface {
+	vector() bool
+	eval(series []*metricSeries) exprValue
+}
+
+type exprNumber float64
+
+func (exprNumber) vector() bool { return false }
+
+func (e exprNumber) eval([]*metricSeries) exprValue { return exprValue{num: float64(e)} }
+
+type exprMatcher struct {
+	label string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m exprMatcher) matches(v string) bool {
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	default:
+		return !m.re.MatchString(v)
+	}
+}
+
+type exprSelector struct {
+	name     string
+	matchers []exprMatcher
+}
+
+func (*exprSelector) vector() bool { return true }
+
+// selectSeries returns the live series e matches, leaving out expression
+// results and series ended by a staleness marker.
+func (e *exprSelector) selectSeries(series []*metricSeries) []*metricSeries {
+	var out []*metricSeries
+	for _, s := range series {
+		if s.source == exprSource || s.count() == 0 || !s.endedAt.IsZero() {
+			continue
+		}
+		if e.name != "" && s.name != e.name {
+			continue
+		}
+		ok := true
+		for _, m := range e.matchers {
+			v := s.labels[m.label]
+			if m.label == "__name__" {
+				v = s.name
+			}
+			if !m.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (e *exprSelector) eval(series []*metricSeries) exprValue {
+	var v exprValue
+	for _, s := range e.selectSeries(series) {
+		v.vec = append(v.vec, exprSample{labels: copyExprLabels(s.labels), value: s.last()})
+	}
+	return v
+}
+
+// exprRate is rate(selector[window]); without a window it follows the
+// dashboard's rate window.
+type exprRate struct {
+	sel    *exprSelector
+	window time.Duration
+}
+
+func (*exprRate) vector() bool { return true }
+
+func (e *exprRate) eval(series []*metricSeries) exprValue {
+	window := e.window
+	if window == 0 {
+		window = rateWindowGet()
+	}
+	var v exprValue
+	for _, s := range e.sel.selectSeries(series) {
+		if s.count() < 2 {
+			continue
+		}
+		v.vec = append(v.vec, exprSample{labels: copyExprLabels(s.labels), value: s.rate(window)})
+	}
+	return v
+}
+
+type exprAggregate struct {
+	op  string
+	by  []string
+	arg exprNode
+}
+
+func (*exprAggregate) vector() bool { return true }
+
+func (e *exprAggregate) eval(series []*metricSeries) exprValue {
+	type group struct {
+		labels        map[string]string
+		sum, min, max float64
+		n             int
+	}
+	groups := map[string]*group{}
+	var order []string
+	for _, s := range e.arg.eval(series).vec {
+		labels := map[string]string{}
+		for _, l := range e.by {
+			if v, ok := s.labels[l]; ok {
+				labels[l] = v
+			}
+		}
+		key := seriesKey("", labels)
+		g := groups[key]
+		if g == nil {
+			g = &group{labels: labels, min: s.value, max: s.value}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.sum += s.value
+		g.min = math.Min(g.min, s.value)
+		g.max = math.Max(g.max, s.value)
+		g.n++
+	}
+	var v exprValue
+	for _, key := range order {
+		g := groups[key]
+		val := g.sum
+		switch e.op {
+		case "avg":
+			val = g.sum / float64(g.n)
+		case "min":
+			val = g.min
+		case "max":
+			val = g.max
+		case "count":
+			val = float64(g.n)
+		}
+		v.vec = append(v.vec, exprSample{labels: g.labels, value: val})
+	}
+	return v
+}
+
+// exprBinary applies op between two operands. Two vectors are matched one to
+// one on identical label sets; samples without a match are dropped.
+type exprBinary struct {
+	op       byte
+	lhs, rhs exprNode
+}
+
+func (e *exprBinary) vector() bool { return e.lhs.vector() || e.rhs.vector() }
+
+func (e *exprBinary) eval(series []*metricSeries) exprValue {
+	l, r := e.lhs.eval(series), e.rhs.eval(series)
+	var v exprValue
+	switch {
+	case !e.lhs.vector() && !e.rhs.vector():
+		v.num = exprArith(e.op, l.num, r.num)
+	case !e.rhs.vector():
+		for _, s := range l.vec {
+			v.vec = append(v.vec, exprSample{labels: s.labels, value: exprArith(e.op, s.value, r.num)})
+		}
+	case !e.lhs.vector():
+		for _, s := range r.vec {
+			v.vec = append(v.vec, exprSample{labels: s.labels, value: exprArith(e.op, l.num, s.value)})
+		}
+	default:
+		right := map[string]float64{}
+		for _, s := range r.vec {
+			key := seriesKey("", s.labels)
+			if _, dup := right[key]; !dup {
+				right[key] = s.value
+			}
+		}
+		for _, s := range l.vec {
+			if rv, ok := right[seriesKey("", s.labels)]; ok {
+				v.vec = append(v.vec, exprSample{labels: s.labels, value: exprArith(e.op, s.value, rv)})
+			}
+		}
+	}
+	return v
+}
+
+func exprArith(op byte, a, b float64) float64 {
+	switch op {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	default:
+		return a / b
+	}
+}
+
+func copyExprLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+// --- parsing ---
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprIdent
+	exprNum
+	exprString
+	exprRange
+	exprPunct
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+var exprAggregates = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+func isExprIdentStart(c byte) bool {
+	return c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isExprIdent(c byte) bool {
+	return isExprIdentStart(c) || c >= '0' && c <= '9'
+}
+
+func lexExpr(s string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isExprIdentStart(c):
+			j := i + 1
+			for j < len(s) && isExprIdent(s[j]) {
+				j++
+			}
+			toks = append(toks, exprToken{exprIdent, s[i:j], i})
+			i = j
+		case c >= '0' && c <= '9' || c == '.':
+			j := i + 1
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || s[j] == 'e' || s[j] == 'E' ||
+				(s[j] == '+' || s[j] == '-') && (s[j-1] == 'e' || s[j-1] == 'E')) {
+				j++
+			}
+			toks = append(toks, exprToken{exprNum, s[i:j], i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at column %d", i+1)
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at column %d", i+1)
+			}
+			toks = append(toks, exprToken{exprString, v, i})
+			i = j + 1
+		case c == '[':
+			j := strings.IndexByte(s[i:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated range at column %d", i+1)
+			}
+			toks = append(toks, exprToken{exprRange, strings.TrimSpace(s[i+1 : i+j]), i})
+			i += j + 1
+		case strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], "=~") || strings.HasPrefix(s[i:], "!~"):
+			toks = append(toks, exprToken{exprPunct, s[i : i+2], i})
+			i += 2
+		case strings.IndexByte("(){},+-*/=", c) >= 0:
+			toks = append(toks, exprToken{exprPunct, s[i : i+1], i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q at column %d", c, i+1)
+		}
+	}
+	return append(toks, exprToken{kind: exprEOF, pos: len(s)}), nil
+}
+
+type exprParser struct {
+	toks []exprToken
+	pos  int
+}
+
+func (p *exprParser) peek() exprToken { return p.toks[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.pos]
+	if t.kind != exprEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) punct(text string) bool {
+	if t := p.peek(); t.kind == exprPunct && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.punct(text) {
+		return p.unexpected(fmt.Sprintf("%q", text))
+	}
+	return nil
+}
+
+func (p *exprParser) unexpected(want string) error {
+	t := p.peek()
+	if t.kind == exprEOF {
+		return fmt.Errorf("unexpected end of expression, want %s", want)
+	}
+	return fmt.Errorf("unexpected %q at column %d, want %s", t.text, t.pos+1, want)
+}
+
+// parseExpr parses a PromQL-lite expression.
+func parseExpr(s string) (*exprQuery, error) {
+	s = strings.TrimSpace(s)
+	toks, err := lexExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("expression: %w", err)
+	}
+	p := &exprParser{toks: toks}
+	root, err := p.additive()
+	if err == nil && p.peek().kind != exprEOF {
+		err = p.unexpected("an operator")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression: %w", err)
+	}
+	return &exprQuery{text: s, root: root}, nil
+}
+
+func (p *exprParser) additive() (exprNode, error) {
+	lhs, err := p.multiplicative()
+	for err == nil {
+		op := p.peek()
+		if !p.punct("+") && !p.punct("-") {
+			return lhs, nil
+		}
+		var rhs exprNode
+		if rhs, err = p.multiplicative(); err == nil {
+			lhs = &exprBinary{op: op.text[0], lhs: lhs, rhs: rhs}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) multiplicative() (exprNode, error) {
+	lhs, err := p.unary()
+	for err == nil {
+		op := p.peek()
+		if !p.punct("*") && !p.punct("/") {
+			return lhs, nil
+		}
+		var rhs exprNode
+		if rhs, err = p.unary(); err == nil {
+			lhs = &exprBinary{op: op.text[0], lhs: lhs, rhs: rhs}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	switch {
+	case p.punct("-"):
+		arg, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprBinary{op: '-', lhs: exprNumber(0), rhs: arg}, nil
+	case p.punct("+"):
+		return p.unary()
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	t := p.peek()
+	switch {
+	case t.kind == exprNum:
+		p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at column %d", t.text, t.pos+1)
+		}
+		return exprNumber(v), nil
+	case p.punct("("):
+		e, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case t.kind == exprIdent && t.text == "rate":
+		p.next()
+		return p.rate()
+	case t.kind == exprIdent && exprAggregates[t.text]:
+		p.next()
+		return p.aggregate(t.text)
+	}
+	return p.selector()
+}
+
+func (p *exprParser) selector() (*exprSelector, error) {
+	sel := &exprSelector{}
+	if t := p.peek(); t.kind == exprIdent && t.text != "rate" && !exprAggregates[t.text] {
+		sel.name = p.next().text
+	}
+	if !p.punct("{") {
+		if sel.name == "" {
+			return nil, p.unexpected("a metric name, number or \"(\"")
+		}
+		return sel, nil
+	}
+	for !p.punct("}") {
+		label := p.peek()
+		if label.kind != exprIdent {
+			return nil, p.unexpected("a label name")
+		}
+		p.next()
+		op := p.peek()
+		if !p.punct("=") && !p.punct("!=") && !p.punct("=~") && !p.punct("!~") {
+			return nil, p.unexpected("=, !=, =~ or !~")
+		}
+		value := p.peek()
+		if value.kind != exprString {
+			return nil, p.unexpected("a quoted label value")
+		}
+		p.next()
+		m := exprMatcher{label: label.text, op: op.text, value: value.text}
+		if op.text == "=~" || op.text == "!~" {
+			re, err := regexp.Compile("^(?:" + value.text + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q at column %d: %w", value.text, value.pos+1, err)
+			}
+			m.re = re
+		}
+		sel.matchers = append(sel.matchers, m)
+		if !p.punct(",") && p.peek().text != "}" {
+			return nil, p.unexpected("\",\" or \"}\"")
+		}
+	}
+	if sel.name == "" && len(sel.matchers) == 0 {
+		return nil, fmt.Errorf("selector needs a metric name or a label matcher")
+	}
+	return sel, nil
+}
+
+func (p *exprParser) rate() (exprNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	sel, err := p.selector()
+	if err != nil {
+		return nil, err
+	}
+	e := &exprRate{sel: sel}
+	if t := p.peek(); t.kind == exprRange {
+		p.next()
+		d, err := time.ParseDuration(t.text)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid range %q at column %d", t.text, t.pos+1)
+		}
+		e.window = d
+	}
+	return e, p.expect(")")
+}
+
+func (p *exprParser) aggregate(op string) (exprNode, error) {
+	e := &exprAggregate{op: op}
+	by, err := p.by()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if e.arg, err = p.additive(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if !e.arg.vector() {
+		return nil, fmt.Errorf("%s expects a vector, not a number", op)
+	}
+	if by == nil {
+		if by, err = p.by(); err != nil {
+			return nil, err
+		}
+	}
+	e.by = by
+	return e, nil
+}
+
+// by parses an optional "by (label, ...)" clause.
+func (p *exprParser) by() ([]string, error) {
+	if t := p.peek(); t.kind != exprIdent || t.text != "by" {
+		return nil, nil
+	}
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for !p.punct(")") {
+		t := p.peek()
+		if t.kind != exprIdent {
+			return nil, p.unexpected("a label name")
+		}
+		p.next()
+		labels = append(labels, t.text)
+		if !p.punct(",") && p.peek().text != ")" {
+			return nil, p.unexpected("\",\" or \")\"")
+		}
+	}
+	return labels, nil
+}
+
+// --- evaluation ---
+
+// setExpr replaces the charted expression, or clears it when q is nil, and
+// drops the replaced expression's temporary series. It returns how many
+// were dropped.
+func (st *store) setExpr(q *exprQuery) int {
+	st.exprMu.Lock()
+	defer st.exprMu.Unlock()
+	old := st.expr
+	st.expr = q
+	if old == nil {
+		return 0
+	}
+	return st.dropSeries(func(s *metricSeries) bool {
+		return s.source == exprSource && s.name == old.name()
+	})
+}
+
+func (st *store) activeExpr() *exprQuery {
+	st.exprMu.Lock()
+	defer st.exprMu.Unlock()
+	return st.expr
+}
+
+// evalExpr evaluates the charted expression against the latest samples and
+// records the result at t. Non-finite results, such as a division by zero,
+// are skipped rather than charted. It returns how many series were recorded.
+func (st *store) evalExpr(t time.Time) int {
+	st.exprMu.Lock()
+	defer st.exprMu.Unlock()
+	q := st.expr
+	if q == nil {
+		return 0
+	}
+	st.mu.RLock()
+	series := make([]*metricSeries, 0, len(st.order))
+	for _, k := range st.order {
+		series = append(series, st.series[k])
+	}
+	res := q.root.eval(series)
+	st.mu.RUnlock()
+
+	if !q.root.vector() {
+		res.vec = []exprSample{{value: res.num}}
+	}
+	n := 0
+	for _, s := range res.vec {
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		st.ingest(exprSource, q.name(), s.labels, exprHelp, "gauge", s.value, t)
+		n++
+	}
+	return n
+}
+
+// runExpr re-evaluates the charted expression every scrape interval.
+func (st *store) runExpr(ctx context.Context) {
+	ticker := time.NewTicker(scrapeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			st.evalExpr(now)
+		}
+	}
+}
+
+// exprMessage reports a newly charted expression in the status bar.
+func exprMessage(q *exprQuery, n int) string {
+	if n == 0 {
+		return q.name() + ": no series yet, re-evaluated every scrape"
+	}
+	return fmt.Sprintf("%s: %d series", q.name(), n)
+}
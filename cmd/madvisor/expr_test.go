@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func exprStore(now time.Time) *store {
+	st := newStore()
+	for i, v := range []float64{0, 60} {
+		at := now.Add(time.Duration(i-1) * time.Minute)
+		st.ingest("a:1", "http_requests_total", map[string]string{"code": "200", "pod": "a"}, "", "counter", v, at)
+		st.ingest("a:1", "http_requests_total", map[string]string{"code": "500", "pod": "a"}, "", "counter", v/2, at)
+		st.ingest("b:1", "http_requests_total", map[string]string{"code": "200", "pod": "b"}, "", "counter", v*2, at)
+	}
+	st.ingest("a:1", "mem_bytes", map[string]string{"pod": "a"}, "", "gauge", 100, now)
+	st.ingest("b:1", "mem_bytes", map[string]string{"pod": "b"}, "", "gauge", 300, now)
+	st.ingest("a:1", "mem_limit_bytes", map[string]string{"pod": "a"}, "", "gauge", 400, now)
+	return st
+}
+
+func evalExprString(t *testing.T, st *store, s string) map[string]float64 {
+	t.Helper()
+	q, err := parseExpr(s)
+	if err != nil {
+		t.Fatalf("parseExpr(%q): %v", s, err)
+	}
+	st.setExpr(q)
+	st.evalExpr(time.Now())
+	out := map[string]float64{}
+	for _, s := range st.seriesForName(q.name()) {
+		out[seriesKey("", s.labels)] = s.last()
+	}
+	return out
+}
+
+func TestEvalExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want map[string]float64
+	}{
+		{`mem_bytes`, map[string]float64{"{pod=a}": 100, "{pod=b}": 300}},
+		{`mem_bytes{pod!="a"} / 1e2`, map[string]float64{"{pod=b}": 3}},
+		{`http_requests_total{code=~"2.."}`, map[string]float64{"{code=200,pod=a}": 60, "{code=200,pod=b}": 120}},
+		{`rate(http_requests_total{code="500"}[5m])`, map[string]float64{"{code=500,pod=a}": 0.5}},
+		{`sum by (code) (rate(http_requests_total[5m]))`, map[string]float64{"{code=200}": 3, "{code=500}": 0.5}},
+		{`sum(rate(http_requests_total[5m])) by (pod)`, map[string]float64{"{pod=a}": 1.5, "{pod=b}": 2}},
+		{`avg(mem_bytes)`, map[string]float64{"": 200}},
+		{`max by (pod) (http_requests_total)`, map[string]float64{"{pod=a}": 60, "{pod=b}": 120}},
+		{`count({__name__=~"mem_.*"})`, map[string]float64{"": 3}},
+		{`mem_bytes / mem_limit_bytes * 100`, map[string]float64{"{pod=a}": 25}},
+		{`-(mem_bytes - 2 * 50)`, map[string]float64{"{pod=a}": 0, "{pod=b}": -200}},
+		{`1 + 2 * 3`, map[string]float64{"": 7}},
+		{`mem_bytes / 0`, map[string]float64{}},
+		{`missing_total`, map[string]float64{}},
+	}
+	for _, tt := range tests {
+		st := exprStore(time.Now())
+		got := evalExprString(t, st, tt.expr)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if g, ok := got[k]; !ok || g != v {
+				t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEvalExprDefaultWindow(t *testing.T) {
+	old := rateWindowGet()
+	defer rateWindowSet(old)
+	rateWindowSet(time.Minute)
+	st := exprStore(time.Now())
+	got := evalExprString(t, st, "rate(http_requests_total{pod=\"b\"})")
+	if got["{code=200,pod=b}"] != 2 {
+		t.Errorf("rate without a range = %v, want 2/s over the rate window", got)
+	}
+}
+
+func TestSetExprDropsResults(t *testing.T) {
+	st := exprStore(time.Now())
+	evalExprString(t, st, "mem_bytes * 2")
+	if n := st.seriesCount("= mem_bytes * 2"); n != 2 {
+		t.Fatalf("recorded %d result series, want 2", n)
+	}
+	evalExprString(t, st, "mem_bytes * 2")
+	evalExprString(t, st, "sum(mem_bytes)")
+	if n := st.seriesCount("= mem_bytes * 2"); n != 0 {
+		t.Errorf("replaced expression kept %d series", n)
+	}
+	for _, name := range st.names() {
+		if name == "= mem_bytes * 2" {
+			t.Error("replaced expression still listed")
+		}
+	}
+	// An expression does not select its own results.
+	evalExprString(t, st, `{pod="b"}`)
+	st.evalExpr(time.Now())
+	if n := st.seriesCount(`= {pod="b"}`); n != 2 {
+		t.Errorf("re-evaluated selector recorded %d series, want the 2 scraped ones", n)
+	}
+	if n := st.setExpr(nil); n != 2 {
+		t.Errorf("clearing dropped %d series, want 2", n)
+	}
+	if st.activeExpr() != nil || st.evalExpr(time.Now()) != 0 {
+		t.Error("cleared expression still evaluated")
+	}
+	if n := st.seriesCount("mem_bytes"); n != 2 {
+		t.Errorf("scraped series dropped: %d left", n)
+	}
+}
+
+func TestExprResultsNotObserved(t *testing.T) {
+	st := exprStore(time.Now())
+	var observed []string
+	st.observe = func(s sample) { observed = append(observed, s.Name) }
+	evalExprString(t, st, "mem_bytes * 2")
+	if n := st.seriesCount("= mem_bytes * 2"); n != 2 {
+		t.Fatalf("recorded %d result series, want 2", n)
+	}
+	if len(observed) != 0 {
+		t.Errorf("observed %q, want expression results kept out of recordings and exports", observed)
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "unexpected end of expression"},
+		{"sum(1)", "sum expects a vector"},
+		{"rate(x[soon])", `invalid range "soon"`},
+		{"rate(1)", "want a metric name"},
+		{`x{a="b"`, "unexpected end of expression"},
+		{`x{a=b}`, "want a quoted label value"},
+		{`x{a=~"("}`, "invalid regex"},
+		{"x y", `unexpected "y" at column 3`},
+		{"x % 2", `unexpected '%' at column 3`},
+		{"{}", "needs a metric name or a label matcher"},
+		{"(x", `want ")"`},
+		{`x{a="b}`, "unterminated string"},
+	}
+	for _, tt := range tests {
+		_, err := parseExpr(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseExpr(%q) = %v, want error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestExprScriptCommand(t *testing.T) {
+	if _, err := parseScriptLine(1, "expr sum(rate(x[1m]"); err == nil {
+		t.Error("invalid expression accepted")
+	}
+	cmds, err := parseScript(strings.NewReader("expr sum by (pod) (mem_bytes)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := exprStore(time.Now())
+	ui := &uiState{}
+	if errs := runScript(cmds, ui, st); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if got := ui.selectedKey(); got != "= sum by (pod) (mem_bytes)" {
+		t.Errorf("selected %q, want the expression result", got)
+	}
+	if msg := ui.message; msg != "= sum by (pod) (mem_bytes): 2 series" {
+		t.Errorf("message = %q", msg)
+	}
+	cmds, _ = parseScript(strings.NewReader("expr clear\n"))
+	runScript(cmds, ui, st)
+	if n := st.seriesCount("= sum by (pod) (mem_bytes)"); n != 0 {
+		t.Errorf("expr clear kept %d series", n)
+	}
+}
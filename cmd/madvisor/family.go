@@ -0,0 +1,153 @@
+package main
+
+import "strings"
+
+var familySuffixes = []string{"_bucket", "_sum", "_count"}
+
+type familyRow struct {
+	base     string
+	suffix   string
+	header   bool
+	expanded bool
+	hidden   int
+}
+
+func familyBase(name, mtype string) (string, bool) {
+	dt := detectMetricType(name, mtype)
+	if dt != "histogram" && dt != "summary" {
+		return "", false
+	}
+	for _, suf := range familySuffixes {
+		if strings.HasSuffix(name, suf) {
+			return strings.TrimSuffix(name, suf), true
+		}
+	}
+	if dt == "summary" {
+		return name, true
+	}
+	return "", false
+}
+
+// familyLead picks the child that stands in for a collapsed family: the
+// quantiles of a summary, the observation rate of a histogram.
+func familyLead(base string, children []string) string {
+	for _, want := range []string{base, base + "_count"} {
+		for _, c := range children {
+			if c == want {
+				return c
+			}
+		}
+	}
+	return children[0]
+}
+
+// groupFamilies folds the _bucket/_sum/_count (and quantile) children of
+// each histogram or summary into a single header row at the position of the
+// family's first child. The header's key is the lead child, so selecting it
+// charts that metric; expanded families list the remaining children below.
+func groupFamilies(names []string, types map[string]string, expanded map[string]bool) ([]string, map[string]familyRow) {
+	children := map[string][]string{}
+	var bases []string
+	for _, n := range names {
+		base, ok := familyBase(n, types[n])
+		if !ok {
+			continue
+		}
+		if _, seen := children[base]; !seen {
+			bases = append(bases, base)
+		}
+		children[base] = append(children[base], n)
+	}
+	for _, base := range bases {
+		if len(children[base]) < 2 {
+			delete(children, base)
+		}
+	}
+	if len(children) == 0 {
+		return names, nil
+	}
+
+	out := make([]string, 0, len(names))
+	rows := map[string]familyRow{}
+	done := map[string]bool{}
+	for _, n := range names {
+		base, _ := familyBase(n, types[n])
+		kids, ok := children[base]
+		if !ok {
+			out = append(out, n)
+			continue
+		}
+		if done[base] {
+			continue
+		}
+		done[base] = true
+		lead := familyLead(base, kids)
+		open := expanded[base]
+		row := familyRow{base: base, suffix: strings.TrimPrefix(lead, base), header: true, expanded: open}
+		if !open {
+			row.hidden = len(kids) - 1
+		}
+		rows[lead] = row
+		out = append(out, lead)
+		if !open {
+			continue
+		}
+		for _, k := range kids {
+			if k != lead {
+				rows[k] = familyRow{base: base, suffix: strings.TrimPrefix(k, base)}
+				out = append(out, k)
+			}
+		}
+	}
+	return out, rows
+}
+
+func (st *store) nameTypes() map[string]string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	out := make(map[string]string, len(st.metricNames))
+	for _, k := range st.order {
+		s := st.series[k]
+		if _, ok := out[s.name]; !ok {
+			out[s.name] = s.detectedType()
+		}
+	}
+	return out
+}
+
+func (u *uiState) toggleFamily(base string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.expandedFamilies == nil {
+		u.expandedFamilies = map[string]bool{}
+	}
+	if u.expandedFamilies[base] {
+		delete(u.expandedFamilies, base)
+		return false
+	}
+	u.expandedFamilies[base] = true
+	return true
+}
+
+func (u *uiState) setFamilyExpanded(base string, open bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.expandedFamilies == nil {
+		u.expandedFamilies = map[string]bool{}
+	}
+	if open {
+		u.expandedFamilies[base] = true
+	} else {
+		delete(u.expandedFamilies, base)
+	}
+}
+
+func (u *uiState) expanded() map[string]bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make(map[string]bool, len(u.expandedFamilies))
+	for k, v := range u.expandedFamilies {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFamilyBase(t *testing.T) {
+	cases := []struct {
+		name, mtype, base string
+		ok                bool
+	}{
+		{"http_duration_seconds_bucket", "histogram", "http_duration_seconds", true},
+		{"http_duration_seconds_count", "histogram", "http_duration_seconds", true},
+		{"rpc_latency", "summary", "rpc_latency", true},
+		{"rpc_latency_sum", "summary", "rpc_latency", true},
+		{"requests_count", "counter", "", false},
+		{"odd_histogram", "histogram", "", false},
+	}
+	for _, c := range cases {
+		base, ok := familyBase(c.name, c.mtype)
+		if base != c.base || ok != c.ok {
+			t.Errorf("familyBase(%q, %q) = %q, %v; want %q, %v", c.name, c.mtype, base, ok, c.base, c.ok)
+		}
+	}
+}
+
+func TestGroupFamilies(t *testing.T) {
+	names := []string{"a_total", "h_bucket", "h_count", "h_sum", "s", "s_count", "s_sum", "z"}
+	types := map[string]string{
+		"a_total":  "counter",
+		"h_bucket": "histogram", "h_count": "histogram", "h_sum": "histogram",
+		"s": "summary", "s_count": "summary", "s_sum": "summary",
+		"z": "gauge",
+	}
+
+	got, rows := groupFamilies(names, types, nil)
+	if want := []string{"a_total", "h_count", "s", "z"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("collapsed = %v, want %v", got, want)
+	}
+	if r := rows["h_count"]; !r.header || r.base != "h" || r.suffix != "_count" || r.hidden != 2 {
+		t.Errorf("histogram header = %+v", r)
+	}
+	if r := rows["s"]; !r.header || r.suffix != "" || r.hidden != 2 {
+		t.Errorf("summary header = %+v", r)
+	}
+
+	got, rows = groupFamilies(names, types, map[string]bool{"h": true})
+	if want := []string{"a_total", "h_count", "h_bucket", "h_sum", "s", "z"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expanded = %v, want %v", got, want)
+	}
+	if r := rows["h_bucket"]; r.header || r.suffix != "_bucket" {
+		t.Errorf("child row = %+v", r)
+	}
+	if r := rows["h_count"]; !r.expanded || r.hidden != 0 {
+		t.Errorf("expanded header = %+v", r)
+	}
+}
+
+func TestGroupFamiliesSingleChild(t *testing.T) {
+	names := []string{"h_count", "up"}
+	types := map[string]string{"h_count": "histogram", "up": "gauge"}
+	got, rows := groupFamilies(names, types, nil)
+	if !reflect.DeepEqual(got, names) || rows != nil {
+		t.Errorf("single child = %v, %v; want names unchanged", got, rows)
+	}
+}
+
+func TestToggleFamily(t *testing.T) {
+	ui := &uiState{}
+	if !ui.toggleFamily("h") || !ui.expanded()["h"] {
+		t.Error("first toggle should expand")
+	}
+	if ui.toggleFamily("h") || ui.expanded()["h"] {
+		t.Error("second toggle should collapse")
+	}
+	ui.setFamilyExpanded("s", true)
+	ui.setFamilyExpanded("s", false)
+	if len(ui.expanded()) != 0 {
+		t.Errorf("expanded = %v, want none", ui.expanded())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type forecastModel int
+
+const (
+	forecastOff forecastModel = iota
+	forecastLinear
+	forecastHolt
+)
+
+const (
+	holtAlpha = 0.5
+	holtBeta  = 0.3
+)
+
+func (m forecastModel) String() string {
+	switch m {
+	case forecastLinear:
+		return "linear"
+	case forecastHolt:
+		return "holt"
+	}
+	return "off"
+}
+
+type forecastTarget struct {
+	label string
+	value float64
+}
+
+func fitLinear(data []float64) (level, slope float64, ok bool) {
+	var n, sx, sy, sxx, sxy float64
+	for i, v := range data {
+		if math.IsNaN(v) {
+			continue
+		}
+		x := float64(i)
+		n++
+		sx += x
+		sy += v
+		sxx += x * x
+		sxy += x * v
+	}
+	if n < 2 {
+		return 0, 0, false
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0, 0, false
+	}
+	slope = (n*sxy - sx*sy) / den
+	intercept := (sy - slope*sx) / n
+	return intercept + slope*float64(len(data)-1), slope, true
+}
+
+func fitHolt(data []float64) (level, slope float64, ok bool) {
+	prev := -1
+	for i, v := range data {
+		if math.IsNaN(v) {
+			continue
+		}
+		switch {
+		case prev < 0:
+			level = v
+		case !ok:
+			slope = (v - level) / float64(i-prev)
+			level = v
+			ok = true
+		default:
+			steps := float64(i - prev)
+			prevLevel := level
+			level = holtAlpha*v + (1-holtAlpha)*(level+slope*steps)
+			slope = holtBeta*(level-prevLevel)/steps + (1-holtBeta)*slope
+		}
+		prev = i
+	}
+	if !ok {
+		return 0, 0, false
+	}
+	return level + slope*float64(len(data)-1-prev), slope, true
+}
+
+func fitForecast(m forecastModel, data []float64) (float64, float64, bool) {
+	switch m {
+	case forecastLinear:
+		return fitLinear(data)
+	case forecastHolt:
+		return fitHolt(data)
+	}
+	return 0, 0, false
+}
+
+// The projection starts at the last sample so it joins the series, and
+// alternates pairs of points with gaps so it draws as a dotted line.
+func forecastSeries(n int, level, slope float64, horizon int) []float64 {
+	out := make([]float64, n+horizon)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	for k := 0; k <= horizon; k++ {
+		if (k/2)%2 == 0 {
+			out[n-1+k] = level + slope*float64(k)
+		}
+	}
+	return out
+}
+
+func timeToTarget(level, slope float64, step time.Duration, target float64) (time.Duration, bool) {
+	if slope == 0 || math.IsNaN(slope) {
+		return 0, false
+	}
+	steps := (target - level) / slope
+	if steps <= 0 || steps > float64(math.MaxInt64)/float64(step) {
+		return 0, false
+	}
+	return time.Duration(steps * float64(step)), true
+}
+
+func forecastTargets(rules []compiledThreshold, name string, watches []*watch) []forecastTarget {
+	lines, ranges := thresholdsFor(rules, name)
+	var out []forecastTarget
+	for _, l := range lines {
+		out = append(out, forecastTarget{label: thresholdLabel(l.Label, l.Value), value: l.Value})
+	}
+	for _, b := range ranges {
+		out = append(out, forecastTarget{label: thresholdLabel(b.Label, b.From), value: b.From})
+	}
+	for _, w := range watches {
+		if w.kind == watchAbove || w.kind == watchBelow {
+			out = append(out, forecastTarget{label: "watch " + w.condition(), value: w.threshold})
+		}
+	}
+	return out
+}
+
+func forecastSummary(level, slope float64, step time.Duration, targets []forecastTarget) string {
+	var parts []string
+	for _, t := range targets {
+		if d, ok := timeToTarget(level, slope, step, t.value); ok {
+			parts = append(parts, t.label+" in "+formatRelDuration(d))
+		}
+	}
+	if len(parts) == 0 {
+		return "trend " + strconv.FormatFloat(slope/step.Seconds(), 'g', 3, 64) + "/s"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (u *uiState) cycleForecast() forecastModel {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.forecast = (u.forecast + 1) % (forecastHolt + 1)
+	return u.forecast
+}
+
+func (u *uiState) forecastModel() forecastModel {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.forecast
+}
+
+func (u *uiState) watchesFor(key string) []*watch {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var out []*watch
+	for _, w := range u.watches {
+		if w.key == key {
+			out = append(out, w)
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestFitLinear(t *testing.T) {
+	level, slope, ok := fitLinear([]float64{1, 3, math.NaN(), 7, 9})
+	if !ok || !approx(level, 9) || !approx(slope, 2) {
+		t.Errorf("fitLinear = %v, %v, %v; want 9, 2, true", level, slope, ok)
+	}
+	if _, _, ok := fitLinear([]float64{math.NaN(), 4}); ok {
+		t.Error("a single point should not fit")
+	}
+}
+
+func TestFitHolt(t *testing.T) {
+	data := make([]float64, 30)
+	for i := range data {
+		data[i] = 100 + 5*float64(i)
+	}
+	data[10] = math.NaN()
+	level, slope, ok := fitHolt(data)
+	if !ok || !approx(level, 245) || !approx(slope, 5) {
+		t.Errorf("fitHolt on a straight line = %v, %v, %v; want 245, 5, true", level, slope, ok)
+	}
+
+	data = append(data, math.NaN(), math.NaN())
+	if level, _, _ := fitHolt(data); !approx(level, 255) {
+		t.Errorf("trailing gaps should extrapolate the level, got %v", level)
+	}
+	if _, _, ok := fitHolt([]float64{1}); ok {
+		t.Error("a single point should not fit")
+	}
+}
+
+func TestForecastSeries(t *testing.T) {
+	out := forecastSeries(3, 10, 1, 5)
+	if len(out) != 8 {
+		t.Fatalf("len = %d, want 8", len(out))
+	}
+	for i := 0; i < 2; i++ {
+		if !math.IsNaN(out[i]) {
+			t.Errorf("out[%d] = %v, history should be empty", i, out[i])
+		}
+	}
+	if out[2] != 10 || out[3] != 11 || !math.IsNaN(out[4]) || !math.IsNaN(out[5]) || out[6] != 14 || out[7] != 15 {
+		t.Errorf("forecast = %v", out)
+	}
+}
+
+func TestTimeToTarget(t *testing.T) {
+	tests := []struct {
+		level, slope, target float64
+		want                 time.Duration
+		ok                   bool
+	}{
+		{50, 1, 80, 30 * time.Second, true},
+		{50, -2, 10, 20 * time.Second, true},
+		{50, 1, 10, 0, false},
+		{50, 0, 80, 0, false},
+		{0, 1e-12, 1e12, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := timeToTarget(tt.level, tt.slope, time.Second, tt.target)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("timeToTarget(%v, %v, %v) = %v, %v; want %v, %v", tt.level, tt.slope, tt.target, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestForecastTargetsAndSummary(t *testing.T) {
+	rules, err := compileThresholds([]ThresholdConfig{{
+		Metric: "^disk_used_ratio$",
+		Lines:  []ThresholdLine{{Value: 0.9, Label: "full"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	watches := []*watch{
+		{key: "disk_used_ratio", kind: watchAbove, threshold: 0.8},
+		{key: "disk_used_ratio", kind: watchChange, threshold: 10},
+	}
+	targets := forecastTargets(rules, "disk_used_ratio", watches)
+	if len(targets) != 2 || targets[0].label != "full" || targets[1].label != "watch >0.8" {
+		t.Fatalf("targets = %+v", targets)
+	}
+
+	got := forecastSummary(0.5, 0.01, time.Second, targets)
+	if !strings.Contains(got, "full in 40s") || !strings.Contains(got, "watch >0.8 in 30s") {
+		t.Errorf("summary = %q", got)
+	}
+	if got := forecastSummary(0.5, -0.01, time.Second, targets); !strings.HasPrefix(got, "trend -0.01/s") {
+		t.Errorf("summary without reachable targets = %q", got)
+	}
+}
+
+func TestUIStateCycleForecast(t *testing.T) {
+	u := &uiState{}
+	for _, want := range []forecastModel{forecastLinear, forecastHolt, forecastOff} {
+		if got := u.cycleForecast(); got != want {
+			t.Errorf("cycleForecast = %s, want %s", got, want)
+		}
+	}
+}
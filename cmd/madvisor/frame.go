@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const frameWait = 50 * time.Millisecond
+
+type frameRequest struct {
+	name      string
+	group     string
+	focus     focusPanel
+	seriesIdx int
+	sort      tableSort
+	tf        chartTransform
+	heatmap   bool
+	dual      bool
+	// width is the chart's plot width in pixels; longer datasets are
+	// reduced to a min/max envelope of it. 0 keeps every point.
+	width int
+	seq   uint64
+}
+
+type chartFrame struct {
+	req        frameRequest
+	seriesList []*metricSeries
+	// tableList is seriesList in table order, sorted once per frame.
+	tableList   []*metricSeries
+	chartSeries []*metricSeries
+	otherSeries []*metricSeries
+	totalSeries int
+	datasets    [][]float64
+	rawSets     [][]float64
+	otherData   []float64
+	xLabels     map[int]string
+	gaps        int
+	dualOn      bool
+	heatmapOn   bool
+}
+
+func prepareFrame(st *store, req frameRequest) *chartFrame {
+	fr := &chartFrame{req: req}
+	fr.seriesList = filterGroup(st.seriesForName(req.name), req.group)
+	fr.tableList = sortSeries(fr.seriesList, req.sort)
+	if req.focus == focusSeriesTable && req.seriesIdx >= 0 && req.seriesIdx < len(fr.tableList) {
+		fr.chartSeries = []*metricSeries{fr.tableList[req.seriesIdx]}
+	} else {
+		fr.chartSeries = fr.seriesList
+	}
+
+	fr.dualOn = req.dual && len(fr.chartSeries) > 0 && fr.chartSeries[0].shouldRate()
+	fr.heatmapOn = req.heatmap && !fr.dualOn
+	fr.totalSeries = len(fr.chartSeries)
+	if !fr.heatmapOn {
+		fr.chartSeries, fr.otherSeries = topSeries(fr.chartSeries, maxChartSeries, watchValue)
+	}
+
+	live := !st.isPaused()
+	fr.datasets = make([][]float64, len(fr.chartSeries))
+	for i, cs := range fr.chartSeries {
+		data, times := chartData(cs)
+		data, times = applyTransform(req.tf, data, times)
+		if live {
+			data, times = padTrailingGap(data, times, st.lastFailedScrape(cs), scrapeInterval)
+		}
+		var n int
+		fr.datasets[i], n = resampleTiered(data, times, scrapeInterval, st.resolutionAt(cs))
+		fr.datasets[i] = envelope(fr.datasets[i], req.width)
+		fr.gaps += n
+		if i == 0 && len(times) >= 2 {
+			from, to := times[0], times[len(times)-1]
+			fr.xLabels = annotationLabels(st.annotationsBetween(from, to), from, to, len(fr.datasets[i]))
+		}
+		if i == 0 && n > 0 {
+			fr.xLabels = gapLabels(fr.datasets[i], fr.xLabels)
+		}
+		if fr.dualOn {
+			rawData, rawTimes := cs.slice(), cs.timeSlice()
+			if live {
+				rawData, rawTimes = padTrailingGap(rawData, rawTimes, st.lastFailedScrape(cs), scrapeInterval)
+			}
+			raw, _ := resampleTiered(rawData, rawTimes, scrapeInterval, st.resolutionAt(cs))
+			fr.rawSets = append(fr.rawSets, envelope(raw, req.width))
+		}
+	}
+
+	if len(fr.otherSeries) > 0 {
+		sets := make([][]float64, len(fr.otherSeries))
+		for i, cs := range fr.otherSeries {
+			data, times := chartData(cs)
+			data, times = applyTransform(req.tf, data, times)
+			if live {
+				data, times = padTrailingGap(data, times, st.lastFailedScrape(cs), scrapeInterval)
+			}
+			sets[i], _ = resampleTiered(data, times, scrapeInterval, st.resolutionAt(cs))
+		}
+		fr.otherData = envelope(sumAligned(sets), req.width)
+	}
+	return fr
+}
+
+// table is the series table of name within group in order m: the frame's,
+// when it answers that, so the table shows the order the chart was picked
+// from, or else freshly sorted.
+func (fr *chartFrame) table(st *store, name, group string, m tableSort) []*metricSeries {
+	if fr.tableList != nil && fr.req.name == name && fr.req.group == group && fr.req.sort == m {
+		return fr.tableList
+	}
+	return tableSeries(st, name, group, m)
+}
+
+// framePreparer computes chart frames off the render tick. Requests are
+// coalesced so a slow frame never queues up more than one follow-up.
+type framePreparer struct {
+	st        *store
+	reqs      chan frameRequest
+	published chan struct{}
+	frame     atomic.Pointer[chartFrame]
+	seq       uint64
+}
+
+func newFramePreparer(st *store) *framePreparer {
+	return &framePreparer{
+		st:        st,
+		reqs:      make(chan frameRequest, 1),
+		published: make(chan struct{}, 1),
+	}
+}
+
+func (p *framePreparer) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-p.reqs:
+			p.frame.Store(prepareFrame(p.st, req))
+			select {
+			case p.published <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (p *framePreparer) request(req frameRequest) frameRequest {
+	p.seq++
+	req.seq = p.seq
+	select {
+	case <-p.reqs:
+	default:
+	}
+	p.reqs <- req
+	return req
+}
+
+// await waits up to timeout for the frame answering req. A busy worker
+// leaves the last published frame on screen rather than stalling the tick.
+func (p *framePreparer) await(req frameRequest, timeout time.Duration) *chartFrame {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		fr := p.frame.Load()
+		if fr != nil && fr.req.seq >= req.seq {
+			return fr
+		}
+		select {
+		case <-p.published:
+		case <-deadline.C:
+			if fr == nil {
+				return &chartFrame{req: req}
+			}
+			return fr
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func frameStore() *store {
+	st := newStore()
+	for i := 0; i < 5; i++ {
+		st.update("requests_total", map[string]string{"code": string(rune('a' + i))}, "", "counter", float64(i))
+		st.update("requests_total", map[string]string{"code": string(rune('a' + i))}, "", "counter", float64(i*2))
+	}
+	st.update("queue_depth", nil, "", "gauge", 3)
+	return st
+}
+
+func TestPrepareFrame(t *testing.T) {
+	defer func(k int) { maxChartSeries = k }(maxChartSeries)
+	maxChartSeries = 2
+	st := frameStore()
+
+	fr := prepareFrame(st, frameRequest{name: "requests_total"})
+	if len(fr.seriesList) != 5 || fr.totalSeries != 5 {
+		t.Fatalf("seriesList = %d, totalSeries = %d", len(fr.seriesList), fr.totalSeries)
+	}
+	if len(fr.chartSeries) != 2 || len(fr.otherSeries) != 3 || len(fr.datasets) != 2 {
+		t.Errorf("chart = %d, other = %d, datasets = %d", len(fr.chartSeries), len(fr.otherSeries), len(fr.datasets))
+	}
+
+	fr = prepareFrame(st, frameRequest{name: "requests_total", heatmap: true})
+	if len(fr.chartSeries) != 5 || fr.otherSeries != nil || !fr.heatmapOn {
+		t.Errorf("heatmap frame should keep every series, got %d", len(fr.chartSeries))
+	}
+
+	fr = prepareFrame(st, frameRequest{name: "requests_total", focus: focusSeriesTable, seriesIdx: 1, dual: true})
+	if len(fr.chartSeries) != 1 || fr.chartSeries[0] != sortSeries(fr.seriesList, sortRate)[1] {
+		t.Errorf("series focus should chart the selected row of the rate-sorted table")
+	}
+	if !fr.dualOn || len(fr.rawSets) != 1 {
+		t.Errorf("dual frame = %v with %d raw sets", fr.dualOn, len(fr.rawSets))
+	}
+
+	if fr := prepareFrame(st, frameRequest{name: "queue_depth", dual: true, heatmap: true}); fr.dualOn || !fr.heatmapOn {
+		t.Error("dual view only applies to rated series")
+	}
+}
+
+func TestPrepareFrameEnvelope(t *testing.T) {
+	st := newStore()
+	base := time.Now().Add(-ringSize * scrapeInterval)
+	for i := 0; i < ringSize; i++ {
+		v := 1.0
+		if i == 53 {
+			v = 90
+		}
+		st.ingest("t:1", "queue_depth", nil, "", "gauge", v, base.Add(time.Duration(i)*scrapeInterval))
+	}
+	st.setPaused(true)
+	fr := prepareFrame(st, frameRequest{name: "queue_depth", width: 20})
+	data := fr.datasets[0]
+	if len(data) != 20 {
+		t.Fatalf("len = %d, want the 20-pixel envelope", len(data))
+	}
+	peak := 0.0
+	for _, v := range data {
+		peak = math.Max(peak, v)
+	}
+	if peak != 90 {
+		t.Errorf("envelope peak = %v, want the one-sample spike of 90 kept", peak)
+	}
+}
+
+func TestPrepareFramePadsOnlyFailedScrapes(t *testing.T) {
+	st := newStore()
+	base := time.Now().Add(-time.Minute)
+	for i := 0; i < 5; i++ {
+		st.ingest("t:1", "queue_depth", nil, "", "gauge", 1, base.Add(time.Duration(i)*scrapeInterval))
+	}
+	if fr := prepareFrame(st, frameRequest{name: "queue_depth"}); fr.gaps != 0 {
+		t.Errorf("gaps = %d, want none without a failed scrape, however long ago the last sample", fr.gaps)
+	}
+	st.recordScrape("t:1", errors.New("refused"), base.Add(10*scrapeInterval))
+	if fr := prepareFrame(st, frameRequest{name: "queue_depth"}); fr.gaps == 0 {
+		t.Error("want the outage up to the failed scrape drawn as a gap")
+	}
+}
+
+func TestFramePreparer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newFramePreparer(frameStore())
+
+	req := p.request(frameRequest{name: "queue_depth"})
+	if fr := p.await(req, time.Millisecond); fr.req.name != "queue_depth" || fr.chartSeries != nil {
+		t.Errorf("without a worker await should return an empty frame for the request, got %+v", fr.req)
+	}
+
+	go p.run(ctx)
+	req = p.request(frameRequest{name: "queue_depth"})
+	fr := p.await(req, time.Second)
+	if fr.req.seq != req.seq || len(fr.chartSeries) != 1 {
+		t.Fatalf("await = seq %d with %d series, want seq %d", fr.req.seq, len(fr.chartSeries), req.seq)
+	}
+
+	next := p.request(frameRequest{name: "requests_total"})
+	if fr := p.await(next, time.Second); fr.req.name != "requests_total" {
+		t.Errorf("await after selection change = %q", fr.req.name)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Inputs that make a fuzz target fail are written to testdata/fuzz/<Fuzz…>/
+// by go test -fuzz; committing them turns each into a regression case that
+// every plain go test run replays.
+
+func addCorpusSeeds(f *testing.F) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "exporters", "*.prom"))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(b))
+	}
+}
+
+func FuzzParseLabels(f *testing.F) {
+	for _, s := range []string{
+		`up`, `m{}`, `m{a="1",b="2"}`, `m{a="x,y", b="}"}`, `m{q="say \"hi\""}`,
+		`m{a="unterminated`, `m{a=}`, `m{=""}`, `m{a="\`, `{a="1"}`, `m{a=1,b}`,
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		name, labels := parseLabels(s)
+		if i := strings.IndexByte(s, '{'); i < 0 {
+			if name != s || labels != nil {
+				t.Fatalf("parseLabels(%q) = %q, %v; want the input as name and no labels", s, name, labels)
+			}
+		} else if name != s[:i] {
+			t.Fatalf("parseLabels(%q) name = %q, want %q", s, name, s[:i])
+		}
+	})
+}
+
+// FuzzLabelRoundTrip checks that any label value survives being escaped into
+// an exposition line and parsed back.
+func FuzzLabelRoundTrip(f *testing.F) {
+	for _, v := range []string{"", "plain", "a,b", "}", `"quoted"`, `back\slash`, "new\nline", "sp ace 1", "# not a comment", "\\n"} {
+		f.Add(v, 1.5)
+	}
+	esc := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	f.Fuzz(func(t *testing.T, v string, val float64) {
+		if math.IsNaN(val) {
+			return
+		}
+		line := `m{a="` + esc.Replace(v) + `",b="x"} ` + strconv.FormatFloat(val, 'g', -1, 64) + "\n"
+		var n int
+		ps, err := scanExposition(strings.NewReader(line), func(name string, labels map[string]string, help, mtype string, got float64) {
+			n++
+			if name != "m" || labels["a"] != v || labels["b"] != "x" || len(labels) != 2 || got != val {
+				t.Fatalf("%q parsed as %s %v %v", line, name, labels, got)
+			}
+		}, nil)
+		if err != nil || n != 1 || ps.skipped != 0 {
+			t.Fatalf("%q: %d sample(s), %d skipped (%v), err %v", line, n, ps.skipped, ps.examples, err)
+		}
+	})
+}
+
+func FuzzScanExposition(f *testing.F) {
+	addCorpusSeeds(f)
+	f.Add(openMetricsBody)
+	f.Add("m{a=\"1\"} 1 1700000000000\nm_bucket{le=\"1\"} 4 # {trace_id=\"abc\"} 0.5\n# TYPE\n# HELP\nbroken\n")
+	f.Fuzz(func(t *testing.T, body string) {
+		var n int
+		ps, err := scanExposition(strings.NewReader(body), func(name string, labels map[string]string, help, mtype string, val float64) {
+			n++
+			if name == "" && len(labels) == 0 {
+				t.Fatalf("sample with no name and no labels from %q", body)
+			}
+		}, func(string, map[string]string, float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n+ps.skipped > ps.lines {
+			t.Fatalf("%d sample(s) and %d skipped from %d line(s)", n, ps.skipped, ps.lines)
+		}
+	})
+}
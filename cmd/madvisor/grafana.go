@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type grafanaTarget struct {
+	Expr string `json:"expr"`
+}
+
+type grafanaPanel struct {
+	Title   string          `json:"title"`
+	Type    string          `json:"type"`
+	Targets []grafanaTarget `json:"targets"`
+	Panels  []grafanaPanel  `json:"panels"`
+}
+
+type grafanaDashboard struct {
+	Title  string         `json:"title"`
+	Panels []grafanaPanel `json:"panels"`
+	Rows   []grafanaPanel `json:"rows"`
+}
+
+var (
+	promSelectorRe = regexp.MustCompile(`\{[^}]*\}|\[[^\]]*\]`)
+	promGroupingRe = regexp.MustCompile(`\b(by|without)\s*\([^)]*\)`)
+	promIdentRe    = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*(\s*\()?`)
+)
+
+// promFuncs are the functions and aggregations a "simple" expression may wrap
+// its single metric in; madVisor already rates counters and sums by group.
+var promFuncs = map[string]bool{
+	"rate": true, "irate": true, "increase": true, "delta": true, "deriv": true,
+	"sum": true, "avg": true, "min": true, "max": true, "count": true,
+	"avg_over_time": true, "max_over_time": true, "min_over_time": true,
+}
+
+// simpleMetric returns the metric an expression plots when it is a bare
+// selector optionally wrapped in rate-like functions and aggregations, e.g.
+// sum by (code) (rate(http_requests_total{job="api"}[5m])).
+func simpleMetric(expr string) (string, bool) {
+	s := promSelectorRe.ReplaceAllString(expr, "")
+	s = promGroupingRe.ReplaceAllString(s, "")
+	var metric string
+	for _, m := range promIdentRe.FindAllString(s, -1) {
+		if strings.HasSuffix(m, "(") {
+			if !promFuncs[strings.TrimSpace(strings.TrimSuffix(m, "("))] {
+				return "", false
+			}
+			continue
+		}
+		if m == "by" || m == "without" || m == "offset" {
+			return "", false
+		}
+		if metric != "" && metric != m {
+			return "", false
+		}
+		metric = m
+	}
+	if metric == "" || strings.ContainsAny(s, "/+*-%^<>=") {
+		return "", false
+	}
+	return metric, true
+}
+
+func parseGrafana(r io.Reader) (grafanaDashboard, error) {
+	var d grafanaDashboard
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return d, fmt.Errorf("parse dashboard: %w", err)
+	}
+	// Exports from the API wrap the model as {"dashboard": {...}}.
+	if d.Title == "" && len(d.Panels) == 0 && len(d.Rows) == 0 {
+		return d, fmt.Errorf("no panels found (for API exports, pass the inner \"dashboard\" object)")
+	}
+	return d, nil
+}
+
+func flattenPanels(panels []grafanaPanel) []grafanaPanel {
+	var out []grafanaPanel
+	for _, p := range panels {
+		if len(p.Targets) > 0 {
+			out = append(out, p)
+		}
+		out = append(out, flattenPanels(p.Panels)...)
+	}
+	return out
+}
+
+// grafanaPreset maps a dashboard onto a madVisor preset: one panel per
+// Grafana panel with a simple query, matching its metrics by exact name.
+// Skipped queries are returned as warnings.
+func grafanaPreset(d grafanaDashboard) (Preset, []string) {
+	p := Preset{Name: d.Title}
+	if p.Name == "" {
+		p.Name = "grafana"
+	}
+	var warnings []string
+	for _, gp := range flattenPanels(append(d.Panels, d.Rows...)) {
+		title := gp.Title
+		if title == "" {
+			title = fmt.Sprintf("Panel %d", len(p.Panels)+1)
+		}
+		panel := PresetPanel{Title: title}
+		seen := map[string]bool{}
+		for _, t := range gp.Targets {
+			if strings.TrimSpace(t.Expr) == "" {
+				continue
+			}
+			metric, ok := simpleMetric(t.Expr)
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("%s: skipped %q (not a simple metric, rate or sum by)", title, t.Expr))
+				continue
+			}
+			if !seen[metric] {
+				seen[metric] = true
+				panel.Matchers = append(panel.Matchers, "^"+regexp.QuoteMeta(metric)+"$")
+			}
+		}
+		if len(panel.Matchers) > 0 {
+			p.Panels = append(p.Panels, panel)
+		}
+	}
+	if len(p.Panels) > 0 {
+		first := p.Panels[0].Matchers[0]
+		p.Detect = []string{strings.TrimSuffix(strings.TrimPrefix(first, "^"), "$")}
+	}
+	return p, warnings
+}
+
+func writeGrafanaPreset(w io.Writer, p Preset) error {
+	out, err := yaml.Marshal(struct {
+		Presets []Preset `yaml:"presets"`
+	}{[]Preset{p}})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSimpleMetric(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+		ok   bool
+	}{
+		{`up`, "up", true},
+		{`node_load1{instance="$instance"}`, "node_load1", true},
+		{`rate(http_requests_total{job="api"}[$__rate_interval])`, "http_requests_total", true},
+		{`sum by (code) (rate(http_requests_total[5m]))`, "http_requests_total", true},
+		{`sum(irate(node_cpu_seconds_total{mode!="idle"}[1m])) by (cpu)`, "node_cpu_seconds_total", true},
+		{`histogram_quantile(0.99, sum by (le) (rate(req_duration_seconds_bucket[5m])))`, "", false},
+		{`a_total / b_total`, "", false},
+		{`sum(rate(x_total[5m])) * 100`, "", false},
+		{`rate(x_total[5m] offset 1h)`, "", false},
+		{`x_total offset 1h`, "", false},
+		{`vector(1)`, "", false},
+	}
+	for _, c := range cases {
+		got, ok := simpleMetric(c.expr)
+		if got != c.want || ok != c.ok {
+			t.Errorf("simpleMetric(%q) = %q, %v; want %q, %v", c.expr, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+const testDashboard = `{
+  "title": "API",
+  "panels": [
+    {"type": "row", "title": "Traffic", "panels": [
+      {"type": "timeseries", "title": "Requests", "targets": [
+        {"expr": "sum by (code) (rate(http_requests_total[5m]))"},
+        {"expr": "rate(http_requests_total{code=~\"5..\"}[5m])"}
+      ]}
+    ]},
+    {"type": "timeseries", "title": "Latency p99", "targets": [
+      {"expr": "histogram_quantile(0.99, rate(req_duration_seconds_bucket[5m]))"}
+    ]},
+    {"type": "stat", "title": "Memory", "targets": [
+      {"expr": "process_resident_memory_bytes"},
+      {"expr": "go_memstats_heap_inuse_bytes"}
+    ]}
+  ]
+}`
+
+func TestGrafanaPreset(t *testing.T) {
+	d, err := parseGrafana(strings.NewReader(testDashboard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, warnings := grafanaPreset(d)
+	if p.Name != "API" || len(p.Detect) != 1 || p.Detect[0] != "http_requests_total" {
+		t.Errorf("preset = %+v", p)
+	}
+	if len(p.Panels) != 2 || p.Panels[0].Title != "Requests" || p.Panels[1].Title != "Memory" {
+		t.Fatalf("panels = %+v", p.Panels)
+	}
+	if len(p.Panels[0].Matchers) != 1 || len(p.Panels[1].Matchers) != 2 {
+		t.Errorf("matchers = %v / %v", p.Panels[0].Matchers, p.Panels[1].Matchers)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Latency p99") {
+		t.Errorf("warnings = %v", warnings)
+	}
+	if _, err := compilePresets([]Preset{p}); err != nil {
+		t.Errorf("imported preset does not compile: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeGrafanaPreset(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	var cfg UnitsConfig
+	if err := yaml.Unmarshal(buf.Bytes(), &cfg); err != nil || len(cfg.Presets) != 1 || cfg.Presets[0].Name != "API" {
+		t.Errorf("round trip = %+v, %v\n%s", cfg, err, buf.String())
+	}
+}
+
+func TestParseGrafanaErrors(t *testing.T) {
+	if _, err := parseGrafana(strings.NewReader("{")); err == nil {
+		t.Error("invalid JSON should fail")
+	}
+	if _, err := parseGrafana(strings.NewReader(`{"dashboard": {"title": "x"}}`)); err == nil {
+		t.Error("wrapped API export should be rejected with a hint")
+	}
+}
+
+func TestImportGrafanaCmd(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "dash.json")
+	out := filepath.Join(dir, "preset.yaml")
+	if err := os.WriteFile(in, []byte(testDashboard), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := newRootCmd()
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"import-grafana", in, "-o", out})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || !strings.Contains(string(data), "^http_requests_total$") {
+		t.Errorf("preset file = %q, %v", data, err)
+	}
+	if !strings.Contains(stderr.String(), "warning: Latency p99") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// scrapeHeader is an extra request header sent to every target, or only to
+// target when it is set.
+type scrapeHeader struct {
+	target string
+	name   string
+	value  string
+}
+
+var scrapeHeaders []scrapeHeader
+
+// userAgent is sent on every outgoing request; --user-agent overrides it.
+func userAgent() string {
+	if flagUserAgent != "" {
+		return flagUserAgent
+	}
+	return "madvisor/" + version
+}
+
+// parseScrapeHeader parses "[host:port/]Name: value". A prefix before the
+// first slash is only taken as a target when it has no spaces and the rest
+// is itself a valid header, so values like "Accept: text/plain" still work.
+func parseScrapeHeader(spec string) (scrapeHeader, error) {
+	if prefix, rest, ok := strings.Cut(spec, "/"); ok && prefix != "" && !strings.ContainsAny(prefix, " \t") {
+		if h, err := parseHeaderPair(rest); err == nil {
+			h.target = prefix
+			return h, nil
+		}
+	}
+	h, err := parseHeaderPair(spec)
+	if err != nil {
+		return scrapeHeader{}, fmt.Errorf("invalid header %q, want \"Name: value\" or \"host:port/Name: value\"", spec)
+	}
+	return h, nil
+}
+
+func parseHeaderPair(s string) (scrapeHeader, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || strings.ContainsAny(name, " \t/") {
+		return scrapeHeader{}, fmt.Errorf("invalid header %q", s)
+	}
+	return scrapeHeader{name: name, value: value}, nil
+}
+
+func parseScrapeHeaders(specs []string) ([]scrapeHeader, error) {
+	var out []scrapeHeader
+	for _, spec := range specs {
+		h, err := parseScrapeHeader(spec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// newScrapeRequest builds the GET for a target's /metrics with the
+// User-Agent and any --scrape-header entries; per-target headers win.
+func newScrapeRequest(tgt target) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, tgt.url(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	for _, h := range scrapeHeaders {
+		if h.target == "" {
+			req.Header.Set(h.name, h.value)
+		}
+	}
+	for _, h := range scrapeHeaders {
+		if h.target == tgt.addr {
+			req.Header.Set(h.name, h.value)
+		}
+	}
+	return req, nil
+}
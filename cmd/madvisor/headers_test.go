@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScrapeHeader(t *testing.T) {
+	cases := []struct {
+		spec string
+		want scrapeHeader
+	}{
+		{"X-Scope-OrgID: tenant1", scrapeHeader{name: "X-Scope-OrgID", value: "tenant1"}},
+		{"db:9187/X-Scope-OrgID: tenant2", scrapeHeader{target: "db:9187", name: "X-Scope-OrgID", value: "tenant2"}},
+		{"Accept: text/plain", scrapeHeader{name: "Accept", value: "text/plain"}},
+		{"web:8080/Authorization: Bearer a/b", scrapeHeader{target: "web:8080", name: "Authorization", value: "Bearer a/b"}},
+		{"X-Empty:", scrapeHeader{name: "X-Empty"}},
+	}
+	for _, c := range cases {
+		got, err := parseScrapeHeader(c.spec)
+		if err != nil || got != c.want {
+			t.Errorf("parseScrapeHeader(%q) = %+v, %v; want %+v", c.spec, got, err, c.want)
+		}
+	}
+	for _, spec := range []string{"", "novalue", ": x", "Bad Name: x", "a/b/c"} {
+		if _, err := parseScrapeHeader(spec); err == nil {
+			t.Errorf("parseScrapeHeader(%q) should fail", spec)
+		}
+	}
+}
+
+func TestScrapeRequestHeaders(t *testing.T) {
+	defer func(h []scrapeHeader, ua string) { scrapeHeaders, flagUserAgent = h, ua }(scrapeHeaders, flagUserAgent)
+	var err error
+	scrapeHeaders, err = parseScrapeHeaders([]string{"db:9187/X-Tenant: db", "X-Tenant: all", "X-Global: yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := newScrapeRequest(target{addr: "db:9187"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.String() != "http://db:9187/metrics" || req.Header.Get("X-Tenant") != "db" || req.Header.Get("X-Global") != "yes" {
+		t.Errorf("db request: %s %v", req.URL, req.Header)
+	}
+	if req.Header.Get("User-Agent") != "madvisor/"+version {
+		t.Errorf("User-Agent = %q", req.Header.Get("User-Agent"))
+	}
+	req, _ = newScrapeRequest(target{addr: "web:80"})
+	if req.Header.Get("X-Tenant") != "all" {
+		t.Errorf("web X-Tenant = %q", req.Header.Get("X-Tenant"))
+	}
+
+	var gotUA, gotTenant string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA, gotTenant = r.UserAgent(), r.Header.Get("X-Tenant")
+		w.Write([]byte("up 1\n"))
+	}))
+	defer srv.Close()
+	flagUserAgent = "probe/1"
+	scrapeTarget(&http.Client{Timeout: time.Second}, target{addr: strings.TrimPrefix(srv.URL, "http://")}, newStore())
+	if gotUA != "probe/1" || gotTenant != "all" {
+		t.Errorf("server saw User-Agent %q, X-Tenant %q", gotUA, gotTenant)
+	}
+}
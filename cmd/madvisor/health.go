@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	degradedRatio = 0.99
+
+	upMetric = "up"
+	upHelp   = "1 if the last scrape of the target succeeded, 0 otherwise (synthetic)"
+)
+
+type targetHealth struct {
+	ok       int
+	failed   int
+	lastFail time.Time
+	lastErr  string
+	state    string
+
+	truncated     int
+	lastTruncated time.Time
+
+	parse parseStats
+
+	durations scrapeDurations
+
+	// scan is the outcome of the --port-scan fallback after a refused scrape.
+	scan string
+}
+
+func (h targetHealth) ratio() float64 {
+	total := h.ok + h.failed
+	if total == 0 {
+		return 1
+	}
+	return float64(h.ok) / float64(total)
+}
+
+func (h targetHealth) degraded() bool {
+	return h.ratio() < degradedRatio
+}
+
+func (h targetHealth) summary(now time.Time) string {
+	if h.ok+h.failed == 0 {
+		return "no scrapes yet"
+	}
+	s := fmt.Sprintf("%.1f%% ok", h.ratio()*100)
+	if h.failed == 0 {
+		return s + ", no failures"
+	}
+	return s + ", last fail " + formatRelDuration(now.Sub(h.lastFail)) + " ago"
+}
+
+func (st *store) recordScrape(addr string, err error, at time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.health == nil {
+		st.health = map[string]*targetHealth{}
+	}
+	h := st.health[addr]
+	if h == nil {
+		h = &targetHealth{}
+		st.health[addr] = h
+	}
+	h.state = probeState(err)
+	if err != nil {
+		h.failed++
+		h.lastFail = at
+		h.lastErr = err.Error()
+		return
+	}
+	h.ok++
+}
+
+// scrapeDone records the outcome of a scrape in the target's health and as
+// a synthetic up series (1 on success, 0 on failure) stored like any scraped
+// metric, so availability can be charted, watched and exported.
+func (st *store) scrapeDone(tgt target, err error, at time.Time) {
+	st.recordScrape(tgt.key(), err, at)
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	labels := tgt.attachLabels(map[string]string{instanceLabel: tgt.addr})
+	st.ingest(tgt.key(), upMetric, labels, upHelp, "gauge", up, at)
+}
+
+// hasData reports whether any target has delivered metrics, ignoring the
+// synthetic up and scrape_duration_seconds series that are recorded even
+// when every scrape fails.
+func (st *store) hasData() bool {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	for _, s := range st.series {
+		if !syntheticSeries(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// syntheticSeries reports whether s was recorded about a scrape rather than
+// scraped from a target.
+func syntheticSeries(s *metricSeries) bool {
+	return s.name == upMetric && s.help == upHelp || s.name == scrapeDurationMetric && s.help == scrapeDurationHelp
+}
+
+func (st *store) targetHealth(addr string) targetHealth {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	if h := st.health[addr]; h != nil {
+		return *h
+	}
+	return targetHealth{}
+}
+
+// lastFailedScrape is when the target behind s last failed a scrape; zero for
+// series not scraped by madvisor, such as a replay.
+func (st *store) lastFailedScrape(s *metricSeries) time.Time {
+	return st.targetHealth(replicaOf(s)).lastFail
+}
+
+func degradedTargets(st *store, targets []target) int {
+	n := 0
+	for _, t := range targets {
+		if st.targetHealth(t.key()).degraded() {
+			n++
+		}
+	}
+	return n
+}
+
+func renderTargets(w *text.Text, st *store, targets []target, now time.Time) {
+	w.Reset()
+
+	if pprofAddr != "" {
+		w.Write(" "+runtimeSummary(st)+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+	}
+	for _, issue := range st.targetIssueList() {
+		w.Write(" ✗ "+issue+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+	}
+	if len(targets) == 0 {
+		w.Write("  no scrape targets", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+	for _, t := range targets {
+		h := st.targetHealth(t.key())
+		name := t.title()
+		color := cell.ColorGreen
+		if h.degraded() {
+			color = cell.ColorRed
+		}
+		w.Write(fmt.Sprintf(" %-32s", truncateText(name, 32)), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write(fmt.Sprintf(" %-40s", h.summary(now)), text.WriteCellOpts(cell.FgColor(color)))
+		if s, slow := h.durationSummary(); s != "" {
+			durColor := cell.ColorWhite
+			if slow {
+				durColor = cell.ColorRed
+			}
+			w.Write(fmt.Sprintf(" %-36s", s), text.WriteCellOpts(cell.FgColor(durColor)))
+		}
+		if h.lastErr != "" && h.degraded() {
+			w.Write(" "+h.lastErr, text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		}
+		if s := h.truncationSummary(now); s != "" {
+			w.Write(" "+s, text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		}
+		if s := h.parseSummary(); s != "" {
+			w.Write(" "+s, text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		}
+		if h.scan != "" {
+			w.Write(" "+h.scan, text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+		}
+		w.Write("\n")
+	}
+}
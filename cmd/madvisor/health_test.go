@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTargetHealthSummary(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := newStore()
+	for i := 0; i < 74; i++ {
+		st.recordScrape("a:9100", nil, now)
+	}
+	st.recordScrape("a:9100", errors.New("connection refused"), now.Add(-2*time.Minute))
+
+	h := st.targetHealth("a:9100")
+	if got, want := h.summary(now), "98.7% ok, last fail 2m0s ago"; got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+	if !h.degraded() {
+		t.Error("98.7% success should be degraded")
+	}
+	if h.lastErr != "connection refused" {
+		t.Errorf("lastErr = %q", h.lastErr)
+	}
+
+	if got := st.targetHealth("b:9100").summary(now); got != "no scrapes yet" {
+		t.Errorf("unknown target summary = %q", got)
+	}
+	st.recordScrape("b:9100", nil, now)
+	if got := st.targetHealth("b:9100").summary(now); got != "100.0% ok, no failures" {
+		t.Errorf("healthy summary = %q", got)
+	}
+	if n := degradedTargets(st, []target{{addr: "a:9100"}, {addr: "b:9100"}}); n != 1 {
+		t.Errorf("degradedTargets = %d, want 1", n)
+	}
+}
+
+func TestScrapeTargetRecordsHealth(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "up 1")
+	}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	st := newStore()
+	client := &http.Client{Timeout: time.Second}
+	okAddr := strings.TrimPrefix(ok.URL, "http://")
+	brokenAddr := strings.TrimPrefix(broken.URL, "http://")
+	scrapeTarget(client, target{addr: okAddr}, st)
+	scrapeTarget(client, target{addr: brokenAddr}, st)
+
+	if h := st.targetHealth(okAddr); h.ok != 1 || h.failed != 0 {
+		t.Errorf("healthy target = %+v", h)
+	}
+	h := st.targetHealth(brokenAddr)
+	if h.ok != 0 || h.failed != 1 {
+		t.Errorf("failing target = %+v", h)
+	}
+	if !strings.Contains(h.lastErr, "500") {
+		t.Errorf("lastErr = %q, want HTTP status", h.lastErr)
+	}
+}
+
+func TestScrapeDoneRecordsUp(t *testing.T) {
+	st := newStore()
+	tgt := target{addr: "db:9187", group: "prod"}
+	now := time.Now()
+	st.scrapeDone(tgt, nil, now)
+	st.scrapeDone(tgt, errors.New("connection refused"), now.Add(time.Second))
+
+	s := st.get(seriesKey(upMetric, map[string]string{instanceLabel: "db:9187", groupLabel: "prod"}))
+	if s == nil {
+		t.Fatal("missing up series")
+	}
+	if got := s.slice(); len(got) != 2 || got[0] != 1 || got[1] != 0 {
+		t.Errorf("up values = %v, want [1 0]", got)
+	}
+	if h := st.targetHealth("db:9187"); h.ok != 1 || h.failed != 1 {
+		t.Errorf("health = %+v, want 1 ok and 1 failed", h)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	heatmapCols       = 60
+	heatmapLabelWidth = 28
+	heatmapMaxRows    = 40
+)
+
+// xterm-256 ramp from cold (blue) to hot (red).
+var heatmapRamp = []int{17, 19, 21, 27, 33, 39, 45, 51, 50, 48, 46, 82, 118, 154, 190, 226, 220, 214, 208, 202, 196}
+
+func bucketAverages(data []float64, cols int) []float64 {
+	out := make([]float64, cols)
+	if len(data) == 0 {
+		for i := range out {
+			out[i] = math.NaN()
+		}
+		return out
+	}
+	for c := 0; c < cols; c++ {
+		from := c * len(data) / cols
+		to := (c + 1) * len(data) / cols
+		if to <= from {
+			to = from + 1
+		}
+		sum, n := 0.0, 0
+		for _, v := range data[from:min(to, len(data))] {
+			if !math.IsNaN(v) {
+				sum += v
+				n++
+			}
+		}
+		if n == 0 {
+			out[c] = math.NaN()
+		} else {
+			out[c] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+func heatmapRange(rows [][]float64) (float64, float64, bool) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, row := range rows {
+		for _, v := range row {
+			if math.IsNaN(v) {
+				continue
+			}
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	return lo, hi, lo <= hi
+}
+
+func heatLevel(v, lo, hi float64) int {
+	if hi <= lo {
+		return len(heatmapRamp) / 2
+	}
+	f := (v - lo) / (hi - lo)
+	return min(len(heatmapRamp)-1, max(0, int(f*float64(len(heatmapRamp)))))
+}
+
+func renderHeatmap(w *text.Text, labels []string, datasets [][]float64, format func(float64) string) {
+	w.Reset()
+
+	if len(datasets) == 0 {
+		w.Write("  select a metric name", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+
+	rows := make([][]float64, len(datasets))
+	for i, d := range datasets {
+		rows[i] = bucketAverages(d, heatmapCols)
+	}
+	lo, hi, ok := heatmapRange(rows)
+	if !ok {
+		w.Write("  waiting for data", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+
+	for i, row := range rows {
+		if i == heatmapMaxRows {
+			w.Write(fmt.Sprintf(" ↓ %d more series\n", len(rows)-i), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+			break
+		}
+		w.Write(fmt.Sprintf("%-*s ", heatmapLabelWidth, truncateText(labels[i], heatmapLabelWidth)), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		for _, v := range row {
+			if math.IsNaN(v) {
+				w.Write("·", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(240))))
+				continue
+			}
+			w.Write("█", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(heatmapRamp[heatLevel(v, lo, hi)]))))
+		}
+		w.Write("\n")
+	}
+
+	w.Write(fmt.Sprintf("%*s ", heatmapLabelWidth, format(lo)), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	for _, c := range heatmapRamp {
+		w.Write("█", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(c))))
+	}
+	w.Write(" "+format(hi)+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	older, now := "← older", "now →"
+	pad := heatmapCols - utf8.RuneCountInString(older) - utf8.RuneCountInString(now)
+	w.Write(fmt.Sprintf("%*s %s%s%s", heatmapLabelWidth, "", older, strings.Repeat(" ", max(0, pad)), now),
+		text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestBucketAverages(t *testing.T) {
+	got := bucketAverages([]float64{1, 3, math.NaN(), math.NaN(), 5, 7}, 3)
+	if got[0] != 2 || !math.IsNaN(got[1]) || got[2] != 6 {
+		t.Errorf("bucketAverages = %v, want [2 NaN 6]", got)
+	}
+
+	got = bucketAverages([]float64{4, 8}, 4)
+	if len(got) != 4 || got[0] != 4 || got[3] != 8 {
+		t.Errorf("upsampled = %v", got)
+	}
+
+	for _, v := range bucketAverages(nil, 2) {
+		if !math.IsNaN(v) {
+			t.Errorf("empty data should give NaN buckets, got %v", v)
+		}
+	}
+}
+
+func TestHeatLevel(t *testing.T) {
+	last := len(heatmapRamp) - 1
+	tests := []struct {
+		v, lo, hi float64
+		want      int
+	}{
+		{0, 0, 10, 0},
+		{10, 0, 10, last},
+		{5, 0, 10, len(heatmapRamp) / 2},
+		{-1, 0, 10, 0},
+		{3, 3, 3, len(heatmapRamp) / 2},
+	}
+	for _, tt := range tests {
+		if got := heatLevel(tt.v, tt.lo, tt.hi); got != tt.want {
+			t.Errorf("heatLevel(%v, %v, %v) = %d, want %d", tt.v, tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
+
+func TestHeatmapRange(t *testing.T) {
+	lo, hi, ok := heatmapRange([][]float64{{math.NaN(), 4}, {-2, 9}})
+	if !ok || lo != -2 || hi != 9 {
+		t.Errorf("heatmapRange = %v, %v, %v", lo, hi, ok)
+	}
+	if _, _, ok := heatmapRange([][]float64{{math.NaN()}}); ok {
+		t.Error("all-NaN input should report no range")
+	}
+}
+
+func TestRenderHeatmap(t *testing.T) {
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	renderHeatmap(w, nil, nil, format)
+	renderHeatmap(w, []string{"a"}, [][]float64{{math.NaN()}}, format)
+
+	labels := make([]string, heatmapMaxRows+5)
+	datasets := make([][]float64, len(labels))
+	for i := range labels {
+		labels[i] = "series" + strconv.Itoa(i)
+		datasets[i] = []float64{float64(i), float64(i * 2), math.NaN()}
+	}
+	renderHeatmap(w, labels, datasets, format)
+}
+
+func TestUIStateToggleHeatmap(t *testing.T) {
+	u := &uiState{}
+	if u.heatmapEnabled() {
+		t.Error("heatmap should be off by default")
+	}
+	if !u.toggleHeatmap() || !u.heatmapEnabled() {
+		t.Error("toggleHeatmap should enable the heatmap")
+	}
+}
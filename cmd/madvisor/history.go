@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+var globalHistoryTiers []tierSpec
+
+const (
+	fineTierStep   = 10 * time.Second
+	fineTierSpan   = 30 * time.Minute
+	coarseTierStep = time.Minute
+)
+
+type tierSpec struct {
+	step time.Duration
+	size int
+}
+
+func historyTierSpecs(history time.Duration) ([]tierSpec, error) {
+	raw := ringSize * scrapeInterval
+	if history <= 0 {
+		return nil, nil
+	}
+	if history <= raw {
+		return nil, fmt.Errorf("history must be longer than the %s kept at full resolution", raw)
+	}
+	specs := []tierSpec{{step: fineTierStep, size: int((min(history, fineTierSpan) + fineTierStep - 1) / fineTierStep)}}
+	if history > fineTierSpan {
+		specs = append(specs, tierSpec{step: coarseTierStep, size: int((history + coarseTierStep - 1) / coarseTierStep)})
+	}
+	return specs, nil
+}
+
+type historyTier struct {
+	step   time.Duration
+	values []float64
+	times  []time.Time
+	idx    int
+	full   bool
+
+	bucket time.Time
+	lastAt time.Time
+	sum    float64
+	n      int
+}
+
+func newHistoryTiers(specs []tierSpec) []*historyTier {
+	if len(specs) == 0 {
+		return nil
+	}
+	tiers := make([]*historyTier, len(specs))
+	for i, spec := range specs {
+		tiers[i] = &historyTier{
+			step:   spec.step,
+			values: make([]float64, spec.size),
+			times:  make([]time.Time, spec.size),
+		}
+	}
+	return tiers
+}
+
+func (t *historyTier) push(v float64, at time.Time) {
+	t.values[t.idx] = v
+	t.times[t.idx] = at
+	t.idx = (t.idx + 1) % len(t.values)
+	if t.idx == 0 {
+		t.full = true
+	}
+}
+
+func (t *historyTier) add(v float64, at time.Time) (float64, time.Time, bool) {
+	if math.IsNaN(v) {
+		return 0, time.Time{}, false
+	}
+	bucket := at.Truncate(t.step)
+	var avg float64
+	var avgAt time.Time
+	flushed := false
+	if t.n > 0 && !bucket.Equal(t.bucket) {
+		avg, avgAt, flushed = t.sum/float64(t.n), t.lastAt, true
+		t.push(avg, avgAt)
+		t.sum, t.n = 0, 0
+	}
+	t.bucket = bucket
+	t.lastAt = at
+	t.sum += v
+	t.n++
+	return avg, avgAt, flushed
+}
+
+func (t *historyTier) count() int {
+	if t.full {
+		return len(t.values)
+	}
+	return t.idx
+}
+
+func (t *historyTier) oldest() (time.Time, bool) {
+	if t.count() == 0 {
+		return time.Time{}, false
+	}
+	if t.full {
+		return t.times[t.idx], true
+	}
+	return t.times[0], true
+}
+
+func (t *historyTier) appendBefore(values []float64, times []time.Time, cutoff time.Time) ([]float64, []time.Time) {
+	n := t.count()
+	start := 0
+	if t.full {
+		start = t.idx
+	}
+	for k := 0; k < n; k++ {
+		i := (start + k) % len(t.values)
+		if !cutoff.IsZero() && !t.times[i].Before(cutoff) {
+			break
+		}
+		values = append(values, t.values[i])
+		times = append(times, t.times[i])
+	}
+	return values, times
+}
+
+func (t *historyTier) reset() {
+	for i := range t.values {
+		t.values[i] = 0
+		t.times[i] = time.Time{}
+	}
+	t.idx, t.full = 0, false
+	t.sum, t.n = 0, 0
+}
+
+func (s *metricSeries) feedTiers(v float64, at time.Time) {
+	for _, t := range s.tiers {
+		var ok bool
+		if v, at, ok = t.add(v, at); !ok {
+			return
+		}
+	}
+}
+
+// tierHistory returns the downsampled points older than the raw ring,
+// coarsest tier first, so that prepending them to the raw samples yields a
+// single time-ordered history.
+func (s *metricSeries) tierHistory() ([]float64, []time.Time) {
+	if len(s.tiers) == 0 {
+		return nil, nil
+	}
+	cutoffs := make([]time.Time, len(s.tiers))
+	if s.rawCount() > 0 {
+		cutoffs[0] = s.oldestRaw()
+	}
+	for i := 1; i < len(s.tiers); i++ {
+		if t, ok := s.tiers[i-1].oldest(); ok {
+			cutoffs[i] = t
+		} else {
+			cutoffs[i] = cutoffs[i-1]
+		}
+	}
+	var values []float64
+	var times []time.Time
+	for i := len(s.tiers) - 1; i >= 0; i-- {
+		values, times = s.tiers[i].appendBefore(values, times, cutoffs[i])
+	}
+	return values, times
+}
+
+func (s *metricSeries) resolutionAt(at time.Time) time.Duration {
+	if len(s.tiers) == 0 || s.rawCount() == 0 || !at.Before(s.oldestRaw()) {
+		return scrapeInterval
+	}
+	for i, t := range s.tiers {
+		if i+1 == len(s.tiers) {
+			return t.step
+		}
+		if oldest, ok := t.oldest(); ok && !at.Before(oldest) {
+			return t.step
+		}
+	}
+	return scrapeInterval
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistoryTierSpecs(t *testing.T) {
+	if specs, err := historyTierSpecs(0); err != nil || specs != nil {
+		t.Errorf("no history = %v, %v", specs, err)
+	}
+	if _, err := historyTierSpecs(time.Minute); err == nil {
+		t.Error("history shorter than the raw ring should fail")
+	}
+	specs, err := historyTierSpecs(10 * time.Minute)
+	if err != nil || len(specs) != 1 || specs[0].step != 10*time.Second || specs[0].size != 60 {
+		t.Errorf("10m = %+v, %v", specs, err)
+	}
+	specs, err = historyTierSpecs(2 * time.Hour)
+	if err != nil || len(specs) != 2 || specs[0].size != 180 || specs[1].step != time.Minute || specs[1].size != 120 {
+		t.Errorf("2h = %+v, %v", specs, err)
+	}
+}
+
+func tieredSeries(t *testing.T, history time.Duration) *metricSeries {
+	t.Helper()
+	specs, err := historyTierSpecs(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSeries("queue_depth", nil)
+	s.tiers = newHistoryTiers(specs)
+	return s
+}
+
+func TestTieredHistoryMerge(t *testing.T) {
+	s := tieredSeries(t, time.Hour)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const n = 45 * 60
+	for i := 0; i < n; i++ {
+		s.pushAt(float64(i), start.Add(time.Duration(i)*time.Second))
+	}
+
+	values, times := s.slice(), s.timeSlice()
+	if len(values) != len(times) || len(values) != s.count() {
+		t.Fatalf("len(values)=%d len(times)=%d count=%d", len(values), len(times), s.count())
+	}
+	if len(values) <= ringSize {
+		t.Fatalf("history should extend beyond the raw ring, got %d points", len(values))
+	}
+	for i := 1; i < len(times); i++ {
+		if !times[i].After(times[i-1]) {
+			t.Fatalf("times not increasing at %d: %s then %s", i, times[i-1], times[i])
+		}
+	}
+	if span := times[len(times)-1].Sub(times[0]); span < 40*time.Minute {
+		t.Errorf("history spans %s, want most of the 45m pushed", span)
+	}
+	if values[len(values)-1] != n-1 {
+		t.Errorf("newest value = %v, want %d", values[len(values)-1], n-1)
+	}
+	if got := values[len(values)-ringSize-1]; got != n-ringSize-5.5 {
+		t.Errorf("newest 10s bucket average = %v, want %v", got, n-ringSize-5.5)
+	}
+
+	if got := s.resolutionAt(times[0]); got != time.Minute {
+		t.Errorf("resolution of oldest point = %s, want 1m", got)
+	}
+	if got := s.resolutionAt(times[len(times)-ringSize-1]); got != 10*time.Second {
+		t.Errorf("resolution of newest bucket = %s, want 10s", got)
+	}
+	if got := s.resolutionAt(times[len(times)-1]); got != scrapeInterval {
+		t.Errorf("resolution of raw point = %s, want %s", got, scrapeInterval)
+	}
+
+	if _, gaps := resampleTiered(values, times, scrapeInterval, s.resolutionAt); gaps != 0 {
+		t.Errorf("tiered resample reported %d gaps", gaps)
+	}
+	if _, gaps := resample(values, times, scrapeInterval); gaps == 0 {
+		t.Error("plain resample should treat bucket spacing as gaps")
+	}
+
+	s.reset()
+	if s.count() != 0 || len(s.slice()) != 0 {
+		t.Errorf("reset left %d points", s.count())
+	}
+}
+
+func TestHistoryTierSkipsNaN(t *testing.T) {
+	tier := newHistoryTiers([]tierSpec{{step: 10 * time.Second, size: 4}})[0]
+	base := time.Unix(1000, 0)
+	tier.add(2, base)
+	tier.add(math.NaN(), base.Add(time.Second))
+	tier.add(4, base.Add(2*time.Second))
+	v, at, ok := tier.add(9, base.Add(10*time.Second))
+	if !ok || v != 3 || !at.Equal(base.Add(2*time.Second)) {
+		t.Errorf("flushed bucket = %v @ %s, %v; want 3 @ last sample time", v, at, ok)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// inClusterResync is how often the sibling pods are listed again, so
+	// replicas that come and go are scraped and dropped.
+	inClusterResync = 30 * time.Second
+)
+
+var flagInCluster bool
+
+// siblingIgnoredLabels differ between replicas of one workload, so they are
+// left out of the selector that finds a pod's siblings.
+var siblingIgnoredLabels = map[string]bool{
+	"pod-template-hash":                  true,
+	"controller-revision-hash":           true,
+	"pod-template-generation":            true,
+	"statefulset.kubernetes.io/pod-name": true,
+	"apps.kubernetes.io/pod-index":       true,
+}
+
+type kubePod struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []kubeContainer `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+type kubeContainer struct {
+	Ports []kubePort `json:"ports"`
+}
+
+type kubePort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// kubeDiscovery finds the pods of the workload madvisor runs in, through
+// the Kubernetes API with the pod's service account.
+type kubeDiscovery struct {
+	base      string
+	tokenPath string
+	namespace string
+	pod       string
+	client    *http.Client
+}
+
+// newInClusterDiscovery configures discovery from the service account mount
+// and the API server address Kubernetes puts in every container's
+// environment. The pod and namespace come from the downward API
+// (POD_NAME, POD_NAMESPACE) when set, else the hostname and the service
+// account's namespace.
+func newInClusterDiscovery() (*kubeDiscovery, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("--in-cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set, not running in a pod?")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("--in-cluster: read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("--in-cluster: no certificates in the service account CA")
+	}
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		b, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("--in-cluster: read service account namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		if pod, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("--in-cluster: pod name: %w", err)
+		}
+	}
+	return &kubeDiscovery{
+		base:      "https://" + net.JoinHostPort(host, port),
+		tokenPath: filepath.Join(serviceAccountDir, "token"),
+		namespace: namespace,
+		pod:       pod,
+		client: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// get decodes an API response into v. The token is read on every request
+// since projected service account tokens are rotated.
+func (d *kubeDiscovery) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if token, err := os.ReadFile(d.tokenPath); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// discover lists the running pods sharing this pod's workload labels and
+// returns their metrics endpoints, grouped by pod name.
+func (d *kubeDiscovery) discover(ctx context.Context) ([]target, error) {
+	var self kubePod
+	if err := d.get(ctx, "/api/v1/namespaces/"+url.PathEscape(d.namespace)+"/pods/"+url.PathEscape(d.pod), &self); err != nil {
+		return nil, fmt.Errorf("look up pod %s/%s: %w", d.namespace, d.pod, err)
+	}
+	pods := []kubePod{self}
+	if selector := siblingSelector(self.Metadata.Labels); selector != "" {
+		var list struct {
+			Items []kubePod `json:"items"`
+		}
+		path := "/api/v1/namespaces/" + url.PathEscape(d.namespace) + "/pods?labelSelector=" + url.QueryEscape(selector)
+		if err := d.get(ctx, path, &list); err != nil {
+			return nil, fmt.Errorf("list sibling pods: %w", err)
+		}
+		pods = list.Items
+	}
+	var out []target
+	for _, p := range pods {
+		out = append(out, podTargets(p)...)
+	}
+	return out, nil
+}
+
+// siblingSelector is a label selector for the replicas of the workload a
+// pod with labels belongs to.
+func siblingSelector(labels map[string]string) string {
+	var parts []string
+	for k, v := range labels {
+		if !siblingIgnoredLabels[k] {
+			parts = append(parts, k+"="+v)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// podTargets are the endpoints to scrape on a running pod: the
+// prometheus.io/port, path and scheme annotations when present, else its
+// container ports named like metrics, else every TCP container port.
+func podTargets(p kubePod) []target {
+	ann := p.Metadata.Annotations
+	if p.Status.Phase != "Running" || p.Status.PodIP == "" || ann["prometheus.io/scrape"] == "false" {
+		return nil
+	}
+	endpoint := func(port int) target {
+		t := target{addr: net.JoinHostPort(p.Status.PodIP, strconv.Itoa(port)), group: p.Metadata.Name, path: ann["prometheus.io/path"]}
+		if s := ann["prometheus.io/scheme"]; s == "https" {
+			t.scheme = s
+		}
+		return t
+	}
+	if port, err := strconv.Atoi(ann["prometheus.io/port"]); err == nil && port > 0 {
+		return []target{endpoint(port)}
+	}
+	var named, all []target
+	for _, c := range p.Spec.Containers {
+		for _, port := range c.Ports {
+			if port.Protocol != "" && port.Protocol != "TCP" {
+				continue
+			}
+			all = append(all, endpoint(port.ContainerPort))
+			if strings.Contains(port.Name, "metrics") {
+				named = append(named, endpoint(port.ContainerPort))
+			}
+		}
+	}
+	if len(named) > 0 {
+		return named
+	}
+	return all
+}
+
+// resync keeps the scraped pods in step with the workload: new replicas are
+// added as runtime targets and vanished ones removed. A failed listing
+// keeps the last known set.
+func (d *kubeDiscovery) resync(ctx context.Context, st *store, known []target) {
+	ticker := time.NewTicker(inClusterResync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			found, err := d.discover(ctx)
+			if err != nil {
+				continue
+			}
+			known = applyDiscovered(st, known, found)
+		}
+	}
+}
+
+func applyDiscovered(st *store, known, found []target) []target {
+	seen := map[string]bool{}
+	for _, t := range found {
+		seen[t.key()] = true
+		st.addTarget(t)
+	}
+	for _, t := range known {
+		if !seen[t.key()] {
+			st.removeTarget(t.key())
+		}
+	}
+	return found
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeKubeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func testPod(name, ip string, labels map[string]string) map[string]any {
+	return map[string]any{
+		"metadata": map[string]any{"name": name, "labels": labels},
+		"spec": map[string]any{"containers": []any{
+			map[string]any{"ports": []any{
+				map[string]any{"name": "http", "containerPort": 8080},
+				map[string]any{"name": "http-metrics", "containerPort": 9100},
+			}},
+		}},
+		"status": map[string]any{"phase": "Running", "podIP": ip},
+	}
+}
+
+func TestInClusterDiscover(t *testing.T) {
+	labels := map[string]string{"app": "api", "pod-template-hash": "abc"}
+	var selector, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/api/v1/namespaces/prod/pods/api-1":
+			writeKubeJSON(w, testPod("api-1", "10.0.0.1", labels))
+		case "/api/v1/namespaces/prod/pods":
+			selector = r.URL.Query().Get("labelSelector")
+			writeKubeJSON(w, map[string]any{"items": []any{
+				testPod("api-1", "10.0.0.1", labels),
+				testPod("api-2", "10.0.0.2", map[string]string{"app": "api", "pod-template-hash": "def"}),
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d := &kubeDiscovery{base: srv.URL, tokenPath: token, namespace: "prod", pod: "api-1", client: srv.Client()}
+
+	got, err := d.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []target{{addr: "10.0.0.1:9100", group: "api-1"}, {addr: "10.0.0.2:9100", group: "api-2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discover = %+v, want %+v", got, want)
+	}
+	if selector != "app=api" {
+		t.Errorf("labelSelector = %q, want the workload labels without pod-template-hash", selector)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	d.pod = "gone"
+	if _, err := d.discover(context.Background()); err == nil {
+		t.Error("discover of a missing pod should fail")
+	}
+}
+
+func TestPodTargets(t *testing.T) {
+	var p kubePod
+	p.Metadata.Name = "db-0"
+	p.Status.Phase = "Running"
+	p.Status.PodIP = "10.0.0.9"
+	p.Spec.Containers = []kubeContainer{{Ports: []kubePort{
+		{Name: "pg", ContainerPort: 5432},
+		{Name: "dns", ContainerPort: 53, Protocol: "UDP"},
+	}}}
+
+	if got := podTargets(p); !reflect.DeepEqual(got, []target{{addr: "10.0.0.9:5432", group: "db-0"}}) {
+		t.Errorf("without metrics ports = %+v, want every TCP port", got)
+	}
+	p.Metadata.Annotations = map[string]string{"prometheus.io/port": "9187", "prometheus.io/path": "/stats", "prometheus.io/scheme": "https"}
+	if got := podTargets(p); !reflect.DeepEqual(got, []target{{addr: "10.0.0.9:9187", group: "db-0", scheme: "https", path: "/stats"}}) {
+		t.Errorf("annotated = %+v", got)
+	}
+	p.Metadata.Annotations["prometheus.io/scrape"] = "false"
+	if got := podTargets(p); got != nil {
+		t.Errorf("scrape=false = %+v, want none", got)
+	}
+	delete(p.Metadata.Annotations, "prometheus.io/scrape")
+	p.Status.Phase = "Pending"
+	if got := podTargets(p); got != nil {
+		t.Errorf("pending pod = %+v, want none", got)
+	}
+}
+
+func TestApplyDiscovered(t *testing.T) {
+	st := newStore()
+	a, b, c := target{addr: "a:1", group: "a"}, target{addr: "b:1", group: "b"}, target{addr: "c:1", group: "c"}
+	known := applyDiscovered(st, nil, []target{a, b})
+	known = applyDiscovered(st, known, []target{b, c})
+	if got := st.activeTargets(nil); !reflect.DeepEqual(got, []target{b, c}) {
+		t.Errorf("active targets = %+v, want b and c", got)
+	}
+	if !reflect.DeepEqual(known, []target{b, c}) {
+		t.Errorf("known = %+v", known)
+	}
+}
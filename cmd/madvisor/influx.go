@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const influxMaxLine = 1 << 20
+
+type influxPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]float64
+}
+
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+		case quotes && s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeInflux(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func parseInfluxLine(line string) (influxPoint, error) {
+	sections := splitUnescaped(line, ' ', true)
+	var nonEmpty []string
+	for _, s := range sections {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	if len(nonEmpty) < 2 || len(nonEmpty) > 3 {
+		return influxPoint{}, fmt.Errorf("want measurement[,tags] fields [timestamp]")
+	}
+	if len(nonEmpty) == 3 {
+		if _, err := strconv.ParseInt(nonEmpty[2], 10, 64); err != nil {
+			return influxPoint{}, fmt.Errorf("invalid timestamp %q", nonEmpty[2])
+		}
+	}
+
+	keyParts := splitUnescaped(nonEmpty[0], ',', false)
+	p := influxPoint{measurement: unescapeInflux(keyParts[0]), fields: map[string]float64{}}
+	if p.measurement == "" {
+		return influxPoint{}, fmt.Errorf("empty measurement")
+	}
+	for _, tag := range keyParts[1:] {
+		kv := splitUnescaped(tag, '=', false)
+		if len(kv) != 2 || kv[0] == "" {
+			return influxPoint{}, fmt.Errorf("invalid tag %q", tag)
+		}
+		if p.tags == nil {
+			p.tags = map[string]string{}
+		}
+		p.tags[sanitizeName(unescapeInflux(kv[0]))] = unescapeInflux(kv[1])
+	}
+
+	for _, field := range splitUnescaped(nonEmpty[1], ',', true) {
+		kv := splitUnescaped(field, '=', true)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return influxPoint{}, fmt.Errorf("invalid field %q", field)
+		}
+		key, raw := unescapeInflux(kv[0]), kv[1]
+		switch {
+		case strings.HasPrefix(raw, `"`):
+			continue
+		case raw == "t" || raw == "T" || raw == "true" || raw == "True" || raw == "TRUE":
+			p.fields[key] = 1
+		case raw == "f" || raw == "F" || raw == "false" || raw == "False" || raw == "FALSE":
+			p.fields[key] = 0
+		default:
+			raw = strings.TrimRight(raw, "iu")
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return influxPoint{}, fmt.Errorf("invalid value for field %q", key)
+			}
+			p.fields[key] = v
+		}
+	}
+	return p, nil
+}
+
+func sanitizeName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		ok := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !ok {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func influxMetricName(measurement, field string) string {
+	if field == "value" {
+		return sanitizeName(measurement)
+	}
+	return sanitizeName(measurement + "_" + field)
+}
+
+func ingestInflux(r io.Reader, src string, st *store) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), influxMaxLine)
+	n := 0
+	lineNo := 0
+	var firstErr error
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseInfluxLine(line)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			continue
+		}
+		now := time.Now()
+		for field, v := range p.fields {
+			name, labels, keep := applyRelabel(globalRelabel, src, influxMetricName(p.measurement, field), p.tags)
+			if !keep {
+				continue
+			}
+			st.ingest(src, name, labels, "", "", v, now)
+			n++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	return n, firstErr
+}
+
+// --- listeners ---
+
+type influxListener struct {
+	network string
+	addr    string
+}
+
+func parseInfluxListen(spec string) ([]influxListener, error) {
+	var out []influxListener
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		u, err := url.Parse(part)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid influx listener %q, want udp://, tcp:// or http://host:port", part)
+		}
+		switch u.Scheme {
+		case "udp", "tcp", "http":
+		default:
+			return nil, fmt.Errorf("invalid influx listener %q: unsupported scheme %q", part, u.Scheme)
+		}
+		out = append(out, influxListener{network: u.Scheme, addr: u.Host})
+	}
+	return out, nil
+}
+
+func influxSource(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return "influx:" + host
+	}
+	return "influx:" + addr
+}
+
+func influxHTTPHandler(st *store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := ingestInflux(r.Body, influxSource(r.RemoteAddr), st); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func startInflux(l influxListener) (func(context.Context, *store), error) {
+	switch l.network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", l.addr)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, st *store) { go serveInfluxUDP(ctx, conn, st) }, nil
+	case "tcp":
+		ln, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, st *store) { go serveInfluxTCP(ctx, ln, st) }, nil
+	default:
+		ln, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, st *store) {
+			mux := http.NewServeMux()
+			mux.Handle("/write", influxHTTPHandler(st))
+			mux.Handle("/api/v2/write", influxHTTPHandler(st))
+			srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				<-ctx.Done()
+				srv.Close()
+			}()
+			go srv.Serve(ln)
+		}, nil
+	}
+}
+
+func serveInfluxUDP(ctx context.Context, conn net.PacketConn, st *store) {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		ingestInflux(bytes.NewReader(buf[:n]), influxSource(addr.String()), st)
+	}
+}
+
+func serveInfluxTCP(ctx context.Context, ln net.Listener, st *store) {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			ingestInflux(conn, influxSource(conn.RemoteAddr().String()), st)
+		}()
+	}
+}
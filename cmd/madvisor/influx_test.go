@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	p, err := parseInfluxLine(`cpu\ load,host=web\,1,region=us-east usage_idle=92.5,usage_user=3i,up=t,note="a b,c=d" 1700000000000000000`)
+	if err != nil {
+		t.Fatalf("parseInfluxLine: %v", err)
+	}
+	if p.measurement != "cpu load" {
+		t.Errorf("measurement = %q", p.measurement)
+	}
+	if p.tags["host"] != "web,1" || p.tags["region"] != "us-east" {
+		t.Errorf("tags = %v", p.tags)
+	}
+	if p.fields["usage_idle"] != 92.5 || p.fields["usage_user"] != 3 || p.fields["up"] != 1 {
+		t.Errorf("fields = %v", p.fields)
+	}
+	if _, ok := p.fields["note"]; ok {
+		t.Error("string fields should be skipped")
+	}
+
+	for _, bad := range []string{"cpu", "cpu value=abc", "cpu,host value=1", "cpu value=1 notatime", ",t=1 value=1"} {
+		if _, err := parseInfluxLine(bad); err == nil {
+			t.Errorf("parseInfluxLine(%q) should fail", bad)
+		}
+	}
+}
+
+func TestInfluxMetricName(t *testing.T) {
+	tests := map[[2]string]string{
+		{"cpu", "usage_idle"}: "cpu_usage_idle",
+		{"disk.io", "reads"}:  "disk_io_reads",
+		{"temp", "value"}:     "temp",
+		{"9p", "x"}:           "_p_x",
+	}
+	for in, want := range tests {
+		if got := influxMetricName(in[0], in[1]); got != want {
+			t.Errorf("influxMetricName(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestIngestInflux(t *testing.T) {
+	st := newStore()
+	body := "mem,host=a used=10,free=20\n# comment\n\nbroken\nmem,host=b used=5\n"
+	n, err := ingestInflux(strings.NewReader(body), "influx:test", st)
+	if n != 3 {
+		t.Errorf("ingested %d samples, want 3", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("err = %v, want line 4 error", err)
+	}
+	if s := st.get("mem_used{host=a}"); s == nil || s.last() != 10 {
+		t.Errorf("mem_used{host=a} = %v", s)
+	}
+}
+
+func TestParseInfluxListen(t *testing.T) {
+	got, err := parseInfluxListen("udp://:8089, tcp://127.0.0.1:8094,http://:8086")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != (influxListener{"udp", ":8089"}) || got[1].addr != "127.0.0.1:8094" {
+		t.Errorf("listeners = %+v", got)
+	}
+	for _, bad := range []string{":8089", "ftp://:21", "udp://"} {
+		if _, err := parseInfluxListen(bad); err == nil {
+			t.Errorf("parseInfluxListen(%q) should fail", bad)
+		}
+	}
+}
+
+func TestInfluxHTTP(t *testing.T) {
+	st := newStore()
+	srv := httptest.NewServer(influxHTTPHandler(st))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/write", "text/plain", strings.NewReader("load,host=x value=1.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+	if st.get("load{host=x}") == nil {
+		t.Errorf("names = %v", st.names())
+	}
+
+	resp, err = http.Post(srv.URL+"/write", "text/plain", strings.NewReader("garbage\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad body status = %d", resp.StatusCode)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInfluxUDPAndTCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := newStore()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveInfluxUDP(ctx, pc, st)
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveInfluxTCP(ctx, tcpLn, st)
+
+	conn, err := net.Dial("tcp", tcpLn.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("net,if=eth0 rx=100i\n"))
+	conn.Close()
+	waitFor(t, func() bool { return st.get("net_rx{if=eth0}") != nil })
+
+	udp, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	udp.Write([]byte("disk,dev=sda used_percent=42\n"))
+	waitFor(t, func() bool { return st.get("disk_used_percent{dev=sda}") != nil })
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// targetJob is a --job entry: the job name for every target, or only for
+// the targets of a group or a single host:port when scope is set.
+type targetJob struct {
+	scope string
+	name  string
+}
+
+var targetJobs []targetJob
+
+// parseTargetJob parses "[group=|host:port=]name".
+func parseTargetJob(spec string) (targetJob, error) {
+	var j targetJob
+	j.name = spec
+	if i := strings.LastIndex(spec, "="); i >= 0 {
+		j.scope, j.name = strings.TrimSpace(spec[:i]), spec[i+1:]
+		if j.scope == "" {
+			return targetJob{}, fmt.Errorf("invalid job %q, want \"name\", \"group=name\" or \"host:port=name\"", spec)
+		}
+	}
+	j.name = strings.TrimSpace(j.name)
+	if j.name == "" {
+		return targetJob{}, fmt.Errorf("invalid job %q, want \"name\", \"group=name\" or \"host:port=name\"", spec)
+	}
+	return j, nil
+}
+
+func parseTargetJobs(specs []string) ([]targetJob, error) {
+	var out []targetJob
+	for _, spec := range specs {
+		j, err := parseTargetJob(spec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, j)
+	}
+	return out, nil
+}
+
+// job is t's job name: a --job entry for its host:port wins over one for its
+// group, which wins over an unscoped one. Without any, t has no job label.
+func (t target) job() string {
+	var group, all string
+	for _, j := range targetJobs {
+		switch {
+		case j.scope == t.addr:
+			return j.name
+		case j.scope == "":
+			all = j.name
+		case t.group != "" && j.scope == t.group:
+			group = j.name
+		}
+	}
+	if group != "" {
+		return group
+	}
+	return all
+}
+
+// title names t in the targets panel and picker: group/key, with the job
+// appended when it is set.
+func (t target) title() string {
+	name := t.key()
+	if t.group != "" {
+		name = t.group + "/" + t.key()
+	}
+	if job := t.job(); job != "" {
+		name += " (" + job + ")"
+	}
+	return name
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestParseTargetJob(t *testing.T) {
+	tests := []struct {
+		in   string
+		want targetJob
+	}{
+		{"node", targetJob{name: "node"}},
+		{"api=api-server", targetJob{scope: "api", name: "api-server"}},
+		{"db:9187 = postgres", targetJob{scope: "db:9187", name: "postgres"}},
+		{"[::1]:9100=node", targetJob{scope: "[::1]:9100", name: "node"}},
+	}
+	for _, tt := range tests {
+		got, err := parseTargetJob(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTargetJob(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "api=", "=node", " "} {
+		if _, err := parseTargetJob(bad); err == nil {
+			t.Errorf("parseTargetJob(%q) should fail", bad)
+		}
+	}
+}
+
+func TestTargetJob(t *testing.T) {
+	defer func() { targetJobs = nil }()
+	var err error
+	targetJobs, err = parseTargetJobs([]string{"db=postgres", "default", "b:1=special"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		t    target
+		want string
+	}{
+		{target{addr: "a:1"}, "default"},
+		{target{addr: "a:1", group: "db"}, "postgres"},
+		{target{addr: "b:1", group: "db"}, "special"},
+		{target{addr: "c:1", group: "api"}, "default"},
+	}
+	for _, tt := range tests {
+		if got := tt.t.job(); got != tt.want {
+			t.Errorf("job(%+v) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+	if got := (target{addr: "a:1", group: "db"}).title(); got != "db/a:1 (postgres)" {
+		t.Errorf("title = %q", got)
+	}
+
+	got := (target{addr: "a:1", group: "db"}).attachLabels(map[string]string{"job": "exporter"})
+	if got["job"] != "postgres" || got["exported_job"] != "exporter" || got["group"] != "db" {
+		t.Errorf("attachLabels = %v, want job=postgres exported_job=exporter group=db", got)
+	}
+
+	targetJobs = nil
+	if got := (target{addr: "a:1"}).title(); got != "a:1" {
+		t.Errorf("title without job = %q", got)
+	}
+	if got := (target{addr: "a:1"}).attachLabels(nil); got != nil {
+		t.Errorf("attachLabels without job = %v, want nil", got)
+	}
+}
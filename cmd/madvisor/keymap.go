@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// keyEnv is what the metric list's single-key bindings act on.
+type keyEnv struct {
+	cancel  context.CancelFunc
+	ui      *uiState
+	st      *store
+	targets []target
+	nav     *navigator
+	titles  *fullChartTitle
+}
+
+// keyBinding runs for each character in keys, which it receives as r.
+type keyBinding struct {
+	keys string
+	run  func(e *keyEnv, r rune)
+}
+
+// metricListKeys are the single-key commands of the dashboard outside the
+// filter, command line and prompts. Type-ahead jumps start on any other
+// printable character.
+var metricListKeys = []keyBinding{
+	{"qQ", func(e *keyEnv, r rune) { e.cancel() }},
+	{"k", func(e *keyEnv, r rune) {
+		e.ui.moveUp()
+		e.nav.moved(time.Now())
+	}},
+	{"j", func(e *keyEnv, r rune) {
+		e.ui.moveDown()
+		e.nav.moved(time.Now())
+	}},
+	{"/", func(e *keyEnv, r rune) { e.ui.startFilter() }},
+	{"?", func(e *keyEnv, r rune) { e.ui.startSearch() }},
+	{"F", func(e *keyEnv, r rune) { e.ui.setMessage(highlightFilterMessage(e.ui.toggleHighlightFilter())) }},
+	{"K", func(e *keyEnv, r rune) { e.ui.setMessage(rateColumnsMessage(toggleRateColumns())) }},
+	{"Y", func(e *keyEnv, r rune) { e.ui.setMessage(e.ui.toggleYPin()) }},
+	{"y", func(e *keyEnv, r rune) { e.ui.startCommandWith("yrange ") }},
+	{"=", func(e *keyEnv, r rune) { e.ui.startCommandWith("expr ") }},
+	{"X", func(e *keyEnv, r rune) {
+		if name := e.ui.selectedKey(); name != "" {
+			runCommandLine("deny "+regexp.QuoteMeta(name), e.ui, e.st)
+		}
+	}},
+	{"Z", func(e *keyEnv, r rune) { e.ui.setMessage(yZeroMessage(e.ui.toggleYZero())) }},
+	{"O", func(e *keyEnv, r rune) { e.ui.setMessage(sortMessage(e.ui.cycleSort())) }},
+	{"P", func(e *keyEnv, r rune) { runCommandLine("table md", e.ui, e.st) }},
+	{"L", func(e *keyEnv, r rune) {
+		if msg, ok := e.titles.message(); ok {
+			e.ui.setMessage(msg)
+		}
+	}},
+	{":", func(e *keyEnv, r rune) { e.ui.startCommand() }},
+	{"u", func(e *keyEnv, r rune) {
+		if !e.ui.undo(e.st) {
+			e.ui.setMessage("nothing to undo")
+		}
+	}},
+	{"U", func(e *keyEnv, r rune) {
+		if !e.ui.redo(e.st) {
+			e.ui.setMessage("nothing to redo")
+		}
+	}},
+	{"]+", func(e *keyEnv, r rune) { changeRateWindow(e.st, rateWindowUp) }},
+	{"[-", func(e *keyEnv, r rune) { changeRateWindow(e.st, rateWindowDown) }},
+	{"eE", func(e *keyEnv, r rune) {
+		format := "png"
+		if r == 'E' {
+			format = "svg"
+		}
+		name, list := chartSelection(e.ui, e.st)
+		path, err := exportChart(flagExportDir, format, name, list, e.st)
+		if err != nil {
+			e.ui.setMessage("export: " + err.Error())
+		} else {
+			e.ui.setMessage("chart exported to " + path)
+		}
+	}},
+	{"p ", func(e *keyEnv, r rune) { e.st.togglePaused() }},
+	{"r", func(e *keyEnv, r rune) { resetSelection(e.ui, e.st) }},
+	{"R", func(e *keyEnv, r rune) { e.st.resetAll() }},
+	{"g", func(e *keyEnv, r rune) { e.ui.cycleGroup(targetGroups(e.targets)) }},
+	{"123456789", func(e *keyEnv, r rune) {
+		if e.ui.switchTab(e.st, int(r-'0')) {
+			e.ui.setMessage(fmt.Sprintf("tab %d", e.ui.currentTab()))
+		}
+	}},
+	{"s", func(e *keyEnv, r rune) {
+		msg, err := openSplit(e.targets, e.ui.selectedKey(), e.ui.group())
+		if err != nil {
+			msg = "split: " + err.Error()
+		}
+		e.ui.setMessage(msg)
+	}},
+	{"i", func(e *keyEnv, r rune) { e.ui.togglePanel(panelInfo) }},
+	{"m", func(e *keyEnv, r rune) { e.ui.togglePanel(panelMatrix) }},
+	{"T", func(e *keyEnv, r rune) {
+		if e.ui.panel() == panelTargets {
+			e.ui.togglePanel(panelTargets)
+		} else {
+			e.ui.openTargetPicker()
+		}
+	}},
+	{"C", func(e *keyEnv, r rune) { e.ui.togglePanel(panelCards) }},
+	{"A", func(e *keyEnv, r rune) { e.ui.togglePanel(panelAlerts) }},
+	{"D", func(e *keyEnv, r rune) { e.ui.togglePanel(panelCanary) }},
+	{"B", func(e *keyEnv, r rune) { e.ui.togglePanel(panelBaseline) }},
+	{"S", func(e *keyEnv, r rune) {
+		if n := e.ui.ackFiring(defaultSilence, time.Now()); n > 0 {
+			e.ui.setMessage(fmt.Sprintf("silenced %d firing watch(es) for %s", n, defaultSilence))
+		} else {
+			e.ui.setMessage("no unsilenced watches firing")
+		}
+	}},
+	{"t", func(e *keyEnv, r rune) {
+		if e.ui.selectedKey() != "" {
+			e.ui.startTransform()
+		}
+	}},
+	{"f", func(e *keyEnv, r rune) { e.ui.setMessage("forecast: " + e.ui.cycleForecast().String()) }},
+	{"h", func(e *keyEnv, r rune) { e.ui.toggleHeatmap() }},
+	{"M", func(e *keyEnv, r rune) { e.ui.setMessage("chart: " + e.ui.cycleMultiples().String()) }},
+	{"w", func(e *keyEnv, r rune) {
+		if s := selectedSeries(e.ui, e.st); s != nil {
+			e.ui.startWatch(s.key, s.displayName())
+		} else if name := e.ui.selectedKey(); name != "" {
+			e.ui.startWatch(metricWatchPrefix+name, metricAlias(name))
+			e.ui.setMessage("watch: whole metric, only absent applies (Tab to pick a series)")
+		} else {
+			e.ui.setMessage("watch: select a metric or series first")
+		}
+	}},
+	{"W", func(e *keyEnv, r rune) { e.ui.setMessage(fmt.Sprintf("cleared %d watch(es)", e.ui.clearWatches())) }},
+	{"H", func(e *keyEnv, r rune) {
+		if e.ui.toggleShowHidden() {
+			e.ui.setMessage("showing metrics hidden by display rules")
+		} else {
+			e.ui.setMessage("hiding metrics per display rules")
+		}
+		names, _, _ := listKeys(e.st, e.ui, e.ui.group())
+		e.ui.setKeys(names)
+	}},
+	{"v", func(e *keyEnv, r rune) {
+		if e.ui.toggleRawList() {
+			e.ui.setMessage("metric list: alphabetical")
+		} else if active := detectPresets(globalPresets, visibleNames(e.st, e.ui.group())); len(active) > 0 {
+			e.ui.setMessage("metric list: " + strings.Join(presetNames(active), ", ") + " preset")
+		} else {
+			e.ui.setMessage("metric list: no preset detected")
+		}
+	}},
+	{"d", func(e *keyEnv, r rune) { e.ui.toggleDual() }},
+	{"o", func(e *keyEnv, r rune) {
+		if name := e.ui.selectedKey(); name != "" {
+			e.ui.toggleClip(name)
+		}
+	}},
+}
+
+// keyHandlers indexes metricListKeys by key.
+var keyHandlers = func() map[rune]func(*keyEnv, rune) {
+	m := map[rune]func(*keyEnv, rune){}
+	for _, b := range metricListKeys {
+		for _, r := range b.keys {
+			m[r] = b.run
+		}
+	}
+	return m
+}()
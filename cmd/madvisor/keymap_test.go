@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMetricListKeysBoundOnce(t *testing.T) {
+	seen := map[rune]bool{}
+	for _, b := range metricListKeys {
+		for _, r := range b.keys {
+			if seen[r] {
+				t.Errorf("%q is bound twice", r)
+			}
+			seen[r] = true
+		}
+	}
+	if len(keyHandlers) != len(seen) {
+		t.Errorf("keyHandlers has %d keys, want %d", len(keyHandlers), len(seen))
+	}
+}
+
+func TestKeyHandlers(t *testing.T) {
+	st := newStore()
+	st.update("cpu_usage", nil, "", "gauge", 1)
+	ui := &uiState{}
+	ui.setKeys(st.names())
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &keyEnv{cancel: cancel, ui: ui, st: st, nav: newNavigator(), titles: &fullChartTitle{}}
+
+	keyHandlers['i'](e, 'i')
+	if ui.panel() != panelInfo {
+		t.Errorf("i: panel = %v, want the info panel", ui.panel())
+	}
+	keyHandlers['q'](e, 'q')
+	if ctx.Err() == nil {
+		t.Error("q should quit")
+	}
+}
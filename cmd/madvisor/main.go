@@ -3,17 +3,18 @@ package main
 import (
 	"bufio"
 	"context"
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
 	"os"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mum4k/termdash"
@@ -42,7 +43,12 @@ const (
 	defaultRateWindow = 5 * time.Second
 )
 
+// rateWindowSteps are the rate windows +/- step through. The sub-second ones
+// suit high-frequency push sources; with 1s scrapes they fall back to the
+// last two samples.
 var rateWindowSteps = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
 	1 * time.Second,
 	2 * time.Second,
 	5 * time.Second,
@@ -57,7 +63,7 @@ type rateWindowState struct {
 	idx int
 }
 
-var rws = rateWindowState{idx: 2}
+var rws = rateWindowState{idx: rateWindowStep(defaultRateWindow)}
 
 func rateWindowGet() time.Duration {
 	rws.mu.Lock()
@@ -86,13 +92,18 @@ func rateWindowDown() time.Duration {
 func rateWindowSet(d time.Duration) {
 	rws.mu.Lock()
 	defer rws.mu.Unlock()
+	rws.idx = rateWindowStep(d)
+}
+
+// rateWindowStep is the index of the smallest step of at least d, or of the
+// largest step.
+func rateWindowStep(d time.Duration) int {
 	for i, s := range rateWindowSteps {
 		if s >= d {
-			rws.idx = i
-			return
+			return i
 		}
 	}
-	rws.idx = len(rateWindowSteps) - 1
+	return len(rateWindowSteps) - 1
 }
 
 const logo = `
@@ -119,25 +130,48 @@ type metricSeries struct {
 	times  []time.Time
 	idx    int
 	full   bool
+	tiers  []*historyTier
+
+	firstSeen time.Time
+	samples   int
+	resets    []time.Time
+	endedAt   time.Time
+	source    string
 }
 
 func (s *metricSeries) push(v float64) {
 	now := time.Now()
 	s.values[s.idx] = v
 	s.times[s.idx] = now
+	s.samples++
 	s.idx = (s.idx + 1) % ringSize
 	if s.idx == 0 {
 		s.full = true
 	}
+	s.feedTiers(v, now)
 }
 
 func (s *metricSeries) pushAt(v float64, t time.Time) {
 	s.values[s.idx] = v
 	s.times[s.idx] = t
+	s.samples++
 	s.idx = (s.idx + 1) % ringSize
 	if s.idx == 0 {
 		s.full = true
 	}
+	s.feedTiers(v, t)
+}
+
+func (s *metricSeries) reset() {
+	for i := range s.values {
+		s.values[i] = 0
+		s.times[i] = time.Time{}
+	}
+	s.idx = 0
+	s.full = false
+	for _, t := range s.tiers {
+		t.reset()
+	}
 }
 
 func detectMetricType(name, mtype string) string {
@@ -171,10 +205,17 @@ func (s *metricSeries) isCounter() bool {
 }
 
 func matchUnit(name string) *UnitMatch {
+	if o := overriddenUnit(name); o != nil {
+		return o
+	}
+	var m *UnitMatch
 	if globalUnitMatcher != nil {
-		return globalUnitMatcher.Match(name)
+		m = globalUnitMatcher.Match(name)
 	}
-	return nil
+	if d := declaredUnitMatch(name, m); d != nil {
+		return d
+	}
+	return m
 }
 
 func isTimestampMetric(name string) bool {
@@ -183,14 +224,24 @@ func isTimestampMetric(name string) bool {
 }
 
 func (s *metricSeries) shouldRate() bool {
-	dt := s.detectedType()
+	return shouldRateType(s.name, s.mtype)
+}
+
+func shouldRateType(name, mtype string) bool {
+	switch chartModeFor(name) {
+	case "rate":
+		return true
+	case "raw":
+		return false
+	}
+	dt := detectMetricType(name, mtype)
 	if dt == "counter" {
 		return true
 	}
-	if dt == "summary" && (strings.HasSuffix(s.name, "_count") || strings.HasSuffix(s.name, "_sum")) {
+	if dt == "summary" && (strings.HasSuffix(name, "_count") || strings.HasSuffix(name, "_sum")) {
 		return true
 	}
-	if dt == "histogram" && (strings.HasSuffix(s.name, "_count") || strings.HasSuffix(s.name, "_sum")) {
+	if dt == "histogram" && (strings.HasSuffix(name, "_count") || strings.HasSuffix(name, "_sum")) {
 		return true
 	}
 	return false
@@ -201,92 +252,101 @@ func (s *metricSeries) rate(window time.Duration) float64 {
 	if n < 2 {
 		return 0
 	}
+	return windowRate(s.slice(), s.timeSlice(), n-1, window, s.resets)
+}
 
-	newestIdx := s.idx - 1
-	if newestIdx < 0 {
-		newestIdx = ringSize - 1
-	}
-	newest := s.values[newestIdx]
-	newestT := s.times[newestIdx]
-	cutoff := newestT.Add(-window)
-
-	oldestIdx := newestIdx
-	oldestVal := newest
-	oldestT := newestT
-
-	for j := 1; j < n; j++ {
-		i := newestIdx - j
-		if i < 0 {
-			i += ringSize
+// windowRate is the per-second increase from the oldest sample within window
+// of values[end] to it, stopping at a counter reset. A window shorter than
+// the sample spacing still spans the previous sample, so sub-second windows
+// over slower sources give the last interval's rate rather than 0.
+func windowRate(values []float64, times []time.Time, end int, window time.Duration, resets []time.Time) float64 {
+	cutoff := times[end].Add(-window)
+	oldest := end
+	for i := end - 1; i >= 0; i-- {
+		if values[i] > values[i+1] || resetBetween(resets, times[i], times[i+1]) {
+			break
 		}
-		if s.times[i].Before(cutoff) {
+		if times[i].Before(cutoff) {
+			if oldest == end {
+				oldest = i
+			}
 			break
 		}
-		oldestIdx = i
-		oldestVal = s.values[i]
-		oldestT = s.times[i]
+		oldest = i
 	}
-	_ = oldestIdx
 
-	elapsed := newestT.Sub(oldestT).Seconds()
+	elapsed := times[end].Sub(times[oldest]).Seconds()
 	if elapsed <= 0 {
 		return 0
 	}
-	delta := newest - oldestVal
-	if delta < 0 {
-		return 0
-	}
-	return delta / elapsed
+	return (values[end] - values[oldest]) / elapsed
 }
 
-func (s *metricSeries) count() int {
+func (s *metricSeries) rawCount() int {
 	if s.full {
 		return ringSize
 	}
 	return s.idx
 }
 
+func (s *metricSeries) count() int {
+	n := s.rawCount()
+	if len(s.tiers) > 0 {
+		older, _ := s.tierHistory()
+		n += len(older)
+	}
+	return n
+}
+
+func (s *metricSeries) oldestRaw() time.Time {
+	if s.full {
+		return s.times[s.idx]
+	}
+	return s.times[0]
+}
+
 func (s *metricSeries) rateSlice(window time.Duration) []float64 {
 	n := s.count()
 	if n < 2 {
 		return nil
 	}
 
-	start := 0
-	if s.full {
-		start = s.idx
-	}
-
+	values := s.slice()
+	times := s.timeSlice()
 	rates := make([]float64, 0, n-1)
-	prevVal := s.values[start%ringSize]
-	prevT := s.times[start%ringSize]
-
 	for j := 1; j < n; j++ {
-		i := (start + j) % ringSize
-		dt := s.times[i].Sub(prevT).Seconds()
-		var r float64
-		if dt > 0 {
-			delta := s.values[i] - prevVal
-			if delta < 0 {
-				delta = 0
-			}
-			r = delta / dt
-		}
-		rates = append(rates, r)
-		prevVal = s.values[i]
-		prevT = s.times[i]
+		rates = append(rates, windowRate(values, times, j, window, s.resets))
 	}
 	return rates
 }
 
 func (s *metricSeries) slice() []float64 {
+	out, _ := s.tierHistory()
 	if !s.full {
-		return append([]float64{}, s.values[:s.idx]...)
+		return append(out, s.values[:s.idx]...)
 	}
-	out := make([]float64, ringSize)
-	copy(out, s.values[s.idx:])
-	copy(out[ringSize-s.idx:], s.values[:s.idx])
-	return out
+	out = append(out, s.values[s.idx:]...)
+	return append(out, s.values[:s.idx]...)
+}
+
+func (s *metricSeries) timeSlice() []time.Time {
+	_, out := s.tierHistory()
+	if !s.full {
+		return append(out, s.times[:s.idx]...)
+	}
+	out = append(out, s.times[s.idx:]...)
+	return append(out, s.times[:s.idx]...)
+}
+
+func (s *metricSeries) lastTime() time.Time {
+	if s.idx == 0 && !s.full {
+		return time.Time{}
+	}
+	i := s.idx - 1
+	if i < 0 {
+		i = ringSize - 1
+	}
+	return s.times[i]
 }
 
 func (s *metricSeries) last() float64 {
@@ -301,19 +361,22 @@ func (s *metricSeries) last() float64 {
 }
 
 func (s *metricSeries) displayName() string {
-	if len(s.labels) == 0 {
-		return s.name
-	}
-	parts := make([]string, 0, len(s.labels))
+	name := metricAlias(s.name)
 	keys := make([]string, 0, len(s.labels))
 	for k := range s.labels {
-		keys = append(keys, k)
+		if !labelHidden(s.name, k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return name
 	}
 	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
 	for _, k := range keys {
 		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, s.labels[k]))
 	}
-	return s.name + "{" + strings.Join(parts, ",") + "}"
+	return name + "{" + strings.Join(parts, ",") + "}"
 }
 
 // --- value formatting ---
@@ -336,6 +399,8 @@ func formatValue(name string, v float64) string {
 		return formatDuration(v / 1000)
 	case "percent":
 		return fmt.Sprintf("%.1f%%", v)
+	case "ratio":
+		return fmt.Sprintf("%.1f%%", v*100)
 	case "timestamp":
 		return formatTimestamp(v)
 	case "count":
@@ -346,6 +411,9 @@ func formatValue(name string, v float64) string {
 }
 
 func formatBytes(b float64) string {
+	if b < 0 {
+		return "-" + formatBytes(-b)
+	}
 	switch {
 	case b >= 1<<40:
 		return fmt.Sprintf("%.2f TiB", b/(1<<40))
@@ -361,6 +429,9 @@ func formatBytes(b float64) string {
 }
 
 func formatDuration(sec float64) string {
+	if sec < 0 {
+		return "-" + formatDuration(-sec)
+	}
 	switch {
 	case sec >= 86400:
 		return fmt.Sprintf("%.1fd", sec/86400)
@@ -380,6 +451,9 @@ func formatDuration(sec float64) string {
 }
 
 func formatCount(v float64) string {
+	if v < 0 {
+		return "-" + formatCount(-v)
+	}
 	switch {
 	case v >= 1e9:
 		return fmt.Sprintf("%.2fG", v/1e9)
@@ -461,6 +535,15 @@ func rateAxisFormatter() linechart.ValueFormatter {
 	}
 }
 
+func genericAxisFormatter() linechart.ValueFormatter {
+	return func(v float64) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return formatGeneric(v)
+	}
+}
+
 func yAxisFormatter(metricName string) linechart.ValueFormatter {
 	return func(v float64) string {
 		if math.IsNaN(v) {
@@ -486,12 +569,38 @@ type store struct {
 	order       []string
 	metricNames []string
 	nameSet     map[string]bool
+	observe     func(sample)
+	onCrash     func(crash)
+
+	owners     map[string]string
+	collided   map[string]bool
+	collisions []string
+
+	paused        bool
+	lowPower      bool
+	lowPowerSpans []lowPowerSpan
+	annotations   []annotation
+	health        map[string]*targetHealth
+
+	addedTargets   []target
+	removedTargets map[string]bool
+	created        map[string]float64
+	targetIssues   []string
+	crashes        []crash
+	promqlLast     map[string]time.Time
+
+	// exprMu serialises evaluating the ad-hoc expression with replacing it,
+	// so a replaced expression's results are not recorded after being dropped.
+	exprMu sync.Mutex
+	expr   *exprQuery
 }
 
 func newStore() *store {
 	return &store{
-		series:  make(map[string]*metricSeries),
-		nameSet: make(map[string]bool),
+		series:   make(map[string]*metricSeries),
+		nameSet:  make(map[string]bool),
+		owners:   make(map[string]string),
+		collided: make(map[string]bool),
 	}
 }
 
@@ -512,10 +621,84 @@ func seriesKey(name string, labels map[string]string) string {
 }
 
 func (st *store) update(name string, labels map[string]string, help, mtype string, value float64) {
+	st.updateAt(name, labels, help, mtype, value, time.Now())
+}
+
+func (st *store) updateAt(name string, labels map[string]string, help, mtype string, value float64, t time.Time) {
+	labels = redactLabels(labels)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.updateLocked("", name, labels, help, mtype, value, t)
+}
+
+func (st *store) ingest(src, name string, labels map[string]string, help, mtype string, value float64, t time.Time) {
+	labels = redactLabels(labels)
 	st.mu.Lock()
 	defer st.mu.Unlock()
 	key := seriesKey(name, labels)
+	switch owner, owned := st.owners[key]; {
+	case st.collided[key]:
+		labels = withInstance(labels, src)
+	case owned && owner != src:
+		st.collided[key] = true
+		st.collisions = append(st.collisions, fmt.Sprintf("%s from %s and %s", key, owner, src))
+		st.rekeyLocked(key, withInstance(st.series[key].labels, owner))
+		labels = withInstance(labels, src)
+	default:
+		st.owners[key] = src
+	}
+	st.updateLocked(src, name, labels, help, mtype, value, t)
+}
+
+func (st *store) rekeyLocked(key string, labels map[string]string) {
+	s, ok := st.series[key]
+	if !ok {
+		return
+	}
+	newKey := seriesKey(s.name, labels)
+	delete(st.series, key)
+	s.key = newKey
+	s.labels = labels
+	st.series[newKey] = s
+	for i, k := range st.order {
+		if k == key {
+			st.order[i] = newKey
+			break
+		}
+	}
+	sort.Strings(st.order)
+}
+
+func (st *store) collisionList() []string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]string{}, st.collisions...)
+}
+
+func withInstance(labels map[string]string, instance string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	if v, ok := out[instanceLabel]; ok && v != instance {
+		out["exported_"+instanceLabel] = v
+	}
+	out[instanceLabel] = instance
+	return out
+}
+
+func (st *store) updateLocked(src, name string, labels map[string]string, help, mtype string, value float64, t time.Time) {
+	if st.paused || metricExcluded(name) {
+		return
+	}
+	key := seriesKey(name, labels)
 	s, ok := st.series[key]
+	if isStaleMarker(value) {
+		if ok {
+			s.endedAt = t
+		}
+		return
+	}
 	if !ok {
 		s = &metricSeries{
 			key:    key,
@@ -525,6 +708,9 @@ func (st *store) update(name string, labels map[string]string, help, mtype strin
 			mtype:  mtype,
 			values: make([]float64, ringSize),
 			times:  make([]time.Time, ringSize),
+			tiers:  newHistoryTiers(globalHistoryTiers),
+
+			firstSeen: t,
 		}
 		st.series[key] = s
 		st.order = append(st.order, key)
@@ -535,7 +721,93 @@ func (st *store) update(name string, labels map[string]string, help, mtype strin
 			sort.Strings(st.metricNames)
 		}
 	}
-	s.push(value)
+	s.endedAt = time.Time{}
+	if src != "" {
+		s.source = src
+	}
+	s.pushAt(value, t)
+	// Expression results are derived from stored series, so recording them
+	// would replay them twice and export what was never scraped.
+	if st.observe != nil && src != exprSource {
+		st.observe(sample{Time: t, Name: name, Labels: labels, Type: mtype, Help: help, Value: value, Source: src})
+	}
+}
+
+func (st *store) resetSeries(key string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.series[key]
+	if !ok {
+		return false
+	}
+	s.reset()
+	return true
+}
+
+func (st *store) resetName(name string) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	n := 0
+	for _, s := range st.series {
+		if s.name == name {
+			s.reset()
+			n++
+		}
+	}
+	return n
+}
+
+func (st *store) resetAll() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, s := range st.series {
+		s.reset()
+	}
+	return len(st.series)
+}
+
+// dropSeries removes the series drop selects, along with metric names left
+// without series.
+func (st *store) dropSeries(drop func(s *metricSeries) bool) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	removed := 0
+	names := map[string]bool{}
+	for key, s := range st.series {
+		if !drop(s) {
+			continue
+		}
+		delete(st.series, key)
+		delete(st.owners, key)
+		names[s.name] = true
+		removed++
+	}
+	if removed == 0 {
+		return 0
+	}
+	order := st.order[:0]
+	for _, k := range st.order {
+		if _, ok := st.series[k]; ok {
+			order = append(order, k)
+		}
+	}
+	st.order = order
+	for _, s := range st.series {
+		delete(names, s.name)
+	}
+	if len(names) > 0 {
+		kept := st.metricNames[:0]
+		for _, n := range st.metricNames {
+			if names[n] {
+				delete(st.nameSet, n)
+				continue
+			}
+			kept = append(kept, n)
+		}
+		st.metricNames = kept
+		sort.Strings(st.metricNames)
+	}
+	return removed
 }
 
 func (st *store) snapshot() []*metricSeries {
@@ -579,6 +851,24 @@ func (st *store) seriesCount(name string) int {
 	return count
 }
 
+func (st *store) namesInGroup(group string) []string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	present := map[string]bool{}
+	for _, s := range st.series {
+		if inGroup(s, group) {
+			present[s.name] = true
+		}
+	}
+	var out []string
+	for _, name := range st.metricNames {
+		if present[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
 func (st *store) get(key string) *metricSeries {
 	st.mu.RLock()
 	defer st.mu.RUnlock()
@@ -599,27 +889,39 @@ func (st *store) firstType(name string) string {
 
 // --- scraper ---
 
-func scrape(ctx context.Context, targets []string, st *store) {
+func scrape(ctx context.Context, targets []target, st *store) {
+	supervise(ctx, st, "scrape", func(ctx context.Context) { scrapeLoop(ctx, targets, st) })
+}
+
+func scrapeLoop(ctx context.Context, targets []target, st *store) {
 	client := &http.Client{Timeout: 2 * time.Second}
 
-	for _, target := range targets {
-		scrapeTarget(client, target, st)
+	for _, tgt := range targets {
+		scrapeGuarded(client, tgt, st)
 	}
 
+	last := time.Now()
 	ticker := time.NewTicker(scrapeInterval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			for _, target := range targets {
-				go func(t string) { scrapeTarget(client, t, st) }(target)
+		case now := <-ticker.C:
+			if st.isLowPower() && now.Sub(last) < idleScrapeInterval {
+				continue
+			}
+			last = now
+			st.collectEnded(now)
+			for _, tgt := range st.activeTargets(targets) {
+				go scrapeGuarded(client, tgt, st)
 			}
 		}
 	}
 }
 
+// parseLabels splits name{k="v",...} into the name and its labels. Values
+// are quoted and may contain commas, braces and \\, \" and \n escapes.
 func parseLabels(s string) (string, map[string]string) {
 	idx := strings.Index(s, "{")
 	if idx < 0 {
@@ -627,38 +929,196 @@ func parseLabels(s string) (string, map[string]string) {
 	}
 	name := s[:idx]
 	rest := s[idx+1:]
-	end := strings.Index(rest, "}")
-	if end < 0 {
-		return name, nil
-	}
-	labelStr := rest[:end]
 	labels := map[string]string{}
-	for _, pair := range strings.Split(labelStr, ",") {
-		pair = strings.TrimSpace(pair)
-		eqIdx := strings.Index(pair, "=")
-		if eqIdx < 0 {
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" || rest[0] == '}' {
+			return name, labels
+		}
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return name, labels
+		}
+		k := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimLeft(rest[eq+1:], " ")
+		if rest == "" || rest[0] != '"' {
+			// Unquoted value: take it up to the next separator.
+			end := strings.IndexAny(rest, ",}")
+			if end < 0 {
+				end = len(rest)
+			}
+			labels[k] = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
 			continue
 		}
-		k := pair[:eqIdx]
-		v := strings.Trim(pair[eqIdx+1:], `"`)
+		v, n := unquoteLabel(rest[1:])
 		labels[k] = v
+		rest = rest[1+n:]
+	}
+}
+
+// unquoteLabel reads a label value up to its closing quote, returning the
+// unescaped value and how many bytes it consumed (including the quote).
+func unquoteLabel(s string) (string, int) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return b.String(), i + 1
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', '"':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), len(s)
+}
+
+// splitSample separates a sample line into the series part and its value,
+// skipping quoted label values (which may hold spaces) and dropping an
+// optional timestamp and OpenMetrics exemplar.
+func splitSample(line string) (string, string, bool) {
+	end := 0
+	if i := strings.IndexByte(line, '{'); i >= 0 && (strings.IndexByte(line, ' ') < 0 || i < strings.IndexByte(line, ' ')) {
+		inQuote := false
+		for end = i + 1; end < len(line); end++ {
+			switch c := line[end]; {
+			case inQuote && c == '\\':
+				end++
+			case c == '"':
+				inQuote = !inQuote
+			case !inQuote && c == '}':
+				goto closed
+			}
+		}
+		return "", "", false
+	closed:
+		end++
+	} else {
+		end = strings.IndexAny(line, " \t")
+		if end < 0 {
+			return "", "", false
+		}
+	}
+	rest := line[end:]
+	if rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+		return "", "", false
+	}
+	if i := strings.Index(rest, " # "); i >= 0 {
+		rest = rest[:i]
+	}
+	f := strings.Fields(rest)
+	if len(f) == 0 || len(f) > 2 {
+		return "", "", false
+	}
+	return line[:end], f[0], true
+}
+
+// scrapeGuarded scrapes tgt, recording a panic (e.g. on a malformed
+// exposition) as a crash of the scrape subsystem and a failed scrape of the
+// target instead of letting it kill the process. Every scrape's duration is
+// recorded, failed ones included.
+func scrapeGuarded(client *http.Client, tgt target, st *store) {
+	start := time.Now()
+	if guard(st, "scrape", func() { scrapeTarget(client, tgt, st) }) {
+		st.scrapeDone(tgt, errors.New("panic while scraping, see "+debugLogPath), time.Now())
 	}
-	return name, labels
+	st.recordScrapeDuration(tgt, time.Since(start), time.Now())
 }
 
-func scrapeTarget(client *http.Client, target string, st *store) {
-	url := fmt.Sprintf("http://%s/metrics", target)
-	resp, err := client.Get(url)
+func scrapeTarget(client *http.Client, tgt target, st *store) {
+	if tgt.query != "" {
+		scrapePromQL(client, tgt, st)
+		return
+	}
+	req, err := newScrapeRequest(tgt)
 	if err != nil {
+		st.scrapeDone(tgt, err, time.Now())
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		st.scrapeDone(tgt, err, time.Now())
+		scanRefused(client, tgt, st, err, scanPorts)
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		st.scrapeDone(tgt, fmt.Errorf("HTTP %s", resp.Status), time.Now())
+		return
+	}
+
+	samples := 0
+	now := time.Now()
+	ps, err := scanExposition(limitBody(resp.Body, maxScrapeBytes), func(name string, labels map[string]string, help, mtype string, val float64) {
+		samples++
+		labels = tgt.attachLabels(labels)
+		name, labels, keep := applyRelabel(globalRelabel, tgt.addr, name, labels)
+		if !keep {
+			return
+		}
+		st.ingest(tgt.key(), name, labels, help, mtype, val, now)
+	}, func(base string, labels map[string]string, created float64) {
+		labels = tgt.attachLabels(labels)
+		name, labels, keep := applyRelabel(globalRelabel, tgt.addr, base+"_created", labels)
+		if keep {
+			st.observeCreated(strings.TrimSuffix(name, "_created"), labels, created, now)
+		}
+	})
+	if tgt.scheme == envoyScheme && samples > 0 {
+		scrapeEnvoyClusters(client, tgt, st, now)
+	}
+	st.recordParseStats(tgt.key(), ps)
+	deriveBacklogs(st, tgt.key(), now)
+	if errors.Is(err, errBodyTruncated) {
+		st.recordTruncation(tgt.key(), now)
+		err = nil
+	}
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%w: %v", errParse, err)
+	case samples == 0 && len(ps.examples) > 0:
+		err = fmt.Errorf("%w: no samples in response (%s)", errParse, ps.examples[0])
+	case samples == 0:
+		err = fmt.Errorf("%w: no samples in response", errParse)
+	}
+	st.scrapeDone(tgt, err, time.Now())
+}
+
+func parseExposition(r io.Reader, fn func(name string, labels map[string]string, help, mtype string, val float64)) error {
+	_, err := scanExposition(r, fn, nil)
+	return err
+}
 
+// scanExposition parses the Prometheus text format and the OpenMetrics subset
+// madVisor understands: counter samples named <family>_total, # UNIT lines, and
+// <family>_created timestamps, which are passed to created instead of fn.
+func scanExposition(r io.Reader, fn func(name string, labels map[string]string, help, mtype string, val float64), created func(base string, labels map[string]string, ts float64)) (parseStats, error) {
 	var currentHelp, currentType, currentBaseName string
+	var ps parseStats
 
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return ps, nil
+		}
+		// An unterminated last line is complete at EOF, but partial when the
+		// body was cut off, so it is dropped on any other error.
+		if err != nil && err != io.EOF {
+			return ps, err
+		}
+		ps.lines++
+		line = strings.TrimRight(line, "\r\n")
 		if strings.HasPrefix(line, "# HELP ") {
 			parts := strings.SplitN(line[7:], " ", 2)
 			currentBaseName = parts[0]
@@ -675,50 +1135,73 @@ func scrapeTarget(client *http.Client, target string, st *store) {
 			}
 			continue
 		}
+		if strings.HasPrefix(line, "# UNIT ") {
+			parts := strings.SplitN(line[7:], " ", 2)
+			if len(parts) > 1 {
+				declareUnit(parts[0], strings.TrimSpace(parts[1]))
+			}
+			continue
+		}
 		if strings.HasPrefix(line, "#") || line == "" {
 			continue
 		}
 
-		spaceIdx := strings.LastIndex(line, " ")
-		if spaceIdx < 0 {
+		metricPart, valStr, ok := splitSample(line)
+		if !ok {
+			ps.skip(ps.lines, "no value in %q", truncateText(line, 40))
 			continue
 		}
-		metricPart := line[:spaceIdx]
-		valStr := line[spaceIdx+1:]
 		val, err := strconv.ParseFloat(valStr, 64)
 		if err != nil {
+			ps.skip(ps.lines, "invalid value %q", truncateText(valStr, 20))
 			continue
 		}
 
 		name, labels := parseLabels(metricPart)
+		if name == "" {
+			ps.skip(ps.lines, "no metric name in %q", truncateText(line, 40))
+			continue
+		}
+		if createdBase(name, currentBaseName, currentType) {
+			if created != nil {
+				created(currentBaseName, labels, val)
+			}
+			continue
+		}
 		help, mtype := "", ""
-		if name == currentBaseName {
+		if familyMember(name, currentBaseName) {
 			help = currentHelp
 			mtype = currentType
 		}
-		st.update(name, labels, help, mtype, val)
+		fn(name, labels, help, mtype, val)
 	}
 }
 
 // --- TTY guard ---
 
-func waitForTTY() {
+func waitForTTY() error {
 	if term.IsTerminal(int(os.Stdin.Fd())) {
-		return
+		return nil
+	}
+	if !ttyAttachable {
+		return fmt.Errorf("no console attached: run madvisor in a terminal such as Windows Terminal, or use --plain or --output ndjson")
 	}
 	fmt.Fprintln(os.Stderr, "madvisor: no TTY detected, waiting for terminal attachment...")
 	for {
 		time.Sleep(2 * time.Second)
 		if term.IsTerminal(int(os.Stdin.Fd())) {
 			fmt.Fprintln(os.Stderr, "madvisor: TTY detected, starting dashboard")
-			return
+			return nil
 		}
 	}
 }
 
 // --- UI state ---
 
-const defaultPageSize = 30
+const (
+	defaultPageSize = 30
+	messageTTL      = 8 * time.Second
+)
 
 type focusPanel int
 
@@ -727,6 +1210,20 @@ const (
 	focusSeriesTable
 )
 
+// bottomPanel is the panel shown in place of the series table, if any.
+type bottomPanel int
+
+const (
+	panelNone bottomPanel = iota
+	panelInfo
+	panelMatrix
+	panelTargets
+	panelCards
+	panelAlerts
+	panelCanary
+	panelBaseline
+)
+
 type uiState struct {
 	mu           sync.Mutex
 	allKeys      []string
@@ -737,11 +1234,70 @@ type uiState struct {
 	filterText   string
 	filterMode   bool
 	regexValid   bool
+	highlight    bool
+	matches      map[string]bool
+
+	highlightFilter bool
 
 	focus          focusPanel
 	seriesIdx      int
 	seriesScroll   int
 	seriesPageSize int
+	tableScroll    int
+
+	clipCharts    map[string]bool
+	yPins         map[string]yRange
+	dualView      bool
+	yZero         bool
+	tableSort     tableSort
+	shownTable    shownTable
+	forecast      forecastModel
+	transforms    map[string]chartTransform
+	transformMode bool
+
+	groupFilter string
+
+	message   string
+	messageAt time.Time
+	alert     bool
+
+	bottom     bottomPanel
+	heatmap    bool
+	rawList    bool
+	showHidden bool
+
+	tabs      [maxTabs]tab
+	activeTab int
+
+	picking   bool
+	pickQuery string
+	pickIdx   int
+	multiples multiplesMode
+	// multiplesRows and multiplesCols fix the small-multiples grid (:dashboard).
+	multiplesRows, multiplesCols int
+	// pinned metrics are listed first, in the order they were pinned.
+	pinned []string
+
+	expandedFamilies map[string]bool
+
+	typeAhead     string
+	typeAheadAt   time.Time
+	typeAheadOn   bool
+	typeAheadMiss bool
+
+	watches    []*watch
+	watchMode  bool
+	watchKey   string
+	watchLabel string
+	watchInput string
+
+	cmdMode  bool
+	cmdInput string
+
+	undoStack []viewState
+	redoStack []viewState
+	undoKind  string
+	restoring bool
 }
 
 func (u *uiState) setKeys(keys []string) {
@@ -764,26 +1320,28 @@ func (u *uiState) setKeys(keys []string) {
 }
 
 func (u *uiState) applyFilter() {
-	if u.filterText == "" {
+	u.matches = nil
+	switch {
+	case u.filterText == "":
 		u.filtered = append([]string{}, u.allKeys...)
 		u.regexValid = true
-	} else {
-		u.filtered = nil
-		re, err := regexp.Compile("(?i)" + u.filterText)
-		if err != nil {
-			u.regexValid = false
-			lower := strings.ToLower(u.filterText)
-			for _, k := range u.allKeys {
-				if strings.Contains(strings.ToLower(k), lower) {
-					u.filtered = append(u.filtered, k)
-				}
+	case u.highlight:
+		var match func(string) bool
+		match, u.regexValid = filterMatcher(u.filterText)
+		u.filtered = append([]string{}, u.allKeys...)
+		u.matches = map[string]bool{}
+		for _, k := range u.allKeys {
+			if match(k) {
+				u.matches[k] = true
 			}
-		} else {
-			u.regexValid = true
-			for _, k := range u.allKeys {
-				if re.MatchString(k) {
-					u.filtered = append(u.filtered, k)
-				}
+		}
+	default:
+		var match func(string) bool
+		match, u.regexValid = filterMatcher(u.filterText)
+		u.filtered = nil
+		for _, k := range u.allKeys {
+			if match(k) {
+				u.filtered = append(u.filtered, k)
 			}
 		}
 	}
@@ -796,6 +1354,7 @@ func (u *uiState) applyFilter() {
 	u.scrollOffset = 0
 	u.seriesIdx = 0
 	u.seriesScroll = 0
+	u.tableScroll = 0
 	u.adjustScroll()
 }
 
@@ -828,6 +1387,7 @@ func (u *uiState) adjustSeriesScroll() {
 	}
 	if u.seriesScroll < 0 {
 		u.seriesScroll = 0
+		u.tableScroll = 0
 	}
 }
 
@@ -840,6 +1400,7 @@ func (u *uiState) moveUp() {
 			u.adjustScroll()
 			u.seriesIdx = 0
 			u.seriesScroll = 0
+			u.tableScroll = 0
 		}
 	} else {
 		if u.seriesIdx > 0 {
@@ -858,6 +1419,7 @@ func (u *uiState) moveDown() {
 			u.adjustScroll()
 			u.seriesIdx = 0
 			u.seriesScroll = 0
+			u.tableScroll = 0
 		}
 	} else {
 		u.seriesIdx++
@@ -900,6 +1462,7 @@ func (u *uiState) addFilterChar(ch rune) {
 	defer u.mu.Unlock()
 	u.filterText += string(ch)
 	u.applyFilter()
+	u.seekMatchLocked(0)
 }
 
 func (u *uiState) backspaceFilter() {
@@ -908,6 +1471,7 @@ func (u *uiState) backspaceFilter() {
 	if len(u.filterText) > 0 {
 		u.filterText = u.filterText[:len(u.filterText)-1]
 		u.applyFilter()
+		u.seekMatchLocked(0)
 	}
 }
 
@@ -916,13 +1480,49 @@ func (u *uiState) clearFilter() {
 	defer u.mu.Unlock()
 	u.filterText = ""
 	u.filterMode = false
+	u.highlight = u.highlightFilter
+	u.applyFilter()
+}
+
+func (u *uiState) setFilter(text string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.filterText = text
+	u.filterMode = false
+	u.highlight = u.highlightFilter
 	u.applyFilter()
 }
 
+func (u *uiState) selectName(name string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for i, k := range u.filtered {
+		if k == name {
+			u.selectedIdx = i
+			u.seriesIdx = 0
+			u.seriesScroll = 0
+			u.tableScroll = 0
+			u.adjustScroll()
+			return true
+		}
+	}
+	return false
+}
+
+func (u *uiState) setFocus(f focusPanel) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.focus = f
+}
+
 func (u *uiState) startFilter() {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 	u.filterMode = true
+	if u.highlight != u.highlightFilter {
+		u.highlight = u.highlightFilter
+		u.applyFilter()
+	}
 }
 
 func (u *uiState) snapshot() (filtered []string, selIdx int, scrollOff int, filter string, filterMode bool) {
@@ -937,19 +1537,186 @@ func (u *uiState) seriesSnapshot() (seriesIdx int, seriesScroll int, focus focus
 	return u.seriesIdx, u.seriesScroll, u.focus, u.regexValid
 }
 
-// --- colors ---
-
-func colorForIndex(i int) cell.Color {
-	palette := []cell.Color{
-		cell.ColorGreen,
-		cell.ColorCyan,
-		cell.ColorMagenta,
-		cell.ColorYellow,
-		cell.ColorBlue,
-		cell.ColorRed,
-		cell.ColorWhite,
+func (u *uiState) toggleClip(name string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.clipCharts == nil {
+		u.clipCharts = make(map[string]bool)
+	}
+	u.clipCharts[name] = !u.clipCharts[name]
+	return u.clipCharts[name]
+}
+
+func (u *uiState) clipEnabled(name string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.clipCharts[name]
+}
+
+func (u *uiState) toggleDual() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.dualView = !u.dualView
+	return u.dualView
+}
+
+func (u *uiState) dualEnabled() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.dualView
+}
+
+func (u *uiState) cycleGroup(groups []string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	next := ""
+	if u.groupFilter == "" {
+		if len(groups) > 0 {
+			next = groups[0]
+		}
+	} else {
+		for i, g := range groups {
+			if g == u.groupFilter && i+1 < len(groups) {
+				next = groups[i+1]
+				break
+			}
+		}
+	}
+	u.groupFilter = next
+	u.selectedIdx = 0
+	u.seriesIdx = 0
+	u.seriesScroll = 0
+	u.tableScroll = 0
+	u.scrollOffset = 0
+	return next
+}
+
+func (u *uiState) setGroup(group string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.groupFilter = group
+	u.selectedIdx = 0
+	u.seriesIdx = 0
+	u.seriesScroll = 0
+	u.tableScroll = 0
+	u.scrollOffset = 0
+}
+
+func (u *uiState) group() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.groupFilter
+}
+
+// togglePanel shows p in place of the series table, or hides it when it is
+// already shown.
+func (u *uiState) togglePanel(p bottomPanel) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.bottom == p {
+		u.bottom = panelNone
+	} else {
+		u.bottom = p
+	}
+	return u.bottom == p
+}
+
+func (u *uiState) panel() bottomPanel {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.bottom
+}
+
+func (u *uiState) toggleHeatmap() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.heatmap = !u.heatmap
+	return u.heatmap
+}
+
+func (u *uiState) heatmapEnabled() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.heatmap
+}
+
+func (u *uiState) toggleRawList() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rawList = !u.rawList
+	return u.rawList
+}
+
+func (u *uiState) rawListEnabled() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.rawList
+}
+
+func (u *uiState) setMessage(msg string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.message = msg
+	u.messageAt = time.Now()
+	u.alert = false
+}
+
+func (u *uiState) setAlert(msg string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.message = msg
+	u.messageAt = time.Now()
+	u.alert = true
+}
+
+func (u *uiState) alertActive() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.alert && u.message != "" && time.Since(u.messageAt) <= messageTTL
+}
+
+func (u *uiState) currentMessage() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.message == "" || time.Since(u.messageAt) > messageTTL {
+		return ""
+	}
+	return u.message
+}
+
+func resetSelection(ui *uiState, st *store) {
+	name := ui.selectedKey()
+	if name == "" {
+		return
+	}
+	seriesIdx, _, focus, _ := ui.seriesSnapshot()
+	group := ui.group()
+	if focus == focusSeriesTable {
+		seriesList := displayedSeries(ui, st)
+		if seriesIdx >= 0 && seriesIdx < len(seriesList) {
+			st.resetSeries(seriesList[seriesIdx].key)
+		}
+		return
+	}
+	if group != "" {
+		for _, s := range filterGroup(st.seriesForName(name), group) {
+			st.resetSeries(s.key)
+		}
+		return
+	}
+	st.resetName(name)
+}
+
+func chartSelection(ui *uiState, st *store) (string, []*metricSeries) {
+	name := ui.selectedKey()
+	if name == "" {
+		return "", nil
+	}
+	seriesIdx, _, focus, _ := ui.seriesSnapshot()
+	if table := displayedSeries(ui, st); focus == focusSeriesTable && seriesIdx >= 0 && seriesIdx < len(table) {
+		return name, []*metricSeries{table[seriesIdx]}
 	}
-	return palette[i%len(palette)]
+	return name, filterGroup(st.seriesForName(name), ui.group())
 }
 
 // --- grid builders ---
@@ -978,18 +1745,34 @@ func buildSplashGrid(logoWidget *text.Text, statusWidget *text.Text) ([]containe
 
 // --- render metric name list (sidebar) ---
 
-func renderMetricList(w *text.Text, st *store, filtered []string, selIdx int, scrollOff int, filter string, filterMode bool, regexOK bool, focus focusPanel) {
+func renderMetricList(w *text.Text, st *store, filtered []string, selIdx int, scrollOff int, filter string, filterMode bool, regexOK bool, highlight bool, matches map[string]bool, focus focusPanel, group string, sections map[string]string, families map[string]familyRow) {
 	w.Reset()
 
+	if src, ok := targetScope(group); ok {
+		w.Write("Target: ", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write(src+"\n\n", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	} else if group != "" {
+		w.Write("Group: ", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write(group+"\n\n", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	}
+
 	if filterMode || filter != "" {
-		w.Write("Filter", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		prompt := "Filter"
+		if highlight {
+			prompt = "Search"
+		}
+		w.Write(prompt, text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
 		if !regexOK {
 			w.Write("(err)", text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
 		}
 		w.Write(": ", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
 		w.Write(filter, text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
-		w.Write("█\n", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
-		w.Write("\n")
+		w.Write("█", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		if filter != "" {
+			pos, total := matchPosition(filtered, selIdx, matches)
+			w.Write("  "+matchCounter(pos, total), text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+		}
+		w.Write("\n\n")
 	}
 
 	if scrollOff > 0 {
@@ -1003,11 +1786,23 @@ func renderMetricList(w *text.Text, st *store, filtered []string, selIdx int, sc
 
 	for i := scrollOff; i < end; i++ {
 		name := filtered[i]
+		if section := sections[name]; section != "" && (i == scrollOff || sections[filtered[i-1]] != section) {
+			w.Write("─ "+section+" ─\n", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		}
 		mtype := st.firstType(name)
 		count := st.seriesCount(name)
+		if group != "" {
+			count = len(filterGroup(st.seriesForName(name), group))
+		}
 
 		prefix := "  "
 		fg := cell.ColorWhite
+		if matches != nil {
+			fg = cell.ColorNumber(245)
+			if matches[name] {
+				fg = cell.ColorYellow
+			}
+		}
 		if i == selIdx {
 			if focus == focusSidebar {
 				prefix = "▶ "
@@ -1025,8 +1820,24 @@ func renderMetricList(w *text.Text, st *store, filtered []string, selIdx int, sc
 		}
 
 		w.Write(prefix, text.WriteCellOpts(cell.FgColor(fg)))
+		if fam, ok := families[name]; ok {
+			switch {
+			case !fam.header:
+				w.Write("   "+fam.suffix, text.WriteCellOpts(cell.FgColor(fg)))
+			case fam.expanded:
+				w.Write("▾ "+badge+" ", text.WriteCellOpts(cell.FgColor(cell.ColorMagenta)))
+				w.Write(fam.base, text.WriteCellOpts(cell.FgColor(fg)))
+				w.Write(fam.suffix, text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+			default:
+				w.Write("▸ "+badge+" ", text.WriteCellOpts(cell.FgColor(cell.ColorMagenta)))
+				w.Write(fam.base, text.WriteCellOpts(cell.FgColor(fg)))
+				w.Write(fmt.Sprintf("%s +%d", fam.suffix, fam.hidden), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+			}
+			w.Write(countStr+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+			continue
+		}
 		w.Write(badge+" ", text.WriteCellOpts(cell.FgColor(cell.ColorMagenta)))
-		w.Write(name, text.WriteCellOpts(cell.FgColor(fg)))
+		w.Write(metricAlias(name), text.WriteCellOpts(cell.FgColor(fg)))
 		w.Write(countStr+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
 	}
 
@@ -1041,93 +1852,73 @@ func renderMetricList(w *text.Text, st *store, filtered []string, selIdx int, sc
 
 // --- render series table ---
 
-func renderSeriesTable(w *text.Text, st *store, metricName string, seriesIdx int, seriesScroll int, focus focusPanel) {
-	w.Reset()
-
-	if metricName == "" {
-		w.Write("  select a metric name", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
-		return
+func seriesLabelText(s *metricSeries) string {
+	if len(s.labels) == 0 {
+		return "(no labels)"
 	}
-
-	seriesList := st.seriesForName(metricName)
-	if len(seriesList) == 0 {
-		w.Write("  no series for "+metricName, text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
-		return
+	parts := make([]string, 0, len(s.labels))
+	keys := make([]string, 0, len(s.labels))
+	for k := range s.labels {
+		keys = append(keys, k)
 	}
-
-	mtype := st.firstType(metricName)
-	w.Write(fmt.Sprintf(" %s %s — %d series\n", metricTypeBadge(mtype), metricName, len(seriesList)),
-		text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
-
-	if seriesList[0].help != "" {
-		w.Write(" "+seriesList[0].help+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, k, s.labels[k]))
 	}
-	w.Write("\n")
+	return "{" + strings.Join(parts, ", ") + "}"
+}
 
-	pageSize := 10
-	end := len(seriesList)
-	if end > seriesScroll+pageSize {
-		end = seriesScroll + pageSize
+func seriesValueText(s *metricSeries) string {
+	raw := s.last()
+	rawStr := formatRaw(raw)
+	var valStr string
+	if s.shouldRate() {
+		r := s.rate(rateWindowGet())
+		valStr = formatGeneric(r) + "/s"
+	} else {
+		valStr = formatValue(s.name, raw)
 	}
 
-	if seriesScroll > 0 {
-		w.Write(fmt.Sprintf("  ↑ %d more\n", seriesScroll), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	if valStr != rawStr {
+		return valStr + " (" + rawStr + ")"
 	}
+	return valStr
+}
 
-	for i := seriesScroll; i < end; i++ {
-		s := seriesList[i]
-		prefix := "  "
-		fg := cell.ColorWhite
-		if i == seriesIdx && focus == focusSeriesTable {
-			prefix = "▶ "
-			fg = cell.ColorCyan
-		}
-
-		labelStr := ""
-		if len(s.labels) > 0 {
-			parts := make([]string, 0, len(s.labels))
-			keys := make([]string, 0, len(s.labels))
-			for k := range s.labels {
-				keys = append(keys, k)
-			}
-			sort.Strings(keys)
-			for _, k := range keys {
-				parts = append(parts, fmt.Sprintf(`%s="%s"`, k, s.labels[k]))
-			}
-			labelStr = "{" + strings.Join(parts, ", ") + "}"
-		} else {
-			labelStr = "(no labels)"
-		}
-
-		raw := s.last()
-		rawStr := strconv.FormatFloat(raw, 'f', -1, 64)
-		var valStr string
-		if s.shouldRate() {
-			r := s.rate(rateWindowGet())
-			valStr = formatGeneric(r) + "/s"
-		} else {
-			valStr = formatValue(s.name, raw)
-		}
-
-		display := valStr
-		if valStr != rawStr {
-			display = valStr + " (" + rawStr + ")"
-		}
+// --- main run ---
 
-		w.Write(prefix, text.WriteCellOpts(cell.FgColor(fg)))
-		w.Write(labelStr, text.WriteCellOpts(cell.FgColor(fg)))
-		w.Write(" = "+display+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+func visibleNames(st *store, group string) []string {
+	if group != "" {
+		return st.namesInGroup(group)
 	}
+	return st.names()
+}
 
-	if end < len(seriesList) {
-		w.Write(fmt.Sprintf("  ↓ %d more\n", len(seriesList)-end), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+func listKeys(st *store, ui *uiState, group string) ([]string, map[string]string, map[string]familyRow) {
+	names, _ := withoutHidden(visibleNames(st, group), ui.showHiddenEnabled())
+	if ui.rawListEnabled() {
+		return names, nil, nil
 	}
+	names, sections := arrangeByPresets(globalPresets, names)
+	names, sections = pinFirst(names, sections, ui.pinnedNames())
+	names, families := groupFamilies(names, st.nameTypes(), ui.expanded())
+	return names, sections, families
 }
 
-// --- main run ---
+func run(targets []target, script []scriptCmd, feed func(context.Context, *store)) error {
+	t, err := tcell.New()
+	if err != nil {
+		return fmt.Errorf("tcell.New: %w", err)
+	}
+	defer t.Close()
+	defer termOut.openTTY()()
+	return runTerminal(context.Background(), t, targets, script, feed)
+}
 
-func run(targets []string) error {
-	dbg, _ := os.Create("/tmp/madvisor-debug.log")
+// runTerminal runs the dashboard on t until the user quits or parent is
+// done; tests drive it on a fake terminal.
+func runTerminal(parent context.Context, t terminalapi.Terminal, targets []target, script []scriptCmd, feed func(context.Context, *store)) error {
+	dbg, _ := os.Create(debugLogPath)
 	if dbg != nil {
 		defer dbg.Close()
 	}
@@ -1137,19 +1928,14 @@ func run(targets []string) error {
 		}
 	}
 
-	t, err := tcell.New()
-	if err != nil {
-		return fmt.Errorf("tcell.New: %w", err)
-	}
-	defer t.Close()
-
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	st := newStore()
-	go scrape(ctx, targets, st)
+	st.onCrash = func(c crash) { dlog("%s", c) }
+	go feed(ctx, st)
 
-	ui := &uiState{}
+	ui := &uiState{yZero: flagYZero}
 
 	logoWidget, err := text.New(text.WrapAtRunes())
 	if err != nil {
@@ -1164,7 +1950,7 @@ func run(targets []string) error {
 		return err
 	}
 	statusWidget.Write(
-		fmt.Sprintf("Connecting to %s ...", strings.Join(targets, ", ")),
+		fmt.Sprintf("Connecting to %s ...", formatTargets(targets)),
 		text.WriteCellOpts(cell.FgColor(cell.ColorYellow)),
 	)
 
@@ -1185,6 +1971,11 @@ func run(targets []string) error {
 		return err
 	}
 
+	rawChart, err := linechart.New(linechart.YAxisAdaptive())
+	if err != nil {
+		return err
+	}
+
 	listWidget, err := text.New(text.WrapAtRunes())
 	if err != nil {
 		return err
@@ -1195,61 +1986,266 @@ func run(targets []string) error {
 		return err
 	}
 
+	infoWidget, err := text.New(text.WrapAtWords())
+	if err != nil {
+		return err
+	}
+
+	matrixWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+
+	heatmapWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+
+	targetsWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+	cardsWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+	alertsWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+	canaryWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+	baselineWidget, err := text.New()
+	if err != nil {
+		return err
+	}
+
+	frames := newFramePreparer(st)
+	go supervise(ctx, st, "frames", frames.run)
+	go supervise(ctx, st, "expr", st.runExpr)
+
 	prevSelName := ""
 	prevSeriesKey := ""
 
-	go func() {
+	rf := newRefresher(flagIdleAfter)
+	var ctrl atomic.Pointer[termdash.Controller]
+	var chartTitles fullChartTitle
+	var multiples smallMultiples
+	// drawMu keeps the render loop from redrawing while the controller is
+	// being closed on quit.
+	var drawMu sync.Mutex
+	redraw := func() {
+		drawMu.Lock()
+		defer drawMu.Unlock()
+		if ctl := ctrl.Load(); ctl != nil {
+			if redrawErr := ctl.Redraw(); redrawErr != nil {
+				dlog("redraw error: %v", redrawErr)
+			}
+			if escErr := termOut.flush(); escErr != nil {
+				dlog("terminal escape error: %v", escErr)
+			}
+		}
+	}
+	started := time.Now()
+
+	// panelMu serialises the render loop and the navigator writing the
+	// metric list and series table; listSections and listFamilies are the
+	// render loop's last list layout, reused by the navigator.
+	var panelMu sync.Mutex
+	var listSections map[string]string
+	var listFamilies map[string]familyRow
+	withPanels := func(fn func()) {
+		panelMu.Lock()
+		defer panelMu.Unlock()
+		fn()
+	}
+	nav := newNavigator()
+	go supervise(ctx, st, "navigation", func(ctx context.Context) {
+		nav.run(ctx, func() {
+			withPanels(func() {
+				group := ui.group()
+				filtered, selIdx, scrollOff, filter, filterMode := ui.snapshot()
+				seriesIdx, seriesScroll, focus, regexOK := ui.seriesSnapshot()
+				highlight, matches := ui.searchSnapshot()
+				renderMetricList(listWidget, st, filtered, selIdx, scrollOff, filter, filterMode, regexOK, highlight, matches, focus, group, listSections, listFamilies)
+				if focus == focusSeriesTable && selIdx >= 0 && selIdx < len(filtered) {
+					ui.clampSeriesIdx(len(filterGroup(st.seriesForName(filtered[selIdx]), group)))
+					seriesIdx, seriesScroll, _, _ = ui.seriesSnapshot()
+					renderSeriesTable(seriesWidget, st, filtered[selIdx], displayedSeries(ui, st), seriesIdx, seriesScroll, focus, group, ui.tableOffset(), ui.sortMode())
+				}
+			})
+			redraw()
+		})
+	})
+
+	go supervise(ctx, st, "render", func(ctx context.Context) {
 		ticker := time.NewTicker(refreshInterval)
 		defer ticker.Stop()
+		var lastRender time.Time
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				names := st.names()
-				dlog("tick: names=%d", len(names))
-				if len(names) == 0 {
+			case now := <-ticker.C:
+				lowPower := rf.lowPower(now)
+				wasLowPower := st.setLowPower(lowPower)
+				if lowPower && wasLowPower || !rf.due(now, lastRender) {
+					continue
+				}
+			case <-rf.wake:
+				st.setLowPower(false)
+			}
+			if nav.active(time.Now()) {
+				// The navigator keeps the list current; the rest catches
+				// up on the first tick after the keys settle.
+				continue
+			}
+			lastRender = time.Now()
+			{
+				allNames := st.names()
+				dlog("tick: names=%d", len(allNames))
+				if !st.hasData() && !connectDeadlinePassed(time.Since(started)) {
+					renderSplash(statusWidget, st, st.activeTargets(targets), time.Since(started))
+					redraw()
 					continue
 				}
 
+				if script != nil {
+					for _, scriptErr := range runScript(script, ui, st) {
+						dlog("init script: %v", scriptErr)
+					}
+					script = nil
+				}
+
+				if alerts := ui.checkWatches(st); len(alerts) > 0 {
+					ui.setAlert("🔔 " + strings.Join(alerts, " │ "))
+					ringBell()
+				}
+
+				group := ui.group()
+				names, sections, families := listKeys(st, ui, group)
 				ui.setKeys(names)
 
 				filtered, selIdx, scrollOff, filter, filterMode := ui.snapshot()
 				seriesIdx, seriesScroll, focus, regexOK := ui.seriesSnapshot()
 				dlog("ui: filtered=%d selIdx=%d scrollOff=%d filter=%q filterMode=%v focus=%d", len(filtered), selIdx, scrollOff, filter, filterMode, focus)
 
-				renderMetricList(listWidget, st, filtered, selIdx, scrollOff, filter, filterMode, regexOK, focus)
+				highlight, matches := ui.searchSnapshot()
+				withPanels(func() {
+					listSections, listFamilies = sections, families
+					renderMetricList(listWidget, st, filtered, selIdx, scrollOff, filter, filterMode, regexOK, highlight, matches, focus, group, sections, families)
+				})
 
 				selName := ""
 				if selIdx >= 0 && selIdx < len(filtered) {
 					selName = filtered[selIdx]
 				}
 
-				seriesList := st.seriesForName(selName)
-				ui.clampSeriesIdx(len(seriesList))
+				sortMode := ui.sortMode()
+				ui.clampSeriesIdx(len(filterGroup(st.seriesForName(selName), group)))
 				seriesIdx, seriesScroll, focus, _ = ui.seriesSnapshot()
 
-				renderSeriesTable(seriesWidget, st, selName, seriesIdx, seriesScroll, focus)
-
-				var chartSeries []*metricSeries
-				if focus == focusSeriesTable && seriesIdx >= 0 && seriesIdx < len(seriesList) {
-					chartSeries = []*metricSeries{seriesList[seriesIdx]}
-				} else {
-					chartSeries = seriesList
+				fr := frames.await(frames.request(frameRequest{
+					name:      selName,
+					group:     group,
+					focus:     focus,
+					seriesIdx: seriesIdx,
+					sort:      sortMode,
+					tf:        ui.transformFor(selName),
+					heatmap:   ui.heatmapEnabled(),
+					dual:      ui.dualEnabled(),
+					width:     chartPlotWidth(t.Size().X, ui.forecastModel()),
+				}), frameWait)
+				seriesList := fr.table(st, selName, group, sortMode)
+				ui.setShownTable(shownTable{name: selName, group: group, sort: sortMode, list: seriesList})
+
+				withPanels(func() {
+					renderSeriesTable(seriesWidget, st, selName, seriesList, seriesIdx, seriesScroll, focus, group, ui.tableOffset(), sortMode)
+				})
+
+				bottomWidget, bottomTitle := seriesWidget, " series "
+				switch ui.panel() {
+				case panelInfo:
+					renderMetadata(infoWidget, selName, seriesList, time.Now())
+					bottomWidget, bottomTitle = infoWidget, " metadata "
+				case panelMatrix:
+					renderReplicaMatrix(matrixWidget, selName, seriesList)
+					bottomWidget, bottomTitle = matrixWidget, " replicas "
+				case panelTargets:
+					if picking, query, cursor := ui.targetPicker(); picking {
+						renderTargetPicker(targetsWidget, st, st.activeTargets(targets), query, cursor, group, time.Now())
+						bottomWidget, bottomTitle = targetsWidget, " switch target "
+					} else {
+						renderTargets(targetsWidget, st, st.activeTargets(targets), time.Now())
+						bottomWidget, bottomTitle = targetsWidget, " targets "
+					}
+				case panelCards:
+					renderCardinality(cardsWidget, selName, seriesList)
+					bottomWidget, bottomTitle = cardsWidget, " cardinality "
+				case panelAlerts:
+					renderAlerts(alertsWidget, ui, time.Now())
+					bottomWidget, bottomTitle = alertsWidget, " alerts "
+				case panelCanary:
+					canaryNames, _ := withoutHidden(allNames, ui.showHiddenEnabled())
+					renderCanary(canaryWidget, st, canaryNames)
+					bottomWidget, bottomTitle = canaryWidget, " canary "
+				case panelBaseline:
+					baselineNames, _ := withoutHidden(allNames, ui.showHiddenEnabled())
+					renderBaseline(baselineWidget, st, globalBaseline, baselineNames, time.Now())
+					bottomWidget, bottomTitle = baselineWidget, " baseline "
 				}
 
+				chartName, chartSeries, otherSeries := fr.req.name, fr.chartSeries, fr.otherSeries
+				datasets, xLabels, gaps, otherData := fr.datasets, fr.xLabels, fr.gaps, fr.otherData
+				dualOn, heatmapOn, tf := fr.dualOn, fr.heatmapOn, fr.req.tf
+				clipOn := ui.clipEnabled(chartName)
+
 				chartKey := ""
 				if len(chartSeries) > 0 {
 					for _, cs := range chartSeries {
 						chartKey += cs.key + ";"
 					}
 				}
+				if clipOn {
+					chartKey += "clip;"
+				}
+				if dualOn {
+					chartKey += "dual;"
+				}
+				yZero := ui.yZeroEnabled()
+				if yZero {
+					chartKey += "zero;"
+				}
+				pin, pinned := ui.yPin(chartName)
+				if pinned {
+					chartKey += "pin=" + pin.String() + ";"
+				}
+				if tf != transformNone {
+					chartKey += "tf=" + tf.String() + ";"
+				}
+				if len(otherSeries) > 0 {
+					chartKey += "other;"
+				}
+				fm := ui.forecastModel()
+				if fm != forecastOff {
+					chartKey += "fc=" + fm.String() + ";"
+				}
+				if len(xLabels) > 0 {
+					chartKey += fmt.Sprintf("ann=%d;", len(xLabels))
+				}
 
-				if chartKey != prevSeriesKey || selName != prevSelName {
-					chartOpts := []linechart.Option{linechart.YAxisAdaptive()}
+				if chartKey != prevSeriesKey || chartName != prevSelName {
+					chartOpts := yAxisScale(yZero)
+					if pinned {
+						chartOpts = append(chartOpts, linechart.YAxisCustomScale(pin.lo, pin.hi))
+					}
 					if len(chartSeries) > 0 {
 						first := chartSeries[0]
-						if first.shouldRate() {
+						if tf != transformNone {
+							chartOpts = append(chartOpts, linechart.YAxisFormattedValues(genericAxisFormatter()))
+						} else if first.shouldRate() {
 							chartOpts = append(chartOpts, linechart.YAxisFormattedValues(rateAxisFormatter()))
 						} else if isTimestampMetric(first.name) {
 							chartOpts = append(chartOpts, linechart.YAxisFormattedValues(func(v float64) string {
@@ -1268,41 +2264,108 @@ func run(targets []string) error {
 					} else {
 						dlog("chart create error: %v", chartErr)
 					}
-					prevSelName = selName
+					if dualOn {
+						newRaw, rawErr := linechart.New(append(yAxisScale(yZero),
+							linechart.YAxisFormattedValues(yAxisFormatter(chartSeries[0].name)))...)
+						if rawErr == nil {
+							rawChart = newRaw
+						} else {
+							dlog("raw chart create error: %v", rawErr)
+						}
+					}
+					prevSelName = chartName
 					prevSeriesKey = chartKey
 				}
 
+				clipped := 0
+				clipLo, clipHi, clipOK := 0.0, 0.0, false
+				if pinned {
+					clipLo, clipHi, clipOK = pin.lo, pin.hi, true
+					shownYRange.Store(&namedYRange{chart: chartName, yRange: pin})
+				} else {
+					if clipOn {
+						clipLo, clipHi, clipOK = clipBounds(datasets, clipLowPercentile, clipHighPercentile)
+					}
+					if r, ok := dataRange(datasets); ok {
+						shownYRange.Store(&namedYRange{chart: chartName, yRange: r})
+					}
+				}
+
 				for i, cs := range chartSeries {
-					var data []float64
-					if cs.shouldRate() {
-						data = cs.rateSlice(rateWindowGet())
-					} else if isTimestampMetric(cs.name) {
-						nowSec := float64(time.Now().Unix())
-						raw := cs.slice()
-						data = make([]float64, len(raw))
-						for j, v := range raw {
-							if v > 0 {
-								data[j] = nowSec - v
-							}
-						}
-					} else {
-						data = cs.slice()
+					data := datasets[i]
+					var marks []float64
+					if clipOK {
+						var n int
+						data, marks, n = clipData(data, clipLo, clipHi)
+						clipped += n
 					}
 					if len(data) >= 2 {
 						label := cs.displayName()
-						if seriesErr := chart.Series(label, data,
-							linechart.SeriesCellOpts(cell.FgColor(colorForIndex(i))),
+						seriesOpts := []linechart.SeriesOption{linechart.SeriesCellOpts(cell.FgColor(colorForIndex(i)))}
+						if i == 0 && xLabels != nil {
+							seriesOpts = append(seriesOpts, linechart.SeriesXLabels(xLabels))
+						}
+						if seriesErr := chart.Series(label, data, seriesOpts...); seriesErr != nil {
+							dlog("chart.Series error: %v", seriesErr)
+						}
+						if marks != nil {
+							if seriesErr := chart.Series("~clipped "+label, marks,
+								linechart.SeriesCellOpts(cell.FgColor(cell.ColorRed)),
+							); seriesErr != nil {
+								dlog("chart.Series error: %v", seriesErr)
+							}
+						}
+					}
+					if dualOn {
+						if raw := fr.rawSets[i]; len(raw) >= 2 {
+							if seriesErr := rawChart.Series(cs.displayName(), raw,
+								linechart.SeriesCellOpts(cell.FgColor(colorForIndex(i))),
+							); seriesErr != nil {
+								dlog("rawChart.Series error: %v", seriesErr)
+							}
+						}
+					}
+				}
+
+				if len(otherData) >= 2 {
+					if seriesErr := chart.Series("~other", otherData,
+						linechart.SeriesCellOpts(cell.FgColor(cell.ColorNumber(245))),
+					); seriesErr != nil {
+						dlog("chart.Series error: %v", seriesErr)
+					}
+				}
+
+				if len(datasets) > 0 && len(datasets[0]) >= 2 {
+					for _, ref := range referenceLines(globalThresholds, chartName, len(datasets[0])) {
+						if seriesErr := chart.Series(ref.label, ref.values,
+							linechart.SeriesCellOpts(cell.FgColor(ref.color)),
+						); seriesErr != nil {
+							dlog("chart.Series error: %v", seriesErr)
+						}
+					}
+				}
+
+				forecastNote := ""
+				if fm != forecastOff && len(datasets) > 0 && len(datasets[0]) >= 2 {
+					if level, slope, ok := fitForecast(fm, datasets[0]); ok {
+						n := len(datasets[0])
+						if seriesErr := chart.Series("~forecast", forecastSeries(n, level, slope, max(n/4, 10)),
+							linechart.SeriesCellOpts(cell.FgColor(colorForIndex(0)), cell.Dim()),
 						); seriesErr != nil {
 							dlog("chart.Series error: %v", seriesErr)
 						}
+						forecastNote = forecastSummary(level, slope, scrapeInterval,
+							forecastTargets(globalThresholds, chartName, ui.watchesFor(chartSeries[0].key)))
 					}
 				}
 
 				chartTitle := " chart "
-				if selName != "" {
-					mtype := st.firstType(selName)
-					if focus == focusSeriesTable && len(chartSeries) == 1 {
+				var titleSeries *metricSeries
+				if chartName != "" {
+					mtype := st.firstType(chartName)
+					if fr.req.focus == focusSeriesTable && len(chartSeries) == 1 {
 						cs := chartSeries[0]
+						titleSeries = cs
 						if cs.shouldRate() {
 							chartTitle = fmt.Sprintf(" %s [rate/s] ", cs.displayName())
 						} else if isTimestampMetric(cs.name) {
@@ -1311,9 +2374,35 @@ func run(targets []string) error {
 							chartTitle = fmt.Sprintf(" %s%s ", cs.displayName(), unitSuffix(cs.name))
 						}
 					} else {
-						chartTitle = fmt.Sprintf(" %s %s (%d series) ", metricTypeBadge(mtype), selName, len(seriesList))
+						chartTitle = fmt.Sprintf(" %s %s (%d series) ", metricTypeBadge(mtype), chartName, len(fr.seriesList))
+					}
+					if gaps > 0 {
+						chartTitle += fmt.Sprintf("[gaps: %d] ", gaps)
+					}
+					if len(xLabels) > 0 {
+						chartTitle += fmt.Sprintf("[annotations: %d] ", len(xLabels))
+					}
+					if tf != transformNone {
+						chartTitle += "[" + tf.String() + "] "
+					}
+					if len(otherSeries) > 0 {
+						chartTitle += fmt.Sprintf("[top %d of %d, %d summed as other] ", len(chartSeries), fr.totalSeries, len(otherSeries))
+					}
+					if forecastNote != "" {
+						chartTitle += "[forecast " + fm.String() + ": " + forecastNote + "] "
+					}
+					if pinned {
+						chartTitle += fmt.Sprintf("[pinned %s: %d outside] ", pin, clipped)
+					} else if clipOn {
+						chartTitle += fmt.Sprintf("[clip p%d–p%d: %d] ", clipLowPercentile, clipHighPercentile, clipped)
+					}
+					if len(chartSeries) > 0 {
+						if breached := breachedThresholds(globalThresholds, chartName, watchValue(chartSeries[0])); len(breached) > 0 {
+							chartTitle += "[⚠ " + strings.Join(breached, ", ") + "] "
+						}
 					}
 				}
+				chartTitle = chartTitles.fit(chartTitle, titleSeries, fr.seriesList, chartTitleWidth(t.Size().X))
 
 				sidebarBorderColor := cell.ColorGreen
 				seriesBorderColor := cell.ColorBlue
@@ -1328,28 +2417,141 @@ func run(targets []string) error {
 				allSeries := st.snapshot()
 				statusWidget.Reset()
 				statusWidget.Write(fmt.Sprintf(
-					" madVisor %s │ Targets: %s │ Metrics: %d/%d │ Series: %d │ Rate: %s │ Q: quit │ /: filter │ Tab: focus │ ↑↓: nav │ []: rate",
+					" madVisor %s │ Targets: %s │ Metrics: %d/%d │ Series: %d │ Rate: %s │ ",
 					version,
-					strings.Join(targets, ", "),
-					len(filtered), len(names),
+					formatTargets(st.activeTargets(targets)),
+					len(filtered), len(allNames),
 					len(allSeries),
 					rateWindowGet(),
 				), text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+				if rw := globalRemoteWriter; rw != nil {
+					color := cell.ColorGreen
+					if rw.failing() {
+						color = cell.ColorRed
+					}
+					statusWidget.Write(rw.status()+" │ ", text.WriteCellOpts(cell.FgColor(color)))
+				}
+				if am := globalAlertmanager; am != nil {
+					if n := am.firing(); n > 0 {
+						statusWidget.Write(fmt.Sprintf("🚨 %d firing in Alertmanager (A) │ ", n), text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+					}
+				}
+				if bar := ui.tabBar(); bar != "" {
+					statusWidget.Write("Tabs: "+bar+" │ ", text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+				}
+				if st.isPaused() {
+					statusWidget.Write("⏸ PAUSED │ ", text.WriteCellOpts(cell.FgColor(cell.ColorYellow), cell.Bold()))
+				}
+				if st.isLowPower() {
+					statusWidget.Write(fmt.Sprintf("☾ IDLE, scraping every %s, press any key │ ", idleScrapeInterval),
+						text.WriteCellOpts(cell.FgColor(cell.ColorBlue), cell.Bold()))
+				}
+				if n := len(st.targetIssueList()); n > 0 {
+					statusWidget.Write(fmt.Sprintf("⚠ %d invalid target(s) (T) │ ", n), text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+				}
+				if n := degradedTargets(st, st.activeTargets(targets)); n > 0 {
+					statusWidget.Write(fmt.Sprintf("⚠ %d target(s) degraded (T) │ ", n), text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+				}
+				if collisions := st.collisionList(); len(collisions) > 0 {
+					statusWidget.Write(fmt.Sprintf("⚠ %d collisions, instance label added │ ", len(collisions)),
+						text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+				}
+				if degraded := degradedSubsystems(st.crashList(), time.Now()); len(degraded) > 0 {
+					statusWidget.Write(crashStatus(degraded)+" │ ", text.WriteCellOpts(cell.FgColor(cell.ColorRed), cell.Bold()))
+				}
+				if _, n := withoutHidden(visibleNames(st, group), ui.showHiddenEnabled()); n > 0 {
+					statusWidget.Write(fmt.Sprintf("%d hidden (H) │ ", n), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+				}
+				if n := ui.watchCount(); n > 0 {
+					label := fmt.Sprintf("Watching: %d", n)
+					if q := ui.silencedCount(time.Now()); q > 0 {
+						label += fmt.Sprintf(" (%d silenced)", q)
+					}
+					statusWidget.Write(label+" │ ", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+				}
+				if cmdMode, input := ui.commandPrompt(); cmdMode {
+					statusWidget.Write(":"+input+"█", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+				} else if ui.transformPrompt() {
+					statusWidget.Write(transformMenu, text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+				} else if watchMode, input := ui.watchPrompt(); watchMode {
+					statusWidget.Write("watch (>N, <N, N% or absent [30s], Enter to set, Esc to cancel): "+input+"█",
+						text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+				} else if prefix, found, live := ui.typeAheadEcho(time.Now()); live {
+					color := cell.ColorYellow
+					if !found {
+						color = cell.ColorRed
+					}
+					statusWidget.Write("jump: "+prefix+"█", text.WriteCellOpts(cell.FgColor(color)))
+				} else if msg := ui.currentMessage(); msg != "" {
+					opts := []cell.Option{cell.FgColor(cell.ColorYellow)}
+					if ui.alertActive() {
+						opts = []cell.Option{cell.FgColor(cell.ColorRed), cell.Bold()}
+						if time.Now().UnixNano()/int64(500*time.Millisecond)%2 == 0 {
+							opts = []cell.Option{cell.FgColor(cell.ColorBlack), cell.BgColor(cell.ColorRed), cell.Bold()}
+						}
+					}
+					statusWidget.Write(msg, text.WriteCellOpts(opts...))
+				} else {
+					statusWidget.Write("Q: quit │ /: filter │ Tab: focus │ ↑↓: nav │ []: rate │ r/R: reset │ o: clip │ d: raw+rate │ h: heatmap │ g: group │ s: split │ i: info │ w/W: watch │ p: pause │ e/E: export",
+						text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+				}
 
-				builder := grid.New()
-				builder.Add(grid.RowHeightPerc(95,
-					grid.ColWidthPerc(70,
-						grid.RowHeightPerc(60,
-							grid.Widget(chart,
+				chartElems := []grid.Element{
+					grid.Widget(chart,
+						container.Border(linestyle.Light),
+						container.BorderTitle(chartTitle),
+						container.BorderColor(cell.ColorCyan),
+					),
+				}
+				labels := make([]string, len(chartSeries))
+				for i, cs := range chartSeries {
+					labels[i] = cs.displayName()
+				}
+				format := yAxisFormatter(chartName)
+				if tf != transformNone {
+					format = genericAxisFormatter()
+				} else if len(chartSeries) > 0 && chartSeries[0].shouldRate() {
+					format = rateAxisFormatter()
+				}
+				if mm := ui.multiplesMode(); mm != multiplesOff && !heatmapOn && !dualOn && len(chartSeries) > 1 {
+					rows, cols := ui.multiplesGrid()
+					if elems, multErr := multiples.build(fmt.Sprint(chartName, tf), chartTitle+"["+mm.String()+"] ", labels, datasets, format, mm, rows, cols); multErr == nil {
+						chartElems = elems
+					} else {
+						dlog("small multiples error: %v", multErr)
+					}
+				}
+				if heatmapOn {
+					renderHeatmap(heatmapWidget, labels, datasets, format)
+					chartElems = []grid.Element{
+						grid.Widget(heatmapWidget,
+							container.Border(linestyle.Light),
+							container.BorderTitle(chartTitle+"[heatmap] "),
+							container.BorderColor(cell.ColorCyan),
+						),
+					}
+				}
+				if dualOn {
+					chartElems = []grid.Element{
+						grid.RowHeightPerc(50,
+							grid.Widget(rawChart,
 								container.Border(linestyle.Light),
-								container.BorderTitle(chartTitle),
+								container.BorderTitle(" raw "+chartName+unitSuffix(chartName)+" "),
 								container.BorderColor(cell.ColorCyan),
 							),
 						),
+						grid.RowHeightPerc(49, chartElems...),
+					}
+				}
+
+				builder := grid.New()
+				builder.Add(grid.RowHeightPerc(95,
+					grid.ColWidthPerc(70,
+						grid.RowHeightPerc(60, chartElems...),
 						grid.RowHeightPerc(39,
-							grid.Widget(seriesWidget,
+							grid.Widget(bottomWidget,
 								container.Border(linestyle.Light),
-								container.BorderTitle(" series "),
+								container.BorderTitle(bottomTitle),
 								container.BorderColor(seriesBorderColor),
 							),
 						),
@@ -1375,12 +2577,93 @@ func run(targets []string) error {
 						dlog("container.Update error: %v", updateErr)
 					}
 				}
+				redraw()
 			}
 		}
-	}()
+	})
 
-	err = termdash.Run(ctx, t, c,
+	keys := &keyEnv{cancel: cancel, ui: ui, st: st, targets: targets, nav: nav, titles: &chartTitles}
+	controller, err := termdash.NewController(t, c,
 		termdash.KeyboardSubscriber(func(k *terminalapi.Keyboard) {
+			defer rf.poke()
+			defer ui.recordView(ui.captureView())
+			if cmdMode, _ := ui.commandPrompt(); cmdMode {
+				switch k.Key {
+				case keyboard.KeyEsc:
+					ui.cancelCommand()
+				case keyboard.KeyBackspace, keyboard.KeyBackspace2, keyboard.KeyDelete:
+					if _, input := ui.commandPrompt(); input == "" {
+						ui.cancelCommand()
+					} else {
+						ui.backspaceCommand()
+					}
+				case keyboard.KeyEnter:
+					runCommandLine(ui.commitCommand(), ui, st)
+				default:
+					if k.Key >= 0x20 && k.Key < 0x7f {
+						ui.addCommandChar(rune(k.Key))
+					}
+				}
+				return
+			}
+
+			if watchMode, _ := ui.watchPrompt(); watchMode {
+				switch k.Key {
+				case keyboard.KeyEsc:
+					ui.cancelWatch()
+				case keyboard.KeyBackspace, keyboard.KeyBackspace2, keyboard.KeyDelete:
+					ui.backspaceWatch()
+				case keyboard.KeyEnter:
+					w, watchErr := ui.commitWatch()
+					if watchErr != nil {
+						ui.setMessage("watch: " + watchErr.Error())
+					} else {
+						ui.setMessage(fmt.Sprintf("watching %s %s", w.label, w.condition()))
+					}
+				default:
+					if k.Key >= 0x20 && k.Key < 0x7f {
+						ui.addWatchChar(rune(k.Key))
+					}
+				}
+				return
+			}
+
+			if picking, query, cursor := ui.targetPicker(); picking {
+				rows := pickerRows(st, st.activeTargets(targets), query)
+				switch k.Key {
+				case keyboard.KeyEsc:
+					ui.closeTargetPicker()
+				case keyboard.KeyBackspace, keyboard.KeyBackspace2, keyboard.KeyDelete:
+					ui.pickerBackspace()
+				case keyboard.KeyArrowUp:
+					ui.pickerMove(-1, len(rows))
+				case keyboard.KeyArrowDown:
+					ui.pickerMove(1, len(rows))
+				case keyboard.KeyEnter:
+					if cursor < len(rows) {
+						ui.pickTarget(st, rows[cursor])
+						ui.setMessage("scoped to " + rows[cursor].label)
+					}
+				default:
+					if k.Key > 0x20 && k.Key < 0x7f {
+						ui.pickerType(rune(k.Key))
+					}
+				}
+				return
+			}
+
+			if ui.transformPrompt() {
+				t, ok := transformKeys[rune(k.Key)]
+				name := ui.selectedKey()
+				if !ok || name == "" {
+					ui.cancelTransform()
+					return
+				}
+				ui.setTransform(name, t)
+				ui.setMessage(fmt.Sprintf("transform %s: %s", name, t))
+				return
+			}
+
 			_, _, _, _, filterMode := ui.snapshot()
 
 			if filterMode {
@@ -1389,6 +2672,8 @@ func run(targets []string) error {
 					ui.clearFilter()
 				case keyboard.KeyBackspace, keyboard.KeyBackspace2, keyboard.KeyDelete:
 					ui.backspaceFilter()
+				case keyboard.KeyTab:
+					ui.setMessage(highlightFilterMessage(ui.toggleHighlightFilter()))
 				case keyboard.KeyEnter:
 					ui.mu.Lock()
 					ui.filterMode = false
@@ -1401,6 +2686,41 @@ func run(targets []string) error {
 				return
 			}
 
+			if (k.Key == keyboard.Key('n') || k.Key == keyboard.Key('N')) && ui.searching() {
+				dir := 1
+				if k.Key == keyboard.Key('N') {
+					dir = -1
+				}
+				if !ui.nextMatch(dir) {
+					ui.setMessage("search: no matches")
+				}
+				nav.moved(time.Now())
+				return
+			}
+
+			if runAction(ctx, k.Key, ui, st) {
+				return
+			}
+
+			if _, _, focus, _ := ui.seriesSnapshot(); focus == focusSidebar {
+				now, r := time.Now(), rune(k.Key)
+				typing := ui.typeAheadActive(now)
+				switch {
+				case typing && k.Key == keyboard.KeyEsc:
+					ui.cancelTypeAhead()
+					return
+				case typing && (k.Key == keyboard.KeyBackspace || k.Key == keyboard.KeyBackspace2):
+					ui.typeAheadBackspace(now)
+					return
+				case typing && r > 0x20 && r < 0x7f, startsTypeAhead(r):
+					ui.typeAheadKey(r, now)
+					return
+				case k.Key == keyboard.Key('\''):
+					ui.startTypeAhead(now)
+					return
+				}
+			}
+
 			switch k.Key {
 			case keyboard.KeyEsc:
 				_, _, _, f, _ := ui.snapshot()
@@ -1409,51 +2729,66 @@ func run(targets []string) error {
 				} else {
 					cancel()
 				}
-			case keyboard.Key('q'), keyboard.Key('Q'):
-				cancel()
-			case keyboard.KeyArrowUp, keyboard.Key('k'):
+			case keyboard.KeyArrowUp:
 				ui.moveUp()
-			case keyboard.KeyArrowDown, keyboard.Key('j'):
+				nav.moved(time.Now())
+			case keyboard.KeyArrowDown:
 				ui.moveDown()
+				nav.moved(time.Now())
 			case keyboard.KeyTab:
 				ui.toggleFocus()
-			case keyboard.Key('/'):
-				ui.startFilter()
-			case keyboard.Key(']'), keyboard.Key('+'):
-				rateWindowUp()
-			case keyboard.Key('['), keyboard.Key('-'):
-				rateWindowDown()
+			case keyboard.KeyEnter, keyboard.KeyArrowRight, keyboard.KeyArrowLeft:
+				_, _, focus, _ := ui.seriesSnapshot()
+				name := ui.selectedKey()
+				if focus == focusSeriesTable && k.Key != keyboard.KeyEnter {
+					limit := max(len(labelKeys(filterGroup(st.seriesForName(name), ui.group())))-1, 0)
+					if k.Key == keyboard.KeyArrowRight {
+						ui.scrollTable(1, limit)
+					} else {
+						ui.scrollTable(-1, limit)
+					}
+					break
+				}
+				base, ok := familyBase(name, st.firstType(name))
+				if focus != focusSidebar || !ok || ui.rawListEnabled() {
+					break
+				}
+				switch k.Key {
+				case keyboard.KeyEnter:
+					ui.toggleFamily(base)
+				case keyboard.KeyArrowRight:
+					ui.setFamilyExpanded(base, true)
+				case keyboard.KeyArrowLeft:
+					ui.setFamilyExpanded(base, false)
+				}
+				names, _, families := listKeys(st, ui, ui.group())
+				ui.setKeys(names)
+				if _, visible := families[name]; !visible {
+					for key, row := range families {
+						if row.header && row.base == base {
+							ui.selectName(key)
+						}
+					}
+				} else {
+					ui.selectName(name)
+				}
+			default:
+				if run, ok := keyHandlers[rune(k.Key)]; ok {
+					run(keys, rune(k.Key))
+				}
 			}
 		}),
-		termdash.RedrawInterval(refreshInterval),
 	)
-	return err
-}
-
-var (
-	flagTargets    = flag.String("targets", "", "comma-separated host:port list of Prometheus endpoints (env: METRIC_TARGETS)")
-	flagRateWindow = flag.String("rate-window", "", "rate calculation window duration, e.g. 10s (env: RATE_WINDOW)")
-	flagPatterns   = flag.String("patterns", "", "path to custom metric patterns YAML file (overrides built-in defaults)")
-	flagVersion    = flag.Bool("version", false, "print version and exit")
-)
-
-func parseTargets(flagVal string) []string {
-	val := flagVal
-	if val == "" {
-		val = os.Getenv("METRIC_TARGETS")
-	}
-	if val == "" {
-		val = "localhost:8080"
-	}
-	parts := strings.Split(val, ",")
-	var targets []string
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p != "" {
-			targets = append(targets, p)
-		}
+	if err != nil {
+		return err
 	}
-	return targets
+	defer controller.Close()
+	ctrl.Store(controller)
+	<-ctx.Done()
+	drawMu.Lock()
+	ctrl.Store(nil)
+	drawMu.Unlock()
+	return nil
 }
 
 func parseRateWindow(flagVal string) {
@@ -1472,25 +2807,7 @@ func parseRateWindow(flagVal string) {
 }
 
 func main() {
-	flag.Parse()
-
-	if *flagVersion {
-		fmt.Printf("madvisor %s (commit=%s branch=%s)\n", version, commit, branch)
-		os.Exit(0)
-	}
-
-	if err := initPatterns(*flagPatterns); err != nil {
-		log.Fatalf("madvisor: %v", err)
-	}
-
-	targets := parseTargets(*flagTargets)
-	parseRateWindow(*flagRateWindow)
-	log.Printf("madvisor %s (commit=%s branch=%s)", version, commit, branch)
-	log.Printf("madvisor: targets=%v rateWindow=%s", targets, rateWindowGet())
-
-	waitForTTY()
-
-	if err := run(targets); err != nil {
-		log.Fatalf("madvisor: %v", err)
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
 }
@@ -254,6 +254,74 @@ func TestStoreDistinctLabelSets(t *testing.T) {
 	}
 }
 
+func TestMetricSeriesReset(t *testing.T) {
+	s := newTestSeries("test", nil)
+	for i := 0; i < ringSize+3; i++ {
+		s.push(float64(i))
+	}
+	s.reset()
+
+	if s.count() != 0 {
+		t.Errorf("count after reset = %d, want 0", s.count())
+	}
+	if s.full {
+		t.Error("should not be full after reset")
+	}
+	if s.last() != 0 {
+		t.Errorf("last() after reset = %f, want 0", s.last())
+	}
+
+	s.push(7)
+	if got := s.slice(); len(got) != 1 || got[0] != 7 {
+		t.Errorf("slice after reset+push = %v, want [7]", got)
+	}
+}
+
+func TestStoreResetSeries(t *testing.T) {
+	st := newStore()
+	st.update("cpu", map[string]string{"env": "prod"}, "", "gauge", 10)
+	st.update("cpu", map[string]string{"env": "staging"}, "", "gauge", 20)
+
+	if !st.resetSeries("cpu{env=prod}") {
+		t.Fatal("resetSeries() = false for existing key")
+	}
+	if st.resetSeries("missing") {
+		t.Error("resetSeries() = true for missing key")
+	}
+	if n := st.get("cpu{env=prod}").count(); n != 0 {
+		t.Errorf("reset series count = %d, want 0", n)
+	}
+	if n := st.get("cpu{env=staging}").count(); n != 1 {
+		t.Errorf("untouched series count = %d, want 1", n)
+	}
+	if len(st.snapshot()) != 2 {
+		t.Error("resetSeries should keep the series in the store")
+	}
+}
+
+func TestStoreResetNameAndAll(t *testing.T) {
+	st := newStore()
+	st.update("cpu", map[string]string{"env": "prod"}, "", "gauge", 10)
+	st.update("cpu", map[string]string{"env": "staging"}, "", "gauge", 20)
+	st.update("mem", nil, "", "gauge", 30)
+
+	if n := st.resetName("cpu"); n != 2 {
+		t.Errorf("resetName(cpu) = %d, want 2", n)
+	}
+	if st.get("mem").count() != 1 {
+		t.Error("resetName should not touch other metrics")
+	}
+
+	if n := st.resetAll(); n != 3 {
+		t.Errorf("resetAll() = %d, want 3", n)
+	}
+	for _, s := range st.snapshot() {
+		if s.count() != 0 {
+			t.Errorf("%s count after resetAll = %d, want 0", s.key, s.count())
+		}
+	}
+}
+
 // --- parseLabels tests ---
 
 func TestParseLabels(t *testing.T) {
@@ -267,6 +335,8 @@ func TestParseLabels(t *testing.T) {
 		{`http_requests{method="GET"}`, "http_requests", map[string]string{"method": "GET"}},
 		{`http_requests{method="GET",path="/api"}`, "http_requests", map[string]string{"method": "GET", "path": "/api"}},
 		{`m{a="1", b="2"}`, "m", map[string]string{"a": "1", "b": "2"}},
+		{`m{a="x,y",b="}"}`, "m", map[string]string{"a": "x,y", "b": "}"}},
+		{`m{q="say \"hi\"",p="C:\\tmp",n="a\nb"}`, "m", map[string]string{"q": `say "hi"`, "p": `C:\tmp`, "n": "a\nb"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -289,66 +359,26 @@ func TestParseLabels(t *testing.T) {
 	}
 }
 
-// --- parseTargets tests ---
-
-func TestParseTargets(t *testing.T) {
-	os.Setenv("METRIC_TARGETS", "host1:8080,host2:9090")
-	defer os.Unsetenv("METRIC_TARGETS")
-
-	got := parseTargets("")
-	if len(got) != 2 {
-		t.Fatalf("len = %d, want 2", len(got))
-	}
-	if got[0] != "host1:8080" || got[1] != "host2:9090" {
-		t.Errorf("parseTargets() = %v", got)
-	}
-}
-
-func TestParseTargetsDefault(t *testing.T) {
-	os.Unsetenv("METRIC_TARGETS")
-
-	got := parseTargets("")
-	if len(got) != 1 || got[0] != "localhost:8080" {
-		t.Errorf("parseTargets() default = %v, want [localhost:8080]", got)
-	}
-}
-
-func TestParseTargetsTrimsWhitespace(t *testing.T) {
-	os.Setenv("METRIC_TARGETS", " host1:8080 , host2:9090 ")
-	defer os.Unsetenv("METRIC_TARGETS")
-
-	got := parseTargets("")
-	if len(got) != 2 || got[0] != "host1:8080" || got[1] != "host2:9090" {
-		t.Errorf("parseTargets() = %v", got)
-	}
-}
-
-func TestParseTargetsSkipsEmpty(t *testing.T) {
-	os.Setenv("METRIC_TARGETS", "host1:8080,,host2:9090,")
-	defer os.Unsetenv("METRIC_TARGETS")
-
-	got := parseTargets("")
-	if len(got) != 2 {
-		t.Errorf("parseTargets() len = %d, want 2 (skip empties)", len(got))
-	}
-}
-
-func TestParseTargetsFlagOverridesEnv(t *testing.T) {
-	os.Setenv("METRIC_TARGETS", "envhost:8080")
-	defer os.Unsetenv("METRIC_TARGETS")
-
-	got := parseTargets("flaghost:9090")
-	if len(got) != 1 || got[0] != "flaghost:9090" {
-		t.Errorf("parseTargets(flag) = %v, want [flaghost:9090]", got)
+func TestSplitSample(t *testing.T) {
+	tests := []struct {
+		line, series, value string
+		ok                  bool
+	}{
+		{`up 1`, "up", "1", true},
+		{`m 5 1700000000000`, "m", "5", true},
+		{`m{a="b c"} 2`, `m{a="b c"}`, "2", true},
+		{`m{a="} 9"} 3 1700000000000`, `m{a="} 9"}`, "3", true},
+		{`m_bucket{le="1"} 4 # {trace_id="abc"} 0.5 1700000000.1`, `m_bucket{le="1"}`, "4", true},
+		{`m{a="1"}`, "", "", false},
+		{`m{a="1" 2`, "", "", false},
+		{`m 1 2 3`, "", "", false},
+		{`m{}0`, "", "", false},
 	}
-}
-
-func TestParseTargetsFlagOnly(t *testing.T) {
-	os.Unsetenv("METRIC_TARGETS")
-
-	got := parseTargets("a:1,b:2")
-	if len(got) != 2 || got[0] != "a:1" || got[1] != "b:2" {
-		t.Errorf("parseTargets(flag) = %v, want [a:1 b:2]", got)
+	for _, tt := range tests {
+		series, value, ok := splitSample(tt.line)
+		if series != tt.series || value != tt.value || ok != tt.ok {
+			t.Errorf("splitSample(%q) = %q, %q, %v; want %q, %q, %v", tt.line, series, value, ok, tt.series, tt.value, tt.ok)
+		}
 	}
 }
 
@@ -606,12 +636,15 @@ cpu_usage 50.0
 
 	st := newStore()
 	client := &http.Client{}
-	target := strings.TrimPrefix(srv.URL, "http://")
-	scrapeTarget(client, target, st)
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	scrapeTarget(client, target{addr: addr}, st)
 
 	snap := st.snapshot()
-	if len(snap) != 4 {
-		t.Fatalf("snapshot len = %d, want 4", len(snap))
+	if len(snap) != 5 {
+		t.Fatalf("snapshot len = %d, want 4 plus up", len(snap))
+	}
+	if up := st.get(seriesKey(upMetric, map[string]string{instanceLabel: addr})); up == nil || up.last() != 1 {
+		t.Errorf("up = %+v, want 1 after a successful scrape", up)
 	}
 
 	s := st.get("http_requests_total{method=GET,path=/api}")
@@ -651,6 +684,9 @@ func TestFormatBytes(t *testing.T) {
 		{1048576, "1.00 MiB"},
 		{1073741824, "1.00 GiB"},
 		{1099511627776, "1.00 TiB"},
+		{-512, "-512 B"},
+		{-1536, "-1.50 KiB"},
+		{-1073741824, "-1.00 GiB"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
@@ -673,6 +709,9 @@ func TestFormatDuration(t *testing.T) {
 		{90, "1.5m"},
 		{5400, "1.5h"},
 		{172800, "2.0d"},
+		{-0.045, "-45.0ms"},
+		{-1.5, "-1.50s"},
+		{-90, "-1.5m"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
@@ -694,6 +733,8 @@ func TestFormatCount(t *testing.T) {
 		{1500, "1.50k"},
 		{2500000, "2.50M"},
 		{3500000000, "3.50G"},
+		{-42, "-42"},
+		{-1500, "-1.50k"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
@@ -715,6 +756,8 @@ func TestFormatGeneric(t *testing.T) {
 		{42.5, "42.50"},
 		{1500, "1.50k"},
 		{2500000, "2.50M"},
+		{-0.15, "-0.150"},
+		{-1500, "-1.50k"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.want, func(t *testing.T) {
@@ -911,6 +954,57 @@ func TestRateShortWindow(t *testing.T) {
 	}
 }
 
+func TestRateSubSecondSpacing(t *testing.T) {
+	s := newTestSeries("req_total", nil)
+	base := time.Now()
+	// 100ms spacing, 2/sample for the first 10 samples and then 10/sample.
+	v := 0.0
+	for i := 0; i < 20; i++ {
+		if i < 10 {
+			v += 2
+		} else {
+			v += 10
+		}
+		s.pushAt(v, base.Add(time.Duration(i)*100*time.Millisecond))
+	}
+
+	if r := s.rate(250 * time.Millisecond); math.Abs(r-100) > 1e-9 {
+		t.Errorf("rate(250ms) = %f, want 100 (10 per 100ms)", r)
+	}
+	if r := s.rate(time.Second); math.Abs(r-100) > 1e-9 {
+		t.Errorf("rate(1s) = %f, want 100", r)
+	}
+	if r := s.rate(2 * time.Second); math.Abs(r-118/1.9) > 1e-9 {
+		t.Errorf("rate(2s) = %f, want %f (2→120 over 1.9s)", r, 118/1.9)
+	}
+	rates := s.rateSlice(500 * time.Millisecond)
+	if got := rates[4]; math.Abs(got-20) > 1e-9 {
+		t.Errorf("rateSlice(500ms)[4] = %f, want 20", got)
+	}
+}
+
+func TestRateWindowShorterThanSpacing(t *testing.T) {
+	s := newTestSeries("req_total", nil)
+	base := time.Now()
+	s.pushAt(0, base)
+	s.pushAt(10, base.Add(time.Second))
+	s.pushAt(30, base.Add(2*time.Second+10*time.Millisecond))
+
+	if r := s.rate(250 * time.Millisecond); math.Abs(r-20/1.01) > 1e-9 {
+		t.Errorf("rate(250ms) over 1s scrapes = %f, want the last interval's %f", r, 20/1.01)
+	}
+	if r := s.rate(time.Second); math.Abs(r-20/1.01) > 1e-9 {
+		t.Errorf("rate(1s) with a jittered scrape = %f, want %f", r, 20/1.01)
+	}
+
+	reset := newTestSeries("req_total", nil)
+	reset.pushAt(50, base)
+	reset.pushAt(10, base.Add(time.Second))
+	if r := reset.rate(250 * time.Millisecond); r != 0 {
+		t.Errorf("rate(250ms) across a reset = %f, want 0", r)
+	}
+}
+
 func TestRateSingleSample(t *testing.T) {
 	s := newTestSeries("counter_total", nil)
 	s.pushAt(100, time.Now())
@@ -957,7 +1051,8 @@ func TestRateSlice(t *testing.T) {
 		t.Fatalf("rateSlice len = %d, want 3", len(rates))
 	}
 
-	expected := []float64{10, 20, 30}
+	// each point is the delta over the trailing 5s window: 10/1, 30/2, 60/3
+	expected := []float64{10, 15, 20}
 	for i, want := range expected {
 		if rates[i] < want-0.1 || rates[i] > want+0.1 {
 			t.Errorf("rateSlice[%d] = %f, want ~%f", i, rates[i], want)
@@ -965,6 +1060,93 @@ func TestRateSlice(t *testing.T) {
 	}
 }
 
+func TestRateSliceShortWindow(t *testing.T) {
+	s := &metricSeries{
+		name:   "req_total",
+		mtype:  "counter",
+		values: make([]float64, ringSize),
+		times:  make([]time.Time, ringSize),
+	}
+
+	base := time.Now()
+	s.pushAt(0, base)
+	s.pushAt(10, base.Add(1*time.Second))
+	s.pushAt(30, base.Add(2*time.Second))
+	s.pushAt(60, base.Add(3*time.Second))
+
+	rates := s.rateSlice(1 * time.Second)
+	expected := []float64{10, 20, 30}
+	for i, want := range expected {
+		if rates[i] < want-0.1 || rates[i] > want+0.1 {
+			t.Errorf("rateSlice(1s)[%d] = %f, want ~%f", i, rates[i], want)
+		}
+	}
+}
+
+func TestRateSliceMatchesRate(t *testing.T) {
+	s := &metricSeries{
+		name:   "req_total",
+		mtype:  "counter",
+		values: make([]float64, ringSize),
+		times:  make([]time.Time, ringSize),
+	}
+
+	base := time.Now()
+	v := 0.0
+	for i := 0; i < ringSize+20; i++ {
+		v += float64(i % 7)
+		jitter := time.Duration(i%3) * 100 * time.Millisecond
+		s.pushAt(v, base.Add(time.Duration(i)*time.Second+jitter))
+	}
+
+	for _, w := range []time.Duration{2 * time.Second, 5 * time.Second, 30 * time.Second} {
+		rates := s.rateSlice(w)
+		if got, want := rates[len(rates)-1], s.rate(w); math.Abs(got-want) > 1e-9 {
+			t.Errorf("rateSlice(%s) last = %f, rate(%s) = %f, want equal", w, got, w, want)
+		}
+	}
+}
+
+func TestRateCounterResetMidWindow(t *testing.T) {
+	s := &metricSeries{
+		name:   "req_total",
+		mtype:  "counter",
+		values: make([]float64, ringSize),
+		times:  make([]time.Time, ringSize),
+	}
+
+	base := time.Now()
+	s.pushAt(100, base)
+	s.pushAt(110, base.Add(1*time.Second))
+	s.pushAt(5, base.Add(2*time.Second))
+	s.pushAt(15, base.Add(3*time.Second))
+
+	if r := s.rate(10 * time.Second); r < 9.9 || r > 10.1 {
+		t.Errorf("rate across reset = %f, want ~10 (window restarts at reset)", r)
+	}
+}
+
+func TestTimeSlice(t *testing.T) {
+	s := newTestSeries("test", nil)
+	base := time.Now()
+	for i := 0; i < ringSize+2; i++ {
+		s.pushAt(float64(i), base.Add(time.Duration(i)*time.Second))
+	}
+
+	times := s.timeSlice()
+	if len(times) != ringSize {
+		t.Fatalf("timeSlice len = %d, want %d", len(times), ringSize)
+	}
+	if !times[0].Equal(base.Add(2 * time.Second)) {
+		t.Errorf("timeSlice[0] = %v, want oldest sample time", times[0])
+	}
+	for i := 1; i < len(times); i++ {
+		if !times[i].After(times[i-1]) {
+			t.Fatalf("timeSlice not chronological at %d", i)
+		}
+	}
+}
+
 func TestRateSliceTooFew(t *testing.T) {
 	s := newTestSeries("x_total", nil)
 	s.pushAt(100, time.Now())
@@ -1030,9 +1212,13 @@ func TestRateWindowUpDown(t *testing.T) {
 	}
 
 	rateWindowSet(1 * time.Second)
+	if got = rateWindowDown(); got != 500*time.Millisecond {
+		t.Errorf("rateWindowDown from 1s = %s, want 500ms", got)
+	}
+	rateWindowSet(250 * time.Millisecond)
 	got = rateWindowDown()
-	if got != 1*time.Second {
-		t.Errorf("rateWindowDown at min = %s, want 1s (clamped)", got)
+	if got != 250*time.Millisecond {
+		t.Errorf("rateWindowDown at min = %s, want 250ms (clamped)", got)
 	}
 
 	rateWindowSet(60 * time.Second)
@@ -1054,6 +1240,15 @@ func TestRateWindowSetSnaps(t *testing.T) {
 	if got := rateWindowGet(); got != 60*time.Second {
 		t.Errorf("rateWindowSet(100s) snapped to %s, want 60s", got)
 	}
+
+	rateWindowSet(100 * time.Millisecond)
+	if got := rateWindowGet(); got != 250*time.Millisecond {
+		t.Errorf("rateWindowSet(100ms) snapped to %s, want 250ms", got)
+	}
+	rateWindowSet(400 * time.Millisecond)
+	if got := rateWindowGet(); got != 500*time.Millisecond {
+		t.Errorf("rateWindowSet(400ms) snapped to %s, want 500ms", got)
+	}
 }
 
 func TestParseRateWindow(t *testing.T) {
@@ -1096,6 +1291,16 @@ func TestParseRateWindowFlagOnly(t *testing.T) {
 	}
 }
 
+func TestParseRateWindowSubSecond(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	os.Unsetenv("RATE_WINDOW")
+
+	parseRateWindow("250ms")
+	if got := rateWindowGet(); got != 250*time.Millisecond || got.String() != "250ms" {
+		t.Errorf("rateWindow = %s, want 250ms", got)
+	}
+}
+
 func TestParseRateWindowFlagInvalid(t *testing.T) {
 	defer rateWindowSet(defaultRateWindow)
 	os.Unsetenv("RATE_WINDOW")
@@ -1109,10 +1314,14 @@ func TestParseRateWindowFlagInvalid(t *testing.T) {
 func TestScrapeTargetHandlesError(t *testing.T) {
 	st := newStore()
 	client := &http.Client{}
-	scrapeTarget(client, "localhost:1", st)
+	scrapeTarget(client, target{addr: "localhost:1"}, st)
 
-	if len(st.snapshot()) != 0 {
-		t.Error("scrapeTarget should not populate store on connection error")
+	snap := st.snapshot()
+	if len(snap) != 1 || snap[0].name != upMetric || snap[0].last() != 0 {
+		t.Error("scrapeTarget should only record up=0 on connection error")
+	}
+	if st.hasData() {
+		t.Error("the synthetic up series should not count as data")
 	}
 }
 
@@ -1370,3 +1579,72 @@ func TestUIStateSidebarNavResetsSeries(t *testing.T) {
 		t.Errorf("seriesIdx should reset to 0 when navigating sidebar, got %d", seriesIdx)
 	}
 }
+
+func TestResetSelection(t *testing.T) {
+	st := newStore()
+	st.update("cpu", map[string]string{"env": "prod"}, "", "gauge", 10)
+	st.update("cpu", map[string]string{"env": "staging"}, "", "gauge", 20)
+
+	u := &uiState{}
+	u.setKeys(st.names())
+
+	u.toggleFocus()
+	u.moveDown()
+	resetSelection(u, st)
+	if st.get("cpu{env=prod}").count() != 1 {
+		t.Error("series table focus should only reset the selected series")
+	}
+	if st.get("cpu{env=staging}").count() != 0 {
+		t.Error("selected series should be reset")
+	}
+
+	u.toggleFocus()
+	resetSelection(u, st)
+	if st.get("cpu{env=prod}").count() != 0 {
+		t.Error("sidebar focus should reset every series of the metric")
+	}
+}
+
+func TestUIStateToggleClip(t *testing.T) {
+	u := &uiState{}
+	if u.clipEnabled("cpu") {
+		t.Error("clipping should be off by default")
+	}
+	if !u.toggleClip("cpu") {
+		t.Error("toggleClip should enable clipping")
+	}
+	if u.clipEnabled("mem") {
+		t.Error("clipping is per chart, mem should be unaffected")
+	}
+	if u.toggleClip("cpu") {
+		t.Error("second toggleClip should disable clipping")
+	}
+}
+
+func TestUIStateToggleDual(t *testing.T) {
+	u := &uiState{}
+	if u.dualEnabled() {
+		t.Error("dual view should be off by default")
+	}
+	if !u.toggleDual() || !u.dualEnabled() {
+		t.Error("toggleDual should enable dual view")
+	}
+	if u.toggleDual() {
+		t.Error("second toggleDual should disable dual view")
+	}
+}
+
+func TestUIStateMessage(t *testing.T) {
+	u := &uiState{}
+	if got := u.currentMessage(); got != "" {
+		t.Errorf("initial message = %q, want empty", got)
+	}
+	u.setMessage("hello")
+	if got := u.currentMessage(); got != "hello" {
+		t.Errorf("message = %q, want hello", got)
+	}
+	u.messageAt = time.Now().Add(-messageTTL - time.Second)
+	if got := u.currentMessage(); got != "" {
+		t.Errorf("expired message = %q, want empty", got)
+	}
+}
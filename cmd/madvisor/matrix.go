@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	matrixLabelWidth = 32
+	matrixCellWidth  = 14
+	matrixMaxRows    = 20
+)
+
+type matrixRow struct {
+	label  string
+	values []float64
+}
+
+type replicaMatrix struct {
+	instances []string
+	rows      []matrixRow
+	rated     bool
+}
+
+func replicaLabelText(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != instanceLabel {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return "(no labels)"
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + labels[k]
+	}
+	return strings.Join(parts, ",")
+}
+
+func buildReplicaMatrix(seriesList []*metricSeries, value func(*metricSeries) float64) replicaMatrix {
+	var m replicaMatrix
+	col := map[string]int{}
+	cells := map[string]map[string]float64{}
+	var rowOrder []string
+	for _, s := range seriesList {
+		inst, ok := s.labels[instanceLabel]
+		if !ok {
+			continue
+		}
+		if _, ok := col[inst]; !ok {
+			col[inst] = len(m.instances)
+			m.instances = append(m.instances, inst)
+		}
+		row := replicaLabelText(s.labels)
+		if cells[row] == nil {
+			cells[row] = map[string]float64{}
+			rowOrder = append(rowOrder, row)
+		}
+		cells[row][inst] = value(s)
+		m.rated = m.rated || s.shouldRate()
+	}
+	sort.Strings(m.instances)
+	sort.Strings(rowOrder)
+	for _, row := range rowOrder {
+		r := matrixRow{label: row, values: make([]float64, len(m.instances))}
+		for i, inst := range m.instances {
+			v, ok := cells[row][inst]
+			if !ok {
+				v = math.NaN()
+			}
+			r.values[i] = v
+		}
+		m.rows = append(m.rows, r)
+	}
+	return m
+}
+
+func rowMedian(values []float64) float64 {
+	var vs []float64
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			vs = append(vs, v)
+		}
+	}
+	if len(vs) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(vs)
+	if len(vs)%2 == 1 {
+		return vs[len(vs)/2]
+	}
+	return (vs[len(vs)/2-1] + vs[len(vs)/2]) / 2
+}
+
+func deviation(v, median float64) float64 {
+	if math.IsNaN(v) || math.IsNaN(median) {
+		return 0
+	}
+	if median == 0 {
+		if v == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return math.Abs(v-median) / math.Abs(median)
+}
+
+func heatColor(dev float64) cell.Color {
+	switch {
+	case dev >= 0.5:
+		return cell.ColorRed
+	case dev >= 0.1:
+		return cell.ColorYellow
+	}
+	return cell.ColorGreen
+}
+
+func truncateText(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func renderReplicaMatrix(w *text.Text, name string, seriesList []*metricSeries) {
+	w.Reset()
+
+	if name == "" || len(seriesList) == 0 {
+		w.Write("  select a metric name", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+	m := buildReplicaMatrix(seriesList, watchValue)
+	if len(m.instances) < 2 {
+		w.Write("  "+name+" is not reported by multiple instances\n", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write("  (series get an instance label when replicas expose identical label sets)", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		return
+	}
+
+	format := func(v float64) string {
+		if math.IsNaN(v) {
+			return "—"
+		}
+		if m.rated {
+			return formatGeneric(v) + "/s"
+		}
+		return formatValue(name, v)
+	}
+
+	w.Write(fmt.Sprintf(" %-*s", matrixLabelWidth, truncateText(name, matrixLabelWidth)), text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+	for _, inst := range m.instances {
+		w.Write(fmt.Sprintf(" %*s", matrixCellWidth, truncateText(inst, matrixCellWidth)), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	}
+	w.Write("\n")
+
+	for i, row := range m.rows {
+		if i == matrixMaxRows {
+			w.Write(fmt.Sprintf("  ↓ %d more\n", len(m.rows)-i), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+			break
+		}
+		w.Write(fmt.Sprintf(" %-*s", matrixLabelWidth, truncateText(row.label, matrixLabelWidth)), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		median := rowMedian(row.values)
+		for _, v := range row.values {
+			w.Write(fmt.Sprintf(" %*s", matrixCellWidth, truncateText(format(v), matrixCellWidth)),
+				text.WriteCellOpts(cell.FgColor(heatColor(deviation(v, median)))))
+		}
+		w.Write("\n")
+	}
+}
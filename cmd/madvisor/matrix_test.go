@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func replicaSeries(inst, code string, v float64) *metricSeries {
+	s := newTestSeries("queue_depth", map[string]string{instanceLabel: inst, "code": code})
+	s.push(v)
+	return s
+}
+
+func TestBuildReplicaMatrix(t *testing.T) {
+	list := []*metricSeries{
+		replicaSeries("pod-b:8080", "200", 12),
+		replicaSeries("pod-a:8080", "200", 10),
+		replicaSeries("pod-a:8080", "500", 1),
+		newTestSeries("queue_depth", map[string]string{"code": "200"}),
+	}
+	m := buildReplicaMatrix(list, func(s *metricSeries) float64 { return s.last() })
+
+	if len(m.instances) != 2 || m.instances[0] != "pod-a:8080" || m.instances[1] != "pod-b:8080" {
+		t.Fatalf("instances = %v", m.instances)
+	}
+	if len(m.rows) != 2 || m.rows[0].label != "code=200" || m.rows[1].label != "code=500" {
+		t.Fatalf("rows = %+v", m.rows)
+	}
+	if m.rows[0].values[0] != 10 || m.rows[0].values[1] != 12 {
+		t.Errorf("row 0 = %v", m.rows[0].values)
+	}
+	if m.rows[1].values[0] != 1 || !math.IsNaN(m.rows[1].values[1]) {
+		t.Errorf("missing cell should be NaN, row 1 = %v", m.rows[1].values)
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	tests := []struct {
+		v, median float64
+		want      cell.Color
+	}{
+		{100, 100, cell.ColorGreen},
+		{105, 100, cell.ColorGreen},
+		{130, 100, cell.ColorYellow},
+		{300, 100, cell.ColorRed},
+		{5, 0, cell.ColorRed},
+		{0, 0, cell.ColorGreen},
+		{math.NaN(), 100, cell.ColorGreen},
+	}
+	for _, tt := range tests {
+		if got := heatColor(deviation(tt.v, tt.median)); got != tt.want {
+			t.Errorf("heatColor(deviation(%v, %v)) = %v, want %v", tt.v, tt.median, got, tt.want)
+		}
+	}
+}
+
+func TestRowMedian(t *testing.T) {
+	if got := rowMedian([]float64{3, math.NaN(), 1, 2}); got != 2 {
+		t.Errorf("odd median = %v, want 2", got)
+	}
+	if got := rowMedian([]float64{4, 1, 2, 3}); got != 2.5 {
+		t.Errorf("even median = %v, want 2.5", got)
+	}
+	if got := rowMedian([]float64{math.NaN()}); !math.IsNaN(got) {
+		t.Errorf("empty median = %v, want NaN", got)
+	}
+}
+
+func TestRenderReplicaMatrix(t *testing.T) {
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderReplicaMatrix(w, "", nil)
+	renderReplicaMatrix(w, "queue_depth", []*metricSeries{replicaSeries("pod-a:8080", "200", 1)})
+	renderReplicaMatrix(w, "queue_depth", []*metricSeries{
+		replicaSeries("pod-a:8080", "200", 1),
+		replicaSeries("pod-b:8080", "200", 9),
+	})
+}
+
+func TestUIStateToggleMatrix(t *testing.T) {
+	u := &uiState{}
+	u.togglePanel(panelInfo)
+	if !u.togglePanel(panelMatrix) || u.panel() == panelInfo {
+		t.Error("togglePanel(panelMatrix) should show the matrix and hide the info panel")
+	}
+	if u.togglePanel(panelInfo); u.panel() == panelMatrix {
+		t.Error("togglePanel(panelInfo) should hide the matrix")
+	}
+}
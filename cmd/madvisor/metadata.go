@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+type labelCard struct {
+	key    string
+	values int
+}
+
+func labelCardinality(list []*metricSeries) []labelCard {
+	vals := map[string]map[string]bool{}
+	for _, s := range list {
+		for k, v := range s.labels {
+			if vals[k] == nil {
+				vals[k] = map[string]bool{}
+			}
+			vals[k][v] = true
+		}
+	}
+	out := make([]labelCard, 0, len(vals))
+	for k, vs := range vals {
+		out = append(out, labelCard{key: k, values: len(vs)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].values != out[j].values {
+			return out[i].values > out[j].values
+		}
+		return out[i].key < out[j].key
+	})
+	return out
+}
+
+func renderMetadata(w *text.Text, name string, seriesList []*metricSeries, now time.Time) {
+	w.Reset()
+
+	if name == "" || len(seriesList) == 0 {
+		w.Write("  select a metric name", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+
+	key := func(k string) {
+		w.Write(fmt.Sprintf(" %-12s", k), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	}
+	val := func(v string) {
+		w.Write(v+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	}
+
+	first := seriesList[0]
+	key("metric")
+	val(name)
+
+	key("TYPE")
+	if first.mtype != "" {
+		val(first.mtype + " (from # TYPE)")
+	} else {
+		val(first.detectedType() + " (default, no # TYPE)")
+	}
+
+	key("HELP")
+	if first.help != "" {
+		val(first.help)
+	} else {
+		val("(none)")
+	}
+
+	key("unit")
+	if m := matchUnit(name); m != nil {
+		val(fmt.Sprintf("%s%s %s", m.Unit, m.Suffix, m.source()))
+	} else {
+		val("(no pattern matched, generic formatting)")
+	}
+
+	var firstSeen time.Time
+	samples, history := 0, 0
+	for _, s := range seriesList {
+		if firstSeen.IsZero() || s.firstSeen.Before(firstSeen) {
+			firstSeen = s.firstSeen
+		}
+		samples += s.samples
+		history += s.count()
+	}
+
+	key("series")
+	val(fmt.Sprintf("%d", len(seriesList)))
+
+	key("first seen")
+	if firstSeen.IsZero() {
+		val("unknown")
+	} else {
+		val(fmt.Sprintf("%s (%s ago)", firstSeen.Format("15:04:05"), formatRelDuration(now.Sub(firstSeen))))
+	}
+
+	key("samples")
+	val(fmt.Sprintf("%d scraped, %d in history", samples, history))
+
+	key("labels")
+	cards := labelCardinality(seriesList)
+	if len(cards) == 0 {
+		val("(none)")
+		return
+	}
+	w.Write("\n")
+	for _, c := range cards {
+		w.Write(fmt.Sprintf("   %s", c.key), text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+		w.Write(fmt.Sprintf(" %d values\n", c.values), text.WriteCellOpts(cell.FgColor(cell.ColorGreen)))
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/private/canvas/testcanvas"
+	"github.com/mum4k/termdash/private/faketerm"
+	"github.com/mum4k/termdash/widgetapi"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestLabelCardinality(t *testing.T) {
+	list := []*metricSeries{
+		newTestSeries("m", map[string]string{"method": "GET", "path": "/a", "env": "prod"}),
+		newTestSeries("m", map[string]string{"method": "POST", "path": "/a", "env": "prod"}),
+		newTestSeries("m", map[string]string{"method": "PUT", "path": "/b", "env": "prod"}),
+	}
+
+	got := labelCardinality(list)
+	want := []labelCard{{"method", 3}, {"path", 2}, {"env", 1}}
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("labelCardinality()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLabelCardinalityNoLabels(t *testing.T) {
+	if got := labelCardinality([]*metricSeries{newTestSeries("m", nil)}); len(got) != 0 {
+		t.Errorf("labelCardinality() = %v, want empty", got)
+	}
+}
+
+func TestStoreTracksFirstSeenAndSamples(t *testing.T) {
+	before := time.Now()
+	st := newStore()
+	st.update("m", nil, "", "gauge", 1)
+	st.update("m", nil, "", "gauge", 2)
+
+	s := st.get("m")
+	if s.firstSeen.Before(before) {
+		t.Errorf("firstSeen = %v, want >= %v", s.firstSeen, before)
+	}
+	if s.samples != 2 {
+		t.Errorf("samples = %d, want 2", s.samples)
+	}
+
+	st.resetSeries("m")
+	if s.samples != 2 {
+		t.Errorf("samples after reset = %d, want 2 (lifetime counter)", s.samples)
+	}
+}
+
+func TestUnitMatchPattern(t *testing.T) {
+	m := matchUnit("process_resident_memory_bytes")
+	if m == nil {
+		t.Fatal("no unit match for _bytes metric")
+	}
+	if m.Pattern != "_bytes$" {
+		t.Errorf("Pattern = %q, want _bytes$", m.Pattern)
+	}
+}
+
+// textContent draws w on a fake terminal and returns what it shows.
+func textContent(t *testing.T, w *text.Text) string {
+	t.Helper()
+	area := image.Rect(0, 0, 120, 20)
+	cvs := testcanvas.MustNew(area)
+	if err := w.Draw(cvs, &widgetapi.Meta{}); err != nil {
+		t.Fatal(err)
+	}
+	ft := faketerm.MustNew(area.Size())
+	testcanvas.MustApply(cvs, ft)
+	return ft.String()
+}
+
+func TestRenderMetadata(t *testing.T) {
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderMetadata(w, "", nil, time.Now())
+	if got := textContent(t, w); !strings.Contains(got, "select a metric name") {
+		t.Errorf("no metric selected:\n%s", got)
+	}
+
+	s := newTestSeries("http_requests_total", map[string]string{"method": "GET"})
+	s.help = "Total requests"
+	s.mtype = "counter"
+	s.firstSeen = time.Now().Add(-time.Minute)
+	s.push(1)
+	renderMetadata(w, s.name, []*metricSeries{s}, time.Now())
+	got := textContent(t, w)
+	for _, want := range []string{"TYPE        counter (from # TYPE)", "HELP        Total requests", "unit        count [count] via /_total$/ (patterns)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	bare := newTestSeries("queue_depth", nil)
+	bare.push(3)
+	renderMetadata(w, bare.name, []*metricSeries{bare}, time.Now())
+	got = textContent(t, w)
+	for _, want := range []string{"TYPE        gauge (default, no # TYPE)", "HELP        (none)", "unit        (no pattern matched, generic formatting)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestUIStateToggleInfo(t *testing.T) {
+	u := &uiState{}
+	if u.panel() != panelNone {
+		t.Error("no panel should be shown by default")
+	}
+	if !u.togglePanel(panelInfo) || u.panel() != panelInfo {
+		t.Error("togglePanel should show the info panel")
+	}
+	if u.togglePanel(panelInfo) || u.panel() != panelNone {
+		t.Error("togglePanel again should hide the info panel")
+	}
+}
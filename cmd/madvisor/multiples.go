@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/container"
+	"github.com/mum4k/termdash/container/grid"
+	"github.com/mum4k/termdash/linestyle"
+	"github.com/mum4k/termdash/widgets/linechart"
+)
+
+const maxMultiples = 12
+
+type multiplesMode int
+
+const (
+	multiplesOff multiplesMode = iota
+	multiplesOwnScale
+	multiplesSharedScale
+)
+
+func (m multiplesMode) String() string {
+	switch m {
+	case multiplesOwnScale:
+		return "small multiples"
+	case multiplesSharedScale:
+		return "small multiples, shared Y scale"
+	}
+	return "overlay"
+}
+
+func multiplesLayout(n int) (rows, cols int) {
+	if n <= 0 {
+		return 0, 0
+	}
+	cols = int(math.Ceil(math.Sqrt(float64(n))))
+	rows = (n + cols - 1) / cols
+	return rows, cols
+}
+
+// parseDashboard parses a fixed small-multiples grid such as "2x2", or "off"
+// (reported as 0×0) to return to the overlay chart.
+func parseDashboard(s string) (rows, cols int, err error) {
+	if s == "off" {
+		return 0, 0, nil
+	}
+	r, c, ok := strings.Cut(strings.ToLower(s), "x")
+	rows, rerr := strconv.Atoi(r)
+	cols, cerr := strconv.Atoi(c)
+	if !ok || rerr != nil || cerr != nil || rows < 1 || cols < 1 || rows*cols > maxMultiples {
+		return 0, 0, fmt.Errorf("dashboard expects <rows>x<cols> with up to %d charts, or off, got %q", maxMultiples, s)
+	}
+	return rows, cols, nil
+}
+
+// valueRange is the lowest and highest finite value in datasets.
+func valueRange(datasets [][]float64) (lo, hi float64, ok bool) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, data := range datasets {
+		for _, v := range data {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if lo > hi {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+// scaleSeries spans the Y axis from lo to hi over n values without drawing
+// anything: linechart skips segments that touch a NaN.
+func scaleSeries(lo, hi float64, n int) []float64 {
+	out := make([]float64, max(n, 3))
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	out[0], out[len(out)-1] = lo, hi
+	return out
+}
+
+// smallMultiples keeps one mini chart per series between redraws, only
+// replacing their data; the charts are recreated when key (the metric and
+// its transform, which decide the axis format) changes.
+type smallMultiples struct {
+	key    string
+	charts []*linechart.LineChart
+}
+
+// build lays out one mini chart per series in a grid, optionally on a common
+// Y scale so the panels compare by height as well as by shape. A rows×cols
+// grid (from :dashboard) replaces the automatic layout; 0×0 keeps it.
+func (sm *smallMultiples) build(key, title string, labels []string, datasets [][]float64, format linechart.ValueFormatter, mode multiplesMode, rows, cols int) ([]grid.Element, error) {
+	limit := maxMultiples
+	if rows > 0 {
+		limit = rows * cols
+	}
+	n := min(len(datasets), limit)
+	if n < len(datasets) {
+		title += fmt.Sprintf("[first %d of %d] ", n, len(datasets))
+	}
+	if key != sm.key {
+		sm.key, sm.charts = key, nil
+	}
+	for len(sm.charts) < n {
+		lc, err := linechart.New(linechart.YAxisAdaptive(), linechart.YAxisFormattedValues(format))
+		if err != nil {
+			return nil, err
+		}
+		sm.charts = append(sm.charts, lc)
+	}
+	sharedLo, sharedHi, shared := valueRange(datasets[:n])
+	shared = shared && mode == multiplesSharedScale
+
+	if rows == 0 {
+		rows, cols = multiplesLayout(n)
+	}
+	var rowElems []grid.Element
+	for r := 0; r < rows; r++ {
+		var colElems []grid.Element
+		for c := 0; c < cols; c++ {
+			i := r*cols + c
+			if i >= n {
+				break
+			}
+			lc := sm.charts[i]
+			if err := lc.Series("value", datasets[i], linechart.SeriesCellOpts(cell.FgColor(colorForIndex(i)))); err != nil {
+				return nil, err
+			}
+			lo, hi, ok := valueRange(datasets[i : i+1])
+			if shared {
+				lo, hi, ok = sharedLo, sharedHi, true
+			}
+			if ok {
+				if err := lc.Series(" scale", scaleSeries(lo, hi, len(datasets[i]))); err != nil {
+					return nil, err
+				}
+			}
+			colElems = append(colElems, grid.ColWidthPerc(99/cols,
+				grid.Widget(lc,
+					container.Border(linestyle.Light),
+					container.BorderTitle(" "+truncateText(labels[i], 40)+" "),
+					container.BorderColor(colorForIndex(i)),
+				),
+			))
+		}
+		if len(colElems) == 0 {
+			break
+		}
+		rowElems = append(rowElems, grid.RowHeightPerc(99/rows, colElems...))
+	}
+	return []grid.Element{
+		grid.RowHeightPercWithOpts(99, []container.Option{
+			container.Border(linestyle.Light),
+			container.BorderTitle(title),
+			container.BorderColor(cell.ColorCyan),
+		}, rowElems...),
+	}, nil
+}
+
+func (u *uiState) cycleMultiples() multiplesMode {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.multiples = (u.multiples + 1) % (multiplesSharedScale + 1)
+	return u.multiples
+}
+
+func (u *uiState) multiplesMode() multiplesMode {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.multiples
+}
+
+// setDashboard fixes the small-multiples grid to rows×cols, turning small
+// multiples on if the chart was an overlay; 0×0 returns to the overlay.
+func (u *uiState) setDashboard(rows, cols int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.multiplesRows, u.multiplesCols = rows, cols
+	switch {
+	case rows == 0:
+		u.multiples = multiplesOff
+	case u.multiples == multiplesOff:
+		u.multiples = multiplesOwnScale
+	}
+}
+
+func (u *uiState) multiplesGrid() (rows, cols int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.multiplesRows, u.multiplesCols
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mum4k/termdash/container/grid"
+)
+
+func TestMultiplesLayout(t *testing.T) {
+	cases := []struct{ n, rows, cols int }{
+		{0, 0, 0},
+		{1, 1, 1},
+		{2, 1, 2},
+		{4, 2, 2},
+		{5, 2, 3},
+		{10, 3, 4},
+		{12, 3, 4},
+	}
+	for _, c := range cases {
+		if rows, cols := multiplesLayout(c.n); rows != c.rows || cols != c.cols {
+			t.Errorf("multiplesLayout(%d) = %d×%d, want %d×%d", c.n, rows, cols, c.rows, c.cols)
+		}
+	}
+}
+
+func TestValueRange(t *testing.T) {
+	lo, hi, ok := valueRange([][]float64{{1, math.NaN(), 3}, {-2, 5}})
+	if !ok || lo != -2 || hi != 5 {
+		t.Errorf("valueRange = %v, %v, %v; want -2, 5, true", lo, hi, ok)
+	}
+	if lo, hi, ok := valueRange([][]float64{{4, 4}}); !ok || lo != 4 || hi != 4 {
+		t.Errorf("flat valueRange = %v, %v, %v; want 4, 4, true", lo, hi, ok)
+	}
+	if _, _, ok := valueRange([][]float64{{math.NaN()}, nil}); ok {
+		t.Error("no finite values should have no range")
+	}
+}
+
+func TestScaleSeries(t *testing.T) {
+	got := scaleSeries(1, 9, 5)
+	if len(got) != 5 || got[0] != 1 || got[4] != 9 || !math.IsNaN(got[1]) || !math.IsNaN(got[3]) {
+		t.Errorf("scaleSeries(1, 9, 5) = %v", got)
+	}
+	if got := scaleSeries(1, 9, 2); len(got) != 3 || !math.IsNaN(got[1]) {
+		t.Errorf("scaleSeries(1, 9, 2) = %v, want a NaN between the ends", got)
+	}
+}
+
+func TestSmallMultiples(t *testing.T) {
+	labels := make([]string, 14)
+	datasets := make([][]float64, 14)
+	for i := range datasets {
+		labels[i] = "path=/" + string(rune('a'+i))
+		datasets[i] = []float64{float64(i), float64(i + 1), float64(i + 2)}
+	}
+	var sm smallMultiples
+	for _, mode := range []multiplesMode{multiplesOwnScale, multiplesSharedScale} {
+		elems, err := sm.build("latency", " latency ", labels, datasets, genericAxisFormatter(), mode, 0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if len(elems) != 1 {
+			t.Errorf("%s: %d elements, want one bordered group", mode, len(elems))
+		}
+		builder := grid.New()
+		builder.Add(elems...)
+		if _, err := builder.Build(); err != nil {
+			t.Errorf("%s: grid.Build: %v", mode, err)
+		}
+	}
+	if len(sm.charts) != maxMultiples {
+		t.Fatalf("%d charts, want %d", len(sm.charts), maxMultiples)
+	}
+
+	first := sm.charts[0]
+	if _, err := sm.build("latency", " latency ", labels[:2], datasets[:2], genericAxisFormatter(), multiplesOwnScale, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if sm.charts[0] != first {
+		t.Error("charts of the same metric should be kept between redraws")
+	}
+	if _, err := sm.build("errors", " errors ", labels[:2], datasets[:2], genericAxisFormatter(), multiplesOwnScale, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if sm.charts[0] == first || len(sm.charts) != 2 {
+		t.Error("charts should be recreated for another metric")
+	}
+}
+
+func TestSmallMultiplesFixedGrid(t *testing.T) {
+	labels := []string{"a", "b", "c", "d", "e"}
+	datasets := make([][]float64, len(labels))
+	for i := range datasets {
+		datasets[i] = []float64{1, 2, 3}
+	}
+	var sm smallMultiples
+	for _, n := range []int{5, 3} {
+		elems, err := sm.build("latency", " latency ", labels[:n], datasets[:n], genericAxisFormatter(), multiplesOwnScale, 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder := grid.New()
+		builder.Add(elems...)
+		if _, err := builder.Build(); err != nil {
+			t.Errorf("%d series: grid.Build: %v", n, err)
+		}
+	}
+	if len(sm.charts) != 4 {
+		t.Errorf("%d charts, want the 4 a 2×2 grid holds", len(sm.charts))
+	}
+}
+
+func TestParseDashboard(t *testing.T) {
+	if rows, cols, err := parseDashboard("2x3"); err != nil || rows != 2 || cols != 3 {
+		t.Errorf("parseDashboard(2x3) = %d, %d, %v", rows, cols, err)
+	}
+	if rows, cols, err := parseDashboard("off"); err != nil || rows != 0 || cols != 0 {
+		t.Errorf("parseDashboard(off) = %d, %d, %v", rows, cols, err)
+	}
+	for _, bad := range []string{"2", "0x2", "2x", "4x4", "axb"} {
+		if _, _, err := parseDashboard(bad); err == nil {
+			t.Errorf("parseDashboard(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCycleMultiples(t *testing.T) {
+	ui := &uiState{}
+	want := []multiplesMode{multiplesOwnScale, multiplesSharedScale, multiplesOff}
+	for _, w := range want {
+		if got := ui.cycleMultiples(); got != w {
+			t.Errorf("cycleMultiples = %s, want %s", got, w)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// navSettle is how long after the last navigation key the full render (chart
+// frames, bottom panels) is held back. Held-down j/k repeat faster than a
+// full render, so meanwhile only the navigated panel is redrawn.
+const navSettle = 150 * time.Millisecond
+
+type navigator struct {
+	wake chan struct{}
+	last atomic.Int64
+}
+
+func newNavigator() *navigator {
+	return &navigator{wake: make(chan struct{}, 1)}
+}
+
+// moved records a navigation key press and asks for a panel redraw. Presses
+// that arrive while a redraw is pending or running coalesce into one.
+func (n *navigator) moved(now time.Time) {
+	n.last.Store(now.UnixNano())
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// active reports whether a navigation key was pressed within navSettle.
+func (n *navigator) active(now time.Time) bool {
+	last := n.last.Load()
+	return last != 0 && now.Sub(time.Unix(0, last)) < navSettle
+}
+
+// run calls render for each coalesced batch of key presses until ctx is done.
+func (n *navigator) run(ctx context.Context, render func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.wake:
+			render()
+		}
+	}
+}
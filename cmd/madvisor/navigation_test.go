@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNavigatorActive(t *testing.T) {
+	n := newNavigator()
+	now := time.Now()
+	if n.active(now) {
+		t.Error("navigator active before any key")
+	}
+	n.moved(now)
+	if !n.active(now.Add(navSettle / 2)) {
+		t.Error("navigator should be active right after a key")
+	}
+	if n.active(now.Add(navSettle)) {
+		t.Error("navigator should settle after navSettle")
+	}
+}
+
+func TestNavigatorCoalescesRepeats(t *testing.T) {
+	n := newNavigator()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var renders atomic.Int32
+	release := make(chan struct{})
+	go n.run(ctx, func() {
+		if renders.Add(1) == 1 {
+			<-release
+		}
+	})
+
+	n.moved(time.Now())
+	for renders.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Key repeats while the first render is still drawing.
+	for i := 0; i < 50; i++ {
+		n.moved(time.Now())
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for renders.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := renders.Load(); got != 2 {
+		t.Errorf("renders = %d, want 2 (one per key, then one for the coalesced repeats)", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	outputNDJSON = "ndjson"
+
+	ndjsonFlushInterval = 250 * time.Millisecond
+)
+
+var flagOutput string
+
+func checkOutput(output string) error {
+	switch output {
+	case "", outputNDJSON:
+		return nil
+	}
+	return fmt.Errorf("--output %q: want %s", output, outputNDJSON)
+}
+
+type ndjsonSample struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+	Value     json.RawMessage   `json:"value"`
+	Timestamp json.RawMessage   `json:"timestamp"`
+	Target    string            `json:"target,omitempty"`
+}
+
+// ndjsonLine converts a stored sample to its output line, with values and
+// timestamps formatted like the CSV/JSON exports (--export-precision,
+// --export-time).
+func ndjsonLine(s sample) ndjsonSample {
+	ts := formatExportTime(s.Time)
+	if flagExportTime == exportTimeRFC3339 {
+		ts = strconv.Quote(ts)
+	}
+	labels := s.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return ndjsonSample{
+		Name:      s.Name,
+		Labels:    labels,
+		Value:     json.RawMessage(formatExportValue(s.Value)),
+		Timestamp: json.RawMessage(ts),
+		Target:    s.Source,
+	}
+}
+
+type ndjsonWriter struct {
+	mu  sync.Mutex
+	bw  *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{bw: bw, enc: json.NewEncoder(bw)}
+}
+
+func (n *ndjsonWriter) write(s sample) {
+	if s.Annotation != "" || math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err == nil {
+		n.err = n.enc.Encode(ndjsonLine(s))
+	}
+}
+
+func (n *ndjsonWriter) flush() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err == nil {
+		n.err = n.bw.Flush()
+	}
+	return n.err
+}
+
+// runNDJSON is the headless --output ndjson mode: every sample is streamed
+// to w as one JSON object per line, flushed a few times a second, until ctx
+// is done or w stops accepting output (e.g. the reading end of a pipe
+// exits).
+func runNDJSON(ctx context.Context, targets []target, feed func(context.Context, *store), w io.Writer) error {
+	out := newNDJSONWriter(w)
+	st := newStore()
+	st.observe = out.write
+	st.onCrash = func(c crash) { log.Printf("madvisor: %s", c) }
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if flagConnectTimeout > 0 {
+		timer := time.AfterFunc(flagConnectTimeout, func() {
+			if !st.hasData() {
+				cancel(notReadyError(st, targets))
+			}
+		})
+		defer timer.Stop()
+	}
+	go feed(ctx, st)
+
+	ticker := time.NewTicker(ndjsonFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err := out.flush()
+			if cause := context.Cause(ctx); errors.Is(cause, errNotReady) {
+				return cause
+			}
+			return err
+		case <-ticker.C:
+			if err := out.flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONLine(t *testing.T) {
+	withExportOptions(t, -1, exportTimeUnixMs)
+	at := time.UnixMilli(1772362800500)
+	a, c := 0.1, 0.2
+	b, err := json.Marshal(ndjsonLine(sample{Time: at, Name: "up", Value: a + c, Source: "db:9187"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"up","labels":{},"value":0.30000000000000004,"timestamp":1772362800500,"target":"db:9187"}`
+	if string(b) != want {
+		t.Errorf("line = %s, want %s", b, want)
+	}
+}
+
+func TestRunNDJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE http_requests_total counter\nhttp_requests_total{code=\"200\"} 5\n")
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	var b bytes.Buffer
+	targets := parseTargets(addr)
+	feed := func(ctx context.Context, st *store) { scrape(ctx, targets, st) }
+	if err := runNDJSON(ctx, targets, feed, &b); err != nil {
+		t.Fatalf("runNDJSON: %v", err)
+	}
+
+	var sawCounter, sawUp bool
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var s struct {
+			Name      string
+			Labels    map[string]string
+			Value     float64
+			Timestamp string
+			Target    string
+		}
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if s.Target != addr {
+			t.Errorf("line %q: target = %q, want %q", line, s.Target, addr)
+		}
+		switch s.Name {
+		case "http_requests_total":
+			sawCounter = s.Labels["code"] == "200" && s.Value == 5 && s.Timestamp != ""
+		case upMetric:
+			sawUp = s.Value == 1
+		}
+	}
+	if !sawCounter || !sawUp {
+		t.Errorf("output = %q, want the counter and up samples", b.String())
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	if err := checkOutput("csv"); err == nil {
+		t.Error("checkOutput(csv) should fail")
+	}
+	if err := checkOutput(outputNDJSON); err != nil {
+		t.Error(err)
+	}
+}
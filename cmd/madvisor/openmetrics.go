@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// staleMarkerBits is Prometheus' StaleNaN: a NaN with a fixed payload that
+	// marks a series as ended rather than carrying a value.
+	staleMarkerBits = 0x7ff0000000000002
+	// endedRetention keeps an ended series on screen briefly before the
+	// stale-series GC drops it.
+	endedRetention = time.Minute
+	maxResets      = 32
+)
+
+var staleMarker = math.Float64frombits(staleMarkerBits)
+
+func isStaleMarker(v float64) bool {
+	return math.Float64bits(v) == staleMarkerBits
+}
+
+// createdFamilies are the OpenMetrics types whose <name>_created sample is a
+// creation timestamp rather than a value.
+var createdFamilies = map[string]bool{"counter": true, "histogram": true, "summary": true}
+
+// familyMember reports whether name is a sample of the family base.
+func familyMember(name, base string) bool {
+	if name == base {
+		return true
+	}
+	for _, suffix := range []string{"_total", "_count", "_sum", "_bucket"} {
+		if name == base+suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// sameSeriesLabels compares label sets ignoring le and quantile, which
+// _created samples do not carry.
+func sameSeriesLabels(a, b map[string]string) bool {
+	n := 0
+	for k, v := range a {
+		if k == "le" || k == "quantile" {
+			continue
+		}
+		if b[k] != v {
+			return false
+		}
+		n++
+	}
+	for k := range b {
+		if k != "le" && k != "quantile" {
+			n--
+		}
+	}
+	return n == 0
+}
+
+// observeCreated tracks a family's _created timestamp. When it changes the
+// counter was recreated, so every sample of the family scraped at t starts a
+// new run, even if its value already climbed above the previous one.
+func (st *store) observeCreated(base string, labels map[string]string, created float64, t time.Time) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.paused {
+		return false
+	}
+	if st.created == nil {
+		st.created = map[string]float64{}
+	}
+	key := seriesKey(base, labels)
+	prev, seen := st.created[key]
+	st.created[key] = created
+	if !seen || prev == created {
+		return false
+	}
+	for _, s := range st.series {
+		if familyMember(s.name, base) && sameSeriesLabels(s.labels, labels) {
+			s.resets = append(s.resets, t)
+			if len(s.resets) > maxResets {
+				s.resets = s.resets[len(s.resets)-maxResets:]
+			}
+		}
+	}
+	return true
+}
+
+func resetBetween(resets []time.Time, from, to time.Time) bool {
+	for _, r := range resets {
+		if r.After(from) && !r.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectEnded drops series that were ended by a staleness marker more than
+// endedRetention ago, along with metric names left without series.
+func (st *store) collectEnded(now time.Time) int {
+	return st.dropSeries(func(s *metricSeries) bool {
+		return !s.endedAt.IsZero() && now.Sub(s.endedAt) >= endedRetention
+	})
+}
+
+func createdBase(name, base, mtype string) bool {
+	return createdFamilies[mtype] && name == base+"_created"
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const openMetricsBody = `# HELP requests Requests served.
+# TYPE requests counter
+requests_total{code="200"} 10
+requests_created{code="200"} 1700000000
+# TYPE latency histogram
+latency_bucket{le="1"} 3
+latency_count 3
+latency_sum 1.5
+latency_created 1700000000
+# TYPE temperature gauge
+temperature_created 5
+# EOF
+`
+
+func TestScanExpositionOpenMetrics(t *testing.T) {
+	types := map[string]string{}
+	var created []string
+	_, err := scanExposition(strings.NewReader(openMetricsBody), func(name string, labels map[string]string, help, mtype string, val float64) {
+		types[name] = mtype
+	}, func(base string, labels map[string]string, ts float64) {
+		created = append(created, seriesKey(base, labels))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types["requests_total"] != "counter" {
+		t.Errorf("requests_total type = %q, want counter", types["requests_total"])
+	}
+	if _, ok := types["requests_created"]; ok {
+		t.Error("_created of a counter must not be ingested as a sample")
+	}
+	if _, ok := types["temperature_created"]; !ok {
+		t.Error("_created of a gauge is an ordinary sample")
+	}
+	if strings.Join(created, " ") != "requests{code=200} latency" {
+		t.Errorf("created = %v", created)
+	}
+
+	n := 0
+	parseExposition(strings.NewReader(openMetricsBody), func(name string, labels map[string]string, help, mtype string, val float64) { n++ })
+	if n != 5 {
+		t.Errorf("parseExposition samples = %d, want 5", n)
+	}
+}
+
+func TestCreatedChangeMarksReset(t *testing.T) {
+	st := newStore()
+	labels := map[string]string{"code": "200"}
+	t0 := time.Unix(1000, 0)
+	for i, v := range []float64{100, 110, 120} {
+		st.updateAt("requests_total", labels, "", "counter", v, t0.Add(time.Duration(i)*time.Second))
+	}
+	if st.observeCreated("requests", labels, 1, t0) {
+		t.Error("first _created sighting is not a reset")
+	}
+	if st.observeCreated("requests", labels, 1, t0.Add(time.Second)) {
+		t.Error("unchanged _created is not a reset")
+	}
+
+	// The process restarted and served 130 requests since; without _created the
+	// jump from 120 to 130 looks like ordinary growth.
+	t3 := t0.Add(3 * time.Second)
+	st.updateAt("requests_total", labels, "", "counter", 130, t3)
+	if !st.observeCreated("requests", labels, 2, t3) {
+		t.Fatal("changed _created should mark a reset")
+	}
+	s := st.get(seriesKey("requests_total", labels))
+	if len(s.resets) != 1 || !s.resets[0].Equal(t3) {
+		t.Fatalf("resets = %v", s.resets)
+	}
+	if r := s.rate(time.Minute); r != 0 {
+		t.Errorf("rate across the reset = %v, want 0 (no samples since)", r)
+	}
+	st.updateAt("requests_total", labels, "", "counter", 140, t3.Add(time.Second))
+	if r := s.rate(time.Minute); r != 10 {
+		t.Errorf("rate after reset = %v, want 10", r)
+	}
+}
+
+func TestSameSeriesLabels(t *testing.T) {
+	if !sameSeriesLabels(map[string]string{"le": "1", "job": "a"}, map[string]string{"job": "a"}) {
+		t.Error("le should be ignored")
+	}
+	if sameSeriesLabels(map[string]string{"job": "a"}, map[string]string{"job": "a", "pod": "x"}) {
+		t.Error("extra label should not match")
+	}
+	if !familyMember("latency_bucket", "latency") || familyMember("latency_seconds", "latency") {
+		t.Error("familyMember")
+	}
+}
+
+func TestStaleMarkerEndsSeries(t *testing.T) {
+	if !isStaleMarker(staleMarker) || isStaleMarker(math.NaN()) {
+		t.Fatal("stale marker must be distinguishable from an ordinary NaN")
+	}
+	st := newStore()
+	t0 := time.Unix(1000, 0)
+	st.updateAt("jobs", map[string]string{"job": "a"}, "", "gauge", 1, t0)
+	st.updateAt("jobs", map[string]string{"job": "b"}, "", "gauge", 2, t0)
+	st.updateAt("other", nil, "", "gauge", 3, t0)
+
+	st.updateAt("jobs", map[string]string{"job": "a"}, "", "", staleMarker, t0.Add(time.Second))
+	st.updateAt("other", nil, "", "", staleMarker, t0.Add(time.Second))
+	st.updateAt("missing", nil, "", "", staleMarker, t0)
+	a := st.get(seriesKey("jobs", map[string]string{"job": "a"}))
+	if a.count() != 1 || a.endedAt.IsZero() || a.last() != 1 {
+		t.Fatalf("marker must end the series without adding a sample: count=%d last=%v", a.count(), a.last())
+	}
+	if st.get("missing") != nil {
+		t.Error("a marker for an unknown series must not create it")
+	}
+
+	if n := st.collectEnded(t0.Add(30 * time.Second)); n != 0 {
+		t.Errorf("collected %d series before endedRetention", n)
+	}
+	if n := st.collectEnded(t0.Add(time.Second + endedRetention)); n != 2 {
+		t.Fatalf("collected %d, want 2", n)
+	}
+	if got := strings.Join(st.names(), ","); got != "jobs" {
+		t.Errorf("names = %s", got)
+	}
+	if len(st.seriesForName("jobs")) != 1 {
+		t.Error("job=b should survive")
+	}
+
+	st.updateAt("jobs", map[string]string{"job": "b"}, "", "", staleMarker, t0)
+	st.updateAt("jobs", map[string]string{"job": "b"}, "", "gauge", 4, t0.Add(time.Second))
+	if n := st.collectEnded(t0.Add(time.Hour)); n != 0 {
+		t.Error("a new sample after the marker revives the series")
+	}
+}
+
+func TestScanExpositionExemplars(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "exporters", "madvisor_dummy.prom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each counter line of the dummy's -openmetrics output carries an exemplar
+	// and its timestamp after the value: x_total{..} 12.0000 # {trace_id=".."} 1.0000 1700000000.123
+	want := map[string]float64{}
+	for _, line := range strings.Split(string(body), "\n") {
+		series, ex, ok := strings.Cut(line, " # {")
+		if !ok || !strings.HasPrefix(line, "http_requests_total{") {
+			continue
+		}
+		i := strings.LastIndexByte(series, ' ')
+		v, err := strconv.ParseFloat(series[i+1:], 64)
+		if err != nil || ex == "" {
+			t.Fatalf("bad fixture line %q", line)
+		}
+		want[series[:i]] = v
+	}
+	if len(want) != 16 {
+		t.Fatalf("fixture has %d counter lines with exemplars, want 16", len(want))
+	}
+
+	got := map[string]float64{}
+	_, err = scanExposition(strings.NewReader(string(body)), func(name string, labels map[string]string, help, mtype string, val float64) {
+		if name == "http_requests_total" {
+			got[seriesKey(name, labels)] = val
+		}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for series, v := range want {
+		name, labels := parseLabels(series)
+		if g := got[seriesKey(name, labels)]; g != v {
+			t.Errorf("%s = %v, want %v", series, g, v)
+		}
+	}
+}
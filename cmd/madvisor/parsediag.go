@@ -0,0 +1,45 @@
+package main
+
+import "fmt"
+
+const maxParseExamples = 3
+
+// parseStats describes one pass over an exposition body: how many lines were
+// read and which ones had to be skipped, with a few examples.
+type parseStats struct {
+	lines    int
+	skipped  int
+	examples []string
+}
+
+func (p *parseStats) skip(lineNo int, format string, args ...any) {
+	p.skipped++
+	if len(p.examples) < maxParseExamples {
+		p.examples = append(p.examples, fmt.Sprintf("line %d: ", lineNo)+fmt.Sprintf(format, args...))
+	}
+}
+
+func (st *store) recordParseStats(addr string, ps parseStats) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.health == nil {
+		st.health = map[string]*targetHealth{}
+	}
+	h := st.health[addr]
+	if h == nil {
+		h = &targetHealth{}
+		st.health[addr] = h
+	}
+	h.parse = ps
+}
+
+func (h targetHealth) parseSummary() string {
+	if h.parse.skipped == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("⚠ %d of %d line(s) skipped in the last scrape", h.parse.skipped, h.parse.lines)
+	if len(h.parse.examples) > 0 {
+		s += ", e.g. " + h.parse.examples[0]
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanExpositionDiagnostics(t *testing.T) {
+	body := "# TYPE ok gauge\nok 1\nnovalue\nbad{a=\"b\"} abc\nok2 2\n\nworse x\nworst y\n"
+	n := 0
+	ps, err := scanExposition(strings.NewReader(body), func(name string, labels map[string]string, help, mtype string, val float64) { n++ }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || ps.lines != 8 || ps.skipped != 4 {
+		t.Errorf("samples=%d stats=%+v", n, ps)
+	}
+	want := []string{`line 3: no value in "novalue"`, `line 4: invalid value "abc"`, `line 7: invalid value "x"`}
+	if strings.Join(ps.examples, "|") != strings.Join(want, "|") {
+		t.Errorf("examples = %q", ps.examples)
+	}
+}
+
+func TestScrapeTargetRecordsDiagnostics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "up 1\nbroken NaNish\n")
+	}))
+	defer srv.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "only garbage here\n")
+	}))
+	defer bad.Close()
+
+	st := newStore()
+	client := &http.Client{Timeout: time.Second}
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	badAddr := strings.TrimPrefix(bad.URL, "http://")
+	scrapeTarget(client, target{addr: addr}, st)
+	scrapeTarget(client, target{addr: badAddr}, st)
+
+	h := st.targetHealth(addr)
+	if h.ok != 1 || !strings.Contains(h.parseSummary(), `1 of 2 line(s) skipped`) || !strings.Contains(h.parseSummary(), `line 2: invalid value "NaNish"`) {
+		t.Errorf("summary = %q (health %+v)", h.parseSummary(), h)
+	}
+	if h := st.targetHealth(badAddr); h.failed != 1 || !strings.Contains(h.lastErr, `line 1: invalid value "garbage"`) {
+		t.Errorf("all-garbage target error = %q", h.lastErr)
+	}
+	if (targetHealth{}).parseSummary() != "" {
+		t.Error("clean scrape should have no summary")
+	}
+}
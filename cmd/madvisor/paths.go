@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// debugLogPath is where panics and render diagnostics are logged: the
+// system temp directory, /tmp on Linux and %TEMP% on Windows.
+var debugLogPath = filepath.Join(os.TempDir(), "madvisor-debug.log")
+
+// configDir is madvisor's per-user configuration directory:
+// ~/.config/madvisor on Linux, ~/Library/Application Support/madvisor on
+// macOS and %AppData%\madvisor on Windows.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "madvisor"), nil
+}
+
+// defaultPatternsFile is the patterns file used without --patterns:
+// patterns.yaml in the configuration directory, when there is one.
+func defaultPatternsFile() string {
+	dir, err := configDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(dir, "patterns.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPatternsFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("HOME", home)
+	t.Setenv("AppData", filepath.Join(home, "AppData"))
+	if got := defaultPatternsFile(); got != "" {
+		t.Errorf("defaultPatternsFile without a file = %q, want none", got)
+	}
+
+	base, err := os.UserConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(base, "madvisor", "patterns.yaml")
+	if err := os.MkdirAll(filepath.Dir(want), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(want, []byte("patterns: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := defaultPatternsFile(); got != want {
+		t.Errorf("defaultPatternsFile = %q, want %q", got, want)
+	}
+}
+
+func TestDebugLogPath(t *testing.T) {
+	if !strings.HasPrefix(debugLogPath, os.TempDir()) || filepath.Base(debugLogPath) != "madvisor-debug.log" {
+		t.Errorf("debugLogPath = %q, want madvisor-debug.log in %s", debugLogPath, os.TempDir())
+	}
+}
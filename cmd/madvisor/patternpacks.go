@@ -0,0 +1,94 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//go:embed packs/*.yaml
+var patternPacksFS embed.FS
+
+func patternPackNames() []string {
+	entries, _ := patternPacksFS.ReadDir("packs")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func patternPack(name string) ([]byte, error) {
+	data, err := patternPacksFS.ReadFile("packs/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown pattern pack %q (available: %s)", name, strings.Join(patternPackNames(), ", "))
+	}
+	return data, nil
+}
+
+// isPackRef tells built-in pack names apart from include paths.
+func isPackRef(inc string) bool {
+	ext := filepath.Ext(inc)
+	return !strings.ContainsRune(inc, filepath.Separator) && !strings.ContainsRune(inc, '/') && ext != ".yaml" && ext != ".yml"
+}
+
+// resolveIncludes merges cfg's includes in order, then cfg itself on top.
+// Relative paths are resolved against dir; stack holds the files being
+// loaded so include cycles are reported instead of recursing forever.
+func resolveIncludes(cfg *UnitsConfig, dir string, stack []string) (*UnitsConfig, error) {
+	if len(cfg.Include) == 0 {
+		return cfg, nil
+	}
+	merged := &UnitsConfig{}
+	for _, inc := range cfg.Include {
+		var sub *UnitsConfig
+		var err error
+		if isPackRef(inc) {
+			var data []byte
+			if data, err = patternPack(inc); err != nil {
+				return nil, err
+			}
+			if sub, err = loadUnitsConfig(data); err != nil {
+				return nil, fmt.Errorf("pack %q: %w", inc, err)
+			}
+			sub, err = resolveIncludes(sub, dir, stack)
+		} else {
+			if !filepath.IsAbs(inc) && dir != "" {
+				inc = filepath.Join(dir, inc)
+			}
+			sub, err = loadUnitsFileStack(inc, stack)
+		}
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeUnits(merged, sub)
+	}
+	own := *cfg
+	own.Include = nil
+	return mergeUnits(merged, &own), nil
+}
+
+func loadUnitsFileStack(path string, stack []string) (*UnitsConfig, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read units file %q: %w", path, err)
+	}
+	cfg, err := loadUnitsConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return resolveIncludes(cfg, filepath.Dir(path), append(stack[:len(stack):len(stack)], abs))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeYAML(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPatternPacksCompile(t *testing.T) {
+	if got := patternPackNames(); !reflect.DeepEqual(got, []string{"nginx", "node-exporter", "pii", "postgres"}) {
+		t.Errorf("patternPackNames() = %v", got)
+	}
+	for _, name := range patternPackNames() {
+		data, err := patternPack(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadUnitsConfig(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := compileUnits(cfg); err != nil {
+			t.Errorf("%s units: %v", name, err)
+		}
+		if _, err := compilePresets(cfg.Presets); err != nil {
+			t.Errorf("%s presets: %v", name, err)
+		}
+		if _, err := compileDisplay(cfg.Display); err != nil {
+			t.Errorf("%s display: %v", name, err)
+		}
+		if _, err := compileRedact(cfg.Redact, testRedactKey); err != nil {
+			t.Errorf("%s redact: %v", name, err)
+		}
+	}
+	if _, err := patternPack("mysql"); err == nil || !strings.Contains(err.Error(), "nginx, node-exporter, pii, postgres") {
+		t.Errorf("unknown pack error = %v", err)
+	}
+}
+
+func TestIncludeMergesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeYAML(t, dir, "team.yaml", "units:\n  - unit: ops\n    suffix: \" [team]\"\n    matchers: [\"_ops$\"]\ndisplay:\n  - metric: \"^nginx_http_requests_total$\"\n    name: team requests\n")
+	path := writeYAML(t, dir, "me.yaml", "include: [nginx, team.yaml]\nunits:\n  - unit: ops\n    suffix: \" [mine]\"\n    matchers: [\"_ops$\"]\n")
+
+	cfg, err := loadUnitsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Units) != 1 || cfg.Units[0].Suffix != " [mine]" {
+		t.Errorf("units = %+v, want own file to win", cfg.Units)
+	}
+	if len(cfg.Display) == 0 || cfg.Display[0].Name != "team requests" {
+		t.Errorf("display = %+v, want team.yaml rule before the nginx pack's", cfg.Display)
+	}
+	if len(cfg.Presets) != 1 || cfg.Presets[0].Name != "nginx" {
+		t.Errorf("presets = %+v, want nginx from the pack", cfg.Presets)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeYAML(t, dir, "a.yaml", "include: [b.yaml]\n")
+	writeYAML(t, dir, "b.yaml", "include: [a.yaml]\n")
+	if _, err := loadUnitsFile(filepath.Join(dir, "a.yaml")); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("err = %v, want include cycle", err)
+	}
+}
+
+func TestIncludeMissingFile(t *testing.T) {
+	path := writeYAML(t, t.TempDir(), "p.yaml", "include: [missing.yaml]\n")
+	if _, err := loadUnitsFile(path); err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestIsPackRef(t *testing.T) {
+	for inc, want := range map[string]bool{"nginx": true, "node-exporter": true, "team.yaml": false, "conf/base": false, "x.yml": false} {
+		if got := isPackRef(inc); got != want {
+			t.Errorf("isPackRef(%q) = %v, want %v", inc, got, want)
+		}
+	}
+}
+
+func TestCLIPatternsPacks(t *testing.T) {
+	out, err := executeCmd(t, "patterns", "packs")
+	if err != nil || !strings.Contains(out, "postgres\n") {
+		t.Errorf("patterns packs = %q, %v", out, err)
+	}
+	out, err = executeCmd(t, "patterns", "packs", "nginx")
+	if err != nil || !strings.Contains(out, "name: nginx") {
+		t.Errorf("patterns packs nginx = %q, %v", out, err)
+	}
+}
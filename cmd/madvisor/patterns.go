@@ -1,9 +1,9 @@
 package main
 
 import (
+	"cmp"
 	"embed"
 	"fmt"
-	"os"
 	"regexp"
 	"sync"
 
@@ -20,7 +20,18 @@ type UnitEntry struct {
 }
 
 type UnitsConfig struct {
-	Units []UnitEntry `yaml:"units"`
+	Include        []string          `yaml:"include"`
+	Units          []UnitEntry       `yaml:"units"`
+	RelabelConfigs []RelabelConfig   `yaml:"relabel_configs"`
+	HonorLabels    bool              `yaml:"honor_labels"`
+	Presets        []Preset          `yaml:"presets"`
+	Thresholds     []ThresholdConfig `yaml:"thresholds"`
+	Display        []DisplayRule     `yaml:"display"`
+	SLOs           []SLOConfig       `yaml:"slos"`
+	Backlogs       []BacklogRule     `yaml:"backlogs"`
+	Actions        []ActionConfig    `yaml:"actions"`
+	Redact         []RedactRule      `yaml:"redact"`
+	RedactKey      string            `yaml:"redact_key"`
 }
 
 type compiledUnit struct {
@@ -35,8 +46,11 @@ type UnitMatcher struct {
 }
 
 type UnitMatch struct {
-	Unit   string
-	Suffix string
+	Unit     string
+	Suffix   string
+	Pattern  string
+	Declared string
+	Override bool
 }
 
 func loadUnitsConfig(data []byte) (*UnitsConfig, error) {
@@ -56,11 +70,7 @@ func loadDefaultUnits() (*UnitsConfig, error) {
 }
 
 func loadUnitsFile(path string) (*UnitsConfig, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("read units file %q: %w", path, err)
-	}
-	return loadUnitsConfig(data)
+	return loadUnitsFileStack(path, nil)
 }
 
 func mergeUnits(base, override *UnitsConfig) *UnitsConfig {
@@ -68,7 +78,18 @@ func mergeUnits(base, override *UnitsConfig) *UnitsConfig {
 		return base
 	}
 
-	merged := &UnitsConfig{}
+	merged := &UnitsConfig{
+		RelabelConfigs: append(append([]RelabelConfig{}, base.RelabelConfigs...), override.RelabelConfigs...),
+		HonorLabels:    base.HonorLabels || override.HonorLabels,
+		Presets:        mergePresets(base.Presets, override.Presets),
+		Thresholds:     append(append([]ThresholdConfig{}, base.Thresholds...), override.Thresholds...),
+		Display:        append(append([]DisplayRule{}, override.Display...), base.Display...),
+		SLOs:           append(append([]SLOConfig{}, override.SLOs...), base.SLOs...),
+		Backlogs:       append(append([]BacklogRule{}, base.Backlogs...), override.Backlogs...),
+		Actions:        append(append([]ActionConfig{}, base.Actions...), override.Actions...),
+		Redact:         append(append([]RedactRule{}, base.Redact...), override.Redact...),
+		RedactKey:      cmp.Or(override.RedactKey, base.RedactKey),
+	}
 	seen := make(map[string]bool)
 
 	for _, u := range override.Units {
@@ -109,7 +130,7 @@ func (um *UnitMatcher) Match(name string) *UnitMatch {
 	defer um.mu.RUnlock()
 	for _, cu := range um.units {
 		if cu.re.MatchString(name) {
-			return &UnitMatch{Unit: cu.unit, Suffix: cu.suffix}
+			return &UnitMatch{Unit: cu.unit, Suffix: cu.suffix, Pattern: cu.re.String()}
 		}
 	}
 	return nil
@@ -136,6 +157,52 @@ func initPatterns(userFile string) error {
 	if err != nil {
 		return err
 	}
+	rules, err := compileRelabel(merged.RelabelConfigs)
+	if err != nil {
+		return err
+	}
+	presets, err := compilePresets(merged.Presets)
+	if err != nil {
+		return err
+	}
+	thresholds, err := compileThresholds(merged.Thresholds)
+	if err != nil {
+		return err
+	}
+	display, err := compileDisplay(merged.Display)
+	if err != nil {
+		return err
+	}
+	slos, err := compileSLOs(merged.SLOs)
+	if err != nil {
+		return err
+	}
+	backlogs, err := compileBacklogs(merged.Backlogs)
+	if err != nil {
+		return err
+	}
+	actions, err := compileActions(merged.Actions)
+	if err != nil {
+		return err
+	}
+	dir, _ := configDir()
+	key, err := loadRedactKey(merged.RedactKey, merged.Redact, dir)
+	if err != nil {
+		return err
+	}
+	redact, err := compileRedact(merged.Redact, key)
+	if err != nil {
+		return err
+	}
 	globalUnitMatcher = um
+	globalPresets = presets
+	globalThresholds = thresholds
+	globalDisplay = display
+	globalSLOs = slos
+	globalBacklogs = backlogs
+	globalActions = actions
+	globalRedact = redact
+	globalRelabel = rules
+	globalHonorLabels = merged.HonorLabels
 	return nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import "slices"
+
+const pinnedSection = "Pinned"
+
+// togglePinned pins name to the top of the metric list, or unpins it.
+func (u *uiState) togglePinned(name string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if i := slices.Index(u.pinned, name); i >= 0 {
+		u.pinned = slices.Delete(u.pinned, i, i+1)
+		return false
+	}
+	u.pinned = append(u.pinned, name)
+	return true
+}
+
+func (u *uiState) clearPinned() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.pinned = nil
+}
+
+func (u *uiState) pinnedNames() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Clone(u.pinned)
+}
+
+// pinFirst moves the pinned metrics that are listed to the front, in the
+// order they were pinned, under their own section header; without presets
+// the rest of the list goes under Other.
+func pinFirst(names []string, sections map[string]string, pinned []string) ([]string, map[string]string) {
+	var front []string
+	for _, p := range pinned {
+		if slices.Contains(names, p) {
+			front = append(front, p)
+		}
+	}
+	if len(front) == 0 {
+		return names, sections
+	}
+	out := append(make([]string, 0, len(names)), front...)
+	for _, n := range names {
+		if !slices.Contains(front, n) {
+			out = append(out, n)
+		}
+	}
+	if sections == nil {
+		sections = make(map[string]string, len(names))
+		for _, n := range names {
+			sections[n] = otherSection
+		}
+	}
+	for _, n := range front {
+		sections[n] = pinnedSection
+	}
+	return out, sections
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPinFirst(t *testing.T) {
+	names := []string{"cpu", "disk", "mem", "net"}
+	got, sections := pinFirst(names, nil, []string{"net", "gone", "disk"})
+	if strings.Join(got, ",") != "net,disk,cpu,mem" {
+		t.Errorf("pinFirst = %v, want pins first in pin order", got)
+	}
+	if sections["net"] != pinnedSection || sections["disk"] != pinnedSection || sections["cpu"] != otherSection {
+		t.Errorf("sections = %v", sections)
+	}
+
+	presetSections := map[string]string{"cpu": "node · CPU", "mem": "node · Memory"}
+	_, sections = pinFirst([]string{"cpu", "mem"}, presetSections, []string{"mem"})
+	if sections["mem"] != pinnedSection || sections["cpu"] != "node · CPU" {
+		t.Errorf("preset sections = %v, want pinned metrics moved out of their panel", sections)
+	}
+
+	if got, sections := pinFirst(names, nil, nil); strings.Join(got, ",") != "cpu,disk,mem,net" || sections != nil {
+		t.Errorf("no pins should leave the list alone, got %v %v", got, sections)
+	}
+}
+
+func TestTogglePinned(t *testing.T) {
+	ui := &uiState{}
+	if !ui.togglePinned("cpu") || !ui.togglePinned("mem") {
+		t.Fatal("first toggle should pin")
+	}
+	if ui.togglePinned("cpu") {
+		t.Error("second toggle should unpin")
+	}
+	if got := strings.Join(ui.pinnedNames(), ","); got != "mem" {
+		t.Errorf("pinned = %s, want mem", got)
+	}
+	ui.clearPinned()
+	if len(ui.pinnedNames()) != 0 {
+		t.Error("clearPinned left pins")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	plainInterval  = 5 * time.Second
+	trendSamples   = 10
+	trendThreshold = 0.02
+)
+
+func trendOf(data []float64) string {
+	var pts []float64
+	for _, v := range data {
+		if !math.IsNaN(v) {
+			pts = append(pts, v)
+		}
+	}
+	if len(pts) > trendSamples {
+		pts = pts[len(pts)-trendSamples:]
+	}
+	if len(pts) < 2 {
+		return "unknown"
+	}
+	first, last := pts[0], pts[len(pts)-1]
+	scale := math.Max(math.Abs(first), math.Abs(last))
+	if scale == 0 {
+		return "flat"
+	}
+	change := (last - first) / scale
+	switch {
+	case change > trendThreshold:
+		return "rising"
+	case change < -trendThreshold:
+		return "falling"
+	default:
+		return "flat"
+	}
+}
+
+func writeASCIITable(w io.Writer, header []string, rows [][]string) {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for _, r := range rows {
+		for i, c := range r {
+			if len(c) > widths[i] {
+				widths[i] = len(c)
+			}
+		}
+	}
+	line := func(cells []string) {
+		padded := make([]string, len(cells))
+		for i, c := range cells {
+			padded[i] = c + strings.Repeat(" ", widths[i]-len(c))
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(padded, " | "), " "))
+	}
+	line(header)
+	seps := make([]string, len(widths))
+	for i, n := range widths {
+		seps[i] = strings.Repeat("-", n)
+	}
+	fmt.Fprintln(w, strings.Join(seps, "-+-"))
+	for _, r := range rows {
+		line(r)
+	}
+}
+
+func renderPlain(w io.Writer, st *store, targets []target, now time.Time) {
+	all := st.snapshot()
+	fmt.Fprintf(w, "madVisor %s at %s, targets: %s, rate window: %s, series: %d\n",
+		version, now.Format("15:04:05"), formatTargets(targets), rateWindowGet(), len(all))
+
+	rows := make([][]string, 0, len(all))
+	for _, s := range all {
+		data, _ := chartData(s)
+		labels := seriesLabelText(s)
+		rows = append(rows, []string{
+			s.name,
+			strings.TrimSuffix(strings.TrimPrefix(labels, "{"), "}"),
+			s.detectedType(),
+			seriesValueText(s),
+			trendOf(data),
+		})
+	}
+	writeASCIITable(w, []string{"METRIC", "LABELS", "TYPE", "VALUE", "TREND"}, rows)
+	fmt.Fprintln(w)
+}
+
+func runPlain(ctx context.Context, targets []target, feed func(context.Context, *store), w io.Writer) error {
+	st := newStore()
+	st.onCrash = func(c crash) { log.Printf("madvisor: %s", c) }
+	go feed(ctx, st)
+
+	fmt.Fprintf(w, "madVisor %s plain mode, connecting to %s\n", version, formatTargets(targets))
+
+	started := time.Now()
+	ticker := time.NewTicker(plainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			if !st.hasData() {
+				if connectDeadlinePassed(now.Sub(started)) {
+					return notReadyError(st, st.activeTargets(targets))
+				}
+				fmt.Fprintln(w, "waiting for metrics")
+				writeProbeStatus(w, st, st.activeTargets(targets))
+				continue
+			}
+			renderPlain(w, st, targets, now)
+		}
+	}
+}
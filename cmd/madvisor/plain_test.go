@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrendOf(t *testing.T) {
+	tests := []struct {
+		name string
+		data []float64
+		want string
+	}{
+		{"rising", []float64{1, 2, 3, 4}, "rising"},
+		{"falling", []float64{4, 3, 2, 1}, "falling"},
+		{"flat", []float64{100, 100.5, 100.2, 100.1}, "flat"},
+		{"zeros", []float64{0, 0, 0}, "flat"},
+		{"too few", []float64{1}, "unknown"},
+		{"skips NaN", []float64{1, math.NaN(), 5}, "rising"},
+		{"only recent samples", []float64{100, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, "flat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trendOf(tt.data); got != tt.want {
+				t.Errorf("trendOf(%v) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteASCIITable(t *testing.T) {
+	var b bytes.Buffer
+	writeASCIITable(&b, []string{"A", "BB"}, [][]string{{"xyz", "1"}})
+
+	want := "A   | BB\n----+---\nxyz | 1\n"
+	if got := b.String(); got != want {
+		t.Errorf("table =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderPlainIsASCII(t *testing.T) {
+	st := newStore()
+	st.update("cpu_usage_percent", map[string]string{"env": "prod"}, "", "gauge", 42)
+	st.update("cpu_usage_percent", map[string]string{"env": "prod"}, "", "gauge", 50)
+
+	var b bytes.Buffer
+	renderPlain(&b, st, parseTargets("a:1"), time.Now())
+
+	out := b.String()
+	for _, r := range out {
+		if r > 0x7e && r != '\n' {
+			t.Fatalf("plain output contains non-ASCII rune %q:\n%s", r, out)
+		}
+	}
+	for _, want := range []string{"METRIC", "cpu_usage_percent", `env="prod"`, "gauge", "rising"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plain output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunPlainStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "up 1\n")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var b bytes.Buffer
+	targets := parseTargets(strings.TrimPrefix(srv.URL, "http://"))
+	feed := func(ctx context.Context, st *store) { scrape(ctx, targets, st) }
+	if err := runPlain(ctx, targets, feed, &b); err != nil {
+		t.Fatalf("runPlain: %v", err)
+	}
+	if !strings.Contains(b.String(), "plain mode") {
+		t.Errorf("output = %q, want a plain mode banner", b.String())
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ttyAttachable reports whether a terminal can be attached to an already
+// running process, as kubectl attach does for a container.
+const ttyAttachable = true
+
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// quoteArg quotes one argument of the command line printed by the split
+// view for a POSIX shell.
+var quoteArg = shellQuote
+
+func connRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// A console cannot be attached to a running process on Windows, so without
+// one at startup there is nothing to wait for.
+const ttyAttachable = false
+
+// Windows only delivers Ctrl+C (os.Interrupt) to console programs.
+var shutdownSignals = []os.Signal{os.Interrupt}
+
+var quoteArg = syscall.EscapeArg
+
+// wsaeConnRefused is WSAECONNREFUSED, which Windows reports instead of
+// ECONNREFUSED.
+const wsaeConnRefused syscall.Errno = 10061
+
+func connRefused(err error) bool {
+	return errors.Is(err, wsaeConnRefused) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", line)
+}
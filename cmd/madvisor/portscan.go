@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultScanPorts = "9090,9100,8080,2112,15090"
+
+	// portScanTimeout bounds each connection attempt and request of a scan,
+	// so probing every candidate stays well under a few seconds per port.
+	portScanTimeout = time.Second
+)
+
+var (
+	flagPortScan string
+	scanPorts    []int
+)
+
+// scanPaths are the metrics paths tried on every open candidate port: the
+// Prometheus default, Envoy/Istio, Spring Boot Actuator and a common prefix.
+var scanPaths = []string{defaultMetricsPath, "/stats/prometheus", "/actuator/prometheus", "/prometheus/metrics"}
+
+// parseScanPorts parses the --port-scan list of TCP ports.
+func parseScanPorts(s string) ([]int, error) {
+	var ports []int
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		p, err := strconv.Atoi(f)
+		if err != nil || p < 1 || p > 65535 {
+			return nil, fmt.Errorf("invalid port %q", f)
+		}
+		ports = append(ports, p)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports in %q", s)
+	}
+	return ports, nil
+}
+
+// startPortScan reports whether a scan of addr's host should start now: there
+// are ports to scan, the scrape was refused and addr has not been scanned before.
+func (st *store) startPortScan(addr string, err error, ports []int) bool {
+	if len(ports) == 0 || err == nil || probeState(err) != probeRefused {
+		return false
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	h := st.health[addr]
+	if h == nil || h.scan != "" {
+		return false
+	}
+	h.scan = "scanning ports " + joinPorts(ports) + "…"
+	return true
+}
+
+func (st *store) finishPortScan(addr string, found []target, ports []int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	h := st.health[addr]
+	if h == nil {
+		return
+	}
+	h.scan = portScanSummary(found, ports)
+}
+
+func portScanSummary(found []target, ports []int) string {
+	if len(found) == 0 {
+		return "no metrics on ports " + joinPorts(ports)
+	}
+	specs := make([]string, len(found))
+	for i, t := range found {
+		specs[i] = t.spec()
+	}
+	return "metrics answer at " + strings.Join(specs, ", ") + " (:target add " + specs[0] + ")"
+}
+
+func joinPorts(ports []int) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ",")
+}
+
+// scanMetricsPorts probes ports on tgt's host and returns the
+// endpoints serving a Prometheus exposition, at most one per port.
+func scanMetricsPorts(client *http.Client, tgt target, ports []int) []target {
+	host, _, err := net.SplitHostPort(tgt.addr)
+	if err != nil {
+		return nil
+	}
+	probe := &http.Client{Transport: client.Transport, Timeout: portScanTimeout}
+	var found []target
+	for _, port := range ports {
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		if addr == tgt.addr {
+			continue
+		}
+		conn, err := net.DialTimeout("tcp", addr, portScanTimeout)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		for _, path := range scanPaths {
+			cand := target{addr: addr, path: path}
+			if tgt.scheme == "https" {
+				cand.scheme = tgt.scheme
+			}
+			if path == defaultMetricsPath {
+				cand.path = ""
+			}
+			if servesMetrics(probe, cand) {
+				found = append(found, cand)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// servesMetrics reports whether tgt answers with at least one sample.
+func servesMetrics(client *http.Client, tgt target) bool {
+	req, err := newScrapeRequest(tgt)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return false
+	}
+	samples := 0
+	scanExposition(io.LimitReader(resp.Body, 1<<20), func(string, map[string]string, string, string, float64) {
+		samples++
+	}, nil)
+	return samples > 0
+}
+
+// scanRefused looks for metrics on other ports of a target whose scrape was
+// refused, when --port-scan is on, once per target, and records what it found in its health.
+func scanRefused(client *http.Client, tgt target, st *store, err error, ports []int) {
+	if !st.startPortScan(tgt.key(), err, ports) {
+		return
+	}
+	go func() { st.finishPortScan(tgt.key(), scanMetricsPorts(client, tgt, ports), ports) }()
+}
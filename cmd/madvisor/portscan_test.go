@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseScanPorts(t *testing.T) {
+	got, err := parseScanPorts(defaultScanPorts)
+	if err != nil || !reflect.DeepEqual(got, []int{9090, 9100, 8080, 2112, 15090}) {
+		t.Errorf("parseScanPorts(default) = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "http", "0", "70000"} {
+		if _, err := parseScanPorts(bad); err == nil {
+			t.Errorf("parseScanPorts(%q) accepted", bad)
+		}
+	}
+}
+
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestScanMetricsPorts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stats/prometheus" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "envoy_server_live 1")
+	}))
+	defer srv.Close()
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "<html>hello</html>")
+	}))
+	defer empty.Close()
+
+	port := func(s *httptest.Server) int {
+		_, p, _ := net.SplitHostPort(s.Listener.Addr().String())
+		n, _ := strconv.Atoi(p)
+		return n
+	}
+	ports := []int{port(empty), port(srv)}
+
+	tgt := target{addr: closedPort(t)}
+	got := scanMetricsPorts(srv.Client(), tgt, ports)
+	want := []target{{addr: srv.Listener.Addr().String(), path: "/stats/prometheus"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scanMetricsPorts = %+v, want %+v", got, want)
+	}
+}
+
+func TestScanRefusedOnce(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "up 1")
+	}))
+	defer srv.Close()
+	_, p, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(p)
+	ports := []int{n}
+
+	st := newStore()
+	tgt := target{addr: closedPort(t)}
+	_, refused := http.Get("http://" + tgt.addr)
+	st.scrapeDone(tgt, refused, time.Now())
+	if st.startPortScan(tgt.addr, errors.New("timeout"), ports) {
+		t.Error("scan started for an error other than connection refused")
+	}
+	scanRefused(srv.Client(), tgt, st, refused, ports)
+	if st.startPortScan(tgt.addr, refused, ports) {
+		t.Error("second scan started for the same target")
+	}
+	want := "metrics answer at " + srv.Listener.Addr().String() + " (:target add " + srv.Listener.Addr().String() + ")"
+	deadline := time.Now().Add(5 * time.Second)
+	for st.targetHealth(tgt.addr).scan != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("scan = %q, want %q", st.targetHealth(tgt.addr).scan, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
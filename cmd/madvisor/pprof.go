@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// flagPprof is the hidden --pprof address serving net/http/pprof for
+// madvisor itself; pprofAddr is where it ended up listening.
+var (
+	flagPprof string
+	pprofAddr string
+)
+
+const runtimeSampleInterval = 5 * time.Second
+
+// pprofListenAddr binds a bare :port to loopback; the profiles expose
+// madvisor's memory, so listening on every interface must be asked for.
+func pprofListenAddr(addr string) string {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
+
+func servePprof(ctx context.Context, ln net.Listener) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (st *store) seriesTotal() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.series)
+}
+
+// runtimeStats is the latest sample taken by sampleRuntime; ReadMemStats
+// stops the world, so it is not called on every render.
+var runtimeStats atomic.Pointer[runtime.MemStats]
+
+func sampleRuntime(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		runtimeStats.Store(&m)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runtimeSummary is the targets panel's debug line about madvisor itself,
+// shown while profiling is on.
+func runtimeSummary(st *store) string {
+	var m runtime.MemStats
+	if s := runtimeStats.Load(); s != nil {
+		m = *s
+	}
+	return fmt.Sprintf("madvisor: heap %s of %s from the OS, %d goroutines, %d GCs, %d series, pprof on http://%s/debug/pprof/",
+		formatBytes(float64(m.HeapInuse)), formatBytes(float64(m.Sys)), runtime.NumGoroutine(), m.NumGC, st.seriesTotal(), pprofAddr)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServePprof(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- servePprof(ctx, ln) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/debug/pprof/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine") {
+		t.Errorf("GET /debug/pprof/ = %d, want the profile index", resp.StatusCode)
+	}
+	if resp, err := http.Get("http://" + ln.Addr().String() + "/debug/pprof/cmdline"); err != nil || resp.StatusCode == http.StatusOK {
+		t.Errorf("GET /debug/pprof/cmdline = %v, %v; want the command line not served", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("servePprof: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("servePprof did not stop")
+	}
+}
+
+func TestRuntimeSummary(t *testing.T) {
+	defer func(old string) { pprofAddr = old }(pprofAddr)
+	pprofAddr = "127.0.0.1:6060"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sampleRuntime(ctx, time.Hour)
+	st := newStore()
+	st.update("a", nil, "", "gauge", 1)
+	st.update("b", nil, "", "gauge", 1)
+	got := runtimeSummary(st)
+	for _, want := range []string{"heap ", " goroutines", "2 series", "http://127.0.0.1:6060/debug/pprof/"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("runtimeSummary = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestPprofListenAddr(t *testing.T) {
+	for in, want := range map[string]string{
+		":6060":          "127.0.0.1:6060",
+		"0.0.0.0:6060":   "0.0.0.0:6060",
+		"localhost:6060": "localhost:6060",
+		"[::1]:6060":     "[::1]:6060",
+	} {
+		if got := pprofListenAddr(in); got != want {
+			t.Errorf("pprofListenAddr(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPprofFlagHidden(t *testing.T) {
+	f := newRootCmd().Flags().Lookup("pprof")
+	if f == nil || !f.Hidden {
+		t.Errorf("--pprof flag = %+v, want a hidden flag", f)
+	}
+}
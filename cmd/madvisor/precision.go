@@ -0,0 +1,16 @@
+package main
+
+import "strconv"
+
+// formatRaw prints a raw sample value using --precision significant digits,
+// or the shortest exact representation when the precision is negative.
+func formatRaw(v float64) string {
+	if flagPrecision < 0 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	p := flagPrecision
+	if p == 0 {
+		p = 1
+	}
+	return strconv.FormatFloat(v, 'g', p, 64)
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestFormatRaw(t *testing.T) {
+	defer func(p int) { flagPrecision = p }(flagPrecision)
+	a, b := 0.1, 0.2
+	cases := []struct {
+		prec int
+		v    float64
+		want string
+	}{
+		{-1, a + b, "0.30000000000000004"},
+		{-1, 1234567890, "1234567890"},
+		{6, a + b, "0.3"},
+		{6, 1234567890, "1.23457e+09"},
+		{3, 42.4242, "42.4"},
+		{0, 42.4242, "4e+01"},
+	}
+	for _, c := range cases {
+		flagPrecision = c.prec
+		if got := formatRaw(c.v); got != c.want {
+			t.Errorf("formatRaw(%v) at precision %d = %q, want %q", c.v, c.prec, got, c.want)
+		}
+	}
+}
+
+func TestSeriesValueText(t *testing.T) {
+	defer func(p int) { flagPrecision = p }(flagPrecision)
+	flagPrecision = 4
+	s := newTestSeries("requests_in_flight", nil)
+	s.push(1234.5678)
+	if got := seriesValueText(s); got != "1.23k (1235)" {
+		t.Errorf("seriesValueText = %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var globalPresets []compiledPreset
+
+const otherSection = "Other"
+
+type PresetPanel struct {
+	Title    string   `yaml:"title"`
+	Matchers []string `yaml:"matchers"`
+}
+
+type Preset struct {
+	Name   string        `yaml:"name"`
+	Detect []string      `yaml:"detect"`
+	Panels []PresetPanel `yaml:"panels"`
+}
+
+type compiledPanel struct {
+	title    string
+	matchers []*regexp.Regexp
+}
+
+type compiledPreset struct {
+	name   string
+	detect []string
+	panels []compiledPanel
+}
+
+func compilePresets(presets []Preset) ([]compiledPreset, error) {
+	out := make([]compiledPreset, 0, len(presets))
+	for i, p := range presets {
+		if p.Name == "" {
+			return nil, fmt.Errorf("presets[%d]: name is required", i)
+		}
+		if len(p.Detect) == 0 {
+			return nil, fmt.Errorf("preset %q: detect needs at least one metric name", p.Name)
+		}
+		cp := compiledPreset{name: p.Name, detect: p.Detect}
+		for _, panel := range p.Panels {
+			if panel.Title == "" {
+				return nil, fmt.Errorf("preset %q: panel title is required", p.Name)
+			}
+			c := compiledPanel{title: panel.Title}
+			for _, expr := range panel.Matchers {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					return nil, fmt.Errorf("preset %q panel %q: compile pattern %q: %w", p.Name, panel.Title, expr, err)
+				}
+				c.matchers = append(c.matchers, re)
+			}
+			cp.panels = append(cp.panels, c)
+		}
+		out = append(out, cp)
+	}
+	return out, nil
+}
+
+func mergePresets(base, override []Preset) []Preset {
+	seen := make(map[string]bool)
+	var merged []Preset
+	for _, p := range override {
+		merged = append(merged, p)
+		seen[p.Name] = true
+	}
+	for _, p := range base {
+		if !seen[p.Name] {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+func (p compiledPreset) detected(present map[string]bool) bool {
+	for _, name := range p.detect {
+		if !present[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func detectPresets(presets []compiledPreset, names []string) []compiledPreset {
+	present := make(map[string]bool, len(names))
+	for _, n := range names {
+		present[n] = true
+	}
+	var active []compiledPreset
+	for _, p := range presets {
+		if p.detected(present) {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+func arrangeByPresets(presets []compiledPreset, names []string) ([]string, map[string]string) {
+	active := detectPresets(presets, names)
+	if len(active) == 0 {
+		return names, nil
+	}
+	ordered := make([]string, 0, len(names))
+	sections := make(map[string]string, len(names))
+	for _, p := range active {
+		for _, panel := range p.panels {
+			title := p.name + " · " + panel.title
+			for _, n := range names {
+				if _, ok := sections[n]; ok {
+					continue
+				}
+				for _, re := range panel.matchers {
+					if re.MatchString(n) {
+						sections[n] = title
+						ordered = append(ordered, n)
+						break
+					}
+				}
+			}
+		}
+	}
+	for _, n := range names {
+		if _, ok := sections[n]; !ok {
+			sections[n] = otherSection
+			ordered = append(ordered, n)
+		}
+	}
+	return ordered, sections
+}
+
+func presetNames(presets []compiledPreset) []string {
+	out := make([]string, len(presets))
+	for i, p := range presets {
+		out[i] = p.name
+	}
+	return out
+}
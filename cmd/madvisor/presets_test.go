@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func defaultPresets(t *testing.T) []compiledPreset {
+	t.Helper()
+	cfg, err := loadDefaultUnits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	presets, err := compilePresets(cfg.Presets)
+	if err != nil {
+		t.Fatalf("compilePresets: %v", err)
+	}
+	return presets
+}
+
+func TestDetectPresets(t *testing.T) {
+	presets := defaultPresets(t)
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{[]string{"http_requests_total"}, []string{}},
+		{[]string{"node_cpu_seconds_total"}, []string{}},
+		{[]string{"node_cpu_seconds_total", "node_memory_MemAvailable_bytes"}, []string{"node_exporter"}},
+		{[]string{"go_goroutines", "go_memstats_alloc_bytes", "kube_pod_info", "kube_pod_status_phase"}, []string{"kube-state-metrics", "Go runtime"}},
+		{[]string{"container_cpu_usage_seconds_total", "container_memory_working_set_bytes"}, []string{"cAdvisor"}},
+		{[]string{"envoy_server_live", "envoy_cluster_upstream_cx_active"}, []string{"Envoy"}},
+	}
+	for _, tt := range tests {
+		got := presetNames(detectPresets(presets, tt.names))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectPresets(%v) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestArrangeByPresets(t *testing.T) {
+	presets := defaultPresets(t)
+	names := []string{
+		"http_requests_total",
+		"node_cpu_seconds_total",
+		"node_disk_read_bytes_total",
+		"node_load1",
+		"node_memory_MemAvailable_bytes",
+		"node_network_receive_bytes_total",
+	}
+	ordered, sections := arrangeByPresets(presets, names)
+	want := []string{
+		"node_cpu_seconds_total",
+		"node_load1",
+		"node_memory_MemAvailable_bytes",
+		"node_disk_read_bytes_total",
+		"node_network_receive_bytes_total",
+		"http_requests_total",
+	}
+	if !reflect.DeepEqual(ordered, want) {
+		t.Errorf("ordered = %v\nwant      %v", ordered, want)
+	}
+	if sections["node_load1"] != "node_exporter · CPU" || sections["node_disk_read_bytes_total"] != "node_exporter · Disk" {
+		t.Errorf("sections = %v", sections)
+	}
+	if sections["http_requests_total"] != otherSection {
+		t.Errorf("unmatched metric section = %q, want %q", sections["http_requests_total"], otherSection)
+	}
+
+	plain := []string{"a", "b"}
+	if got, sections := arrangeByPresets(presets, plain); !reflect.DeepEqual(got, plain) || sections != nil {
+		t.Errorf("without a detected preset got %v, %v", got, sections)
+	}
+}
+
+func TestMergePresetsOverride(t *testing.T) {
+	base := []Preset{{Name: "a", Detect: []string{"x"}}, {Name: "b", Detect: []string{"y"}}}
+	override := []Preset{{Name: "b", Detect: []string{"z"}}, {Name: "c", Detect: []string{"w"}}}
+	merged := mergePresets(base, override)
+	var got []string
+	for _, p := range merged {
+		got = append(got, p.Name+":"+p.Detect[0])
+	}
+	want := []string{"b:z", "c:w", "a:x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged = %v, want %v", got, want)
+	}
+}
+
+func TestCompilePresetsErrors(t *testing.T) {
+	bad := [][]Preset{
+		{{Detect: []string{"x"}}},
+		{{Name: "p"}},
+		{{Name: "p", Detect: []string{"x"}, Panels: []PresetPanel{{Matchers: []string{"^x"}}}}},
+		{{Name: "p", Detect: []string{"x"}, Panels: []PresetPanel{{Title: "t", Matchers: []string{"("}}}}},
+	}
+	for _, b := range bad {
+		if _, err := compilePresets(b); err == nil {
+			t.Errorf("compilePresets(%+v) should fail", b)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+var (
+	errParse    = errors.New("parse error")
+	errNotReady = errors.New("no target became ready")
+)
+
+const (
+	probeWaiting   = "waiting"
+	probeReachable = "reachable"
+	probeRefused   = "refused"
+	probeTimeout   = "timeout"
+	probeParse     = "parse error"
+)
+
+func probeState(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return probeReachable
+	case connRefused(err):
+		return probeRefused
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return probeTimeout
+	case errors.Is(err, errParse):
+		return probeParse
+	case strings.HasPrefix(err.Error(), "HTTP "):
+		return err.Error()
+	}
+	return "unreachable"
+}
+
+func targetState(h targetHealth) string {
+	if h.state == "" {
+		return probeWaiting
+	}
+	return h.state
+}
+
+func connectDeadlinePassed(elapsed time.Duration) bool {
+	return flagConnectTimeout > 0 && elapsed >= flagConnectTimeout
+}
+
+func renderSplash(w *text.Text, st *store, targets []target, elapsed time.Duration) {
+	w.Reset()
+	w.Write(fmt.Sprintf("Connecting to %s ...", formatTargets(targets)), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	if flagConnectTimeout > 0 {
+		left := (flagConnectTimeout - elapsed).Round(time.Second)
+		w.Write(fmt.Sprintf(" opening the dashboard in %s", left), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	}
+	w.Write("\n")
+	for _, t := range targets {
+		h := st.targetHealth(t.key())
+		state := targetState(h)
+		mark, color := "…", cell.ColorYellow
+		switch state {
+		case probeWaiting:
+		case probeReachable:
+			mark, color = "✓", cell.ColorGreen
+		default:
+			mark, color = "✗", cell.ColorRed
+		}
+		w.Write(fmt.Sprintf("  %s %-32s %s", mark, truncateText(t.key(), 32), state), text.WriteCellOpts(cell.FgColor(color)))
+		if h.failed > 0 {
+			w.Write(fmt.Sprintf(" (%d attempts)", h.ok+h.failed), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		}
+		if h.scan != "" {
+			w.Write(" "+h.scan, text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+		}
+		w.Write("\n")
+	}
+}
+
+// notReadyError reports the probe state of every target once the connect
+// timeout expires without any metrics, for non-interactive modes.
+func notReadyError(st *store, targets []target) error {
+	parts := make([]string, 0, len(targets))
+	for _, t := range targets {
+		parts = append(parts, t.key()+": "+targetState(st.targetHealth(t.key())))
+	}
+	sort.Strings(parts)
+	return fmt.Errorf("%w within %s (%s)", errNotReady, flagConnectTimeout, strings.Join(parts, ", "))
+}
+
+func writeProbeStatus(w io.Writer, st *store, targets []target) {
+	for _, t := range targets {
+		fmt.Fprintf(w, "  %s: %s\n", t.key(), targetState(st.targetHealth(t.key())))
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestProbeState(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{nil, probeReachable},
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connect: %w", syscall.ECONNREFUSED)}, probeRefused},
+		{fmt.Errorf("get: %w", timeoutErr{}), probeTimeout},
+		{fmt.Errorf("%w: no samples in response", errParse), probeParse},
+		{errors.New("HTTP 404 Not Found"), "HTTP 404 Not Found"},
+		{errors.New("no such host"), "unreachable"},
+	}
+	for _, c := range cases {
+		if got := probeState(c.err); got != c.want {
+			t.Errorf("probeState(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+	if got := targetState(targetHealth{}); got != probeWaiting {
+		t.Errorf("targetState before any scrape = %q, want %q", got, probeWaiting)
+	}
+}
+
+func TestScrapeTargetProbeStates(t *testing.T) {
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>login</body></html>")
+	}))
+	defer html.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedAddr := strings.TrimPrefix(closed.URL, "http://")
+	closed.Close()
+
+	st := newStore()
+	client := &http.Client{Timeout: time.Second}
+	htmlAddr := strings.TrimPrefix(html.URL, "http://")
+	scrapeTarget(client, target{addr: htmlAddr}, st)
+	scrapeTarget(client, target{addr: closedAddr}, st)
+
+	if got := targetState(st.targetHealth(htmlAddr)); got != probeParse {
+		t.Errorf("html endpoint state = %q, want %q", got, probeParse)
+	}
+	if got := targetState(st.targetHealth(closedAddr)); got != probeRefused {
+		t.Errorf("closed port state = %q, want %q", got, probeRefused)
+	}
+}
+
+func TestRecordConnectTimeout(t *testing.T) {
+	defer func(d time.Duration) { flagConnectTimeout = d }(flagConnectTimeout)
+	flagConnectTimeout = 100 * time.Millisecond
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var b bytes.Buffer
+	_, err := record(ctx, parseTargets(addr), &b)
+	if !errors.Is(err, errNotReady) {
+		t.Fatalf("record err = %v, want errNotReady", err)
+	}
+	if !strings.Contains(err.Error(), addr+": "+probeRefused) {
+		t.Errorf("error %q should report the target state", err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	promqlScheme      = "promql"
+	defaultPromQLPort = "9090"
+	promqlRangePath   = "/api/v1/query_range"
+
+	promqlBackfill = ringSize * scrapeInterval
+)
+
+// parsePromQLQuery extracts the query from the part of a promql:// target
+// after the scheme; it may be written as is or URL-encoded.
+func parsePromQLQuery(spec, rest string) (string, error) {
+	i := strings.Index(rest, "/")
+	if i < 0 || strings.TrimSpace(rest[i+1:]) == "" {
+		return "", fmt.Errorf("target %q: promql target needs a query, e.g. promql://prom:9090/up", spec)
+	}
+	q := strings.TrimSpace(rest[i+1:])
+	if u, err := url.PathUnescape(q); err == nil {
+		q = u
+	}
+	return q, nil
+}
+
+type promqlResponse struct {
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	ErrorType string `json:"errorType"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]any          `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func (st *store) promqlWindow(tgt target, now time.Time) (time.Time, time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	last, ok := st.promqlLast[tgt.spec()]
+	if !ok || now.Sub(last) > promqlBackfill {
+		return now.Add(-promqlBackfill), now
+	}
+	start := last.Add(scrapeInterval)
+	if start.After(now) {
+		start = now
+	}
+	return start, now
+}
+
+func (st *store) setPromQLLast(tgt target, t time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.promqlLast == nil {
+		st.promqlLast = map[string]time.Time{}
+	}
+	st.promqlLast[tgt.spec()] = t
+}
+
+// scrapePromQL evaluates a promql:// target as a range query from just after
+// the previous evaluation (or the last ringSize steps, on the first one) to
+// now, and ingests every point of every result series. Series without a
+// __name__, such as rate() results, are named after the query.
+func scrapePromQL(client *http.Client, tgt target, st *store) {
+	start, end := st.promqlWindow(tgt, time.Now())
+	req, err := newScrapeRequest(tgt)
+	if err != nil {
+		st.scrapeDone(tgt, err, time.Now())
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	req.URL.RawQuery = url.Values{
+		"query": {tgt.query},
+		"start": {strconv.FormatFloat(unixSeconds(start), 'f', 3, 64)},
+		"end":   {strconv.FormatFloat(unixSeconds(end), 'f', 3, 64)},
+		"step":  {strconv.FormatFloat(scrapeInterval.Seconds(), 'f', -1, 64)},
+	}.Encode()
+	resp, err := client.Do(req)
+	if err != nil {
+		st.scrapeDone(tgt, err, time.Now())
+		return
+	}
+	defer resp.Body.Close()
+
+	var pr promqlResponse
+	if err := json.NewDecoder(limitBody(resp.Body, maxScrapeBytes)).Decode(&pr); err != nil {
+		if resp.StatusCode/100 != 2 {
+			err = fmt.Errorf("HTTP %s", resp.Status)
+		} else {
+			err = fmt.Errorf("%w: %v", errParse, err)
+		}
+		st.scrapeDone(tgt, err, time.Now())
+		return
+	}
+	if pr.Status != "success" {
+		st.scrapeDone(tgt, fmt.Errorf("query failed: %s: %s", pr.ErrorType, pr.Error), time.Now())
+		return
+	}
+	if pr.Data.ResultType != "matrix" {
+		st.scrapeDone(tgt, fmt.Errorf("%w: range query returned a %s", errParse, pr.Data.ResultType), time.Now())
+		return
+	}
+
+	for _, r := range pr.Data.Result {
+		name := r.Metric["__name__"]
+		if name == "" {
+			name = tgt.query
+		}
+		labels := make(map[string]string, len(r.Metric))
+		for k, v := range r.Metric {
+			if k != "__name__" {
+				labels[k] = v
+			}
+		}
+		labels = tgt.attachLabels(labels)
+		name, labels, keep := applyRelabel(globalRelabel, tgt.addr, name, labels)
+		if !keep {
+			continue
+		}
+		for _, p := range r.Values {
+			t, v, err := promqlPoint(p)
+			if err != nil {
+				st.scrapeDone(tgt, fmt.Errorf("%w: %v", errParse, err), time.Now())
+				return
+			}
+			st.ingest(tgt.key(), name, labels, "PromQL: "+tgt.query, "gauge", v, t)
+		}
+	}
+	st.setPromQLLast(tgt, end)
+	st.scrapeDone(tgt, nil, time.Now())
+}
+
+// promqlPoint decodes a [<unix seconds>, "<value>"] pair.
+func promqlPoint(p [2]any) (time.Time, float64, error) {
+	ts, ok := p[0].(float64)
+	if !ok {
+		return time.Time{}, 0, errors.New("point timestamp is not a number")
+	}
+	s, ok := p[1].(string)
+	if !ok {
+		return time.Time{}, 0, errors.New("point value is not a string")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("point value %q: %v", s, err)
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e3))*int64(time.Millisecond)), v, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParsePromQLTarget(t *testing.T) {
+	tests := []struct {
+		spec, addr, query string
+	}{
+		{"promql://prom:9090/rate(http_requests_total[1m])", "prom:9090", "rate(http_requests_total[1m])"},
+		{"promql://prom/up", "prom:9090", "up"},
+		{"promql://prom:9090/sum%20by%20(code)(up)", "prom:9090", "sum by (code)(up)"},
+	}
+	for _, tt := range tests {
+		got, err := parseTarget(tt.spec)
+		if err != nil {
+			t.Errorf("parseTarget(%q): %v", tt.spec, err)
+			continue
+		}
+		if got.addr != tt.addr || got.query != tt.query || got.url() != "http://"+tt.addr+promqlRangePath {
+			t.Errorf("parseTarget(%q) = %+v (url %s)", tt.spec, got, got.url())
+		}
+	}
+	for _, bad := range []string{"promql://prom:9090", "promql://prom:9090/", "promql:///up"} {
+		if _, err := parseTarget(bad); err == nil {
+			t.Errorf("parseTarget(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestParseTargetListPromQLKeepsCommas(t *testing.T) {
+	targets, err := parseTargetList("node:9100,promql://prom:9090/sum by (code, method)(rate(x[1m]));api=app:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 3 || targets[1].query != "sum by (code, method)(rate(x[1m]))" || targets[2].group != "api" {
+		t.Fatalf("targets = %+v", targets)
+	}
+	if got := targets[1].spec(); got != "promql://prom:9090/sum by (code, method)(rate(x[1m]))" {
+		t.Errorf("spec() = %q", got)
+	}
+}
+
+func TestScrapePromQL(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != promqlRangePath {
+			http.NotFound(w, r)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		end, _ := strconv.ParseFloat(r.FormValue("end"), 64)
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"code":"200"},"values":[[%.3f,"1.5"],[%.3f,"2.5"]]},
+			{"metric":{"__name__":"up","job":"api"},"values":[[%.3f,"1"]]}]}}`, end-1, end, end)
+	}))
+	defer srv.Close()
+
+	tgt, err := parseTarget("promql://" + strings.TrimPrefix(srv.URL, "http://") + "/rate(http_requests_total[1m])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := newStore()
+	scrapePromQL(&http.Client{}, tgt, st)
+
+	s := st.get(seriesKey("rate(http_requests_total[1m])", map[string]string{"code": "200"}))
+	if s == nil || s.count() != 2 || s.last() != 2.5 || s.source != tgt.key() {
+		t.Fatalf("rate series = %+v, want 2 points ending at 2.5", s)
+	}
+	if st.get(seriesKey("up", map[string]string{"job": "api"})) == nil {
+		t.Error("named result series missing")
+	}
+	if h := st.targetHealth(tgt.key()); h.ok != 1 {
+		t.Errorf("health = %+v, want one successful evaluation", h)
+	}
+
+	scrapePromQL(&http.Client{}, tgt, st)
+	if len(queries) != 2 {
+		t.Fatalf("queries = %q", queries)
+	}
+	start := func(raw string) float64 {
+		for _, kv := range strings.Split(raw, "&") {
+			if v, ok := strings.CutPrefix(kv, "start="); ok {
+				f, _ := strconv.ParseFloat(v, 64)
+				return f
+			}
+		}
+		return 0
+	}
+	if d := start(queries[1]) - start(queries[0]); d < promqlBackfill.Seconds()-1 {
+		t.Errorf("second evaluation starts %.0fs after the first, want it to continue after the backfill", d)
+	}
+}
+
+func TestScrapePromQLError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error at char 5"}`)
+	}))
+	defer srv.Close()
+	tgt := target{addr: strings.TrimPrefix(srv.URL, "http://"), query: "rate(("}
+	st := newStore()
+	scrapePromQL(&http.Client{}, tgt, st)
+	if h := st.targetHealth(tgt.key()); h.failed != 1 || !strings.Contains(h.lastErr, "parse error at char 5") {
+		t.Errorf("health = %+v, want the query error", h)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	pushPathPrefix = "/metrics/job/"
+	pushMaxBody    = 16 << 20
+)
+
+func parsePushPath(path string) (map[string]string, error) {
+	if !strings.HasPrefix(path, pushPathPrefix) {
+		return nil, fmt.Errorf("path must start with %s", pushPathPrefix)
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "/metrics/"), "/"), "/")
+	if len(parts)%2 != 0 {
+		return nil, fmt.Errorf("grouping labels must be /<name>/<value> pairs")
+	}
+	grouping := make(map[string]string, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		name, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, err
+		}
+		value, err := url.PathUnescape(parts[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(name, "@base64") {
+			name = strings.TrimSuffix(name, "@base64")
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+			if err != nil {
+				return nil, fmt.Errorf("label %s: invalid base64 value: %w", name, err)
+			}
+			value = string(decoded)
+		}
+		if name == "" || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid grouping label name %q", name)
+		}
+		grouping[name] = value
+	}
+	if grouping[jobLabel] == "" {
+		return nil, fmt.Errorf("job name must not be empty")
+	}
+	return grouping, nil
+}
+
+func pushSource(grouping map[string]string) string {
+	return "push:" + seriesKey(grouping[jobLabel], grouping)
+}
+
+func matchesGrouping(labels, grouping map[string]string) bool {
+	for k, v := range grouping {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func pushHandler(st *store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grouping, err := parsePushPath(r.URL.EscapedPath())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+		case http.MethodDelete:
+			now := time.Now()
+			for _, s := range st.snapshot() {
+				if matchesGrouping(s.labels, grouping) {
+					st.resetSeries(s.key)
+					st.updateAt(s.name, s.labels, "", "", staleMarker, now)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		default:
+			w.Header().Set("Allow", "PUT, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		src := pushSource(grouping)
+		now := time.Now()
+		err = parseExposition(http.MaxBytesReader(w, r.Body, pushMaxBody), func(name string, labels map[string]string, help, mtype string, val float64) {
+			if labels == nil {
+				labels = make(map[string]string, len(grouping))
+			}
+			for k, v := range grouping {
+				labels[k] = v
+			}
+			name, labels, keep := applyRelabel(globalRelabel, src, name, labels)
+			if !keep {
+				return
+			}
+			st.ingest(src, name, labels, help, mtype, val, now)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		deriveBacklogs(st, src, now)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func servePush(ctx context.Context, ln net.Listener, st *store) error {
+	mux := http.NewServeMux()
+	mux.Handle(pushPathPrefix, pushHandler(st))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePushPath(t *testing.T) {
+	got, err := parsePushPath("/metrics/job/backup/instance/db%2F1/env@base64/cHJvZA")
+	if err != nil {
+		t.Fatalf("parsePushPath: %v", err)
+	}
+	want := map[string]string{"job": "backup", "instance": "db/1", "env": "prod"}
+	if len(got) != len(want) {
+		t.Fatalf("grouping = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	for _, bad := range []string{"/metrics", "/metrics/job/", "/metrics/job/a/instance", "/metrics/job/a/__x/y", "/metrics/job/a/l@base64/!!"} {
+		if _, err := parsePushPath(bad); err == nil {
+			t.Errorf("parsePushPath(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPushHandler(t *testing.T) {
+	st := newStore()
+	srv := httptest.NewServer(pushHandler(st))
+	defer srv.Close()
+
+	body := "# HELP backup_duration_seconds Duration\n# TYPE backup_duration_seconds gauge\nbackup_duration_seconds{job=\"wrong\"} 42\nbackup_ok 1\n"
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/metrics/job/backup/instance/db1", strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT status = %d", resp.StatusCode)
+	}
+
+	s := st.get(`backup_duration_seconds{instance=db1,job=backup}`)
+	if s == nil {
+		t.Fatalf("pushed series missing, have %v", st.names())
+	}
+	if s.last() != 42 || s.help != "Duration" || s.mtype != "gauge" {
+		t.Errorf("series = %v help=%q type=%q", s.last(), s.help, s.mtype)
+	}
+	if st.get(`backup_ok{instance=db1,job=backup}`) == nil {
+		t.Error("unlabelled pushed sample should get grouping labels")
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/metrics/job/backup", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || s.count() != 0 {
+		t.Errorf("DELETE status=%d count=%d", resp.StatusCode, s.count())
+	}
+	if s.endedAt.IsZero() {
+		t.Error("DELETE should mark the group's series as ended")
+	}
+
+	resp, err = http.Get(srv.URL + "/metrics/job/backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/metrics/nojob", "text/plain", strings.NewReader("x 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad path status = %d", resp.StatusCode)
+	}
+}
+
+func TestServePush(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := newStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- servePush(ctx, ln, st) }()
+
+	resp, err := http.Post("http://"+ln.Addr().String()+"/metrics/job/j", "text/plain", strings.NewReader("m 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(st.names()) != 1 {
+		t.Errorf("names = %v", st.names())
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("servePush: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("servePush did not stop")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRateColumns are the short, medium and long windows shown side by
+// side, like load averages, when rate columns are on.
+var defaultRateColumns = []time.Duration{time.Second, 10 * time.Second, time.Minute}
+
+var flagRateColumns string
+
+type rateColumnState struct {
+	mu      sync.Mutex
+	on      bool
+	windows []time.Duration
+}
+
+var rcs = rateColumnState{windows: defaultRateColumns}
+
+// rateColumnWindows are the windows of the series table's rate columns, or
+// nil for the single column over the current rate window.
+func rateColumnWindows() []time.Duration {
+	rcs.mu.Lock()
+	defer rcs.mu.Unlock()
+	if !rcs.on {
+		return nil
+	}
+	return rcs.windows
+}
+
+func toggleRateColumns() bool {
+	rcs.mu.Lock()
+	defer rcs.mu.Unlock()
+	rcs.on = !rcs.on
+	return rcs.on
+}
+
+// setRateColumns turns the rate columns on with windows, or off when there
+// are none.
+func setRateColumns(windows []time.Duration) {
+	rcs.mu.Lock()
+	defer rcs.mu.Unlock()
+	rcs.on = len(windows) > 0
+	if rcs.on {
+		rcs.windows = windows
+	}
+}
+
+// parseRateColumns parses a comma-separated list of windows such as
+// "1s,10s,60s".
+func parseRateColumns(val string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, f := range strings.Split(val, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid window %q", f)
+		}
+		out = append(out, d)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no windows in %q", val)
+	}
+	return out, nil
+}
+
+func rateColumnsMessage(on bool) string {
+	if !on {
+		return "rate columns: current rate window"
+	}
+	rcs.mu.Lock()
+	defer rcs.mu.Unlock()
+	names := make([]string, 0, len(rcs.windows))
+	for _, d := range rcs.windows {
+		names = append(names, shortDuration(d))
+	}
+	return "rate columns: " + strings.Join(names, " / ")
+}
+
+// shortDuration drops the zero trailing units of d's String, e.g. 1m rather
+// than 1m0s.
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateColumns(t *testing.T) {
+	got, err := parseRateColumns("1s, 10s,1m,")
+	if err != nil || len(got) != 3 || got[0] != time.Second || got[2] != time.Minute {
+		t.Errorf("parseRateColumns = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", ",", "1s,soon", "-5s"} {
+		if _, err := parseRateColumns(bad); err == nil {
+			t.Errorf("parseRateColumns(%q) should fail", bad)
+		}
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		250 * time.Millisecond: "250ms",
+		10 * time.Second:       "10s",
+		time.Minute:            "1m",
+		90 * time.Second:       "1m30s",
+		time.Hour:              "1h",
+	} {
+		if got := shortDuration(d); got != want {
+			t.Errorf("shortDuration(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestRateColumns(t *testing.T) {
+	defer setRateColumns(nil)
+	defer func() { rcs.windows = defaultRateColumns }()
+
+	s := newTestSeries("req_total", map[string]string{"code": "200"})
+	s.mtype = "counter"
+	g := newTestSeries("req_total", map[string]string{"code": "500"})
+	g.mtype = "gauge"
+	base := time.Now()
+	for i := 0; i <= 60; i++ {
+		v := float64(i)
+		if i > 55 {
+			v += float64(i-55) * 10
+		}
+		s.pushAt(v, base.Add(time.Duration(i)*time.Second))
+		g.pushAt(1, base.Add(time.Duration(i)*time.Second))
+	}
+	page := []*metricSeries{s, g}
+	titles := func() (string, []*tableColumn) {
+		cols := seriesColumns(page, nil, 0)
+		var out []string
+		for _, c := range cols {
+			out = append(out, c.title)
+		}
+		return strings.Join(out, ","), cols
+	}
+
+	if got, _ := titles(); !strings.Contains(got, ",rate,") {
+		t.Errorf("columns = %s, want a single rate column", got)
+	}
+	if !toggleRateColumns() || rateColumnsMessage(true) != "rate columns: 1s / 10s / 1m" {
+		t.Fatalf("toggle on: message %q", rateColumnsMessage(true))
+	}
+	got, cols := titles()
+	if !strings.Contains(got, "value,Δ 5s,rate 1s,rate 10s,rate 1m,min") {
+		t.Fatalf("columns = %s, want one rate column per window", got)
+	}
+	if cols[2].cells[0] != "11.00/s" || cols[3].cells[0] != "6.00/s" || cols[4].cells[0] != "1.83/s" {
+		t.Errorf("rate cells = %q %q %q, want the spike fading over longer windows", cols[2].cells[0], cols[3].cells[0], cols[4].cells[0])
+	}
+	if cols[2].cells[1] != "" {
+		t.Errorf("gauge rate cell = %q, want empty", cols[2].cells[1])
+	}
+
+	setRateColumns([]time.Duration{250 * time.Millisecond, 5 * time.Second})
+	if got, _ := titles(); !strings.Contains(got, "rate 250ms,rate 5s") {
+		t.Errorf("columns = %s, want the configured windows", got)
+	}
+	if toggleRateColumns() || rateColumnWindows() != nil {
+		t.Error("toggle off should go back to the single rate column")
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type sample struct {
+	Time   time.Time         `json:"t"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Type   string            `json:"type,omitempty"`
+	Help   string            `json:"help,omitempty"`
+	Value  float64           `json:"value"`
+	Source string            `json:"source,omitempty"`
+
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// --- record ---
+
+type recorder struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	n      int
+	err    error
+	closed bool
+}
+
+func newRecorder(w io.Writer) *recorder {
+	return &recorder{enc: json.NewEncoder(w)}
+}
+
+func (r *recorder) write(s sample) {
+	if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+	if err := r.enc.Encode(s); err != nil {
+		r.err = err
+		return
+	}
+	r.n++
+}
+
+func (r *recorder) finish(flush func() error) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.err != nil {
+		return r.n, r.err
+	}
+	return r.n, flush()
+}
+
+func record(ctx context.Context, targets []target, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	rec := newRecorder(bw)
+	st := newStore()
+	st.observe = rec.write
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if flagConnectTimeout > 0 {
+		timer := time.AfterFunc(flagConnectTimeout, func() {
+			if !st.hasData() {
+				cancel(notReadyError(st, targets))
+			}
+		})
+		defer timer.Stop()
+	}
+	scrape(ctx, targets, st)
+	n, err := rec.finish(bw.Flush)
+	if cause := context.Cause(ctx); errors.Is(cause, errNotReady) {
+		return n, cause
+	}
+	return n, err
+}
+
+// --- replay ---
+
+func readSamples(r io.Reader) ([]sample, error) {
+	var out []sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var s sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		out = append(out, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+func loadSamples(path string) ([]sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open recording %q: %w", path, err)
+	}
+	defer f.Close()
+	samples, err := readSamples(f)
+	if err != nil {
+		return nil, fmt.Errorf("recording %q: %w", path, err)
+	}
+	return samples, nil
+}
+
+func replay(ctx context.Context, samples []sample, speed float64, st *store) {
+	if len(samples) == 0 {
+		return
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	origin := samples[0].Time
+	start := time.Now()
+	for _, s := range samples {
+		offset := s.Time.Sub(origin)
+		wait := time.Until(start.Add(time.Duration(float64(offset) / speed)))
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+		if s.Annotation != "" {
+			st.annotateAt(s.Annotation, start.Add(offset))
+			continue
+		}
+		st.updateAt(s.Name, s.Labels, s.Help, s.Type, s.Value, start.Add(offset))
+	}
+}
+
+// --- snapshot diff ---
+
+func lastValues(samples []sample) map[string]float64 {
+	out := map[string]float64{}
+	for _, s := range samples {
+		if s.Annotation != "" {
+			continue
+		}
+		out[seriesKey(s.Name, s.Labels)] = s.Value
+	}
+	return out
+}
+
+func diffRows(a, b []sample, changedOnly bool) [][]string {
+	av, bv := lastValues(a), lastValues(b)
+	keys := make([]string, 0, len(av)+len(bv))
+	for k := range av {
+		keys = append(keys, k)
+	}
+	for k := range bv {
+		if _, ok := av[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	fmtv := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	var rows [][]string
+	for _, k := range keys {
+		x, inA := av[k]
+		y, inB := bv[k]
+		switch {
+		case !inA:
+			rows = append(rows, []string{k, "-", fmtv(y), "added", ""})
+		case !inB:
+			rows = append(rows, []string{k, fmtv(x), "-", "removed", ""})
+		default:
+			if changedOnly && x == y {
+				continue
+			}
+			pct := ""
+			if x != 0 {
+				pct = fmt.Sprintf("%+.1f%%", (y-x)/math.Abs(x)*100)
+			}
+			rows = append(rows, []string{k, fmtv(x), fmtv(y), fmtv(y - x), pct})
+		}
+	}
+	return rows
+}
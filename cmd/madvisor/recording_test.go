@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	rec := newRecorder(&b)
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec.write(sample{Time: base.Add(time.Second), Name: "up", Value: 1})
+	rec.write(sample{Time: base, Name: "req_total", Labels: map[string]string{"code": "200"}, Type: "counter", Help: "Requests", Value: 42})
+	rec.write(sample{Time: base, Name: "weird", Value: math.NaN()})
+
+	n, err := rec.finish(func() error { return nil })
+	if err != nil || n != 2 {
+		t.Fatalf("finish = %d, %v; want 2, nil (NaN skipped)", n, err)
+	}
+
+	samples, err := readSamples(&b)
+	if err != nil {
+		t.Fatalf("readSamples: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("len = %d, want 2", len(samples))
+	}
+	if samples[0].Name != "req_total" {
+		t.Errorf("samples not sorted by time: first = %q", samples[0].Name)
+	}
+	if samples[0].Labels["code"] != "200" || samples[0].Type != "counter" || samples[0].Help != "Requests" {
+		t.Errorf("sample metadata lost: %+v", samples[0])
+	}
+
+	rec.write(sample{Time: base, Name: "late", Value: 1})
+	if n, _ := rec.finish(func() error { return nil }); n != 2 {
+		t.Errorf("writes after finish should be dropped, n = %d", n)
+	}
+}
+
+func TestReadSamplesInvalid(t *testing.T) {
+	if _, err := readSamples(strings.NewReader("{\"name\":\"ok\"}\nnot json\n")); err == nil {
+		t.Error("readSamples should fail on invalid JSON")
+	}
+}
+
+func TestStoreObserve(t *testing.T) {
+	st := newStore()
+	var got []sample
+	st.observe = func(s sample) { got = append(got, s) }
+	st.update("m", map[string]string{"a": "1"}, "help", "gauge", 3)
+
+	if len(got) != 1 || got[0].Name != "m" || got[0].Value != 3 || got[0].Help != "help" {
+		t.Errorf("observed = %+v", got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	base := time.Now()
+	samples := []sample{
+		{Time: base, Name: "req_total", Type: "counter", Value: 0},
+		{Time: base.Add(time.Second), Name: "req_total", Type: "counter", Value: 10},
+		{Time: base.Add(2 * time.Second), Name: "req_total", Type: "counter", Value: 20},
+	}
+
+	st := newStore()
+	replay(context.Background(), samples, 1000, st)
+
+	s := st.get("req_total")
+	if s == nil {
+		t.Fatal("replay did not populate the store")
+	}
+	if s.count() != 3 {
+		t.Errorf("count = %d, want 3", s.count())
+	}
+	if r := s.rate(5 * time.Second); r < 9.9 || r > 10.1 {
+		t.Errorf("rate = %f, want ~10 (recorded timestamps preserved)", r)
+	}
+}
+
+func TestReplayStopsOnCancel(t *testing.T) {
+	base := time.Now()
+	samples := []sample{
+		{Time: base, Name: "a", Value: 1},
+		{Time: base.Add(time.Hour), Name: "b", Value: 1},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	st := newStore()
+	replay(ctx, samples, 1, st)
+	if st.get("b") != nil {
+		t.Error("replay should stop when the context is cancelled")
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	base := time.Now()
+	a := []sample{
+		{Time: base, Name: "same", Value: 1},
+		{Time: base, Name: "grew", Value: 10},
+		{Time: base, Name: "gone", Value: 1},
+	}
+	b := []sample{
+		{Time: base, Name: "same", Value: 1},
+		{Time: base, Name: "grew", Value: 15},
+		{Time: base, Name: "new", Value: 2},
+	}
+
+	rows := diffRows(a, b, false)
+	if len(rows) != 4 {
+		t.Fatalf("rows = %d, want 4", len(rows))
+	}
+	byKey := map[string][]string{}
+	for _, r := range rows {
+		byKey[r[0]] = r
+	}
+	if r := byKey["grew"]; r[3] != "5" || r[4] != "+50.0%" {
+		t.Errorf("grew row = %v, want delta 5 and +50.0%%", r)
+	}
+	if byKey["gone"][3] != "removed" || byKey["new"][3] != "added" {
+		t.Errorf("added/removed rows = %v / %v", byKey["new"], byKey["gone"])
+	}
+
+	if rows := diffRows(a, b, true); len(rows) != 3 {
+		t.Errorf("changed-only rows = %d, want 3", len(rows))
+	}
+}
+
+func TestRecord(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# TYPE up gauge\nup 1\n")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var b bytes.Buffer
+	n, err := record(ctx, parseTargets(strings.TrimPrefix(srv.URL, "http://")), &b)
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if n < 1 {
+		t.Fatalf("recorded %d samples, want at least 1", n)
+	}
+	samples, err := readSamples(&b)
+	if err != nil || len(samples) != n {
+		t.Errorf("readSamples = %d, %v; want %d", len(samples), err, n)
+	}
+}
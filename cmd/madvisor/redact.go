@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var globalRedact []redactRule
+
+// RedactRule masks the parts of label values matching Pattern, in the labels
+// listed (all when empty), before they reach the store: the dashboard,
+// exports, recordings, --output and remote write only ever see the mask.
+// Without a Replacement each match becomes a short keyed hash, so distinct
+// values stay distinct series without being recoverable. The key is
+// redact_key, or one generated once and kept in the configuration
+// directory, so masks match across runs, recordings and --baseline.
+type RedactRule struct {
+	Pattern     string   `yaml:"pattern"`
+	Labels      []string `yaml:"labels"`
+	Replacement string   `yaml:"replacement"`
+}
+
+type redactRule struct {
+	re          *regexp.Regexp
+	labels      map[string]bool
+	replacement string
+	key         []byte
+}
+
+const redactKeyFile = "redact.key"
+
+// loadRedactKey is the key hashing masks: configured when set, else the one
+// kept in dir, created on first use. A secret key means masks cannot be
+// looked up from a list of likely values. Rules that all have a replacement
+// need no key.
+func loadRedactKey(configured string, cfgs []RedactRule, dir string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+	hashed := false
+	for _, c := range cfgs {
+		hashed = hashed || c.Replacement == ""
+	}
+	if !hashed {
+		return nil, nil
+	}
+	if dir == "" {
+		return nil, errors.New("redact: no configuration directory to keep a key in, set redact_key")
+	}
+	path := filepath.Join(dir, redactKeyFile)
+	if b, err := os.ReadFile(path); err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("redact key %s: want hex, as madvisor writes it", path)
+		}
+		return key, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("redact key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("redact key: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("redact key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("redact key: %w", err)
+	}
+	return key, nil
+}
+
+// redactMask matches the masks already in a value, which are left alone
+// when derived series are stored again from redacted labels.
+var redactMask = regexp.MustCompile(`\[redacted:[0-9a-f]{8}\]`)
+
+func compileRedact(cfgs []RedactRule, key []byte) ([]redactRule, error) {
+	out := make([]redactRule, 0, len(cfgs))
+	for i, c := range cfgs {
+		if c.Pattern == "" {
+			return nil, fmt.Errorf("redact[%d]: pattern is required", i)
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact[%d]: %w", i, err)
+		}
+		r := redactRule{re: re, replacement: c.Replacement, key: key}
+		if len(c.Labels) > 0 {
+			r.labels = map[string]bool{}
+			for _, l := range c.Labels {
+				r.labels[l] = true
+			}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (r redactRule) mask(s string) string {
+	if r.replacement != "" {
+		return r.replacement
+	}
+	h := hmac.New(sha256.New, r.key)
+	h.Write([]byte(s))
+	return "[redacted:" + hex.EncodeToString(h.Sum(nil)[:4]) + "]"
+}
+
+// redactPiece is part of a label value being redacted; masked pieces are
+// left alone by later rules.
+type redactPiece struct {
+	s      string
+	masked bool
+}
+
+// split cuts the unmasked pieces at every match of re, masking the matches
+// with mask.
+func split(pieces []redactPiece, re *regexp.Regexp, mask func(string) string) []redactPiece {
+	var out []redactPiece
+	for _, p := range pieces {
+		if p.masked {
+			out = append(out, p)
+			continue
+		}
+		last := 0
+		for _, m := range re.FindAllStringIndex(p.s, -1) {
+			out = append(out, redactPiece{s: p.s[last:m[0]]}, redactPiece{s: mask(p.s[m[0]:m[1]]), masked: true})
+			last = m[1]
+		}
+		out = append(out, redactPiece{s: p.s[last:]})
+	}
+	return out
+}
+
+func redactValue(rules []redactRule, label, v string) string {
+	pieces := split([]redactPiece{{s: v}}, redactMask, func(s string) string { return s })
+	for _, r := range rules {
+		if r.labels == nil || r.labels[label] {
+			pieces = split(pieces, r.re, r.mask)
+		}
+	}
+	var b strings.Builder
+	for _, p := range pieces {
+		b.WriteString(p.s)
+	}
+	return b.String()
+}
+
+// redactLabels returns labels with every rule applied, copying them only
+// when something was masked.
+func redactLabels(labels map[string]string) map[string]string {
+	rules := globalRedact
+	if len(rules) == 0 {
+		return labels
+	}
+	var out map[string]string
+	for k, v := range labels {
+		if nv := redactValue(rules, k, v); nv != v {
+			if out == nil {
+				out = maps.Clone(labels)
+			}
+			out[k] = nv
+		}
+	}
+	if out == nil {
+		return labels
+	}
+	return out
+}
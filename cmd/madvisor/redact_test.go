@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRedactKey = []byte("test")
+
+func piiRules(t *testing.T) []redactRule {
+	t.Helper()
+	data, err := patternPack("pii")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadUnitsConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := compileRedact(cfg.Redact, testRedactKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestRedactValue(t *testing.T) {
+	rules := piiRules(t)
+	for in, keep := range map[string]string{
+		"alice@example.com":                               "",
+		"/api/users?token=abc123.def&page=2":              "/api/users?",
+		"Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig": "",
+		"session 0123456789abcdef0123456789abcdef":        "session ",
+	} {
+		got := redactValue(rules, "path", in)
+		if !strings.Contains(got, "[redacted:") || !strings.HasPrefix(got, keep) {
+			t.Errorf("redactValue(%q) = %q", in, got)
+		}
+	}
+	for _, in := range []string{"GET", "/api/orders", "200", "web-7d9f8b6c4-x2k5z", "10.0.0.1:9100"} {
+		if got := redactValue(rules, "path", in); got != in {
+			t.Errorf("redactValue(%q) = %q, want it untouched", in, got)
+		}
+	}
+
+	a, b := redactValue(rules, "user", "a@example.com"), redactValue(rules, "user", "b@example.com")
+	if a == b || a != redactValue(rules, "user", "a@example.com") {
+		t.Errorf("masks %q and %q should be stable and distinct", a, b)
+	}
+}
+
+func TestRedactRuleOptions(t *testing.T) {
+	rules, err := compileRedact([]RedactRule{{Pattern: `\d+`, Labels: []string{"user_id"}}, {Pattern: "secret", Replacement: "***"}}, testRedactKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := redactValue(rules, "code", "200"); got != "200" {
+		t.Errorf("a rule limited to user_id masked code: %q", got)
+	}
+	masked := redactValue(rules, "user_id", "u-4242")
+	if !strings.HasPrefix(masked, "u-[redacted:") {
+		t.Errorf("user_id = %q", masked)
+	}
+	if again := redactValue(rules, "user_id", masked); again != masked {
+		t.Errorf("masking twice changed %q to %q", masked, again)
+	}
+	if got := redactValue(rules, "x", "my-secret"); got != "my-***" {
+		t.Errorf("replacement = %q", got)
+	}
+
+	for _, bad := range []RedactRule{{}, {Pattern: "("}} {
+		if _, err := compileRedact([]RedactRule{bad}, testRedactKey); err == nil {
+			t.Errorf("%+v should be rejected", bad)
+		}
+	}
+}
+
+func TestRedactKeepsNewMasks(t *testing.T) {
+	rules, err := compileRedact([]RedactRule{{Pattern: "secret"}, {Pattern: `[0-9a-f]{8}`}, {Pattern: `\w+`, Replacement: "x"}}, testRedactKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := redactValue(rules, "l", "secret")
+	if !regexp.MustCompile(`^\[redacted:[0-9a-f]{8}\]$`).MatchString(got) {
+		t.Errorf("redactValue = %q, want the first rule's mask untouched by later rules", got)
+	}
+}
+
+func TestLoadRedactKey(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "madvisor")
+	hashed := []RedactRule{{Pattern: "x"}}
+	if key, err := loadRedactKey("", []RedactRule{{Pattern: "x", Replacement: "y"}}, dir); err != nil || key != nil {
+		t.Errorf("replacement-only rules: key %x, %v; want no key", key, err)
+	}
+	if key, err := loadRedactKey("shared", hashed, dir); err != nil || string(key) != "shared" {
+		t.Errorf("configured key = %q, %v", key, err)
+	}
+	first, err := loadRedactKey("", hashed, dir)
+	if err != nil || len(first) != 32 {
+		t.Fatalf("generated key = %x, %v", first, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, redactKeyFile)); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("key file = %v, %v; want it private", fi, err)
+	}
+	if again, err := loadRedactKey("", hashed, dir); err != nil || string(again) != string(first) {
+		t.Errorf("second run key = %x, %v; want the persisted %x", again, err, first)
+	}
+	os.WriteFile(filepath.Join(dir, redactKeyFile), []byte("not hex"), 0o600)
+	if _, err := loadRedactKey("", hashed, dir); err == nil {
+		t.Error("a corrupt key file should be reported")
+	}
+	if _, err := loadRedactKey("", hashed, ""); err == nil {
+		t.Error("no configuration directory should ask for redact_key")
+	}
+}
+
+func TestRedactAtIngest(t *testing.T) {
+	defer func(r []redactRule) { globalRedact = r }(globalRedact)
+	globalRedact = piiRules(t)
+
+	st := newStore()
+	var recorded []sample
+	st.observe = func(s sample) { recorded = append(recorded, s) }
+	labels := map[string]string{"user": "alice@example.com", "code": "200"}
+	st.ingest("app:8080", "logins_total", labels, "", "counter", 1, time.Now())
+	st.updateAt("logins_total", map[string]string{"user": "bob@example.com"}, "", "counter", 1, time.Now())
+
+	if labels["user"] != "alice@example.com" {
+		t.Error("the caller's labels should not be modified")
+	}
+	for _, s := range st.seriesForName("logins_total") {
+		if strings.Contains(s.key, "example.com") || strings.Contains(s.labels["user"], "example.com") {
+			t.Errorf("stored series %s leaks the address", s.key)
+		}
+	}
+	if len(recorded) != 2 || strings.Contains(recorded[0].Labels["user"], "@") || recorded[0].Labels["code"] != "200" {
+		t.Errorf("recorded %+v", recorded)
+	}
+}
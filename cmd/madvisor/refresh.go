@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	idleRefreshInterval = 1 * time.Second
+	idleAfter           = 5 * time.Second
+	idleScrapeInterval  = 10 * time.Second
+)
+
+type refresher struct {
+	wake          chan struct{}
+	lastInput     atomic.Int64
+	lowPowerAfter time.Duration
+}
+
+func newRefresher(lowPowerAfter time.Duration) *refresher {
+	r := &refresher{wake: make(chan struct{}, 1), lowPowerAfter: lowPowerAfter}
+	r.lastInput.Store(time.Now().UnixNano())
+	return r
+}
+
+func (r *refresher) poke() {
+	r.lastInput.Store(time.Now().UnixNano())
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *refresher) interval(now time.Time) time.Duration {
+	if now.Sub(time.Unix(0, r.lastInput.Load())) < idleAfter {
+		return refreshInterval
+	}
+	return idleRefreshInterval
+}
+
+func (r *refresher) due(now, lastRender time.Time) bool {
+	return now.Sub(lastRender) >= r.interval(now)
+}
+
+func (r *refresher) lowPower(now time.Time) bool {
+	return r.lowPowerAfter > 0 && now.Sub(time.Unix(0, r.lastInput.Load())) >= r.lowPowerAfter
+}
+
+func (st *store) setLowPower(lowPower bool) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	was := st.lowPower
+	st.lowPower = lowPower
+	switch now := time.Now(); {
+	case lowPower && !was:
+		st.lowPowerSpans = append(st.lowPowerSpans, lowPowerSpan{from: now})
+	case !lowPower && was:
+		st.lowPowerSpans[len(st.lowPowerSpans)-1].to = now
+	}
+	return was
+}
+
+// lowPowerSpan is a stretch scraped every idleScrapeInterval; to is zero
+// while it lasts.
+type lowPowerSpan struct{ from, to time.Time }
+
+// scrapeIntervalAt is the scrape interval in effect at t, so samples taken
+// in low-power mode are not mistaken for missed scrapes.
+func (st *store) scrapeIntervalAt(t time.Time) time.Duration {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	for i := len(st.lowPowerSpans) - 1; i >= 0; i-- {
+		sp := st.lowPowerSpans[i]
+		if !t.Before(sp.from) && (sp.to.IsZero() || !t.After(sp.to)) {
+			return idleScrapeInterval
+		}
+	}
+	return scrapeInterval
+}
+
+// resolutionAt is the interval between cs's samples at t: its history
+// tier's step, or the scrape interval then in effect.
+func (st *store) resolutionAt(cs *metricSeries) func(time.Time) time.Duration {
+	return func(t time.Time) time.Duration {
+		return max(cs.resolutionAt(t), st.scrapeIntervalAt(t))
+	}
+}
+
+func (st *store) isLowPower() bool {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.lowPower
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefresherIdleInterval(t *testing.T) {
+	r := newRefresher(0)
+	now := time.Now()
+	if got := r.interval(now); got != refreshInterval {
+		t.Errorf("interval at startup = %v, want %v", got, refreshInterval)
+	}
+	idle := now.Add(idleAfter)
+	if got := r.interval(idle); got != idleRefreshInterval {
+		t.Errorf("interval after %v idle = %v, want %v", idleAfter, got, idleRefreshInterval)
+	}
+	if r.due(idle, idle.Add(-500*time.Millisecond)) {
+		t.Error("idle refresher should not be due after 500ms")
+	}
+	if !r.due(idle, idle.Add(-idleRefreshInterval)) {
+		t.Error("idle refresher should be due after the idle interval")
+	}
+}
+
+func TestRefresherPoke(t *testing.T) {
+	r := newRefresher(0)
+	r.lastInput.Store(0)
+	r.poke()
+	r.poke()
+	select {
+	case <-r.wake:
+	default:
+		t.Fatal("poke should wake the render loop")
+	}
+	select {
+	case <-r.wake:
+		t.Fatal("repeated pokes should coalesce")
+	default:
+	}
+	if got := r.interval(time.Now()); got != refreshInterval {
+		t.Errorf("interval after input = %v, want %v", got, refreshInterval)
+	}
+}
+
+func TestRefresherLowPower(t *testing.T) {
+	now := time.Now()
+	if newRefresher(0).lowPower(now.Add(time.Hour)) {
+		t.Error("low-power mode should be off without --idle-after")
+	}
+	r := newRefresher(5 * time.Minute)
+	now = time.Unix(0, r.lastInput.Load())
+	if r.lowPower(now.Add(4 * time.Minute)) {
+		t.Error("low-power mode entered before --idle-after elapsed")
+	}
+	if !r.lowPower(now.Add(5 * time.Minute)) {
+		t.Error("low-power mode not entered after --idle-after elapsed")
+	}
+	r.poke()
+	if r.lowPower(time.Now()) {
+		t.Error("key press should leave low-power mode")
+	}
+}
+
+func TestStoreLowPower(t *testing.T) {
+	st := newStore()
+	if was := st.setLowPower(true); was {
+		t.Error("store should start outside low-power mode")
+	}
+	if !st.isLowPower() {
+		t.Error("isLowPower = false after setLowPower(true)")
+	}
+	if was := st.setLowPower(false); !was {
+		t.Error("setLowPower should report the previous state")
+	}
+}
+
+func TestStoreScrapeIntervalAt(t *testing.T) {
+	st := newStore()
+	before := time.Now()
+	st.setLowPower(true)
+	during := time.Now()
+	st.setLowPower(false)
+	after := time.Now().Add(time.Millisecond)
+	if got := st.scrapeIntervalAt(during); got != idleScrapeInterval {
+		t.Errorf("interval in low-power mode = %v, want %v", got, idleScrapeInterval)
+	}
+	for _, at := range []time.Time{before.Add(-time.Millisecond), after} {
+		if got := st.scrapeIntervalAt(at); got != scrapeInterval {
+			t.Errorf("interval outside low-power mode = %v, want %v", got, scrapeInterval)
+		}
+	}
+}
+
+func TestLowPowerSamplesAreNotGaps(t *testing.T) {
+	st := newStore()
+	st.setLowPower(true)
+	defer st.setLowPower(false)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		st.ingest("t:1", "queue_depth", nil, "", "gauge", 1, start.Add(time.Duration(i)*idleScrapeInterval))
+	}
+	if fr := prepareFrame(st, frameRequest{name: "queue_depth"}); fr.gaps != 0 {
+		t.Errorf("gaps = %d, want samples %v apart in low-power mode drawn without gaps", fr.gaps, idleScrapeInterval)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	nameLabel    = "__name__"
+	addressLabel = "__address__"
+)
+
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    *string  `yaml:"separator"`
+	Regex        string   `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+}
+
+type relabelRule struct {
+	source      []string
+	separator   string
+	re          *regexp.Regexp
+	target      string
+	replacement string
+	action      string
+}
+
+func compileRelabel(cfgs []RelabelConfig) ([]relabelRule, error) {
+	rules := make([]relabelRule, 0, len(cfgs))
+	for i, c := range cfgs {
+		r := relabelRule{
+			source:      c.SourceLabels,
+			separator:   ";",
+			target:      c.TargetLabel,
+			replacement: "$1",
+			action:      strings.ToLower(c.Action),
+		}
+		if c.Separator != nil {
+			r.separator = *c.Separator
+		}
+		if c.Replacement != nil {
+			r.replacement = *c.Replacement
+		}
+		if r.action == "" {
+			r.action = "replace"
+		}
+		expr := c.Regex
+		if expr == "" {
+			expr = "(.*)"
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("relabel_configs[%d]: compile regex %q: %w", i, expr, err)
+		}
+		r.re = re
+
+		switch r.action {
+		case "replace":
+			if r.target == "" {
+				return nil, fmt.Errorf("relabel_configs[%d]: replace requires target_label", i)
+			}
+		case "keep", "drop":
+			if len(r.source) == 0 {
+				return nil, fmt.Errorf("relabel_configs[%d]: %s requires source_labels", i, r.action)
+			}
+		case "labeldrop", "labelkeep", "labelmap":
+		default:
+			return nil, fmt.Errorf("relabel_configs[%d]: unknown action %q", i, c.Action)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func applyRelabel(rules []relabelRule, addr, name string, labels map[string]string) (string, map[string]string, bool) {
+	if len(rules) == 0 {
+		return name, labels, true
+	}
+	ls := make(map[string]string, len(labels)+2)
+	for k, v := range labels {
+		ls[k] = v
+	}
+	ls[nameLabel] = name
+	ls[addressLabel] = addr
+
+	for _, r := range rules {
+		vals := make([]string, len(r.source))
+		for i, s := range r.source {
+			vals[i] = ls[s]
+		}
+		joined := strings.Join(vals, r.separator)
+
+		switch r.action {
+		case "replace":
+			m := r.re.FindStringSubmatchIndex(joined)
+			if m == nil {
+				continue
+			}
+			target := string(r.re.ExpandString(nil, r.target, joined, m))
+			val := string(r.re.ExpandString(nil, r.replacement, joined, m))
+			if val == "" {
+				delete(ls, target)
+			} else {
+				ls[target] = val
+			}
+		case "keep":
+			if !r.re.MatchString(joined) {
+				return name, nil, false
+			}
+		case "drop":
+			if r.re.MatchString(joined) {
+				return name, nil, false
+			}
+		case "labeldrop":
+			for k := range ls {
+				if !strings.HasPrefix(k, "__") && r.re.MatchString(k) {
+					delete(ls, k)
+				}
+			}
+		case "labelkeep":
+			for k := range ls {
+				if !strings.HasPrefix(k, "__") && !r.re.MatchString(k) {
+					delete(ls, k)
+				}
+			}
+		case "labelmap":
+			for k, v := range ls {
+				if m := r.re.FindStringSubmatchIndex(k); m != nil {
+					ls[string(r.re.ExpandString(nil, r.replacement, k, m))] = v
+				}
+			}
+		}
+	}
+
+	if n := ls[nameLabel]; n != "" {
+		name = n
+	}
+	out := make(map[string]string, len(ls))
+	for k, v := range ls {
+		if !strings.HasPrefix(k, "__") {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		out = nil
+	}
+	return name, out, true
+}
+
+var (
+	globalRelabel     []relabelRule
+	globalHonorLabels bool
+)
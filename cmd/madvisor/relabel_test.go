@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func mustRelabel(t *testing.T, src string) []relabelRule {
+	t.Helper()
+	var cfg UnitsConfig
+	if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	rules, err := compileRelabel(cfg.RelabelConfigs)
+	if err != nil {
+		t.Fatalf("compileRelabel: %v", err)
+	}
+	return rules
+}
+
+func TestRelabelLabelDrop(t *testing.T) {
+	rules := mustRelabel(t, `
+relabel_configs:
+  - action: labeldrop
+    regex: "id|pod_ip"
+`)
+	name, labels, keep := applyRelabel(rules, "a:1", "cpu", map[string]string{"id": "abc", "pod_ip": "10.0.0.1", "env": "prod"})
+	if !keep || name != "cpu" {
+		t.Fatalf("keep=%v name=%q", keep, name)
+	}
+	if len(labels) != 1 || labels["env"] != "prod" {
+		t.Errorf("labels = %v, want only env", labels)
+	}
+}
+
+func TestRelabelReplaceExtract(t *testing.T) {
+	rules := mustRelabel(t, `
+relabel_configs:
+  - source_labels: [pod]
+    regex: "(.+)-[a-z0-9]+-[a-z0-9]+"
+    target_label: deployment
+  - source_labels: [__address__]
+    regex: "([^:]+):.*"
+    target_label: host
+`)
+	_, labels, _ := applyRelabel(rules, "node1:9100", "up", map[string]string{"pod": "api-7d9f8-x2k4q"})
+	if labels["deployment"] != "api" {
+		t.Errorf("deployment = %q, want api", labels["deployment"])
+	}
+	if labels["host"] != "node1" {
+		t.Errorf("host = %q, want node1 (mapped from target)", labels["host"])
+	}
+	if _, ok := labels["__address__"]; ok {
+		t.Error("internal labels must be removed after relabeling")
+	}
+}
+
+func TestRelabelRenameAndRemove(t *testing.T) {
+	rules := mustRelabel(t, `
+relabel_configs:
+  - source_labels: [kubernetes_namespace]
+    target_label: namespace
+  - source_labels: [kubernetes_namespace]
+    target_label: kubernetes_namespace
+    replacement: ""
+`)
+	_, labels, _ := applyRelabel(rules, "a:1", "m", map[string]string{"kubernetes_namespace": "prod"})
+	if labels["namespace"] != "prod" {
+		t.Errorf("namespace = %q, want prod", labels["namespace"])
+	}
+	if _, ok := labels["kubernetes_namespace"]; ok {
+		t.Error("empty replacement should remove the target label")
+	}
+}
+
+func TestRelabelKeepDrop(t *testing.T) {
+	rules := mustRelabel(t, `
+relabel_configs:
+  - source_labels: [__name__]
+    regex: "go_.*"
+    action: drop
+  - source_labels: [env]
+    regex: prod
+    action: keep
+`)
+	if _, _, keep := applyRelabel(rules, "a:1", "go_goroutines", map[string]string{"env": "prod"}); keep {
+		t.Error("go_ metrics should be dropped")
+	}
+	if _, _, keep := applyRelabel(rules, "a:1", "cpu", map[string]string{"env": "staging"}); keep {
+		t.Error("non-prod series should be dropped by keep")
+	}
+	if _, _, keep := applyRelabel(rules, "a:1", "cpu", map[string]string{"env": "prod"}); !keep {
+		t.Error("prod series should be kept")
+	}
+}
+
+func TestRelabelLabelMapAndKeep(t *testing.T) {
+	rules := mustRelabel(t, `
+relabel_configs:
+  - action: labelmap
+    regex: "k8s_(.+)"
+  - action: labelkeep
+    regex: "pod|namespace"
+`)
+	_, labels, _ := applyRelabel(rules, "a:1", "m", map[string]string{"k8s_pod": "p", "k8s_namespace": "n", "other": "x"})
+	if len(labels) != 2 || labels["pod"] != "p" || labels["namespace"] != "n" {
+		t.Errorf("labels = %v, want pod and namespace", labels)
+	}
+}
+
+func TestRelabelRenameMetric(t *testing.T) {
+	rules := mustRelabel(t, `
+relabel_configs:
+  - source_labels: [__name__]
+    regex: "legacy_(.*)"
+    target_label: __name__
+`)
+	name, _, _ := applyRelabel(rules, "a:1", "legacy_requests_total", nil)
+	if name != "requests_total" {
+		t.Errorf("name = %q, want requests_total", name)
+	}
+}
+
+func TestRelabelNoRules(t *testing.T) {
+	labels := map[string]string{"a": "1"}
+	name, got, keep := applyRelabel(nil, "a:1", "m", labels)
+	if name != "m" || !keep || got["a"] != "1" {
+		t.Errorf("applyRelabel(nil) = %q %v %v", name, got, keep)
+	}
+}
+
+func TestCompileRelabelErrors(t *testing.T) {
+	tests := []RelabelConfig{
+		{Action: "replace"},
+		{Action: "keep"},
+		{Action: "explode"},
+		{Action: "labeldrop", Regex: "("},
+	}
+	for _, c := range tests {
+		if _, err := compileRelabel([]RelabelConfig{c}); err == nil {
+			t.Errorf("compileRelabel(%+v) should fail", c)
+		}
+	}
+}
+
+func TestScrapeTargetAppliesRelabel(t *testing.T) {
+	defer initPatterns("")
+
+	path := filepath.Join(t.TempDir(), "cfg.yaml")
+	cfg := "relabel_configs:\n  - action: labeldrop\n    regex: id\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := initPatterns(path); err != nil {
+		t.Fatalf("initPatterns: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "# HELP cpu CPU\n# TYPE cpu gauge\ncpu{id=\"1\",env=\"prod\"} 5\n")
+	}))
+	defer srv.Close()
+
+	st := newStore()
+	scrapeTarget(&http.Client{}, target{addr: strings.TrimPrefix(srv.URL, "http://")}, st)
+	s := st.get("cpu{env=prod}")
+	if s == nil {
+		t.Fatalf("missing relabelled series, got %v", st.snapshot())
+	}
+	if s.help != "CPU" || s.mtype != "gauge" {
+		t.Errorf("metadata lost after relabel: help=%q type=%q", s.help, s.mtype)
+	}
+}
+
+func TestHonorLabels(t *testing.T) {
+	defer func() { globalHonorLabels = false }()
+
+	globalHonorLabels = true
+	got := (target{addr: "a:1", group: "api"}).attachLabels(map[string]string{"group": "exporter"})
+	if got["group"] != "exporter" {
+		t.Errorf("group = %q, want exporter label honoured", got["group"])
+	}
+	if _, ok := got["exported_group"]; ok {
+		t.Error("honor_labels should not add exported_group")
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var globalRemoteWriter *remoteWriter
+
+const (
+	remoteWriteInterval = 5 * time.Second
+	remoteWriteBatch    = 5000
+	remoteWriteMaxQueue = 100000
+	remoteWriteTimeout  = 10 * time.Second
+)
+
+type remoteWriter struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	queue   []sample
+	sent    int
+	dropped int
+	lastErr error
+}
+
+func newRemoteWriter(url string) *remoteWriter {
+	return &remoteWriter{url: url, client: &http.Client{Timeout: remoteWriteTimeout}}
+}
+
+func (rw *remoteWriter) write(s sample) {
+	if s.Annotation != "" || math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+		return
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.queue = append(rw.queue, s)
+	if over := len(rw.queue) - remoteWriteMaxQueue; over > 0 {
+		rw.queue = rw.queue[over:]
+		rw.dropped += over
+	}
+}
+
+func (rw *remoteWriter) run(ctx context.Context) {
+	ticker := time.NewTicker(remoteWriteInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), remoteWriteTimeout)
+			rw.flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			rw.flush(ctx)
+		}
+	}
+}
+
+func (rw *remoteWriter) flush(ctx context.Context) {
+	for {
+		rw.mu.Lock()
+		n := min(len(rw.queue), remoteWriteBatch)
+		batch := rw.queue[:n:n]
+		rw.queue = rw.queue[n:]
+		rw.mu.Unlock()
+		if n == 0 {
+			return
+		}
+
+		retry, err := rw.send(ctx, batch)
+		rw.mu.Lock()
+		rw.lastErr = err
+		switch {
+		case err == nil:
+			rw.sent += n
+		case retry:
+			rw.queue = append(batch, rw.queue...)
+			if over := len(rw.queue) - remoteWriteMaxQueue; over > 0 {
+				rw.queue = rw.queue[over:]
+				rw.dropped += over
+			}
+		default:
+			rw.dropped += n
+		}
+		rw.mu.Unlock()
+		if err != nil && retry {
+			return
+		}
+	}
+}
+
+func (rw *remoteWriter) attach(ctx context.Context, st *store) {
+	prev := st.observe
+	st.observe = func(s sample) {
+		if prev != nil {
+			prev(s)
+		}
+		rw.write(s)
+	}
+	go rw.run(ctx)
+}
+
+func (rw *remoteWriter) send(ctx context.Context, batch []sample) (bool, error) {
+	body := snappy.Encode(nil, encodeWriteRequest(batch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rw.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+func (rw *remoteWriter) status() string {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	s := fmt.Sprintf("RW: %d sent", rw.sent)
+	if rw.dropped > 0 {
+		s += fmt.Sprintf(", %d dropped", rw.dropped)
+	}
+	if rw.lastErr != nil {
+		s += ", error: " + rw.lastErr.Error()
+	}
+	return s
+}
+
+func (rw *remoteWriter) failing() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.lastErr != nil
+}
+
+// --- protobuf encoding (prometheus.WriteRequest) ---
+
+func encodeWriteRequest(samples []sample) []byte {
+	type series struct {
+		labels [][2]string
+		points []sample
+	}
+	bySeries := map[string]*series{}
+	var order []string
+	for _, s := range samples {
+		key := seriesKey(s.Name, s.Labels)
+		ts, ok := bySeries[key]
+		if !ok {
+			ts = &series{labels: [][2]string{{nameLabel, s.Name}}}
+			for k, v := range s.Labels {
+				ts.labels = append(ts.labels, [2]string{k, v})
+			}
+			sort.Slice(ts.labels, func(i, j int) bool { return ts.labels[i][0] < ts.labels[j][0] })
+			bySeries[key] = ts
+			order = append(order, key)
+		}
+		ts.points = append(ts.points, s)
+	}
+
+	var out []byte
+	for _, key := range order {
+		ts := bySeries[key]
+		var tsBuf []byte
+		for _, l := range ts.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l[0])
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l[1])
+			tsBuf = protowire.AppendTag(tsBuf, 1, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, lb)
+		}
+		for _, p := range ts.points {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(p.Value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(p.Time.UnixMilli()))
+			tsBuf = protowire.AppendTag(tsBuf, 2, protowire.BytesType)
+			tsBuf = protowire.AppendBytes(tsBuf, sb)
+		}
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, tsBuf)
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type decodedSeries struct {
+	labels map[string]string
+	values []float64
+	times  []int64
+}
+
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	t.Helper()
+	fields := func(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, u uint64)) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("bad tag")
+			}
+			b = b[n:]
+			switch typ {
+			case protowire.BytesType:
+				v, n := protowire.ConsumeBytes(b)
+				fn(num, typ, v, 0)
+				b = b[n:]
+			case protowire.Fixed64Type:
+				u, n := protowire.ConsumeFixed64(b)
+				fn(num, typ, nil, u)
+				b = b[n:]
+			case protowire.VarintType:
+				u, n := protowire.ConsumeVarint(b)
+				fn(num, typ, nil, u)
+				b = b[n:]
+			default:
+				t.Fatalf("unexpected wire type %v", typ)
+			}
+		}
+	}
+
+	var out []decodedSeries
+	fields(b, func(_ protowire.Number, _ protowire.Type, tsb []byte, _ uint64) {
+		ds := decodedSeries{labels: map[string]string{}}
+		fields(tsb, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				var name, value string
+				fields(v, func(num protowire.Number, _ protowire.Type, s []byte, _ uint64) {
+					if num == 1 {
+						name = string(s)
+					} else {
+						value = string(s)
+					}
+				})
+				ds.labels[name] = value
+			case 2:
+				fields(v, func(num protowire.Number, _ protowire.Type, _ []byte, u uint64) {
+					if num == 1 {
+						ds.values = append(ds.values, math.Float64frombits(u))
+					} else {
+						ds.times = append(ds.times, int64(u))
+					}
+				})
+			}
+		})
+		out = append(out, ds)
+	})
+	return out
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	t0 := time.UnixMilli(1700000000123)
+	got := decodeWriteRequest(t, encodeWriteRequest([]sample{
+		{Time: t0, Name: "up", Labels: map[string]string{"job": "api"}, Value: 1},
+		{Time: t0, Name: "temp", Value: 21.5},
+		{Time: t0.Add(time.Second), Name: "up", Labels: map[string]string{"job": "api"}, Value: 0},
+	}))
+	if len(got) != 2 {
+		t.Fatalf("series = %d, want 2", len(got))
+	}
+	up := got[0]
+	if up.labels["__name__"] != "up" || up.labels["job"] != "api" {
+		t.Errorf("labels = %v", up.labels)
+	}
+	if len(up.values) != 2 || up.values[0] != 1 || up.values[1] != 0 {
+		t.Errorf("values = %v", up.values)
+	}
+	if up.times[0] != 1700000000123 || up.times[1] != 1700000001123 {
+		t.Errorf("timestamps = %v", up.times)
+	}
+	if got[1].values[0] != 21.5 {
+		t.Errorf("temp = %v", got[1].values)
+	}
+}
+
+func TestRemoteWriterSends(t *testing.T) {
+	var mu sync.Mutex
+	var received []decodedSeries
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		raw, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("snappy: %v", err)
+		}
+		mu.Lock()
+		received = append(received, decodeWriteRequest(t, raw)...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	rw := newRemoteWriter(srv.URL)
+	st := newStore()
+	rw.attach(context.Background(), st)
+	st.update("up", nil, "", "gauge", 1)
+	st.annotate("paused")
+	st.update("bad", nil, "", "gauge", math.NaN())
+	rw.flush(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0].labels["__name__"] != "up" {
+		t.Errorf("received = %+v", received)
+	}
+	if got := rw.status(); got != "RW: 1 sent" {
+		t.Errorf("status = %q", got)
+	}
+}
+
+func TestRemoteWriterRetriesServerErrors(t *testing.T) {
+	var mu sync.Mutex
+	code := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	rw := newRemoteWriter(srv.URL)
+	rw.write(sample{Time: time.Now(), Name: "up", Value: 1})
+	rw.flush(context.Background())
+	if !rw.failing() || len(rw.queue) != 1 {
+		t.Fatalf("5xx should keep samples queued: failing=%v queue=%d", rw.failing(), len(rw.queue))
+	}
+
+	mu.Lock()
+	code = http.StatusBadRequest
+	mu.Unlock()
+	rw.flush(context.Background())
+	if len(rw.queue) != 0 || rw.dropped != 1 {
+		t.Errorf("4xx should drop samples: queue=%d dropped=%d", len(rw.queue), rw.dropped)
+	}
+
+	mu.Lock()
+	code = http.StatusOK
+	mu.Unlock()
+	rw.write(sample{Time: time.Now(), Name: "up", Value: 1})
+	rw.flush(context.Background())
+	if rw.failing() || rw.sent != 1 {
+		t.Errorf("after recovery: failing=%v sent=%d", rw.failing(), rw.sent)
+	}
+}
+
+func TestRemoteWriterQueueLimit(t *testing.T) {
+	rw := newRemoteWriter("http://unused")
+	for i := 0; i < remoteWriteMaxQueue+5; i++ {
+		rw.write(sample{Name: "m", Value: float64(i)})
+	}
+	if len(rw.queue) != remoteWriteMaxQueue || rw.dropped != 5 || rw.queue[0].Value != 5 {
+		t.Errorf("queue=%d dropped=%d first=%v", len(rw.queue), rw.dropped, rw.queue[0].Value)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type reportSeries struct {
+	key    string
+	name   string
+	labels map[string]string
+	mtype  string
+	values []float64
+	times  []time.Time
+}
+
+func (r *reportSeries) label() string {
+	return (&metricSeries{name: r.name, labels: r.labels}).displayName()
+}
+
+func (r *reportSeries) rated() bool {
+	return shouldRateType(r.name, r.mtype)
+}
+
+func (r *reportSeries) chartData() ([]float64, []time.Time) {
+	if !r.rated() {
+		return r.values, r.times
+	}
+	if len(r.values) < 2 {
+		return nil, nil
+	}
+	window := rateWindowGet()
+	rates := make([]float64, 0, len(r.values)-1)
+	for j := 1; j < len(r.values); j++ {
+		rates = append(rates, windowRate(r.values, r.times, j, window, nil))
+	}
+	return rates, r.times[1:]
+}
+
+func (r *reportSeries) format(v float64) string {
+	if r.rated() {
+		return formatGeneric(v) + "/s"
+	}
+	return formatValue(r.name, v)
+}
+
+type reportAlert struct {
+	re        *regexp.Regexp
+	expr      string
+	kind      watchKind
+	threshold float64
+}
+
+func parseReportAlert(expr string) (reportAlert, error) {
+	i := strings.IndexAny(expr, "<> ")
+	if i <= 0 {
+		return reportAlert{}, fmt.Errorf("invalid alert %q, want metric>N, metric<N or \"metric N%%\"", expr)
+	}
+	kind, threshold, err := parseWatch(expr[i:])
+	if err != nil {
+		return reportAlert{}, fmt.Errorf("alert %q: %w", expr, err)
+	}
+	re, err := regexp.Compile("^(?:" + strings.TrimSpace(expr[:i]) + ")$")
+	if err != nil {
+		return reportAlert{}, fmt.Errorf("alert %q: %w", expr, err)
+	}
+	return reportAlert{re: re, expr: expr, kind: kind, threshold: threshold}, nil
+}
+
+type reportEvent struct {
+	time time.Time
+	kind string
+	text string
+}
+
+type reportOptions struct {
+	title   string
+	metrics []*regexp.Regexp
+	from    time.Time
+	to      time.Time
+	alerts  []reportAlert
+}
+
+type reportMetric struct {
+	name   string
+	series []*reportSeries
+}
+
+type report struct {
+	title   string
+	source  string
+	from    time.Time
+	to      time.Time
+	metrics []reportMetric
+	events  []reportEvent
+}
+
+func parseReportTime(val string, origin time.Time) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want RFC3339 or an offset from the start of the recording like 5m", val)
+	}
+	return origin.Add(d), nil
+}
+
+func (o reportOptions) wantMetric(name string) bool {
+	if len(o.metrics) == 0 {
+		return true
+	}
+	for _, re := range o.metrics {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func buildReport(source string, samples []sample, opts reportOptions) *report {
+	rep := &report{title: opts.title, source: source, from: opts.from, to: opts.to}
+	if rep.title == "" {
+		rep.title = "madVisor report: " + filepath.Base(source)
+	}
+
+	bySeries := map[string]*reportSeries{}
+	byName := map[string][]*reportSeries{}
+	for _, s := range samples {
+		if !opts.from.IsZero() && s.Time.Before(opts.from) {
+			continue
+		}
+		if !opts.to.IsZero() && s.Time.After(opts.to) {
+			continue
+		}
+		if s.Annotation != "" {
+			rep.events = append(rep.events, reportEvent{time: s.Time, kind: "annotation", text: s.Annotation})
+			continue
+		}
+		if !opts.wantMetric(s.Name) {
+			continue
+		}
+		key := seriesKey(s.Name, s.Labels)
+		rs, ok := bySeries[key]
+		if !ok {
+			rs = &reportSeries{key: key, name: s.Name, labels: s.Labels, mtype: s.Type}
+			bySeries[key] = rs
+			byName[s.Name] = append(byName[s.Name], rs)
+		}
+		rs.values = append(rs.values, s.Value)
+		rs.times = append(rs.times, s.Time)
+		if rep.from.IsZero() || s.Time.Before(rep.from) {
+			rep.from = s.Time
+		}
+		if s.Time.After(rep.to) {
+			rep.to = s.Time
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		list := byName[name]
+		sort.Slice(list, func(i, j int) bool { return list[i].key < list[j].key })
+		rep.metrics = append(rep.metrics, reportMetric{name: name, series: list})
+
+		for _, a := range opts.alerts {
+			if !a.re.MatchString(name) {
+				continue
+			}
+			for _, rs := range list {
+				w := &watch{label: rs.label(), kind: a.kind, threshold: a.threshold}
+				data, times := rs.chartData()
+				for j, v := range data {
+					if msg, fired := w.check(v); fired {
+						rep.events = append(rep.events, reportEvent{time: times[j], kind: "alert " + a.expr, text: msg})
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(rep.events, func(i, j int) bool { return rep.events[i].time.Before(rep.events[j].time) })
+	return rep
+}
+
+func (rep *report) annotations() []annotation {
+	var out []annotation
+	for _, e := range rep.events {
+		if e.kind == "annotation" {
+			out = append(out, annotation{Time: e.time, Text: e.text})
+		}
+	}
+	return out
+}
+
+func (m reportMetric) chart(format string, anns []annotation) ([]byte, error) {
+	lines := make([]chartLine, 0, len(m.series))
+	for _, rs := range m.series {
+		data, times := rs.chartData()
+		lines = append(lines, chartLine{label: rs.label(), values: data, times: times})
+	}
+	var buf bytes.Buffer
+	err := renderChartImage(&buf, format, m.name, chartUnitFor(m.name, m.series[0].rated()), lines, anns)
+	return buf.Bytes(), err
+}
+
+func (m reportMetric) statsRows() [][]string {
+	var rows [][]string
+	for _, rs := range m.series {
+		data, _ := rs.chartData()
+		s, ok := statsOf(data)
+		if !ok {
+			rows = append(rows, []string{rs.label(), "-", "-", "-", "-", fmt.Sprint(len(rs.values))})
+			continue
+		}
+		rows = append(rows, []string{rs.label(), rs.format(s.min), rs.format(s.avg), rs.format(s.max), rs.format(s.last), fmt.Sprint(len(rs.values))})
+	}
+	return rows
+}
+
+var reportStatsHeader = []string{"Series", "Min", "Avg", "Max", "Last", "Samples"}
+
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func writeMarkdown(w io.Writer, rep *report, chartPath func(i int, m reportMetric) (string, error)) error {
+	fmt.Fprintf(w, "# %s\n\n", rep.title)
+	fmt.Fprintf(w, "- Source: `%s`\n", rep.source)
+	fmt.Fprintf(w, "- Range: %s → %s (%s)\n", rep.from.Format(time.RFC3339), rep.to.Format(time.RFC3339), rep.to.Sub(rep.from).Round(time.Second))
+	fmt.Fprintf(w, "- Metrics: %d\n\n", len(rep.metrics))
+
+	fmt.Fprintf(w, "## Events\n\n")
+	if len(rep.events) == 0 {
+		fmt.Fprintf(w, "No events.\n\n")
+	} else {
+		fmt.Fprintf(w, "| Time | Kind | Event |\n|---|---|---|\n")
+		for _, e := range rep.events {
+			fmt.Fprintf(w, "| %s | %s | %s |\n", e.time.Format(time.RFC3339), mdEscape(e.kind), mdEscape(e.text))
+		}
+		fmt.Fprintln(w)
+	}
+
+	for i, m := range rep.metrics {
+		fmt.Fprintf(w, "## %s\n\n", m.name)
+		if chartPath != nil {
+			path, err := chartPath(i, m)
+			if err != nil {
+				return fmt.Errorf("chart %s: %w", m.name, err)
+			}
+			fmt.Fprintf(w, "![%s](%s)\n\n", m.name, path)
+		}
+		fmt.Fprintf(w, "| %s |\n|%s\n", strings.Join(reportStatsHeader, " | "), strings.Repeat("---|", len(reportStatsHeader)))
+		for _, row := range m.statsRows() {
+			for j := range row {
+				row[j] = mdEscape(row[j])
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func writeHTML(w io.Writer, rep *report) error {
+	esc := html.EscapeString
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", esc(rep.title))
+	fmt.Fprint(w, "<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:2em}"+
+		"th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}td.num{text-align:right}svg{max-width:100%;height:auto}</style>\n</head>\n<body>\n")
+	fmt.Fprintf(w, "<h1>%s</h1>\n<ul>\n", esc(rep.title))
+	fmt.Fprintf(w, "<li>Source: <code>%s</code></li>\n", esc(rep.source))
+	fmt.Fprintf(w, "<li>Range: %s → %s (%s)</li>\n", rep.from.Format(time.RFC3339), rep.to.Format(time.RFC3339), rep.to.Sub(rep.from).Round(time.Second))
+	fmt.Fprintf(w, "<li>Metrics: %d</li>\n</ul>\n", len(rep.metrics))
+
+	fmt.Fprint(w, "<h2>Events</h2>\n")
+	if len(rep.events) == 0 {
+		fmt.Fprint(w, "<p>No events.</p>\n")
+	} else {
+		fmt.Fprint(w, "<table>\n<tr><th>Time</th><th>Kind</th><th>Event</th></tr>\n")
+		for _, e := range rep.events {
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>\n", e.time.Format(time.RFC3339), esc(e.kind), esc(e.text))
+		}
+		fmt.Fprint(w, "</table>\n")
+	}
+
+	anns := rep.annotations()
+	for _, m := range rep.metrics {
+		fmt.Fprintf(w, "<h2>%s</h2>\n", esc(m.name))
+		svg, err := m.chart("svg", anns)
+		if err != nil {
+			return fmt.Errorf("chart %s: %w", m.name, err)
+		}
+		if i := bytes.Index(svg, []byte("<svg")); i >= 0 {
+			svg = svg[i:]
+		}
+		w.Write(svg)
+		fmt.Fprint(w, "\n<table>\n<tr>")
+		for _, h := range reportStatsHeader {
+			fmt.Fprintf(w, "<th>%s</th>", h)
+		}
+		fmt.Fprint(w, "</tr>\n")
+		for _, row := range m.statsRows() {
+			fmt.Fprintf(w, "<tr><td>%s</td>", esc(row[0]))
+			for _, c := range row[1:] {
+				fmt.Fprintf(w, "<td class=\"num\">%s</td>", esc(c))
+			}
+			fmt.Fprint(w, "</tr>\n")
+		}
+		fmt.Fprint(w, "</table>\n")
+	}
+	_, err := fmt.Fprint(w, "</body>\n</html>\n")
+	return err
+}
+
+func writeReport(rep *report, format, output string, stdout io.Writer) error {
+	if output == "" || output == "-" {
+		if format == "html" {
+			return writeHTML(stdout, rep)
+		}
+		return writeMarkdown(stdout, rep, nil)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+	defer f.Close()
+	if format == "html" {
+		err = writeHTML(f, rep)
+	} else {
+		base := strings.TrimSuffix(output, filepath.Ext(output))
+		anns := rep.annotations()
+		err = writeMarkdown(f, rep, func(i int, m reportMetric) (string, error) {
+			path := fmt.Sprintf("%s-%d.svg", base, i+1)
+			svg, err := m.chart("svg", anns)
+			if err != nil {
+				return "", err
+			}
+			if err := os.WriteFile(path, svg, 0o644); err != nil {
+				return "", err
+			}
+			return filepath.Base(path), nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
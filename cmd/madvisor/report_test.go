@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func reportSamples(origin time.Time) []sample {
+	var out []sample
+	for i := 0; i < 10; i++ {
+		t := origin.Add(time.Duration(i) * time.Second)
+		out = append(out,
+			sample{Time: t, Name: "queue_depth", Labels: map[string]string{"q": "a"}, Type: "gauge", Value: float64(i * 10)},
+			sample{Time: t, Name: "requests_total", Type: "counter", Value: float64(i * 5)},
+		)
+	}
+	out = append(out, sample{Time: origin.Add(4 * time.Second), Annotation: "paused"})
+	return out
+}
+
+func TestBuildReport(t *testing.T) {
+	origin := time.Unix(1700000000, 0)
+	rep := buildReport("rec.ndjson", reportSamples(origin), reportOptions{})
+	if len(rep.metrics) != 2 || rep.metrics[0].name != "queue_depth" {
+		t.Fatalf("metrics = %+v", rep.metrics)
+	}
+	if !rep.from.Equal(origin) || !rep.to.Equal(origin.Add(9*time.Second)) {
+		t.Errorf("range = %s → %s", rep.from, rep.to)
+	}
+	if len(rep.events) != 1 || rep.events[0].kind != "annotation" {
+		t.Errorf("events = %+v", rep.events)
+	}
+
+	rows := rep.metrics[0].statsRows()
+	if len(rows) != 1 || rows[0][1] != formatValue("queue_depth", 0) || rows[0][3] != formatValue("queue_depth", 90) || rows[0][5] != "10" {
+		t.Errorf("gauge stats = %v", rows)
+	}
+	rows = rep.metrics[1].statsRows()
+	if rows[0][1] != "5.00/s" || rows[0][3] != "5.00/s" {
+		t.Errorf("counter stats should be rates, got %v", rows)
+	}
+}
+
+func TestBuildReportFilters(t *testing.T) {
+	origin := time.Unix(1700000000, 0)
+	opts := reportOptions{
+		metrics: []*regexp.Regexp{regexp.MustCompile("^queue")},
+		from:    origin.Add(2 * time.Second),
+		to:      origin.Add(5 * time.Second),
+	}
+	rep := buildReport("rec.ndjson", reportSamples(origin), opts)
+	if len(rep.metrics) != 1 || rep.metrics[0].name != "queue_depth" {
+		t.Fatalf("metrics = %+v", rep.metrics)
+	}
+	if n := len(rep.metrics[0].series[0].values); n != 4 {
+		t.Errorf("samples in range = %d, want 4", n)
+	}
+}
+
+func TestBuildReportAlerts(t *testing.T) {
+	origin := time.Unix(1700000000, 0)
+	alert, err := parseReportAlert("queue_depth>45")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := buildReport("rec.ndjson", reportSamples(origin), reportOptions{alerts: []reportAlert{alert}})
+	var alerts []reportEvent
+	for _, e := range rep.events {
+		if strings.HasPrefix(e.kind, "alert") {
+			alerts = append(alerts, e)
+		}
+	}
+	if len(alerts) != 1 || !alerts[0].time.Equal(origin.Add(5*time.Second)) {
+		t.Errorf("alerts = %+v", alerts)
+	}
+}
+
+func TestParseReportAlert(t *testing.T) {
+	for _, good := range []string{"up<1", "http_.*_total>100", "queue_depth 10%"} {
+		if _, err := parseReportAlert(good); err != nil {
+			t.Errorf("parseReportAlert(%q): %v", good, err)
+		}
+	}
+	for _, bad := range []string{"", ">5", "up", "up 0%", "(>5"} {
+		if _, err := parseReportAlert(bad); err == nil {
+			t.Errorf("parseReportAlert(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseReportTime(t *testing.T) {
+	origin := time.Unix(1700000000, 0)
+	if got, _ := parseReportTime("90s", origin); !got.Equal(origin.Add(90 * time.Second)) {
+		t.Errorf("offset = %s", got)
+	}
+	if got, _ := parseReportTime("2024-01-02T03:04:05Z", origin); !got.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("rfc3339 = %s", got)
+	}
+	if _, err := parseReportTime("yesterday", origin); err == nil {
+		t.Error("invalid time should fail")
+	}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	rep := buildReport("rec.ndjson", reportSamples(time.Unix(1700000000, 0)), reportOptions{title: "Incident"})
+	path := filepath.Join(t.TempDir(), "incident.md")
+	if err := writeReport(rep, "md", path, nil); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{"# Incident", "## Events", "| annotation | paused |", "## queue_depth", "![queue_depth](incident-1.svg)", "| Series | Min | Avg | Max | Last | Samples |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown missing %q:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "incident-2.svg")); err != nil {
+		t.Errorf("chart file not written: %v", err)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	rep := buildReport("rec.ndjson", reportSamples(time.Unix(1700000000, 0)), reportOptions{title: "A <b> report"})
+	var buf bytes.Buffer
+	if err := writeReport(rep, "html", "-", &buf); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "<h1>A &lt;b&gt; report</h1>") {
+		t.Error("title should be escaped")
+	}
+	if strings.Count(out, "<svg") != 2 || strings.Contains(out, "<?xml") {
+		t.Errorf("expected 2 inline svg charts without XML prolog")
+	}
+}
+
+func TestCLIReport(t *testing.T) {
+	dir := t.TempDir()
+	var b bytes.Buffer
+	rec := newRecorder(&b)
+	for _, s := range reportSamples(time.Now()) {
+		rec.write(s)
+	}
+	path := filepath.Join(dir, "rec.ndjson")
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := executeCmd(t, "report", path, "--metric", "requests", "--alert", "requests_total<1")
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if !strings.Contains(out, "## requests_total") || strings.Contains(out, "## queue_depth") {
+		t.Errorf("report output =\n%s", out)
+	}
+
+	html := filepath.Join(dir, "r.html")
+	if _, err := executeCmd(t, "report", path, "-o", html); err != nil {
+		t.Fatalf("report html: %v", err)
+	}
+	if data, _ := os.ReadFile(html); !bytes.HasPrefix(data, []byte("<!DOCTYPE html>")) {
+		t.Error("format should be inferred from the .html extension")
+	}
+
+	if _, err := executeCmd(t, "report", path, "--metric", "nomatch"); err == nil {
+		t.Error("report with no matching metrics should fail")
+	}
+}
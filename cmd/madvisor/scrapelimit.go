@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultMaxScrapeSize = "32MiB"
+
+// maxScrapeBytes caps how much of a scrape response is parsed (0 = no limit).
+var maxScrapeBytes int64
+
+var errBodyTruncated = errors.New("response body exceeds --max-scrape-size")
+
+var byteSuffixes = []struct {
+	suffix string
+	mult   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// parseByteSize accepts a plain byte count or one with a K/M/G suffix
+// (KiB/MiB/GiB and bare K/M/G are binary, KB/MB/GB decimal).
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	mult := int64(1)
+	for _, b := range byteSuffixes {
+		if strings.HasSuffix(s, b.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, b.suffix)), b.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, want e.g. 512KiB, 32MiB or 0", s)
+	}
+	return n * mult, nil
+}
+
+// limitedBody reads at most left bytes and then fails with errBodyTruncated
+// if the underlying body had more, so the parser can drop the partial line.
+type limitedBody struct {
+	r    io.Reader
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left <= 0 {
+		var one [1]byte
+		for {
+			n, err := b.r.Read(one[:])
+			if n > 0 {
+				return 0, errBodyTruncated
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
+
+func limitBody(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &limitedBody{r: r, left: limit}
+}
+
+func (st *store) recordTruncation(addr string, at time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.health == nil {
+		st.health = map[string]*targetHealth{}
+	}
+	h := st.health[addr]
+	if h == nil {
+		h = &targetHealth{}
+		st.health[addr] = h
+	}
+	h.truncated++
+	h.lastTruncated = at
+}
+
+func (h targetHealth) truncationSummary(now time.Time) string {
+	if h.truncated == 0 {
+		return ""
+	}
+	return fmt.Sprintf("⚠ body truncated at %s (%d×, last %s ago)",
+		formatBytes(float64(maxScrapeBytes)), h.truncated, formatRelDuration(now.Sub(h.lastTruncated)))
+}
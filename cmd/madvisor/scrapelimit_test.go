@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{
+		"0":      0,
+		"1024":   1024,
+		"512KiB": 512 << 10,
+		"32MiB":  32 << 20,
+		"2 GiB":  2 << 30,
+		"10MB":   10 * 1000 * 1000,
+		"4M":     4 << 20,
+		"100B":   100,
+	}
+	for in, want := range cases {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "big", "-1", "1.5MiB", "MiB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestTruncatedBodyDropsPartialLine(t *testing.T) {
+	body := "a 1\nb 2\nc 12345\n"
+	var got []string
+	err := parseExposition(limitBody(strings.NewReader(body), int64(len("a 1\nb 2\nc 12"))), func(name string, labels map[string]string, help, mtype string, val float64) {
+		got = append(got, fmt.Sprintf("%s=%g", name, val))
+	})
+	if err != errBodyTruncated {
+		t.Errorf("err = %v, want errBodyTruncated", err)
+	}
+	if strings.Join(got, " ") != "a=1 b=2" {
+		t.Errorf("samples = %v; the cut-off line must not be ingested", got)
+	}
+
+	got = nil
+	if err := parseExposition(limitBody(strings.NewReader(body), int64(len(body))), func(name string, labels map[string]string, help, mtype string, val float64) {
+		got = append(got, name)
+	}); err != nil || len(got) != 3 {
+		t.Errorf("body exactly at the limit: %v, %v", got, err)
+	}
+}
+
+func TestParseExpositionLongLine(t *testing.T) {
+	long := strings.Repeat("x", 100_000)
+	body := fmt.Sprintf("before 1\nlong{path=%q} 2\nafter 3", long)
+	var names []string
+	if err := parseExposition(strings.NewReader(body), func(name string, labels map[string]string, help, mtype string, val float64) {
+		names = append(names, name)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "before,long,after" {
+		t.Errorf("names = %v", names)
+	}
+}
+
+func TestScrapeTargetTruncation(t *testing.T) {
+	defer func(n int64) { maxScrapeBytes = n }(maxScrapeBytes)
+	maxScrapeBytes = 64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(w, "series_%d %d\n", i, i)
+		}
+	}))
+	defer srv.Close()
+
+	st := newStore()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	scrapeTarget(&http.Client{Timeout: time.Second}, target{addr: addr}, st)
+	h := st.targetHealth(addr)
+	if h.ok != 1 || h.truncated != 1 {
+		t.Fatalf("health = %+v, want one ok, truncated scrape", h)
+	}
+	if n := len(st.names()); n == 0 || n >= 100 {
+		t.Errorf("ingested %d metrics, want a prefix", n)
+	}
+	if s := h.truncationSummary(time.Now()); !strings.Contains(s, "64 B") {
+		t.Errorf("summary = %q", s)
+	}
+
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderTargets(w, st, []target{{addr: addr}}, time.Now())
+}
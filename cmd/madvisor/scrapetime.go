@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	scrapeDurationMetric = "scrape_duration_seconds"
+	scrapeDurationHelp   = "Duration of the scrape of the target in seconds (synthetic)"
+
+	// scrapeDurationSamples is how many recent scrape durations per target
+	// the targets panel summarizes.
+	scrapeDurationSamples = 60
+)
+
+// slowScrape is the p99 scrape duration from which a target's exporter is
+// flagged as a bottleneck: half the interval leaves little slack before
+// scrapes overlap.
+var slowScrape = scrapeInterval / 2
+
+// scrapeDurations is a fixed-size ring, so targetHealth stays safe to copy
+// out of the store.
+type scrapeDurations struct {
+	vals [scrapeDurationSamples]time.Duration
+	n    int
+	next int
+}
+
+func (d *scrapeDurations) add(v time.Duration) {
+	d.vals[d.next] = v
+	d.next = (d.next + 1) % len(d.vals)
+	d.n = min(d.n+1, len(d.vals))
+}
+
+// seconds returns the durations oldest first.
+func (d scrapeDurations) seconds() []float64 {
+	out := make([]float64, 0, d.n)
+	start := (d.next - d.n + len(d.vals)) % len(d.vals)
+	for i := 0; i < d.n; i++ {
+		out = append(out, d.vals[(start+i)%len(d.vals)].Seconds())
+	}
+	return out
+}
+
+// quantiles returns the p50 and p99 of the durations in seconds.
+func (d scrapeDurations) quantiles() (p50, p99 float64, ok bool) {
+	if d.n == 0 {
+		return 0, 0, false
+	}
+	sorted := d.seconds()
+	sort.Float64s(sorted)
+	return percentile(sorted, 50), percentile(sorted, 99), true
+}
+
+// recordScrapeDuration keeps d in tgt's health and stores it as a synthetic
+// scrape_duration_seconds series, as Prometheus does, so it can be charted
+// next to the exporter's own metrics.
+func (st *store) recordScrapeDuration(tgt target, d time.Duration, at time.Time) {
+	st.mu.Lock()
+	if st.health == nil {
+		st.health = map[string]*targetHealth{}
+	}
+	h := st.health[tgt.key()]
+	if h == nil {
+		h = &targetHealth{}
+		st.health[tgt.key()] = h
+	}
+	h.durations.add(d)
+	st.mu.Unlock()
+
+	labels := tgt.attachLabels(map[string]string{instanceLabel: tgt.addr})
+	st.ingest(tgt.key(), scrapeDurationMetric, labels, scrapeDurationHelp, "gauge", d.Seconds(), at)
+}
+
+// durationSummary is the targets panel's scrape latency column: p50 and p99
+// over the recent scrapes and a trend sparkline. slow reports a p99 at or
+// above slowScrape.
+func (h targetHealth) durationSummary() (s string, slow bool) {
+	p50, p99, ok := h.durations.quantiles()
+	if !ok {
+		return "", false
+	}
+	s = fmt.Sprintf("p50 %s p99 %s %s", formatDuration(p50), formatDuration(p99), sparkline(h.durations.seconds(), sparkWidth))
+	return s, p99 >= slowScrape.Seconds()
+}
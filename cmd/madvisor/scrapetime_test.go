@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeDurationsRing(t *testing.T) {
+	var d scrapeDurations
+	if _, _, ok := d.quantiles(); ok {
+		t.Error("empty ring should have no quantiles")
+	}
+	for i := 1; i <= scrapeDurationSamples+5; i++ {
+		d.add(time.Duration(i) * time.Millisecond)
+	}
+	got := d.seconds()
+	if len(got) != scrapeDurationSamples || got[0] != 0.006 || got[len(got)-1] != 0.065 {
+		t.Errorf("seconds = %d values from %v to %v, want the last %d oldest first", len(got), got[0], got[len(got)-1], scrapeDurationSamples)
+	}
+	p50, p99, ok := d.quantiles()
+	if !ok || p50 < 0.035 || p50 > 0.036 || p99 < 0.064 || p99 > 0.065 {
+		t.Errorf("quantiles = %v, %v, %v", p50, p99, ok)
+	}
+}
+
+func TestRecordScrapeDuration(t *testing.T) {
+	st := newStore()
+	tgt := target{addr: "db:9187", group: "prod"}
+	now := time.Now()
+	st.recordScrapeDuration(tgt, 20*time.Millisecond, now)
+	st.recordScrapeDuration(tgt, 800*time.Millisecond, now.Add(time.Second))
+
+	s := st.get(seriesKey(scrapeDurationMetric, map[string]string{instanceLabel: "db:9187", groupLabel: "prod"}))
+	if s == nil {
+		t.Fatal("missing scrape_duration_seconds series")
+	}
+	if got := s.slice(); len(got) != 2 || got[0] != 0.02 || got[1] != 0.8 {
+		t.Errorf("durations = %v, want [0.02 0.8]", got)
+	}
+	if st.hasData() {
+		t.Error("the synthetic scrape_duration_seconds series should not count as data")
+	}
+	sum, slow := st.targetHealth("db:9187").durationSummary()
+	if !strings.HasPrefix(sum, "p50 410.0ms p99 792.2ms ") || !slow {
+		t.Errorf("durationSummary = %q, %v; want a slow p50/p99 summary", sum, slow)
+	}
+	if sum, slow := (targetHealth{}).durationSummary(); sum != "" || slow {
+		t.Errorf("no scrapes: durationSummary = %q, %v", sum, slow)
+	}
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type scriptCmd struct {
+	line int
+	name string
+	args []string
+}
+
+var scriptArgs = map[string]int{
+	"filter":    1,
+	"select":    1,
+	"rate":      1,
+	"group":     1,
+	"focus":     1,
+	"clip":      0,
+	"dual":      0,
+	"zero":      0,
+	"rates":     0,
+	"yrange":    1,
+	"sort":      1,
+	"expr":      1,
+	"allow":     1,
+	"deny":      1,
+	"transform": 1,
+	"export":    1,
+	"table":     1,
+	"target":    1,
+	"all":       1,
+	"silence":   1,
+	"unsilence": 1,
+	"baseline":  1,
+	"annotate":  1,
+	"unit":      1,
+	"pin":       1,
+	"dashboard": 1,
+	"theme":     1,
+}
+
+func parseScript(r io.Reader) ([]scriptCmd, error) {
+	var cmds []scriptCmd
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, err := parseScriptLine(lineNo, line)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cmds, nil
+}
+
+func parseScriptLine(lineNo int, line string) (scriptCmd, error) {
+	fields := strings.Fields(line)
+	name := strings.ToLower(fields[0])
+	rest := strings.TrimSpace(line[len(fields[0]):])
+
+	want, ok := scriptArgs[name]
+	if !ok {
+		return scriptCmd{}, fmt.Errorf("line %d: unknown command %q", lineNo, fields[0])
+	}
+	var args []string
+	if rest != "" {
+		args = []string{rest}
+	}
+	if len(args) != want {
+		return scriptCmd{}, fmt.Errorf("line %d: %s expects %d argument(s)", lineNo, name, want)
+	}
+
+	switch name {
+	case "rate":
+		d, err := time.ParseDuration(rest)
+		if err != nil || d <= 0 {
+			return scriptCmd{}, fmt.Errorf("line %d: invalid rate window %q", lineNo, rest)
+		}
+	case "sort":
+		if _, err := parseTableSort(rest); err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "yrange":
+		if _, _, err := parseYRange(rest); err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "expr":
+		if rest != "clear" {
+			if _, err := parseExpr(rest); err != nil {
+				return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+	case "allow", "deny":
+		if rest != "clear" {
+			if _, err := compileMetricRule(rest); err != nil {
+				return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+	case "unit":
+		if _, err := lookupUnit(rest); rest != "clear" && err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "theme":
+		if err := lookupTheme(rest); err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "dashboard":
+		if _, _, err := parseDashboard(rest); err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "baseline":
+		if rest != "restart" {
+			return scriptCmd{}, fmt.Errorf("line %d: baseline expects restart, got %q", lineNo, rest)
+		}
+	case "focus":
+		if rest != "metrics" && rest != "series" {
+			return scriptCmd{}, fmt.Errorf("line %d: focus expects metrics or series, got %q", lineNo, rest)
+		}
+	case "transform":
+		if _, err := parseTransform(rest); err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "export":
+		if f := strings.Fields(rest); len(f) > 2 || (f[0] != "png" && f[0] != "svg" && f[0] != "csv" && f[0] != "json") {
+			return scriptCmd{}, fmt.Errorf("line %d: export expects png, svg, csv or json and an optional path, got %q", lineNo, rest)
+		}
+	case "table":
+		if f := strings.Fields(rest); len(f) > 2 || (f[0] != "md" && f[0] != "text") {
+			return scriptCmd{}, fmt.Errorf("line %d: table expects md or text and an optional path, got %q", lineNo, rest)
+		}
+	case "target":
+		if f := strings.Fields(rest); len(f) != 2 || (f[0] != "add" && f[0] != "remove") {
+			return scriptCmd{}, fmt.Errorf("line %d: target expects add or remove and an address, got %q", lineNo, rest)
+		} else if f[0] == "add" {
+			if _, err := parseTargetList(f[1]); err != nil {
+				return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+	case "silence":
+		if _, _, err := parseSilence(rest); err != nil {
+			return scriptCmd{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	case "unsilence":
+		if _, _, err := parseSilence(rest); err != nil || len(strings.Fields(rest)) != 1 {
+			return scriptCmd{}, fmt.Errorf("line %d: unsilence expects a watch number or all, got %q", lineNo, rest)
+		}
+	case "all":
+		sub, err := parseScriptLine(lineNo, rest)
+		if err != nil {
+			return scriptCmd{}, err
+		}
+		if !bulkCommands[sub.name] {
+			return scriptCmd{}, fmt.Errorf("line %d: all applies export, clip, transform or unit, not %q", lineNo, sub.name)
+		}
+	}
+	return scriptCmd{line: lineNo, name: name, args: args}, nil
+}
+
+func loadScript(path string) ([]scriptCmd, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open init script %q: %w", path, err)
+	}
+	defer f.Close()
+	cmds, err := parseScript(f)
+	if err != nil {
+		return nil, fmt.Errorf("init script %q: %w", path, err)
+	}
+	return cmds, nil
+}
+
+// startupScript is the --init script followed by the --run commands.
+func startupScript() ([]scriptCmd, error) {
+	cmds, err := loadScript(flagInit)
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range flagRun {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cmd, err := parseScriptLine(i+1, strings.TrimSpace(line))
+		if err != nil {
+			return nil, fmt.Errorf("--run: %w", err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func runScript(cmds []scriptCmd, ui *uiState, st *store) []error {
+	var errs []error
+	for _, c := range cmds {
+		arg := ""
+		if len(c.args) > 0 {
+			arg = c.args[0]
+		}
+		switch c.name {
+		case "filter":
+			if arg == "clear" {
+				arg = ""
+			}
+			ui.setFilter(arg)
+		case "select":
+			names, _, _ := listKeys(st, ui, ui.group())
+			ui.setKeys(names)
+			if base, ok := familyBase(arg, st.firstType(arg)); ok && !ui.selectName(arg) {
+				ui.setFamilyExpanded(base, true)
+				names, _, _ = listKeys(st, ui, ui.group())
+				ui.setKeys(names)
+			}
+			if !ui.selectName(arg) {
+				errs = append(errs, fmt.Errorf("line %d: metric %q not found", c.line, arg))
+			}
+		case "rate":
+			d, _ := time.ParseDuration(arg)
+			changeRateWindow(st, func() time.Duration {
+				rateWindowSet(d)
+				return rateWindowGet()
+			})
+		case "group":
+			ui.setGroup(arg)
+			ui.setKeys(visibleNames(st, arg))
+		case "focus":
+			if arg == "series" {
+				ui.setFocus(focusSeriesTable)
+			} else {
+				ui.setFocus(focusSidebar)
+			}
+		case "clip":
+			if name := ui.selectedKey(); name != "" {
+				ui.toggleClip(name)
+			}
+		case "pin":
+			switch {
+			case arg == "clear":
+				ui.clearPinned()
+				ui.setMessage("metric list unpinned")
+			case ui.togglePinned(arg):
+				ui.setMessage("pinned " + arg + " to the top of the metric list")
+			default:
+				ui.setMessage("unpinned " + arg)
+			}
+			names, _, _ := listKeys(st, ui, ui.group())
+			ui.setKeys(names)
+		case "dashboard":
+			rows, cols, _ := parseDashboard(arg)
+			ui.setDashboard(rows, cols)
+			if rows == 0 {
+				ui.setMessage("chart: overlay")
+			} else {
+				ui.setMessage(fmt.Sprintf("chart: %s in a %d×%d grid", ui.multiplesMode(), rows, cols))
+			}
+		case "theme":
+			setTheme(arg)
+			ui.setMessage("theme: " + arg)
+		case "dual":
+			ui.toggleDual()
+		case "zero":
+			ui.toggleYZero()
+		case "rates":
+			toggleRateColumns()
+		case "sort":
+			m, _ := parseTableSort(arg)
+			ui.setSort(m)
+			ui.setMessage(sortMessage(m))
+		case "yrange":
+			if name := ui.selectedKey(); name != "" {
+				r, pin, _ := parseYRange(arg)
+				ui.setYPin(name, r, pin)
+			}
+		case "expr":
+			if arg == "clear" {
+				ui.setMessage(fmt.Sprintf("expression cleared, %d temporary series dropped", st.setExpr(nil)))
+				continue
+			}
+			q, _ := parseExpr(arg)
+			st.setExpr(q)
+			n := st.evalExpr(time.Now())
+			names, _, _ := listKeys(st, ui, ui.group())
+			ui.setKeys(names)
+			ui.selectName(q.name())
+			ui.setMessage(exprMessage(q, n))
+		case "allow", "deny":
+			if arg == "clear" {
+				setMetricRule(c.name, nil)
+				ui.setMessage(c.name + " rules cleared, new samples are stored again")
+			} else {
+				re, _ := compileMetricRule(arg)
+				setMetricRule(c.name, re)
+				n, m := st.compact()
+				ui.setMessage(compactMessage(c.name+" "+arg, n, m))
+			}
+			names, _, _ := listKeys(st, ui, ui.group())
+			ui.setKeys(names)
+		case "transform":
+			if name := ui.selectedKey(); name != "" {
+				t, _ := parseTransform(arg)
+				ui.setTransform(name, t)
+			}
+		case "unit":
+			if name := ui.selectedKey(); name != "" {
+				overrideUnit(name, arg)
+				ui.setMessage(unitMessage(name, arg))
+			}
+		case "export":
+			f := strings.Fields(arg)
+			name, list := chartSelection(ui, st)
+			var path string
+			var err error
+			if len(f) == 2 {
+				path, err = f[1], exportChartFile(f[1], f[0], name, list, st)
+			} else {
+				path, err = exportChart(flagExportDir, f[0], name, list, st)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: export: %w", c.line, err))
+			} else {
+				ui.setMessage("chart exported to " + path)
+			}
+		case "table":
+			f := append(strings.Fields(arg), "")
+			if msg, err := copySeriesTable(ui, st, f[0], f[1]); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: table: %w", c.line, err))
+			} else {
+				ui.setMessage(msg)
+			}
+		case "all":
+			sub, _ := parseScriptLine(c.line, arg)
+			if err := runBulk(sub, ui, st); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: all %s: %w", c.line, sub.name, err))
+			}
+		case "silence":
+			sel, d, _ := parseSilence(arg)
+			if n, err := ui.silenceWatches(sel, d, time.Now()); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: silence: %w", c.line, err))
+			} else {
+				ui.setMessage(fmt.Sprintf("silenced %d watch(es) for %s", n, d))
+			}
+		case "unsilence":
+			if n, err := ui.unsilenceWatches(arg); err != nil {
+				errs = append(errs, fmt.Errorf("line %d: unsilence: %w", c.line, err))
+			} else {
+				ui.setMessage(fmt.Sprintf("unsilenced %d watch(es)", n))
+			}
+		case "baseline":
+			b := globalBaseline
+			if b == nil {
+				errs = append(errs, fmt.Errorf("line %d: baseline: no --baseline recording loaded", c.line))
+				continue
+			}
+			b.restart(time.Now())
+			if ui.panel() != panelBaseline {
+				ui.togglePanel(panelBaseline)
+			}
+			ui.setMessage("baseline replay restarted from its beginning")
+		case "annotate":
+			st.annotate(arg)
+			ui.setMessage("annotated: " + arg)
+		case "target":
+			f := strings.Fields(arg)
+			if f[0] == "remove" {
+				addr := f[1]
+				if t, err := parseTarget(addr); err == nil {
+					addr = t.key()
+				}
+				st.removeTarget(addr)
+				ui.setMessage("stopped scraping " + f[1])
+				continue
+			}
+			ts, _ := parseTargetList(f[1])
+			for _, t := range ts {
+				if st.addTarget(t) {
+					ui.setMessage("scraping " + t.addr)
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScript(t *testing.T) {
+	src := `# runbook for the API latency alert
+filter http_
+select http_requests_total
+
+rate 10s
+focus series
+dual
+`
+	cmds, err := parseScript(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("parseScript: %v", err)
+	}
+	if len(cmds) != 5 {
+		t.Fatalf("len = %d, want 5", len(cmds))
+	}
+	if cmds[0].name != "filter" || cmds[0].args[0] != "http_" {
+		t.Errorf("cmds[0] = %+v, want filter http_", cmds[0])
+	}
+	if cmds[2].line != 5 {
+		t.Errorf("cmds[2].line = %d, want 5", cmds[2].line)
+	}
+	if cmds[4].name != "dual" || len(cmds[4].args) != 0 {
+		t.Errorf("cmds[4] = %+v, want dual", cmds[4])
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"unknown command", "zoom 2x\n"},
+		{"missing argument", "filter\n"},
+		{"extra argument", "dual now\n"},
+		{"bad duration", "rate fast\n"},
+		{"bad focus", "focus chart\n"},
+		{"bad transform", "transform log\n"},
+		{"bad export format", "export jpg\n"},
+		{"bad target action", "target list host:9100\n"},
+		{"target without address", "target add\n"},
+		{"bad dashboard grid", "dashboard big\n"},
+		{"dashboard grid too large", "dashboard 4x4\n"},
+		{"pin without metric", "pin\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseScript(strings.NewReader(tt.src)); err == nil {
+				t.Errorf("parseScript(%q) should fail", tt.src)
+			}
+		})
+	}
+}
+
+func TestLoadScript(t *testing.T) {
+	if cmds, err := loadScript(""); err != nil || cmds != nil {
+		t.Errorf("loadScript(\"\") = %v, %v; want nil, nil", cmds, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "init.mv")
+	if err := os.WriteFile(path, []byte("rate 30s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmds, err := loadScript(path)
+	if err != nil || len(cmds) != 1 {
+		t.Errorf("loadScript = %v, %v; want 1 command", cmds, err)
+	}
+
+	if _, err := loadScript(filepath.Join(t.TempDir(), "missing.mv")); err == nil {
+		t.Error("loadScript on missing file should fail")
+	}
+}
+
+func TestStartupScript(t *testing.T) {
+	defer func() { flagInit, flagRun = "", nil }()
+	flagInit = filepath.Join(t.TempDir(), "init.mv")
+	if err := os.WriteFile(flagInit, []byte("rate 30s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	flagRun = []string{"filter ^cpu$", "", "select cpu"}
+	cmds, err := startupScript()
+	if err != nil || len(cmds) != 3 || cmds[0].name != "rate" || cmds[2].name != "select" {
+		t.Errorf("startupScript = %+v, %v; want the --init command then the --run commands", cmds, err)
+	}
+
+	flagRun = []string{"bogus"}
+	if _, err := startupScript(); err == nil || !strings.Contains(err.Error(), "--run") {
+		t.Errorf("startupScript with an unknown --run command = %v", err)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+
+	st := newStore()
+	st.update("cpu_usage", map[string]string{"group": "api"}, "", "gauge", 1)
+	st.update("http_requests_total", map[string]string{"group": "api"}, "", "counter", 1)
+	st.update("http_errors_total", map[string]string{"group": "api"}, "", "counter", 1)
+	st.update("pg_up", map[string]string{"group": "db"}, "", "gauge", 1)
+
+	ui := &uiState{}
+	ui.setKeys(st.names())
+
+	cmds, err := parseScript(strings.NewReader("group api\nfilter http_\nselect http_requests_total\nrate 10s\nfocus series\nclip\ntransform derivative\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, st); len(errs) != 0 {
+		t.Fatalf("runScript errors: %v", errs)
+	}
+
+	if ui.group() != "api" {
+		t.Errorf("group = %q, want api", ui.group())
+	}
+	if got := ui.selectedKey(); got != "http_requests_total" {
+		t.Errorf("selected = %q, want http_requests_total", got)
+	}
+	_, _, _, filter, filterMode := ui.snapshot()
+	if filter != "http_" || filterMode {
+		t.Errorf("filter = %q (mode %v), want http_ and not editing", filter, filterMode)
+	}
+	if got := rateWindowGet(); got != 10*time.Second {
+		t.Errorf("rate window = %s, want 10s", got)
+	}
+	if _, _, focus, _ := ui.seriesSnapshot(); focus != focusSeriesTable {
+		t.Error("focus should be series table")
+	}
+	if !ui.clipEnabled("http_requests_total") {
+		t.Error("clip should be enabled on the selected chart")
+	}
+	if got := ui.transformFor("http_requests_total"); got != transformDerivative {
+		t.Errorf("transform = %s, want derivative", got)
+	}
+}
+
+func TestRunScriptSelectMissing(t *testing.T) {
+	st := newStore()
+	st.update("cpu", nil, "", "gauge", 1)
+	ui := &uiState{}
+
+	cmds, _ := parseScript(strings.NewReader("select nope\n"))
+	errs := runScript(cmds, ui, st)
+	if len(errs) != 1 {
+		t.Errorf("errs = %v, want 1 error for missing metric", errs)
+	}
+}
+
+func TestRunScriptPinAndDashboard(t *testing.T) {
+	st := newStore()
+	for _, name := range []string{"cpu", "disk", "mem"} {
+		st.update(name, nil, "", "gauge", 1)
+	}
+	ui := &uiState{}
+
+	cmds, err := parseScript(strings.NewReader("pin mem\ndashboard 2x2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, st); len(errs) != 0 {
+		t.Fatalf("runScript errors: %v", errs)
+	}
+	if names, _, _ := listKeys(st, ui, ""); strings.Join(names, ",") != "mem,cpu,disk" {
+		t.Errorf("metric list = %v, want mem pinned first", names)
+	}
+	if rows, cols := ui.multiplesGrid(); ui.multiplesMode() != multiplesOwnScale || rows != 2 || cols != 2 {
+		t.Errorf("dashboard = %s %d×%d, want small multiples 2×2", ui.multiplesMode(), rows, cols)
+	}
+
+	cmds, _ = parseScript(strings.NewReader("pin mem\ndashboard off\n"))
+	runScript(cmds, ui, st)
+	if names, _, _ := listKeys(st, ui, ""); strings.Join(names, ",") != "cpu,disk,mem" {
+		t.Errorf("metric list = %v, want mem unpinned", names)
+	}
+	if rows, _ := ui.multiplesGrid(); ui.multiplesMode() != multiplesOff || rows != 0 {
+		t.Errorf("dashboard off left %s, %d rows", ui.multiplesMode(), rows)
+	}
+}
+
+func TestRunScriptTargetAndExport(t *testing.T) {
+	st := newStore()
+	st.update("cpu", nil, "", "gauge", 1)
+	st.update("cpu", nil, "", "gauge", 2)
+	ui := &uiState{}
+	path := filepath.Join(t.TempDir(), "cpu.csv")
+
+	cmds, err := parseScript(strings.NewReader("target add node:9100\ntarget remove localhost:8080\nselect cpu\nexport csv " + path + "\nfilter cpu\nfilter clear\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, st); len(errs) != 0 {
+		t.Fatalf("runScript errors: %v", errs)
+	}
+
+	got := st.activeTargets([]target{{addr: "localhost:8080"}})
+	if len(got) != 1 || got[0].addr != "node:9100" {
+		t.Errorf("active targets = %v, want node:9100 only", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 || lines[0] != "series,timestamp,value,annotation" {
+		t.Errorf("csv = %q, want header and 2 samples", data)
+	}
+	if _, _, _, filter, _ := ui.snapshot(); filter != "" {
+		t.Errorf("filter = %q, want cleared", filter)
+	}
+}
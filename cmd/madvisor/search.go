@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// filterMatcher is the metric list's name test for text: a case-insensitive
+// regex, or a plain substring match (reported as not ok) while text is not
+// yet a valid regex.
+func filterMatcher(text string) (func(string) bool, bool) {
+	re, err := regexp.Compile("(?i)" + text)
+	if err != nil {
+		lower := strings.ToLower(text)
+		return func(k string) bool { return strings.Contains(strings.ToLower(k), lower) }, false
+	}
+	return re.MatchString, true
+}
+
+// startSearch opens the filter prompt in highlight mode: the list keeps every
+// name, matches are highlighted and n/N step through them.
+func (u *uiState) startSearch() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.highlight = true
+	u.filterMode = true
+	u.applyFilter()
+}
+
+// toggleHighlightFilter switches / filters between hiding non-matching names
+// and only highlighting matches. An applied filter switches in place, keeping
+// the selection where it can. It returns the new mode.
+func (u *uiState) toggleHighlightFilter() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.highlightFilter = !u.highlightFilter
+	if u.highlight == u.highlightFilter {
+		return u.highlightFilter
+	}
+	selected := ""
+	if u.selectedIdx >= 0 && u.selectedIdx < len(u.filtered) {
+		selected = u.filtered[u.selectedIdx]
+	}
+	u.highlight = u.highlightFilter
+	u.applyFilter()
+	for i, k := range u.filtered {
+		if k == selected {
+			u.selectedIdx = i
+			u.adjustScroll()
+			return u.highlightFilter
+		}
+	}
+	u.selectedIdx = 0
+	u.adjustScroll()
+	return u.highlightFilter
+}
+
+// searching reports whether a highlight search is applied, i.e. n/N jump
+// between matches rather than starting a type-ahead.
+func (u *uiState) searching() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.highlight && u.filterText != "" && !u.filterMode
+}
+
+func (u *uiState) searchSnapshot() (highlight bool, matches map[string]bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.highlight, u.matches
+}
+
+// nextMatch selects the next (dir 1) or previous (dir -1) match after the
+// selection, wrapping around the list, or with dir 0 the first match at or
+// after it. It reports whether there was a match to select.
+func (u *uiState) nextMatch(dir int) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.seekMatchLocked(dir)
+}
+
+func (u *uiState) seekMatchLocked(dir int) bool {
+	n := len(u.filtered)
+	if len(u.matches) == 0 || n == 0 {
+		return false
+	}
+	step, start := dir, u.selectedIdx+dir
+	if dir == 0 {
+		step, start = 1, u.selectedIdx
+	}
+	for i := 0; i < n; i++ {
+		idx := ((start+i*step)%n + n) % n
+		if u.matches[u.filtered[idx]] {
+			if idx != u.selectedIdx {
+				u.selectedIdx = idx
+				u.seriesIdx = 0
+				u.seriesScroll = 0
+				u.tableScroll = 0
+				u.adjustScroll()
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// matchPosition is the selection's place among the filter's matches: with
+// highlight off every listed name is a match. pos is 0 when the selection
+// is not a match.
+func matchPosition(filtered []string, selIdx int, matches map[string]bool) (pos, total int) {
+	if matches == nil {
+		if selIdx >= 0 && selIdx < len(filtered) {
+			pos = selIdx + 1
+		}
+		return pos, len(filtered)
+	}
+	for i, name := range filtered {
+		if !matches[name] {
+			continue
+		}
+		total++
+		if i == selIdx {
+			pos = total
+		}
+	}
+	return pos, total
+}
+
+func highlightFilterMessage(highlight bool) string {
+	if highlight {
+		return "filter: highlight matches, keep the full list"
+	}
+	return "filter: hide non-matching metrics"
+}
+
+func matchCounter(pos, total int) string {
+	switch {
+	case total == 0:
+		return "no matches"
+	case pos == 0:
+		return fmt.Sprintf("match –/%d", total)
+	}
+	return fmt.Sprintf("match %d/%d", pos, total)
+}
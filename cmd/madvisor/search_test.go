@@ -0,0 +1,137 @@
+package main
+
+import "testing"
+
+func TestUIStateSearchKeepsList(t *testing.T) {
+	u := &uiState{}
+	u.setKeys([]string{"cpu_seconds", "http_errors_total", "http_requests_total", "queue_errors_total"})
+
+	u.startSearch()
+	for _, r := range "errors" {
+		u.addFilterChar(r)
+	}
+	filtered, selIdx, _, _, _ := u.snapshot()
+	if len(filtered) != 4 {
+		t.Fatalf("filtered = %v, want the full list", filtered)
+	}
+	if filtered[selIdx] != "http_errors_total" {
+		t.Errorf("selected %q, want the first match", filtered[selIdx])
+	}
+	highlight, matches := u.searchSnapshot()
+	if !highlight || len(matches) != 2 || !matches["queue_errors_total"] {
+		t.Errorf("highlight = %v, matches = %v", highlight, matches)
+	}
+	if u.searching() {
+		t.Error("n/N should not jump while the prompt is open")
+	}
+	u.mu.Lock()
+	u.filterMode = false
+	u.mu.Unlock()
+	if !u.searching() {
+		t.Error("search should be active once the prompt is closed")
+	}
+}
+
+func TestUIStateNextMatchWraps(t *testing.T) {
+	u := &uiState{}
+	u.setKeys([]string{"a_err", "b", "c_err", "d"})
+	u.startSearch()
+	for _, r := range "err" {
+		u.addFilterChar(r)
+	}
+	want := []string{"c_err", "a_err", "c_err"}
+	for _, w := range want {
+		u.nextMatch(1)
+		if got := u.selectedKey(); got != w {
+			t.Errorf("n selected %q, want %q", got, w)
+		}
+	}
+	u.nextMatch(-1)
+	if got := u.selectedKey(); got != "a_err" {
+		t.Errorf("N selected %q, want a_err", got)
+	}
+
+	u.clearFilter()
+	u.startSearch()
+	u.addFilterChar('z')
+	if u.nextMatch(1) {
+		t.Error("nextMatch with no matches should report false")
+	}
+}
+
+func TestUIStateFilterAfterSearch(t *testing.T) {
+	u := &uiState{}
+	u.setKeys([]string{"a_err", "b", "c_err"})
+	u.startSearch()
+	u.addFilterChar('e')
+	u.startFilter()
+	filtered, _, _, _, _ := u.snapshot()
+	if len(filtered) != 2 {
+		t.Errorf("/ after a search should hide non-matches, got %v", filtered)
+	}
+	if highlight, _ := u.searchSnapshot(); highlight {
+		t.Error("/ should leave highlight mode")
+	}
+}
+
+func TestMatchPosition(t *testing.T) {
+	list := []string{"a", "b", "c", "d"}
+	tests := []struct {
+		selIdx     int
+		matches    map[string]bool
+		pos, total int
+		counter    string
+	}{
+		{2, nil, 3, 4, "match 3/4"},
+		{2, map[string]bool{"b": true, "c": true, "d": true}, 2, 3, "match 2/3"},
+		{0, map[string]bool{"b": true}, 0, 1, "match –/1"},
+		{0, map[string]bool{}, 0, 0, "no matches"},
+	}
+	for _, tt := range tests {
+		pos, total := matchPosition(list, tt.selIdx, tt.matches)
+		if pos != tt.pos || total != tt.total || matchCounter(pos, total) != tt.counter {
+			t.Errorf("matchPosition(%d, %v) = %d/%d %q, want %d/%d %q", tt.selIdx, tt.matches, pos, total, matchCounter(pos, total), tt.pos, tt.total, tt.counter)
+		}
+	}
+}
+
+func TestUIStateToggleHighlightFilter(t *testing.T) {
+	u := &uiState{}
+	u.setKeys([]string{"a_err", "b", "c_err"})
+	u.startFilter()
+	for _, r := range "err" {
+		u.addFilterChar(r)
+	}
+	u.moveDown()
+	if got := u.selectedKey(); got != "c_err" {
+		t.Fatalf("selected %q, want c_err", got)
+	}
+
+	if !u.toggleHighlightFilter() {
+		t.Fatal("toggle should turn highlight mode on")
+	}
+	filtered, _, _, filter, _ := u.snapshot()
+	if len(filtered) != 3 || filter != "err" || u.selectedKey() != "c_err" {
+		t.Errorf("highlight mode: filtered %v, filter %q, selected %q", filtered, filter, u.selectedKey())
+	}
+	if _, matches := u.searchSnapshot(); len(matches) != 2 {
+		t.Errorf("matches = %v", matches)
+	}
+
+	// The mode sticks for the next / filter.
+	u.clearFilter()
+	u.startFilter()
+	u.addFilterChar('b')
+	if filtered, _, _, _, _ := u.snapshot(); len(filtered) != 3 {
+		t.Errorf("next / filter should highlight, got %v", filtered)
+	}
+
+	u.moveDown()
+	u.moveDown()
+	if u.toggleHighlightFilter() {
+		t.Fatal("toggle should turn highlight mode off")
+	}
+	if filtered, _, _, _, _ := u.snapshot(); len(filtered) != 1 || u.selectedKey() != "b" {
+		t.Errorf("hide mode: filtered %v, selected %q", filtered, u.selectedKey())
+	}
+}
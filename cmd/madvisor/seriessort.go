@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// tableSort orders the series table. The default ranks counters by their
+// current rate, busiest first, and lists other metrics by labels.
+type tableSort int
+
+const (
+	sortRate tableSort = iota
+	sortValue
+	sortLabels
+)
+
+var tableSortNames = []string{"rate", "value", "labels"}
+
+func (m tableSort) String() string { return tableSortNames[m] }
+
+func parseTableSort(s string) (tableSort, error) {
+	for i, name := range tableSortNames {
+		if s == name {
+			return tableSort(i), nil
+		}
+	}
+	return 0, fmt.Errorf("sort expects rate, value or labels, got %q", s)
+}
+
+func (u *uiState) cycleSort() tableSort {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.tableSort = (u.tableSort + 1) % tableSort(len(tableSortNames))
+	return u.tableSort
+}
+
+func (u *uiState) setSort(m tableSort) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.tableSort = m
+}
+
+func (u *uiState) sortMode() tableSort {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.tableSort
+}
+
+// sortLabel describes how a metric's table is ordered, for its header.
+func sortLabel(m tableSort, counter bool) string {
+	switch {
+	case m == sortValue:
+		return "by value ↓"
+	case m == sortRate && counter:
+		return "by rate ↓"
+	}
+	return "by labels"
+}
+
+func sortMessage(m tableSort) string {
+	if m == sortRate {
+		return "series table: counters by rate ↓, other metrics by labels"
+	}
+	return "series table: " + sortLabel(m, false)
+}
+
+// sortSeries returns the series of one metric, which the store keeps by
+// labels, in table order. list is left untouched since chart colours follow
+// it. Values that are not numbers sort last.
+func sortSeries(list []*metricSeries, m tableSort) []*metricSeries {
+	if m == sortLabels || m == sortRate && (len(list) == 0 || !list[0].shouldRate()) {
+		return list
+	}
+	out := append([]*metricSeries(nil), list...)
+	rank := make(map[*metricSeries]float64, len(out))
+	for _, s := range out {
+		v := s.last()
+		if m == sortRate {
+			v = s.rate(rateWindowGet())
+		}
+		if math.IsNaN(v) {
+			v = math.Inf(-1)
+		}
+		rank[s] = v
+	}
+	sort.SliceStable(out, func(i, j int) bool { return rank[out[i]] > rank[out[j]] })
+	return out
+}
+
+// tableSeries is the series table of a metric within group, as shown.
+func tableSeries(st *store, name, group string, m tableSort) []*metricSeries {
+	return sortSeries(filterGroup(st.seriesForName(name), group), m)
+}
+
+// shownTable is the series table as last drawn. Row numbers index it, so a
+// row picks the series the user saw there even after rates reorder them.
+type shownTable struct {
+	name, group string
+	sort        tableSort
+	list        []*metricSeries
+}
+
+func (u *uiState) setShownTable(t shownTable) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.shownTable = t
+}
+
+// displayedSeries is the selected metric's series table as last drawn, or
+// freshly sorted when it has not been drawn yet.
+func displayedSeries(ui *uiState, st *store) []*metricSeries {
+	name, group, m := ui.selectedKey(), ui.group(), ui.sortMode()
+	ui.mu.Lock()
+	t := ui.shownTable
+	ui.mu.Unlock()
+	if t.list != nil && t.name == name && t.group == group && t.sort == m {
+		return t.list
+	}
+	return tableSeries(st, name, group, m)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func sortedCodes(list []*metricSeries) string {
+	var codes []string
+	for _, s := range list {
+		codes = append(codes, s.labels["code"])
+	}
+	return strings.Join(codes, ",")
+}
+
+func TestSortSeries(t *testing.T) {
+	st := newStore()
+	now := time.Now()
+	for i, code := range []string{"b", "c", "a"} {
+		rate := []float64{5, 50, 1}[i]
+		for j := 0; j < 3; j++ {
+			st.ingest("a:1", "requests_total", map[string]string{"code": code}, "", "counter", rate*float64(j), now.Add(time.Duration(j)*time.Second))
+		}
+		st.ingest("a:1", "queue_depth", map[string]string{"code": code}, "", "gauge", float64(i), now)
+	}
+	counters := st.seriesForName("requests_total")
+	if got := sortedCodes(sortSeries(counters, sortRate)); got != "c,b,a" {
+		t.Errorf("by rate = %s, want the busiest first", got)
+	}
+	if got := sortedCodes(counters); got != "a,b,c" {
+		t.Errorf("sorting changed the stored order to %s", got)
+	}
+	if got := sortedCodes(sortSeries(counters, sortLabels)); got != "a,b,c" {
+		t.Errorf("by labels = %s", got)
+	}
+	gauges := st.seriesForName("queue_depth")
+	if got := sortedCodes(sortSeries(gauges, sortRate)); got != "a,b,c" {
+		t.Errorf("gauges by default = %s, want label order", got)
+	}
+	if got := sortedCodes(sortSeries(gauges, sortValue)); got != "a,c,b" {
+		t.Errorf("gauges by value = %s", got)
+	}
+}
+
+func TestSortKeyAndScript(t *testing.T) {
+	ui := &uiState{}
+	if ui.sortMode() != sortRate {
+		t.Fatal("series table should sort by rate by default")
+	}
+	if ui.cycleSort() != sortValue || ui.cycleSort() != sortLabels || ui.cycleSort() != sortRate {
+		t.Error("O should cycle rate, value, labels")
+	}
+	if _, err := parseScriptLine(1, "sort size"); err == nil {
+		t.Error("unknown sort accepted")
+	}
+	cmds, err := parseScript(strings.NewReader("sort labels\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	runScript(cmds, ui, newStore())
+	if ui.sortMode() != sortLabels || ui.message != "series table: by labels" {
+		t.Errorf("sort labels = %v, %q", ui.sortMode(), ui.message)
+	}
+}
+
+func TestSelectionUsesShownTable(t *testing.T) {
+	st := newStore()
+	now := time.Now()
+	for i, code := range []string{"a", "b"} {
+		rate := []float64{1, 5}[i]
+		for j := 0; j < 3; j++ {
+			st.ingest("a:1", "requests_total", map[string]string{"code": code}, "", "counter", rate*float64(j), now.Add(time.Duration(j)*time.Second))
+		}
+	}
+	ui := &uiState{}
+	ui.setKeys([]string{"requests_total"})
+	ui.setFocus(focusSeriesTable)
+	fr := prepareFrame(st, frameRequest{name: "requests_total", focus: focusSeriesTable})
+	shown := fr.table(st, "requests_total", "", sortRate)
+	ui.setShownTable(shownTable{name: "requests_total", sort: sortRate, list: shown})
+	if got := sortedCodes(shown); got != "b,a" || fr.chartSeries[0] != shown[0] {
+		t.Fatalf("shown = %s charting %v, want b first and charted", got, fr.chartSeries[0].labels)
+	}
+
+	// a overtakes b before the next frame; row 0 is still b on screen.
+	st.ingest("a:1", "requests_total", map[string]string{"code": "a"}, "", "counter", 100, now.Add(3*time.Second))
+	if got := sortedCodes(tableSeries(st, "requests_total", "", sortRate)); got != "a,b" {
+		t.Fatalf("resorted = %s, want the rates to have swapped", got)
+	}
+	if s := selectedSeries(ui, st); s == nil || s.labels["code"] != "b" {
+		t.Errorf("selected = %v, want b from the row shown", s)
+	}
+	if _, list := chartSelection(ui, st); len(list) != 1 || list[0].labels["code"] != "b" {
+		t.Errorf("chart selection = %v, want b from the row shown", list)
+	}
+}
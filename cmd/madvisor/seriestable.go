@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	maxLabelColumnWidth = 24
+	sparkWidth          = 12
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// tableColumn is one column of the series table. Cells are sized to the widest
+// entry (or the title), capped at max runes and truncated with an ellipsis.
+type tableColumn struct {
+	title string
+	right bool
+	max   int
+	cells []string
+}
+
+func (c *tableColumn) width() int {
+	w := utf8.RuneCountInString(c.title)
+	for _, s := range c.cells {
+		if n := utf8.RuneCountInString(s); n > w {
+			w = n
+		}
+	}
+	if c.max > 0 && w > c.max {
+		w = c.max
+	}
+	return w
+}
+
+func (c *tableColumn) format(s string, width int) string {
+	s = truncateText(s, width)
+	if c.right {
+		return padLeft(s, width)
+	}
+	return padRight(s, width)
+}
+
+func (c *tableColumn) empty() bool {
+	for _, s := range c.cells {
+		if s != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func padLeft(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return strings.Repeat(" ", width-n) + s
+	}
+	return s
+}
+
+func sparkline(data []float64, width int) string {
+	var pts []float64
+	for _, v := range data {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			pts = append(pts, v)
+		}
+	}
+	if len(pts) > width {
+		pts = pts[len(pts)-width:]
+	}
+	if len(pts) == 0 {
+		return ""
+	}
+	lo, hi := pts[0], pts[0]
+	for _, v := range pts {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	out := make([]rune, len(pts))
+	for i, v := range pts {
+		idx := 0
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		out[i] = sparkBlocks[idx]
+	}
+	return string(out)
+}
+
+func minMax(data []float64) (float64, float64, bool) {
+	lo, hi, ok := math.Inf(1), math.Inf(-1), false
+	for _, v := range data {
+		if math.IsNaN(v) {
+			continue
+		}
+		lo, hi, ok = math.Min(lo, v), math.Max(hi, v), true
+	}
+	return lo, hi, ok
+}
+
+// labelKeys is the sorted union of label keys across the series.
+func labelKeys(list []*metricSeries) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, s := range list {
+		for k := range s.labels {
+			if !seen[k] && !labelHidden(s.name, k) {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// commonLabels reports which of keys carry the same value on every series in
+// list. With fewer than two series nothing is compared and none are common.
+func commonLabels(list []*metricSeries, keys []string) map[string]bool {
+	common := map[string]bool{}
+	if len(list) < 2 {
+		return common
+	}
+	for _, k := range keys {
+		v, ok := list[0].labels[k]
+		same := true
+		for _, s := range list[1:] {
+			if w, has := s.labels[k]; has != ok || w != v {
+				same = false
+				break
+			}
+		}
+		common[k] = same
+	}
+	return common
+}
+
+// seriesColumns builds the table columns for a page of series. The first
+// hscroll label columns are skipped so wide label sets can be scrolled.
+// With rate columns on, counters get one rate column per window instead of
+// one over the current rate window. The Δ column shows the direction and
+// percent change over the rate window.
+func seriesColumns(page []*metricSeries, keys []string, hscroll int) []*tableColumn {
+	var cols []*tableColumn
+	if hscroll < len(keys) {
+		keys = keys[hscroll:]
+	} else {
+		keys = nil
+	}
+	for _, k := range keys {
+		c := &tableColumn{title: k, max: maxLabelColumnWidth}
+		for _, s := range page {
+			c.cells = append(c.cells, s.labels[k])
+		}
+		cols = append(cols, c)
+	}
+
+	value := &tableColumn{title: "value", right: true}
+	raw := &tableColumn{title: "raw", right: true}
+	delta := &tableColumn{title: "Δ " + shortDuration(rateWindowGet()), right: true}
+	rate := &tableColumn{title: "rate", right: true}
+	windows := rateColumnWindows()
+	rates := make([]*tableColumn, len(windows))
+	for i, d := range windows {
+		rates[i] = &tableColumn{title: "rate " + shortDuration(d), right: true}
+	}
+	lo := &tableColumn{title: "min", right: true}
+	hi := &tableColumn{title: "max", right: true}
+	spark := &tableColumn{title: "trend"}
+	for _, s := range page {
+		v := s.last()
+		formatted := formatValue(s.name, v)
+		value.cells = append(value.cells, formatted)
+		if f, err := strconv.ParseFloat(formatted, 64); err != nil || f != v {
+			raw.cells = append(raw.cells, formatRaw(v))
+		} else {
+			raw.cells = append(raw.cells, "")
+		}
+		delta.cells = append(delta.cells, deltaCell(seriesDelta(s, rateWindowGet())))
+
+		data, _ := chartData(s)
+		format := func(v float64) string { return formatValue(s.name, v) }
+		if s.shouldRate() {
+			rate.cells = append(rate.cells, formatGeneric(s.rate(rateWindowGet()))+"/s")
+			for i, d := range windows {
+				rates[i].cells = append(rates[i].cells, formatGeneric(s.rate(d))+"/s")
+			}
+			format = func(v float64) string { return formatGeneric(v) + "/s" }
+		} else {
+			rate.cells = append(rate.cells, "")
+			for _, c := range rates {
+				c.cells = append(c.cells, "")
+			}
+		}
+		if l, h, ok := minMax(data); ok {
+			lo.cells = append(lo.cells, format(l))
+			hi.cells = append(hi.cells, format(h))
+		} else {
+			lo.cells = append(lo.cells, "")
+			hi.cells = append(hi.cells, "")
+		}
+		spark.cells = append(spark.cells, sparkline(data, sparkWidth))
+	}
+	rateCols := []*tableColumn{rate}
+	if len(rates) > 0 {
+		rateCols = rates
+	}
+	for _, c := range append(append([]*tableColumn{value, raw, delta}, rateCols...), lo, hi, spark) {
+		if !c.empty() {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// scrollTable moves the series table horizontally by whole label columns.
+func (u *uiState) scrollTable(delta, limit int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.tableScroll += delta
+	if u.tableScroll > limit {
+		u.tableScroll = limit
+	}
+	if u.tableScroll < 0 {
+		u.tableScroll = 0
+	}
+}
+
+func (u *uiState) tableOffset() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.tableScroll
+}
+
+func renderSeriesTable(w *text.Text, st *store, metricName string, seriesList []*metricSeries, seriesIdx int, seriesScroll int, focus focusPanel, group string, hscroll int, order tableSort) {
+	w.Reset()
+
+	if metricName == "" {
+		w.Write("  select a metric name", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+
+	if len(seriesList) == 0 {
+		w.Write("  no series for "+metricName, text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+		return
+	}
+
+	mtype := st.firstType(metricName)
+	w.Write(fmt.Sprintf(" %s %s — %d series", metricTypeBadge(mtype), metricName, len(seriesList)),
+		text.WriteCellOpts(cell.FgColor(cell.ColorCyan)))
+	if len(seriesList) > 1 {
+		w.Write(" · "+sortLabel(order, seriesList[0].shouldRate()), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+	}
+	w.Write("\n")
+
+	if seriesList[0].help != "" {
+		w.Write(" "+seriesList[0].help+"\n", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	}
+	renderSLO(w, st, metricName, mtype, group)
+	w.Write("\n")
+
+	pageSize := 10
+	end := len(seriesList)
+	if end > seriesScroll+pageSize {
+		end = seriesScroll + pageSize
+	}
+
+	keys := labelKeys(seriesList)
+	if hscroll > len(keys)-1 {
+		hscroll = max(len(keys)-1, 0)
+	}
+	page := seriesList[seriesScroll:end]
+	cols := seriesColumns(page, keys, hscroll)
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		widths[i] = c.width()
+	}
+
+	header := "  "
+	if hscroll > 0 {
+		header = "◂ "
+	}
+	// Labels shared by every series are dimmed so the ones that tell the
+	// rows apart stand out.
+	common := commonLabels(seriesList, keys)
+	labelCols := len(keys) - hscroll
+	dim := cell.ColorNumber(245)
+	w.Write(header, text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	for i, c := range cols {
+		sep := ""
+		if i > 0 {
+			sep = "  "
+		}
+		color := cell.ColorYellow
+		if i < labelCols && common[c.title] {
+			color = dim
+		}
+		w.Write(sep+c.format(c.title, widths[i]), text.WriteCellOpts(cell.FgColor(color)))
+	}
+	if hscroll > 0 {
+		w.Write(fmt.Sprintf("   (%d label column(s) hidden, ←)", hscroll), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	}
+	w.Write("\n")
+
+	if seriesScroll > 0 {
+		w.Write(fmt.Sprintf("  ↑ %d more\n", seriesScroll), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	}
+
+	for j := range page {
+		i := seriesScroll + j
+		prefix := "  "
+		fg := cell.ColorWhite
+		if i == seriesIdx && focus == focusSeriesTable {
+			prefix = "▶ "
+			fg = cell.ColorCyan
+		}
+
+		w.Write(prefix, text.WriteCellOpts(cell.FgColor(fg)))
+		for c, col := range cols {
+			sep := ""
+			if c > 0 {
+				sep = "  "
+			}
+			opts := []cell.Option{cell.FgColor(fg)}
+			switch {
+			case c >= labelCols:
+				opts = []cell.Option{cell.FgColor(cell.ColorGreen)}
+			case common[col.title]:
+				opts = []cell.Option{cell.FgColor(dim)}
+			case len(common) > 0:
+				opts = append(opts, cell.Bold())
+			}
+			w.Write(sep+col.format(col.cells[j], widths[c]), text.WriteCellOpts(opts...))
+		}
+		w.Write("\n")
+	}
+
+	if end < len(seriesList) {
+		w.Write(fmt.Sprintf("  ↓ %d more\n", len(seriesList)-end), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPadding(t *testing.T) {
+	if got := padRight("ab", 4); got != "ab  " {
+		t.Errorf("padRight = %q", got)
+	}
+	if got := padLeft("ab", 4); got != "  ab" {
+		t.Errorf("padLeft = %q", got)
+	}
+	if got := padLeft("abcdef", 4); got != "abcdef" {
+		t.Errorf("padLeft overflow = %q", got)
+	}
+}
+
+func TestTableColumnWidth(t *testing.T) {
+	c := &tableColumn{title: "pod", cells: []string{"a", "web-1"}}
+	if got := c.width(); got != 5 {
+		t.Errorf("width = %d, want 5", got)
+	}
+	c.cells = append(c.cells, strings.Repeat("x", 40))
+	c.max = 10
+	if got := c.width(); got != 10 {
+		t.Errorf("capped width = %d, want 10", got)
+	}
+	if got := c.format(c.cells[2], 10); got != "xxxxxxxxx…" {
+		t.Errorf("format = %q", got)
+	}
+	r := &tableColumn{title: "value", right: true}
+	if got := r.format("7", 5); got != "    7" {
+		t.Errorf("right aligned = %q", got)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 8); got != "▁▂▃▄▅▆▇█" {
+		t.Errorf("sparkline = %q", got)
+	}
+	if got := sparkline([]float64{3, math.NaN(), 3}, 8); got != "▁▁" {
+		t.Errorf("flat sparkline = %q", got)
+	}
+	if got := sparkline([]float64{1, 2, 3, 4}, 2); got != "▁█" {
+		t.Errorf("clipped sparkline = %q", got)
+	}
+	if got := sparkline(nil, 4); got != "" {
+		t.Errorf("empty sparkline = %q", got)
+	}
+}
+
+func TestSeriesColumns(t *testing.T) {
+	a := newTestSeries("queue_depth", map[string]string{"pod": "web-1", "zone": "a"})
+	b := newTestSeries("queue_depth", map[string]string{"pod": "web-2"})
+	a.push(1)
+	a.push(3)
+	b.push(2)
+	page := []*metricSeries{a, b}
+	keys := labelKeys(page)
+	if strings.Join(keys, ",") != "pod,zone" {
+		t.Fatalf("labelKeys = %v", keys)
+	}
+
+	titles := func(cols []*tableColumn) string {
+		var out []string
+		for _, c := range cols {
+			out = append(out, c.title)
+		}
+		return strings.Join(out, ",")
+	}
+	cols := seriesColumns(page, keys, 0)
+	if got := titles(cols); got != "pod,zone,value,min,max,trend" {
+		t.Errorf("columns = %s", got)
+	}
+	if cols[1].cells[1] != "" || cols[0].cells[1] != "web-2" {
+		t.Errorf("label cells = %v / %v", cols[0].cells, cols[1].cells)
+	}
+	if got := titles(seriesColumns(page, keys, 1)); got != "zone,value,min,max,trend" {
+		t.Errorf("scrolled columns = %s", got)
+	}
+	if got := titles(seriesColumns(page, keys, 5)); got != "value,min,max,trend" {
+		t.Errorf("over-scrolled columns = %s", got)
+	}
+	a.push(1234.5678)
+	if got := titles(seriesColumns(page, keys, 0)); got != "pod,zone,value,raw,min,max,trend" {
+		t.Errorf("columns with raw = %s", got)
+	}
+}
+
+func TestCommonLabels(t *testing.T) {
+	a := newTestSeries("up", map[string]string{"job": "api", "pod": "web-1", "zone": "a"})
+	b := newTestSeries("up", map[string]string{"job": "api", "pod": "web-2"})
+	c := newTestSeries("up", map[string]string{"job": "api", "pod": "web-3", "zone": "a"})
+	list := []*metricSeries{a, b, c}
+	got := commonLabels(list, labelKeys(list))
+	if !got["job"] || got["pod"] || got["zone"] {
+		t.Errorf("commonLabels = %v, want only job common", got)
+	}
+	if got := commonLabels(list[:1], labelKeys(list)); len(got) != 0 {
+		t.Errorf("single series commonLabels = %v, want none", got)
+	}
+}
+
+func TestScrollTable(t *testing.T) {
+	ui := &uiState{}
+	ui.scrollTable(1, 2)
+	ui.scrollTable(5, 2)
+	if got := ui.tableOffset(); got != 2 {
+		t.Errorf("offset = %d, want 2", got)
+	}
+	ui.scrollTable(-9, 2)
+	if got := ui.tableOffset(); got != 0 {
+		t.Errorf("offset = %d, want 0", got)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+const (
+	defaultSilence = 15 * time.Minute
+	// changeFiringFor is how long a ±N% watch counts as firing after it fired.
+	changeFiringFor = time.Minute
+)
+
+func (w *watch) firing(now time.Time) bool {
+	if !w.primed {
+		return false
+	}
+	switch w.kind {
+	case watchAbove:
+		return w.last > w.threshold
+	case watchBelow:
+		return w.last < w.threshold
+	case watchAbsent:
+		return w.missing
+	default:
+		return !w.firedAt.IsZero() && now.Sub(w.firedAt) < changeFiringFor
+	}
+}
+
+func (w *watch) silenced(now time.Time) bool {
+	return now.Before(w.silencedUntil)
+}
+
+// ackFiring silences every firing watch that is not already silenced, so
+// known issues stop alerting while new ones still do.
+func (u *uiState) ackFiring(d time.Duration, now time.Time) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	n := 0
+	for _, w := range u.watches {
+		if w.firing(now) && !w.silenced(now) {
+			w.silencedUntil = now.Add(d)
+			n++
+		}
+	}
+	return n
+}
+
+// selectWatches resolves "all" or a 1-based alerts panel position.
+func (u *uiState) selectWatches(sel string) ([]*watch, error) {
+	if sel == "all" {
+		return u.watches, nil
+	}
+	i, err := strconv.Atoi(sel)
+	if err != nil || i < 1 || i > len(u.watches) {
+		return nil, fmt.Errorf("no watch %q (have %d)", sel, len(u.watches))
+	}
+	return u.watches[i-1 : i], nil
+}
+
+func (u *uiState) silenceWatches(sel string, d time.Duration, now time.Time) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	ws, err := u.selectWatches(sel)
+	for _, w := range ws {
+		w.silencedUntil = now.Add(d)
+	}
+	return len(ws), err
+}
+
+func (u *uiState) unsilenceWatches(sel string) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	ws, err := u.selectWatches(sel)
+	for _, w := range ws {
+		w.silencedUntil = time.Time{}
+	}
+	return len(ws), err
+}
+
+func (u *uiState) silencedCount(now time.Time) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	n := 0
+	for _, w := range u.watches {
+		if w.silenced(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// parseSilence validates "<n|all> [duration]" and returns the duration.
+func parseSilence(arg string) (string, time.Duration, error) {
+	f := strings.Fields(arg)
+	if len(f) == 0 || len(f) > 2 {
+		return "", 0, fmt.Errorf("silence expects a watch number or all and an optional duration, got %q", arg)
+	}
+	if f[0] != "all" {
+		if n, err := strconv.Atoi(f[0]); err != nil || n < 1 {
+			return "", 0, fmt.Errorf("invalid watch number %q", f[0])
+		}
+	}
+	d := defaultSilence
+	if len(f) == 2 {
+		var err error
+		if d, err = time.ParseDuration(f[1]); err != nil || d <= 0 {
+			return "", 0, fmt.Errorf("invalid silence duration %q", f[1])
+		}
+	}
+	return f[0], d, nil
+}
+
+func renderAlerts(w *text.Text, ui *uiState, now time.Time) {
+	w.Reset()
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if am := globalAlertmanager; am != nil {
+		defer renderAlertmanager(w, am, now)
+	}
+	if len(ui.watches) == 0 {
+		w.Write("  no watches, press w on a series to add one\n", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+	for i, wt := range ui.watches {
+		state, color := "ok", cell.ColorGreen
+		switch {
+		case wt.firing(now) && wt.silenced(now):
+			state, color = "firing", cell.ColorBlue
+		case wt.firing(now):
+			state, color = "FIRING", cell.ColorRed
+		}
+		w.Write(fmt.Sprintf(" %2d %-36s", i+1, truncateText(wt.label, 36)), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		w.Write(fmt.Sprintf(" %-10s", wt.condition()), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		w.Write(fmt.Sprintf(" %-7s", state), text.WriteCellOpts(cell.FgColor(color)))
+		if wt.silenced(now) {
+			w.Write(fmt.Sprintf(" 🔕 until %s (%s left)", wt.silencedUntil.Format("15:04:05"), formatRelDuration(wt.silencedUntil.Sub(now))),
+				text.WriteCellOpts(cell.FgColor(cell.ColorBlue)))
+		} else if wt.lastMsg != "" {
+			w.Write(fmt.Sprintf(" %s ago: %s", formatRelDuration(now.Sub(wt.firedAt)), wt.lastMsg), text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+		}
+		w.Write("\n")
+	}
+}
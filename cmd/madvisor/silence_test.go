@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func TestWatchFiring(t *testing.T) {
+	now := time.Now()
+	w := &watch{kind: watchAbove, threshold: 10}
+	if w.firing(now) {
+		t.Error("unprimed watch should not fire")
+	}
+	w.check(5)
+	w.check(12)
+	if !w.firing(now) {
+		t.Error("above-threshold watch should be firing")
+	}
+	w.check(8)
+	if w.firing(now) {
+		t.Error("watch back under the threshold should not be firing")
+	}
+
+	c := &watch{kind: watchChange, threshold: 10, primed: true, firedAt: now}
+	if !c.firing(now) || c.firing(now.Add(changeFiringFor)) {
+		t.Error("change watch should fire for changeFiringFor after its last alert")
+	}
+}
+
+func TestSilencedWatchStopsAlerting(t *testing.T) {
+	st := newStore()
+	u := &uiState{}
+	st.update("temp", nil, "", "gauge", 5)
+	u.watches = []*watch{
+		{key: seriesKey("temp", nil), label: "temp", kind: watchAbove, threshold: 10},
+		{key: seriesKey("load", nil), label: "load", kind: watchAbove, threshold: 1},
+	}
+	u.checkWatches(st)
+	st.update("temp", nil, "", "gauge", 20)
+	if alerts := u.checkWatches(st); len(alerts) != 1 {
+		t.Fatalf("alerts = %v", alerts)
+	}
+
+	now := time.Now()
+	if n := u.ackFiring(time.Minute, now); n != 1 {
+		t.Fatalf("ackFiring = %d, want 1", n)
+	}
+	if n := u.ackFiring(time.Minute, now); n != 0 {
+		t.Errorf("second ack = %d, want 0 (already silenced)", n)
+	}
+	if n := u.silencedCount(now); n != 1 {
+		t.Errorf("silencedCount = %d", n)
+	}
+	st.update("temp", nil, "", "gauge", 5)
+	u.checkWatches(st)
+	st.update("temp", nil, "", "gauge", 20)
+	if alerts := u.checkWatches(st); len(alerts) != 0 {
+		t.Errorf("silenced watch alerted: %v", alerts)
+	}
+	if u.watches[0].lastMsg == "" {
+		t.Error("silenced watch should still record its last alert")
+	}
+
+	u.watches[0].silencedUntil = time.Now().Add(-time.Second)
+	st.update("temp", nil, "", "gauge", 5)
+	u.checkWatches(st)
+	if !u.watches[0].silencedUntil.IsZero() {
+		t.Error("expired silence should be cleared")
+	}
+	st.update("temp", nil, "", "gauge", 20)
+	if alerts := u.checkWatches(st); len(alerts) != 1 {
+		t.Errorf("alerts after expiry = %v", alerts)
+	}
+}
+
+func TestSilenceCommands(t *testing.T) {
+	u := &uiState{watches: []*watch{{label: "a"}, {label: "b"}}}
+	now := time.Now()
+	if n, err := u.silenceWatches("2", time.Hour, now); err != nil || n != 1 || !u.watches[1].silenced(now) || u.watches[0].silenced(now) {
+		t.Errorf("silence 2: n=%d err=%v", n, err)
+	}
+	if _, err := u.silenceWatches("3", time.Hour, now); err == nil {
+		t.Error("silencing a missing watch should fail")
+	}
+	if n, _ := u.silenceWatches("all", time.Hour, now); n != 2 {
+		t.Errorf("silence all = %d", n)
+	}
+	if n, _ := u.unsilenceWatches("all"); n != 2 || u.silencedCount(now) != 0 {
+		t.Errorf("unsilence all = %d", n)
+	}
+
+	for _, line := range []string{"silence 1", "silence all 30m", "unsilence 2"} {
+		if _, err := parseScriptLine(1, line); err != nil {
+			t.Errorf("%q: %v", line, err)
+		}
+	}
+	for _, line := range []string{"silence", "silence x", "silence 1 soon", "silence 0", "unsilence 1 5m"} {
+		if _, err := parseScriptLine(1, line); err == nil {
+			t.Errorf("%q should fail to parse", line)
+		}
+	}
+	if sel, d, _ := parseSilence("all"); sel != "all" || d != defaultSilence {
+		t.Errorf("parseSilence default = %q %s", sel, d)
+	}
+}
+
+func TestAlertsPanel(t *testing.T) {
+	u := &uiState{}
+	u.togglePanel(panelMatrix)
+	if !u.togglePanel(panelAlerts) || u.panel() == panelMatrix {
+		t.Fatal("alerts panel should replace the matrix")
+	}
+	if u.togglePanel(panelInfo); u.panel() == panelAlerts {
+		t.Error("info should close the alerts panel")
+	}
+
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderAlerts(w, u, time.Now())
+	u.watches = []*watch{{label: strings.Repeat("x", 50), kind: watchBelow, threshold: 1, primed: true, last: 0, lastMsg: "x crossed below 1"}}
+	u.silenceWatches("1", time.Minute, time.Now())
+	renderAlerts(w, u, time.Now())
+}
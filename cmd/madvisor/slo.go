@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+var globalSLOs []compiledSLO
+
+// SLOConfig declares a latency SLI on a histogram: the share of
+// observations at or under Threshold (in the metric's unit) should stay at
+// or above Objective.
+type SLOConfig struct {
+	Metric    string  `yaml:"metric"`
+	Threshold float64 `yaml:"threshold"`
+	Objective float64 `yaml:"objective"`
+}
+
+type compiledSLO struct {
+	re        *regexp.Regexp
+	threshold float64
+	objective float64
+}
+
+func compileSLOs(cfgs []SLOConfig) ([]compiledSLO, error) {
+	out := make([]compiledSLO, 0, len(cfgs))
+	for i, c := range cfgs {
+		if c.Metric == "" {
+			return nil, fmt.Errorf("slos[%d]: metric is required", i)
+		}
+		re, err := regexp.Compile(c.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("slos[%d]: compile metric pattern %q: %w", i, c.Metric, err)
+		}
+		if c.Threshold <= 0 {
+			return nil, fmt.Errorf("slos[%d]: threshold must be > 0", i)
+		}
+		if c.Objective < 0 || c.Objective >= 1 {
+			return nil, fmt.Errorf("slos[%d]: objective must be in [0, 1), e.g. 0.99", i)
+		}
+		out = append(out, compiledSLO{re: re, threshold: c.Threshold, objective: c.Objective})
+	}
+	return out, nil
+}
+
+// sloFor returns the first SLO whose pattern matches the histogram's base
+// name.
+func sloFor(rules []compiledSLO, base string) (compiledSLO, bool) {
+	for _, r := range rules {
+		if r.re.MatchString(base) {
+			return r, true
+		}
+	}
+	return compiledSLO{}, false
+}
+
+// latencyBudget is the fraction of observations at or under threshold over
+// the window, from the summed rates of the _bucket series. A threshold that
+// falls between bucket bounds is interpolated linearly, as
+// histogram_quantile does. ok is false when there was no traffic.
+func latencyBudget(buckets []*metricSeries, threshold float64, window time.Duration) (float64, bool) {
+	bounds, rates, total := bucketRates(buckets, window)
+	if total <= 0 {
+		return 0, false
+	}
+
+	lo, loRate := 0.0, 0.0
+	for _, le := range bounds {
+		if le == threshold {
+			return min(rates[le]/total, 1), true
+		}
+		if le > threshold {
+			if math.IsInf(le, 1) {
+				break
+			}
+			good := loRate + (rates[le]-loRate)*(threshold-lo)/(le-lo)
+			return min(good/total, 1), true
+		}
+		lo, loRate = le, rates[le]
+	}
+	return min(loRate/total, 1), true
+}
+
+// bucketRates sums the per-second rates of histogram _bucket series by
+// their le bound, returning the sorted bounds and the +Inf (total) rate.
+func bucketRates(buckets []*metricSeries, window time.Duration) ([]float64, map[float64]float64, float64) {
+	rates := map[float64]float64{}
+	for _, s := range buckets {
+		le, err := strconv.ParseFloat(s.labels["le"], 64)
+		if err != nil {
+			continue
+		}
+		rates[le] += s.rate(window)
+	}
+	bounds := make([]float64, 0, len(rates))
+	for le := range rates {
+		bounds = append(bounds, le)
+	}
+	sort.Float64s(bounds)
+	return bounds, rates, rates[math.Inf(1)]
+}
+
+// bucketQuantile estimates the q-quantile like histogram_quantile: linear
+// interpolation within the bucket holding the rank, and the highest finite
+// bound when the rank falls in +Inf. ok is false without traffic.
+func bucketQuantile(buckets []*metricSeries, q float64, window time.Duration) (float64, bool) {
+	bounds, rates, total := bucketRates(buckets, window)
+	if total <= 0 {
+		return 0, false
+	}
+	rank := q * total
+	lo, loRate := 0.0, 0.0
+	for _, le := range bounds {
+		if rates[le] >= rank {
+			if math.IsInf(le, 1) {
+				return lo, true
+			}
+			if rates[le] == loRate {
+				return le, true
+			}
+			return lo + (le-lo)*(rank-loRate)/(rates[le]-loRate), true
+		}
+		lo, loRate = le, rates[le]
+	}
+	return lo, true
+}
+
+// renderSLO writes the live latency budget line for a histogram with an SLO
+// into the series panel, for any member of the family.
+func renderSLO(w *text.Text, st *store, name, mtype, group string) {
+	base, ok := familyBase(name, mtype)
+	if !ok || detectMetricType(name, mtype) != "histogram" {
+		return
+	}
+	slo, ok := sloFor(globalSLOs, base)
+	if !ok {
+		return
+	}
+	window := rateWindowGet()
+	under := formatValue(base, slo.threshold)
+	ratio, ok := latencyBudget(filterGroup(st.seriesForName(base+"_bucket"), group), slo.threshold, window)
+	if !ok {
+		w.Write(fmt.Sprintf(" SLO: no requests in the last %s (target ≤ %s)\n", window, under), text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		return
+	}
+	line := fmt.Sprintf(" SLO: %.2f%% of requests ≤ %s over %s", ratio*100, under, window)
+	color := cell.ColorGreen
+	if slo.objective > 0 {
+		mark := "✓"
+		if ratio < slo.objective {
+			mark, color = "✗", cell.ColorRed
+		}
+		line += fmt.Sprintf(" (objective %s%%) %s", strconv.FormatFloat(slo.objective*100, 'f', -1, 64), mark)
+	}
+	w.Write(line+"\n", text.WriteCellOpts(cell.FgColor(color), cell.Bold()))
+}
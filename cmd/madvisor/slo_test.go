@@ -0,0 +1,112 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+// bucketStore records two scrapes of a histogram 10s apart, with the given
+// per-bucket increases.
+func bucketStore(t *testing.T, increase map[string]float64) *store {
+	t.Helper()
+	st := newStore()
+	t0 := time.Now().Add(-10 * time.Second)
+	for le, inc := range increase {
+		labels := map[string]string{"le": le}
+		st.ingest("a:1", "req_duration_seconds_bucket", labels, "", "histogram", 100, t0)
+		st.ingest("a:1", "req_duration_seconds_bucket", map[string]string{"le": le}, "", "histogram", 100+inc, t0.Add(10*time.Second))
+	}
+	return st
+}
+
+func TestLatencyBudget(t *testing.T) {
+	st := bucketStore(t, map[string]float64{"0.1": 50, "0.25": 80, "0.5": 95, "+Inf": 100})
+	buckets := st.seriesForName("req_duration_seconds_bucket")
+
+	tests := []struct {
+		threshold float64
+		want      float64
+	}{
+		{0.25, 0.80},
+		{0.3, 0.83},
+		{0.05, 0.25},
+		{2, 0.95},
+	}
+	for _, tt := range tests {
+		got, ok := latencyBudget(buckets, tt.threshold, time.Minute)
+		if !ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("latencyBudget(%g) = %v, %v, want %v", tt.threshold, got, ok, tt.want)
+		}
+	}
+}
+
+func TestLatencyBudgetNoTraffic(t *testing.T) {
+	st := bucketStore(t, map[string]float64{"0.1": 0, "+Inf": 0})
+	if _, ok := latencyBudget(st.seriesForName("req_duration_seconds_bucket"), 0.1, time.Minute); ok {
+		t.Error("no traffic should not report a budget")
+	}
+	if _, ok := latencyBudget(nil, 0.1, time.Minute); ok {
+		t.Error("no buckets should not report a budget")
+	}
+}
+
+func TestCompileSLOs(t *testing.T) {
+	rules, err := compileSLOs([]SLOConfig{{Metric: "^req_duration_seconds$", Threshold: 0.3, Objective: 0.99}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slo, ok := sloFor(rules, "req_duration_seconds"); !ok || slo.threshold != 0.3 || slo.objective != 0.99 {
+		t.Errorf("sloFor = %+v, %v", slo, ok)
+	}
+	if _, ok := sloFor(rules, "other_seconds"); ok {
+		t.Error("unrelated histogram matched")
+	}
+
+	bad := []SLOConfig{
+		{Threshold: 1},
+		{Metric: "(", Threshold: 1},
+		{Metric: "x"},
+		{Metric: "x", Threshold: 1, Objective: 99},
+	}
+	for _, b := range bad {
+		if _, err := compileSLOs([]SLOConfig{b}); err == nil {
+			t.Errorf("compileSLOs(%+v) should fail", b)
+		}
+	}
+}
+
+func TestRenderSLO(t *testing.T) {
+	old := globalSLOs
+	defer func() { globalSLOs = old }()
+	globalSLOs, _ = compileSLOs([]SLOConfig{{Metric: "^req_duration_seconds$", Threshold: 0.25, Objective: 0.9}})
+
+	st := bucketStore(t, map[string]float64{"0.25": 80, "+Inf": 100})
+	st.ingest("a:1", "req_duration_seconds_count", nil, "", "histogram", 1, time.Now())
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderSLO(w, st, "req_duration_seconds_count", "histogram", "")
+	renderSeriesTable(w, st, "req_duration_seconds_count", tableSeries(st, "req_duration_seconds_count", "", sortRate), 0, 0, focusSidebar, "", 0, sortRate)
+}
+
+func TestBucketQuantile(t *testing.T) {
+	st := bucketStore(t, map[string]float64{"0.1": 50, "0.25": 80, "0.5": 95, "+Inf": 100})
+	buckets := st.seriesForName("req_duration_seconds_bucket")
+	tests := []struct {
+		q, want float64
+	}{
+		{0.5, 0.1},
+		{0.65, 0.175},
+		{0.99, 0.5},
+	}
+	for _, tt := range tests {
+		got, ok := bucketQuantile(buckets, tt.q, time.Minute)
+		if !ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("bucketQuantile(%g) = %v, %v, want %v", tt.q, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultSNMPPort     = 161
+	defaultSNMPInterval = 5 * time.Second
+	snmpIndexLabel      = "index"
+)
+
+type SNMPLookup struct {
+	OID   string `yaml:"oid"`
+	Label string `yaml:"label"`
+}
+
+type SNMPMetric struct {
+	Name       string      `yaml:"name"`
+	OID        string      `yaml:"oid"`
+	Type       string      `yaml:"type"`
+	Help       string      `yaml:"help"`
+	Walk       bool        `yaml:"walk"`
+	IndexLabel string      `yaml:"index_label"`
+	Lookup     *SNMPLookup `yaml:"lookup"`
+}
+
+type SNMPConfig struct {
+	Community string        `yaml:"community"`
+	Version   string        `yaml:"version"`
+	Interval  time.Duration `yaml:"interval"`
+	Timeout   time.Duration `yaml:"timeout"`
+	Metrics   []SNMPMetric  `yaml:"metrics"`
+}
+
+func parseSNMPConfig(data []byte) (*SNMPConfig, error) {
+	var cfg SNMPConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse OID file: %w", err)
+	}
+	if cfg.Community == "" {
+		cfg.Community = "public"
+	}
+	if cfg.Version == "" {
+		cfg.Version = "2c"
+	}
+	if _, err := snmpVersion(cfg.Version); err != nil {
+		return nil, err
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultSNMPInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if len(cfg.Metrics) == 0 {
+		return nil, fmt.Errorf("OID file defines no metrics")
+	}
+	for i := range cfg.Metrics {
+		m := &cfg.Metrics[i]
+		if m.Name == "" || m.OID == "" {
+			return nil, fmt.Errorf("metric %d: name and oid are required", i+1)
+		}
+		m.OID = normalizeOID(m.OID)
+		if m.Walk && m.IndexLabel == "" {
+			m.IndexLabel = snmpIndexLabel
+		}
+		if m.Lookup != nil {
+			if !m.Walk || m.Lookup.OID == "" || m.Lookup.Label == "" {
+				return nil, fmt.Errorf("metric %s: lookup needs walk: true, an oid and a label", m.Name)
+			}
+			m.Lookup.OID = normalizeOID(m.Lookup.OID)
+		}
+	}
+	return &cfg, nil
+}
+
+func loadSNMPConfig(path string) (*SNMPConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read OID file %q: %w", path, err)
+	}
+	return parseSNMPConfig(data)
+}
+
+func normalizeOID(oid string) string {
+	return "." + strings.Trim(strings.TrimSpace(oid), ".")
+}
+
+func snmpVersion(v string) (gosnmp.SnmpVersion, error) {
+	switch v {
+	case "1":
+		return gosnmp.Version1, nil
+	case "2c", "2":
+		return gosnmp.Version2c, nil
+	}
+	return 0, fmt.Errorf("unsupported SNMP version %q, want 1 or 2c", v)
+}
+
+func parseSNMPHosts(spec string) ([]string, error) {
+	var hosts []string
+	for _, h := range strings.Split(spec, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(h, strconv.Itoa(defaultSNMPPort))
+		}
+		hosts = append(hosts, h)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no SNMP hosts given")
+	}
+	return hosts, nil
+}
+
+func pduValue(pdu gosnmp.SnmpPDU) (float64, bool) {
+	switch pdu.Type {
+	case gosnmp.Counter32, gosnmp.Counter64, gosnmp.Gauge32, gosnmp.Integer, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		v, _ := new(big.Float).SetInt(gosnmp.ToBigInt(pdu.Value)).Float64()
+		return v, true
+	case gosnmp.OctetString:
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+		return v, err == nil
+	}
+	return 0, false
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	if b, ok := pdu.Value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(pdu.Value)
+}
+
+type snmpConn interface {
+	Get(oids []string) (*gosnmp.SnmpPacket, error)
+	walk(root string) ([]gosnmp.SnmpPDU, error)
+}
+
+type gosnmpConn struct {
+	*gosnmp.GoSNMP
+}
+
+func (c gosnmpConn) walk(root string) ([]gosnmp.SnmpPDU, error) {
+	if c.Version == gosnmp.Version1 {
+		return c.WalkAll(root)
+	}
+	return c.BulkWalkAll(root)
+}
+
+func dialSNMP(cfg *SNMPConfig, hostport string) (snmpConn, error) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %q", hostport)
+	}
+	version, err := snmpVersion(cfg.Version)
+	if err != nil {
+		return nil, err
+	}
+	g := &gosnmp.GoSNMP{
+		Target:    host,
+		Port:      uint16(port),
+		Community: cfg.Community,
+		Version:   version,
+		Timeout:   cfg.Timeout,
+		Retries:   1,
+		MaxOids:   gosnmp.MaxOids,
+	}
+	if err := g.Connect(); err != nil {
+		return nil, err
+	}
+	return gosnmpConn{g}, nil
+}
+
+func pollSNMP(conn snmpConn, cfg *SNMPConfig, host string, st *store) error {
+	now := time.Now()
+	src := "snmp:" + host
+	ingest := func(m SNMPMetric, labels map[string]string, v float64) {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[instanceLabel] = host
+		name, labels, keep := applyRelabel(globalRelabel, src, m.Name, labels)
+		if keep {
+			st.ingest(src, name, labels, m.Help, m.Type, v, now)
+		}
+	}
+
+	var scalars []SNMPMetric
+	var firstErr error
+	for _, m := range cfg.Metrics {
+		if !m.Walk {
+			scalars = append(scalars, m)
+			continue
+		}
+		pdus, err := conn.walk(m.OID)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("walk %s (%s): %w", m.Name, m.OID, err)
+			}
+			continue
+		}
+		var names map[string]string
+		if m.Lookup != nil {
+			names = map[string]string{}
+			if lpdus, err := conn.walk(m.Lookup.OID); err == nil {
+				for _, p := range lpdus {
+					names[strings.TrimPrefix(p.Name, m.Lookup.OID+".")] = pduString(p)
+				}
+			}
+		}
+		for _, p := range pdus {
+			v, ok := pduValue(p)
+			if !ok {
+				continue
+			}
+			idx := strings.TrimPrefix(p.Name, m.OID+".")
+			labels := map[string]string{m.IndexLabel: idx}
+			if name, ok := names[idx]; ok {
+				labels[m.Lookup.Label] = name
+			}
+			ingest(m, labels, v)
+		}
+	}
+
+	for start := 0; start < len(scalars); start += gosnmp.MaxOids {
+		batch := scalars[start:min(start+gosnmp.MaxOids, len(scalars))]
+		oids := make([]string, len(batch))
+		byOID := make(map[string]SNMPMetric, len(batch))
+		for i, m := range batch {
+			oids[i] = m.OID
+			byOID[m.OID] = m
+		}
+		pkt, err := conn.Get(oids)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("get: %w", err)
+			}
+			continue
+		}
+		for _, p := range pkt.Variables {
+			m, ok := byOID[normalizeOID(p.Name)]
+			if !ok {
+				continue
+			}
+			if v, ok := pduValue(p); ok {
+				ingest(m, nil, v)
+			}
+		}
+	}
+	return firstErr
+}
+
+func snmpTargets(hosts []string) []target {
+	out := make([]target, len(hosts))
+	for i, h := range hosts {
+		out[i] = target{addr: "snmp:" + h}
+	}
+	return out
+}
+
+func runSNMP(ctx context.Context, cfg *SNMPConfig, hosts []string, st *store) {
+	for _, h := range hosts {
+		go func(host string) {
+			var conn snmpConn
+			ticker := time.NewTicker(cfg.Interval)
+			defer ticker.Stop()
+			for {
+				if conn == nil {
+					if c, err := dialSNMP(cfg, host); err == nil {
+						conn = c
+					}
+				}
+				if conn != nil {
+					pollSNMP(conn, cfg, host, st)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(h)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const testOIDFile = `
+community: secret
+interval: 10s
+metrics:
+  - name: sys_uptime_ticks
+    oid: 1.3.6.1.2.1.1.3.0
+    type: gauge
+  - name: if_in_octets_total
+    oid: .1.3.6.1.2.1.2.2.1.10
+    type: counter
+    help: Octets received
+    walk: true
+    index_label: ifIndex
+    lookup:
+      oid: 1.3.6.1.2.1.2.2.1.2
+      label: ifDescr
+`
+
+type fakeSNMP struct {
+	walks map[string][]gosnmp.SnmpPDU
+	gets  map[string]gosnmp.SnmpPDU
+}
+
+func (f *fakeSNMP) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	pkt := &gosnmp.SnmpPacket{}
+	for _, oid := range oids {
+		if p, ok := f.gets[oid]; ok {
+			pkt.Variables = append(pkt.Variables, p)
+		}
+	}
+	return pkt, nil
+}
+
+func (f *fakeSNMP) walk(root string) ([]gosnmp.SnmpPDU, error) {
+	pdus, ok := f.walks[root]
+	if !ok {
+		return nil, fmt.Errorf("no such subtree %s", root)
+	}
+	return pdus, nil
+}
+
+func TestParseSNMPConfig(t *testing.T) {
+	cfg, err := parseSNMPConfig([]byte(testOIDFile))
+	if err != nil {
+		t.Fatalf("parseSNMPConfig: %v", err)
+	}
+	if cfg.Community != "secret" || cfg.Version != "2c" || cfg.Interval != 10*time.Second || cfg.Timeout != 2*time.Second {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if cfg.Metrics[0].OID != ".1.3.6.1.2.1.1.3.0" || cfg.Metrics[1].Lookup.OID != ".1.3.6.1.2.1.2.2.1.2" {
+		t.Errorf("OIDs not normalized: %+v", cfg.Metrics)
+	}
+
+	bad := []string{
+		"metrics: []",
+		"metrics:\n  - name: x",
+		"version: 3\nmetrics:\n  - {name: x, oid: 1.2}",
+		"metrics:\n  - {name: x, oid: 1.2, lookup: {oid: 1.3, label: y}}",
+	}
+	for _, b := range bad {
+		if _, err := parseSNMPConfig([]byte(b)); err == nil {
+			t.Errorf("parseSNMPConfig(%q) should fail", b)
+		}
+	}
+}
+
+func TestParseSNMPHosts(t *testing.T) {
+	got, err := parseSNMPHosts("switch1, 10.0.0.1:1161")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != "switch1:161 10.0.0.1:1161" {
+		t.Errorf("hosts = %v", got)
+	}
+	if _, err := parseSNMPHosts(" , "); err == nil {
+		t.Error("empty host list should fail")
+	}
+}
+
+func TestPDUValue(t *testing.T) {
+	tests := []struct {
+		pdu  gosnmp.SnmpPDU
+		want float64
+		ok   bool
+	}{
+		{gosnmp.SnmpPDU{Type: gosnmp.Counter64, Value: uint64(1 << 40)}, 1 << 40, true},
+		{gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: -5}, -5, true},
+		{gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte(" 42.5 ")}, 42.5, true},
+		{gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("eth0")}, 0, false},
+		{gosnmp.SnmpPDU{Type: gosnmp.NoSuchObject}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := pduValue(tt.pdu)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("pduValue(%v) = %v, %v; want %v, %v", tt.pdu, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPollSNMP(t *testing.T) {
+	cfg, err := parseSNMPConfig([]byte(testOIDFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeSNMP{
+		walks: map[string][]gosnmp.SnmpPDU{
+			".1.3.6.1.2.1.2.2.1.10": {
+				{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(100)},
+				{Name: ".1.3.6.1.2.1.2.2.1.10.2", Type: gosnmp.Counter32, Value: uint(200)},
+			},
+			".1.3.6.1.2.1.2.2.1.2": {
+				{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("lo")},
+				{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: []byte("eth0")},
+			},
+		},
+		gets: map[string]gosnmp.SnmpPDU{
+			".1.3.6.1.2.1.1.3.0": {Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(12345)},
+		},
+	}
+
+	st := newStore()
+	if err := pollSNMP(conn, cfg, "switch1:161", st); err != nil {
+		t.Fatalf("pollSNMP: %v", err)
+	}
+	s := st.get("if_in_octets_total{ifDescr=eth0,ifIndex=2,instance=switch1:161}")
+	if s == nil {
+		t.Fatalf("walked series missing, have %d series", len(st.snapshot()))
+	}
+	if s.last() != 200 || s.mtype != "counter" || s.help != "Octets received" {
+		t.Errorf("series = %v type=%q help=%q", s.last(), s.mtype, s.help)
+	}
+	if s := st.get("sys_uptime_ticks{instance=switch1:161}"); s == nil || s.last() != 12345 {
+		t.Errorf("scalar = %v", s)
+	}
+
+	delete(conn.walks, ".1.3.6.1.2.1.2.2.1.10")
+	if err := pollSNMP(conn, cfg, "switch1:161", st); err == nil || !strings.Contains(err.Error(), "if_in_octets_total") {
+		t.Errorf("walk failure should be reported, got %v", err)
+	}
+}
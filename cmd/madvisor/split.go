@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func targetSpec(targets []target) string {
+	var specs []string
+	idx := map[string]int{}
+	for _, t := range targets {
+		if t.group == "" {
+			specs = append(specs, t.spec())
+			continue
+		}
+		if i, ok := idx[t.group]; ok {
+			specs[i] += "," + t.spec()
+			continue
+		}
+		idx[t.group] = len(specs)
+		specs = append(specs, t.group+"="+t.spec())
+	}
+	return strings.Join(specs, ";")
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,@%+", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitCommands are the UI commands that open the split on metric, passed
+// as --run arguments so nothing is left behind when the pane closes.
+func splitCommands(metric, group string) []string {
+	var cmds []string
+	if group != "" {
+		cmds = append(cmds, "group "+group)
+	}
+	return append(cmds, "filter ^"+metric+"$", "select "+metric)
+}
+
+func splitArgs(exe string, targets []target, metric, group string) []string {
+	args := []string{exe, "watch", "--targets", targetSpec(targets), "--rate-window", rateWindowGet().String()}
+	if flagPatterns != "" {
+		args = append(args, "--patterns", flagPatterns)
+	}
+	for _, j := range flagJobs {
+		args = append(args, "--job", j)
+	}
+	for _, c := range splitCommands(metric, group) {
+		args = append(args, "--run", c)
+	}
+	return args
+}
+
+func openSplit(targets []target, metric, group string) (string, error) {
+	if metric == "" {
+		return "", fmt.Errorf("no metric selected")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate executable: %w", err)
+	}
+	quoted := make([]string, 0, 8)
+	for _, a := range splitArgs(exe, targets, metric, group) {
+		quoted = append(quoted, quoteArg(a))
+	}
+	cmdline := strings.Join(quoted, " ")
+
+	if os.Getenv("TMUX") == "" {
+		return "run in a new pane: " + cmdline, nil
+	}
+	if out, err := exec.Command("tmux", "split-window", "-h", cmdline).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("tmux split-window: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return "opened " + metric + " in a new tmux pane", nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTargetSpecRoundTrip(t *testing.T) {
+	for _, in := range []string{"a:1,b:2", "api=a:1,a:2;db=b:1", "a:1;web=b:2"} {
+		targets := parseTargets(in)
+		again := parseTargets(targetSpec(targets))
+		if len(again) != len(targets) {
+			t.Fatalf("round trip of %q: len %d, want %d", in, len(again), len(targets))
+		}
+		for i := range targets {
+			if again[i] != targets[i] {
+				t.Errorf("round trip of %q: [%d] = %+v, want %+v", in, i, again[i], targets[i])
+			}
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"localhost:8080", "localhost:8080"},
+		{"/usr/bin/madvisor", "/usr/bin/madvisor"},
+		{"api=a:1;db=b:1", "'api=a:1;db=b:1'"},
+		{"it's", `'it'\''s'`},
+		{"", "''"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandsParse(t *testing.T) {
+	lines := splitCommands("http_requests_total", "api")
+	if len(lines) != 3 {
+		t.Fatalf("splitCommands = %q", lines)
+	}
+	for i, line := range lines {
+		if _, err := parseScriptLine(i+1, line); err != nil {
+			t.Errorf("split command %q does not parse: %v", line, err)
+		}
+	}
+	if lines[0] != "group api" || lines[2] != "select http_requests_total" {
+		t.Errorf("splitCommands = %q", lines)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args := splitArgs("/bin/madvisor", parseTargets("api=a:1"), "cpu", "")
+	got := strings.Join(args, " ")
+	for _, want := range []string{"/bin/madvisor", "--targets api=a:1", "--rate-window", "--run filter ^cpu$ --run select cpu"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("splitArgs = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestSplitArgsJobs(t *testing.T) {
+	defer func() { flagJobs = nil }()
+	flagJobs = []string{"api=api-server"}
+	got := strings.Join(splitArgs("/bin/madvisor", parseTargets("api=a:1"), "cpu", ""), " ")
+	if !strings.Contains(got, "--job api=api-server") {
+		t.Errorf("splitArgs = %q, missing --job", got)
+	}
+}
+
+func TestOpenSplitWithoutTmux(t *testing.T) {
+	t.Setenv("TMUX", "")
+
+	msg, err := openSplit(parseTargets("a:1"), "cpu", "")
+	if err != nil {
+		t.Fatalf("openSplit: %v", err)
+	}
+	if !strings.Contains(msg, "--run") || !strings.Contains(msg, "select cpu") {
+		t.Errorf("message = %q, want the command line to run", msg)
+	}
+
+	if _, err := openSplit(nil, "", ""); err == nil {
+		t.Error("openSplit without a selected metric should fail")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	restartDelay     = time.Second
+	crashDegradedFor = 5 * time.Minute
+	maxCrashes       = 20
+)
+
+type crash struct {
+	subsystem string
+	at        time.Time
+	err       string
+	stack     string
+}
+
+// recordCrash keeps the last maxCrashes panics and hands each to onCrash
+// (the debug log in the TUI, stderr in plain mode).
+func (st *store) recordCrash(subsystem string, r any, stack []byte) crash {
+	c := crash{subsystem: subsystem, at: time.Now(), err: fmt.Sprint(r), stack: string(stack)}
+	st.mu.Lock()
+	st.crashes = append(st.crashes, c)
+	if len(st.crashes) > maxCrashes {
+		st.crashes = st.crashes[len(st.crashes)-maxCrashes:]
+	}
+	onCrash := st.onCrash
+	st.mu.Unlock()
+	if onCrash != nil {
+		onCrash(c)
+	}
+	return c
+}
+
+func (st *store) crashList() []crash {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return append([]crash{}, st.crashes...)
+}
+
+// guard runs fn, turning a panic into a recorded crash of subsystem. It
+// reports whether fn panicked.
+func guard(st *store, subsystem string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			st.recordCrash(subsystem, r, debug.Stack())
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// supervise runs fn until ctx is done, restarting it restartDelay after a
+// panic so one bad exposition line or render bug does not take the whole
+// dashboard down.
+func supervise(ctx context.Context, st *store, subsystem string, fn func(context.Context)) {
+	for {
+		if !guard(st, subsystem, func() { fn(ctx) }) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+type degradedSubsystem struct {
+	name     string
+	restarts int
+	last     crash
+}
+
+// degradedSubsystems lists the subsystems that panicked within
+// crashDegradedFor of now, with how often and the latest panic.
+func degradedSubsystems(crashes []crash, now time.Time) []degradedSubsystem {
+	bySub := map[string]*degradedSubsystem{}
+	for _, c := range crashes {
+		if now.Sub(c.at) > crashDegradedFor {
+			continue
+		}
+		d := bySub[c.subsystem]
+		if d == nil {
+			d = &degradedSubsystem{name: c.subsystem}
+			bySub[c.subsystem] = d
+		}
+		d.restarts++
+		d.last = c
+	}
+	out := make([]degradedSubsystem, 0, len(bySub))
+	for _, d := range bySub {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+func crashStatus(degraded []degradedSubsystem) string {
+	parts := make([]string, len(degraded))
+	for i, d := range degraded {
+		parts[i] = fmt.Sprintf("%s restarted %d× (%s)", d.name, d.restarts, truncateText(d.last.err, 40))
+	}
+	return "⚠ " + strings.Join(parts, ", ") + ", see " + debugLogPath
+}
+
+func (c crash) String() string {
+	return fmt.Sprintf("%s: panic in %s: %s\n%s", c.at.Format(time.RFC3339), c.subsystem, c.err, c.stack)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSuperviseRestartsAfterPanic(t *testing.T) {
+	st := newStore()
+	var logged atomic.Int32
+	st.onCrash = func(c crash) {
+		if c.subsystem == "render" && strings.Contains(c.stack, "supervise_test.go") {
+			logged.Add(1)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		supervise(ctx, st, "render", func(ctx context.Context) {
+			if runs.Add(1) == 1 {
+				panic("boom")
+			}
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervise did not return after the restarted run finished")
+	}
+	if runs.Load() != 2 {
+		t.Errorf("runs = %d, want a restart after the panic", runs.Load())
+	}
+	if logged.Load() != 1 {
+		t.Errorf("onCrash saw %d render crashes with a stack, want 1", logged.Load())
+	}
+	if c := st.crashList(); len(c) != 1 || c[0].err != "boom" {
+		t.Errorf("crashes = %+v, want one boom", c)
+	}
+}
+
+func TestSuperviseStopsOnCancel(t *testing.T) {
+	st := newStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runs := 0
+	supervise(ctx, st, "scrape", func(context.Context) {
+		runs++
+		panic("boom")
+	})
+	if runs != 1 {
+		t.Errorf("runs = %d, want no restart once the context is done", runs)
+	}
+}
+
+func TestScrapeGuardedMarksTargetFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "boom 1")
+	}))
+	defer srv.Close()
+	st := newStore()
+	st.observe = func(s sample) {
+		if s.Name == "boom" {
+			panic("bad line")
+		}
+	}
+	tgt := target{addr: strings.TrimPrefix(srv.URL, "http://")}
+	scrapeGuarded(&http.Client{}, tgt, st)
+	if h := st.targetHealth(tgt.addr); h.failed == 0 || !strings.Contains(h.lastErr, "panic") {
+		t.Errorf("health = %+v, want a failed scrape blaming the panic", h)
+	}
+	if c := st.crashList(); len(c) == 0 || c[0].subsystem != "scrape" {
+		t.Errorf("crashes = %+v, want a scrape crash", c)
+	}
+}
+
+func TestDegradedSubsystems(t *testing.T) {
+	now := time.Now()
+	crashes := []crash{
+		{subsystem: "scrape", at: now.Add(-time.Hour), err: "old"},
+		{subsystem: "scrape", at: now.Add(-time.Minute), err: "index out of range"},
+		{subsystem: "render", at: now.Add(-2 * time.Minute), err: "nil map"},
+		{subsystem: "scrape", at: now, err: "index out of range"},
+	}
+	got := degradedSubsystems(crashes, now)
+	if len(got) != 2 || got[0].name != "render" || got[1].name != "scrape" || got[1].restarts != 2 {
+		t.Fatalf("degraded = %+v, want render and scrape (2 recent restarts)", got)
+	}
+	if s := crashStatus(got); !strings.Contains(s, "scrape restarted 2× (index out of range)") || !strings.Contains(s, debugLogPath) {
+		t.Errorf("status = %q", s)
+	}
+}
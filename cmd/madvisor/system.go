@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	systemHelp = "Read from /proc or the cgroup filesystem by madvisor --system."
+
+	// userHZ is the unit of the CPU times in /proc, fixed at 100 on Linux.
+	userHZ       = 100
+	sectorSize   = 512
+	systemTarget = "system"
+)
+
+var (
+	flagSystem      bool
+	flagSystemProcs string
+)
+
+// systemCollector samples the machine or container madvisor runs in, named
+// like node_exporter (host), cAdvisor (own cgroup) and the process collector
+// of client libraries (processes whose name matches procs), so the built-in
+// presets apply. As a sidecar sharing the pod's process namespace, that is
+// the pod's containers.
+type systemCollector struct {
+	proc   string
+	cgroup string
+	procs  *regexp.Regexp
+	page   float64
+}
+
+func newSystemCollector(procs string) (*systemCollector, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("--system reads /proc, which %s does not have", runtime.GOOS)
+	}
+	c := &systemCollector{proc: "/proc", cgroup: "/sys/fs/cgroup", page: float64(os.Getpagesize())}
+	if procs != "" {
+		re, err := regexp.Compile(procs)
+		if err != nil {
+			return nil, fmt.Errorf("--system-procs: %w", err)
+		}
+		c.procs = re
+	}
+	return c, nil
+}
+
+type sampleFunc func(name string, labels map[string]string, mtype string, val float64)
+
+// collect reads every source; one that is missing (no cgroup v2, a process
+// that exited) is skipped, and only a host without /proc/stat fails.
+func (c *systemCollector) collect(fn sampleFunc) error {
+	if err := c.cpu(fn); err != nil {
+		return err
+	}
+	c.memory(fn)
+	c.network(fn)
+	c.disks(fn)
+	c.cgroupStats(fn)
+	if c.procs != nil {
+		c.processes(fn)
+	}
+	return nil
+}
+
+func eachLine(path string, fn func(fields []string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fn(strings.Fields(sc.Text()))
+	}
+	return sc.Err()
+}
+
+func parseNum(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+var cpuModes = []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal"}
+
+func (c *systemCollector) cpu(fn sampleFunc) error {
+	return eachLine(filepath.Join(c.proc, "stat"), func(f []string) {
+		if len(f) < 2 || !strings.HasPrefix(f[0], "cpu") || f[0] == "cpu" {
+			return
+		}
+		for i, mode := range cpuModes {
+			if i+1 >= len(f) {
+				break
+			}
+			if v, ok := parseNum(f[i+1]); ok {
+				fn("node_cpu_seconds_total", map[string]string{"cpu": f[0][3:], "mode": mode}, "counter", v/userHZ)
+			}
+		}
+	})
+}
+
+var meminfoName = strings.NewReplacer("(", "_", ")", "")
+
+func (c *systemCollector) memory(fn sampleFunc) {
+	eachLine(filepath.Join(c.proc, "meminfo"), func(f []string) {
+		if len(f) < 2 {
+			return
+		}
+		v, ok := parseNum(f[1])
+		if !ok {
+			return
+		}
+		name := "node_memory_" + meminfoName.Replace(strings.TrimSuffix(f[0], ":"))
+		if len(f) > 2 && f[2] == "kB" {
+			v *= 1024
+			name += "_bytes"
+		}
+		fn(name, nil, "gauge", v)
+	})
+}
+
+// netDevFields are the /proc/net/dev columns kept, per direction.
+var netDevFields = map[int]string{0: "bytes", 1: "packets", 2: "errs", 3: "drop"}
+
+func (c *systemCollector) network(fn sampleFunc) {
+	eachLine(filepath.Join(c.proc, "net", "dev"), func(f []string) {
+		if len(f) < 17 || !strings.HasSuffix(f[0], ":") {
+			return
+		}
+		dev := strings.TrimSuffix(f[0], ":")
+		for i, field := range netDevFields {
+			if v, ok := parseNum(f[1+i]); ok {
+				fn("node_network_receive_"+field+"_total", map[string]string{"device": dev}, "counter", v)
+			}
+			if v, ok := parseNum(f[9+i]); ok {
+				fn("node_network_transmit_"+field+"_total", map[string]string{"device": dev}, "counter", v)
+			}
+		}
+	})
+}
+
+var virtualDisk = regexp.MustCompile(`^(loop|ram|zram|dm-)\d+$`)
+
+func (c *systemCollector) disks(fn sampleFunc) {
+	eachLine(filepath.Join(c.proc, "diskstats"), func(f []string) {
+		if len(f) < 14 || virtualDisk.MatchString(f[2]) {
+			return
+		}
+		labels := map[string]string{"device": f[2]}
+		for _, m := range []struct {
+			col   int
+			name  string
+			scale float64
+		}{
+			{3, "node_disk_reads_completed_total", 1},
+			{5, "node_disk_read_bytes_total", sectorSize},
+			{7, "node_disk_writes_completed_total", 1},
+			{9, "node_disk_written_bytes_total", sectorSize},
+			{12, "node_disk_io_time_seconds_total", 0.001},
+		} {
+			if v, ok := parseNum(f[m.col]); ok {
+				fn(m.name, labels, "counter", v*m.scale)
+			}
+		}
+	})
+}
+
+// cgroupStats reads madvisor's own cgroup v2: the container it runs in.
+func (c *systemCollector) cgroupStats(fn sampleFunc) {
+	eachLine(filepath.Join(c.cgroup, "cpu.stat"), func(f []string) {
+		if len(f) != 2 {
+			return
+		}
+		v, ok := parseNum(f[1])
+		if !ok {
+			return
+		}
+		switch f[0] {
+		case "usage_usec":
+			fn("container_cpu_usage_seconds_total", nil, "counter", v/1e6)
+		case "user_usec":
+			fn("container_cpu_user_seconds_total", nil, "counter", v/1e6)
+		case "system_usec":
+			fn("container_cpu_system_seconds_total", nil, "counter", v/1e6)
+		case "nr_throttled":
+			fn("container_cpu_cfs_throttled_periods_total", nil, "counter", v)
+		}
+	})
+	b, err := os.ReadFile(filepath.Join(c.cgroup, "memory.current"))
+	if err != nil {
+		return
+	}
+	usage, ok := parseNum(strings.TrimSpace(string(b)))
+	if !ok {
+		return
+	}
+	fn("container_memory_usage_bytes", nil, "gauge", usage)
+	inactive := 0.0
+	eachLine(filepath.Join(c.cgroup, "memory.stat"), func(f []string) {
+		if len(f) == 2 && f[0] == "inactive_file" {
+			inactive, _ = parseNum(f[1])
+		}
+	})
+	fn("container_memory_working_set_bytes", nil, "gauge", max(usage-inactive, 0))
+	eachLine(filepath.Join(c.cgroup, "io.stat"), func(f []string) {
+		if len(f) < 2 {
+			return
+		}
+		labels := map[string]string{"device": f[0]}
+		for _, kv := range f[1:] {
+			k, val, _ := strings.Cut(kv, "=")
+			v, ok := parseNum(val)
+			if !ok {
+				continue
+			}
+			switch k {
+			case "rbytes":
+				fn("container_fs_reads_bytes_total", labels, "counter", v)
+			case "wbytes":
+				fn("container_fs_writes_bytes_total", labels, "counter", v)
+			}
+		}
+	})
+}
+
+// processes reports CPU, memory, threads and I/O of every process whose name
+// matches procs, labelled with its pid and name.
+func (c *systemCollector) processes(fn sampleFunc) {
+	entries, err := os.ReadDir(c.proc)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(c.proc, e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ...: comm may hold spaces and parentheses.
+		open, end := strings.IndexByte(string(b), '('), strings.LastIndexByte(string(b), ')')
+		if open < 0 || end < open {
+			continue
+		}
+		comm := string(b[open+1 : end])
+		if !c.procs.MatchString(comm) {
+			continue
+		}
+		f := strings.Fields(string(b[end+1:]))
+		if len(f) < 22 {
+			continue
+		}
+		labels := map[string]string{"pid": e.Name(), "comm": comm}
+		utime, _ := parseNum(f[11])
+		stime, _ := parseNum(f[12])
+		threads, _ := parseNum(f[17])
+		rss, _ := parseNum(f[21])
+		fn("process_cpu_seconds_total", labels, "counter", (utime+stime)/userHZ)
+		fn("process_resident_memory_bytes", labels, "gauge", rss*c.page)
+		fn("process_threads", labels, "gauge", threads)
+		eachLine(filepath.Join(c.proc, e.Name(), "io"), func(f []string) {
+			if len(f) != 2 {
+				return
+			}
+			v, ok := parseNum(f[1])
+			if !ok {
+				return
+			}
+			switch f[0] {
+			case "read_bytes:":
+				fn("process_io_read_bytes_total", labels, "counter", v)
+			case "write_bytes:":
+				fn("process_io_write_bytes_total", labels, "counter", v)
+			}
+		})
+	}
+}
+
+// run samples every scrape interval into the store as the system target.
+func (c *systemCollector) run(ctx context.Context, st *store) {
+	ticker := time.NewTicker(scrapeInterval)
+	defer ticker.Stop()
+	tgt := target{addr: systemTarget}
+	for {
+		now := time.Now()
+		samples := 0
+		err := c.collect(func(name string, labels map[string]string, mtype string, val float64) {
+			samples++
+			labels = maps.Clone(labels)
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels[instanceLabel] = systemTarget
+			name, labels, keep := applyRelabel(globalRelabel, systemTarget, name, labels)
+			if keep {
+				st.ingest(systemTarget, name, labels, systemHelp, mtype, val, now)
+			}
+		})
+		if err == nil && samples == 0 {
+			err = errors.New("no statistics read")
+		}
+		st.scrapeDone(tgt, err, now)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSystemCollector(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"proc/stat":             "cpu  300 0 100 1000 0 0 0 0 0 0\ncpu0 150 0 50 500 10 0 0 0 0 0\nintr 12345\n",
+		"proc/meminfo":          "MemTotal:       16384 kB\nMemAvailable:    8192 kB\nActive(anon):     100 kB\nHugePages_Total:       0\n",
+		"proc/net/dev":          "Inter-|   Receive                                                |  Transmit\n face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n  eth0: 1000 10 0 1 0 0 0 0 2000 20 0 0 0 0 0 0\n",
+		"proc/diskstats":        "   8       0 sda 100 0 2000 50 40 0 800 30 0 1500 80\n   7       0 loop0 1 0 8 0 0 0 0 0 0 0 0\n",
+		"proc/42/stat":          "42 (java (main)) S 1 42 42 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 31 0 1000 100000 256 18446744073709551615\n",
+		"proc/42/io":            "rchar: 10\nread_bytes: 4096\nwrite_bytes: 8192\n",
+		"proc/43/stat":          "43 (sh) S 1 43 43 0 -1 4194560 100 0 0 0 1 1 0 0 20 0 1 0 1000 100000 10 18446744073709551615\n",
+		"cgroup/cpu.stat":       "usage_usec 2500000\nuser_usec 2000000\nsystem_usec 500000\nnr_throttled 3\n",
+		"cgroup/memory.current": "1048576\n",
+		"cgroup/memory.stat":    "anon 524288\ninactive_file 262144\n",
+		"cgroup/io.stat":        "8:0 rbytes=4096 wbytes=1024 rios=1 wios=1\n",
+	})
+	c := &systemCollector{proc: filepath.Join(dir, "proc"), cgroup: filepath.Join(dir, "cgroup"), procs: regexp.MustCompile("^java"), page: 4096}
+
+	got := map[string]string{}
+	if err := c.collect(func(name string, labels map[string]string, mtype string, val float64) {
+		var ls []string
+		for k, v := range labels {
+			ls = append(ls, k+"="+v)
+		}
+		sort.Strings(ls)
+		got[name+"{"+strings.Join(ls, ",")+"}"] = fmt.Sprintf("%s %g", mtype, val)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for series, want := range map[string]string{
+		"node_cpu_seconds_total{cpu=0,mode=user}":                "counter 1.5",
+		"node_cpu_seconds_total{cpu=0,mode=iowait}":              "counter 0.1",
+		"node_memory_MemAvailable_bytes{}":                       "gauge 8.388608e+06",
+		"node_memory_Active_anon_bytes{}":                        "gauge 102400",
+		"node_memory_HugePages_Total{}":                          "gauge 0",
+		"node_network_receive_bytes_total{device=eth0}":          "counter 1000",
+		"node_network_receive_drop_total{device=eth0}":           "counter 1",
+		"node_network_transmit_packets_total{device=eth0}":       "counter 20",
+		"node_disk_read_bytes_total{device=sda}":                 "counter 1.024e+06",
+		"node_disk_io_time_seconds_total{device=sda}":            "counter 1.5",
+		"container_cpu_usage_seconds_total{}":                    "counter 2.5",
+		"container_memory_working_set_bytes{}":                   "gauge 786432",
+		"container_fs_writes_bytes_total{device=8:0}":            "counter 1024",
+		"process_cpu_seconds_total{comm=java (main),pid=42}":     "counter 3",
+		"process_resident_memory_bytes{comm=java (main),pid=42}": "gauge 1.048576e+06",
+		"process_threads{comm=java (main),pid=42}":               "gauge 31",
+		"process_io_write_bytes_total{comm=java (main),pid=42}":  "counter 8192",
+	} {
+		if got[series] != want {
+			t.Errorf("%s = %q, want %q", series, got[series], want)
+		}
+	}
+	for series := range got {
+		if strings.Contains(series, "cpu=,") || strings.Contains(series, "loop0") || strings.Contains(series, "comm=sh") {
+			t.Errorf("unexpected series %s", series)
+		}
+	}
+
+	c.proc = filepath.Join(dir, "missing")
+	if err := c.collect(func(string, map[string]string, string, float64) {}); err == nil {
+		t.Error("collect without /proc/stat should fail")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// seriesTableText renders the series table of a metric for pasting into a
+// ticket: every row in table order, label values in full, columns aligned in
+// plain text or as a Markdown table.
+func seriesTableText(name string, list []*metricSeries, order tableSort, markdown bool, now time.Time) string {
+	cols := seriesColumns(list, labelKeys(list), 0)
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		c.max = 0
+		if markdown {
+			c.title = markdownCell(c.title)
+			for j := range c.cells {
+				c.cells[j] = markdownCell(c.cells[j])
+			}
+		}
+		widths[i] = max(c.width(), 3)
+	}
+
+	var b strings.Builder
+	title := fmt.Sprintf("%s — %d series, %s, at %s", name, len(list), sortLabel(order, len(list) > 0 && list[0].shouldRate()), now.Format("2006-01-02 15:04:05"))
+	if markdown {
+		title = "**" + markdownCell(name) + "**" + strings.TrimPrefix(title, name)
+	}
+	b.WriteString(title + "\n\n")
+	row := func(cell func(i int, c *tableColumn) string) {
+		parts := make([]string, len(cols))
+		for i, c := range cols {
+			parts[i] = cell(i, c)
+		}
+		if markdown {
+			b.WriteString("| " + strings.Join(parts, " | ") + " |\n")
+		} else {
+			b.WriteString(strings.TrimRight(strings.Join(parts, "  "), " ") + "\n")
+		}
+	}
+	row(func(i int, c *tableColumn) string { return c.format(c.title, widths[i]) })
+	row(func(i int, c *tableColumn) string {
+		if !markdown {
+			return strings.Repeat("-", widths[i])
+		}
+		if c.right {
+			return strings.Repeat("-", widths[i]-1) + ":"
+		}
+		return strings.Repeat("-", widths[i])
+	})
+	for j := range list {
+		row(func(i int, c *tableColumn) string { return c.format(c.cells[j], widths[i]) })
+	}
+	return b.String()
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// copyToClipboard sets the terminal's clipboard with OSC 52, which also
+// works over SSH.
+func copyToClipboard(s string) error {
+	if !termOut.send("\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(s)) + "\a") {
+		return fmt.Errorf("no terminal to copy to")
+	}
+	return nil
+}
+
+// copySeriesTable writes the selected metric's series table to path, or to
+// the clipboard when path is empty, and describes the outcome.
+func copySeriesTable(ui *uiState, st *store, format, path string) (string, error) {
+	name := ui.selectedKey()
+	order := ui.sortMode()
+	list := displayedSeries(ui, st)
+	if len(list) == 0 {
+		return "", fmt.Errorf("no series table to copy")
+	}
+	text := seriesTableText(name, list, order, format == "md", time.Now())
+	kind := map[string]string{"md": "Markdown", "text": "text"}[format]
+	if path != "" {
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d-row %s table of %s written to %s", len(list), kind, name, path), nil
+	}
+	if err := copyToClipboard(text); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-row %s table of %s copied to the clipboard", len(list), kind, name), nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSeriesTableText(t *testing.T) {
+	st := newStore()
+	st.update("queue_depth", map[string]string{"queue": "emails|high"}, "", "gauge", 12)
+	st.update("queue_depth", map[string]string{"queue": "a-very-long-queue-name-that-the-panel-truncates"}, "", "gauge", 3)
+	list := tableSeries(st, "queue_depth", "", sortValue)
+	at := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	text := seriesTableText("queue_depth", list, sortValue, false, at)
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if lines[0] != "queue_depth — 2 series, by value ↓, at 2026-10-18 09:30:00" {
+		t.Errorf("title = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], "queue  ") || !strings.HasPrefix(lines[3], "-----") {
+		t.Errorf("header = %q / %q", lines[2], lines[3])
+	}
+	if !strings.HasPrefix(lines[4], "emails|high") || !strings.Contains(lines[5], "a-very-long-queue-name-that-the-panel-truncates") {
+		t.Errorf("rows should follow the table order with labels in full:\n%s", text)
+	}
+	if utf8.RuneCountInString(lines[4]) != utf8.RuneCountInString(lines[5]) || !strings.Contains(lines[5], "   3.00") {
+		t.Errorf("columns are not aligned:\n%s", text)
+	}
+
+	md := seriesTableText("queue_depth", list, sortValue, true, at)
+	for _, want := range []string{"**queue_depth** — 2 series", "| queue ", `| emails\|high `, "--: |"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown table lacks %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestTableScriptCommand(t *testing.T) {
+	var clip bytes.Buffer
+	termOut.attach(&clip)
+	defer termOut.attach(nil)
+
+	if _, err := parseScriptLine(1, "table html"); err == nil {
+		t.Error("unknown table format accepted")
+	}
+	st := newStore()
+	st.update("up", map[string]string{"job": "api"}, "", "gauge", 1)
+	ui := &uiState{}
+	ui.setKeys([]string{"up"})
+
+	runCommandLine("table md", ui, st)
+	if ui.message != "1-row Markdown table of up copied to the clipboard" {
+		t.Errorf("message = %q", ui.message)
+	}
+	if clip.Len() != 0 {
+		t.Error("the clipboard sequence should wait for the next redraw")
+	}
+	termOut.flush()
+	seq := clip.String()
+	if !strings.HasPrefix(seq, "\x1b]52;c;") || !strings.HasSuffix(seq, "\a") {
+		t.Fatalf("clipboard sequence = %q", seq)
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(seq, "\x1b]52;c;"), "\a"))
+	if !strings.Contains(string(decoded), "| api ") {
+		t.Errorf("clipboard = %q", decoded)
+	}
+
+	path := filepath.Join(t.TempDir(), "up.txt")
+	runCommandLine("table text "+path, ui, st)
+	if b, err := os.ReadFile(path); err != nil || !strings.Contains(string(b), "job") {
+		t.Errorf("table file = %q, %v", b, err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxTabs = 9
+
+// A tab is a workspace with its own group, filter and selection. Chart
+// settings such as transforms and the rate window stay shared.
+type tab struct {
+	used bool
+	view viewState
+}
+
+// switchTab saves the current workspace into the active tab and restores
+// tab n (1-based). A tab opened for the first time starts unfiltered.
+func (u *uiState) switchTab(st *store, n int) bool {
+	if n < 1 || n > maxTabs {
+		return false
+	}
+	u.mu.Lock()
+	if n-1 == u.activeTab {
+		u.mu.Unlock()
+		return false
+	}
+	cur := u.captureViewLocked()
+	u.tabs[u.activeTab] = tab{used: true, view: cur}
+	next := u.tabs[n-1]
+	u.activeTab = n - 1
+	u.tabs[u.activeTab].used = true
+	// Switching tabs is not a change to undo within the new tab.
+	u.restoring = true
+	u.mu.Unlock()
+
+	v := cur
+	v.group, v.filter, v.selected, v.seriesIdx, v.focus = next.view.group, next.view.filter, next.view.selected, next.view.seriesIdx, next.view.focus
+	u.applyView(st, v)
+	if v.selected == "" {
+		u.mu.Lock()
+		u.selectedIdx, u.scrollOffset = 0, 0
+		u.mu.Unlock()
+	}
+	return true
+}
+
+func (u *uiState) currentTab() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.activeTab + 1
+}
+
+// tabBar describes the opened tabs for the status bar, e.g.
+// "[1] 2:backend 3:/http"; it is empty until a second tab is used.
+func (u *uiState) tabBar() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var parts []string
+	for i, t := range u.tabs {
+		if !t.used && i != u.activeTab {
+			continue
+		}
+		group, filter := t.view.group, t.view.filter
+		if i == u.activeTab {
+			group, filter = u.groupFilter, u.filterText
+		}
+		label := fmt.Sprint(i + 1)
+		if group != "" {
+			label += ":" + group
+		}
+		if filter != "" {
+			label += ":/" + truncateText(filter, 12)
+		}
+		if i == u.activeTab {
+			label = "[" + label + "]"
+		}
+		parts = append(parts, label)
+	}
+	if len(parts) < 2 {
+		return ""
+	}
+	return strings.Join(parts, " ")
+}
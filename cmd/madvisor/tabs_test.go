@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSwitchTabKeepsWorkspaces(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	ui, st := undoFixture()
+
+	ui.setFilter("http")
+	ui.selectName("http_requests_total")
+
+	if !ui.switchTab(st, 2) {
+		t.Fatal("switchTab(2) = false")
+	}
+	if f := ui.captureView(); f.filter != "" || f.selected != "cpu_usage" {
+		t.Errorf("new tab view = %+v, want unfiltered", f)
+	}
+	ui.setFilter("mem")
+
+	ui.switchTab(st, 1)
+	if v := ui.captureView(); v.filter != "http" || v.selected != "http_requests_total" {
+		t.Errorf("tab 1 view = %+v, want filter http on http_requests_total", v)
+	}
+	if bar := ui.tabBar(); bar != "[1:/http] 2:/mem" {
+		t.Errorf("tabBar() = %q", bar)
+	}
+
+	ui.switchTab(st, 2)
+	if v := ui.captureView(); v.filter != "mem" || v.selected != "mem_bytes" {
+		t.Errorf("tab 2 view = %+v, want filter mem", v)
+	}
+	if ui.currentTab() != 2 {
+		t.Errorf("currentTab() = %d", ui.currentTab())
+	}
+}
+
+func TestSwitchTabSharesChartSettings(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	ui, st := undoFixture()
+	ui.setTransform("cpu_usage", transformNegate)
+	changeRateWindow(st, func() time.Duration { rateWindowSet(30 * time.Second); return rateWindowGet() })
+
+	ui.switchTab(st, 3)
+	if ui.transformFor("cpu_usage") != transformNegate || rateWindowGet() != 30*time.Second {
+		t.Error("chart settings should carry over between tabs")
+	}
+}
+
+func TestSwitchTabBounds(t *testing.T) {
+	ui, st := undoFixture()
+	for _, n := range []int{0, 1, 10} {
+		if ui.switchTab(st, n) {
+			t.Errorf("switchTab(%d) = true", n)
+		}
+	}
+	if bar := ui.tabBar(); bar != "" {
+		t.Errorf("tabBar() with one tab = %q, want empty", bar)
+	}
+}
+
+func TestSwitchTabNotUndone(t *testing.T) {
+	ui, st := undoFixture()
+	ui.setFilter("http")
+	before := ui.captureView()
+	ui.switchTab(st, 2)
+	ui.recordView(before)
+	if ui.undo(st) {
+		t.Error("switching tabs should not push an undo step")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const targetLookupTimeout = 3 * time.Second
+
+var lookupHost = net.DefaultResolver.LookupHost
+
+// checkTargets parses --targets and resolves every host. Malformed targets
+// are dropped; unresolvable ones are kept since DNS may catch up later. Both
+// are returned as issues, in the order they were given.
+func checkTargets(ctx context.Context, flagVal string) ([]target, []string) {
+	targets, err := targetsFromFlag(flagVal)
+	var issues []string
+	if err != nil {
+		for _, e := range splitErrors(err) {
+			issues = append(issues, e.Error())
+		}
+	}
+	return targets, append(issues, resolveTargets(ctx, targets)...)
+}
+
+func splitErrors(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
+
+func resolveTargets(ctx context.Context, targets []target) []string {
+	results := make([]string, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		host, _, err := net.SplitHostPort(t.addr)
+		if err != nil || net.ParseIP(host) != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, targetLookupTimeout)
+			defer cancel()
+			if _, err := lookupHost(ctx, host); err != nil {
+				var dnsErr *net.DNSError
+				if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+					err = errors.New("no such host")
+				}
+				results[i] = fmt.Sprintf("target %q: cannot resolve %s: %v", t.spec(), host, err)
+			}
+		}()
+	}
+	wg.Wait()
+	var issues []string
+	for _, r := range results {
+		if r != "" {
+			issues = append(issues, r)
+		}
+	}
+	return issues
+}
+
+// startupTargets validates --targets, failing under --strict-targets and
+// otherwise logging each issue and carrying on with what can be scraped.
+func startupTargets(ctx context.Context, flagVal string, logf func(string, ...any)) ([]target, []string, error) {
+	targets, issues := checkTargets(ctx, flagVal)
+	if len(issues) > 0 && flagStrictTargets {
+		return nil, nil, fmt.Errorf("%d invalid target(s):\n  %s", len(issues), strings.Join(issues, "\n  "))
+	}
+	for _, issue := range issues {
+		logf("madvisor: %s", issue)
+	}
+	if len(targets) == 0 && len(issues) > 0 {
+		return nil, nil, fmt.Errorf("no valid targets:\n  %s", strings.Join(issues, "\n  "))
+	}
+	return targets, issues, nil
+}
+
+func (st *store) setTargetIssues(issues []string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.targetIssues = issues
+}
+
+func (st *store) targetIssueList() []string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.targetIssues
+}
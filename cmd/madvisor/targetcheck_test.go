@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+func stubLookup(t *testing.T, bad ...string) {
+	t.Helper()
+	orig := lookupHost
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		for _, b := range bad {
+			if host == b {
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+		}
+		return []string{"192.0.2.1"}, nil
+	}
+	t.Cleanup(func() { lookupHost = orig })
+}
+
+func TestCheckTargets(t *testing.T) {
+	stubLookup(t, "nowhere")
+	targets, issues := checkTargets(context.Background(), "good:1,bad:port,nowhere:9100,[::1]:2")
+	if len(targets) != 3 {
+		t.Errorf("targets = %+v, want good, nowhere and ::1", targets)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("issues = %q, want 2", issues)
+	}
+	if !strings.Contains(issues[0], `"bad:port"`) || !strings.Contains(issues[1], "cannot resolve nowhere: no such host") {
+		t.Errorf("issues = %q", issues)
+	}
+}
+
+func TestResolveTargetsSkipsIPLiterals(t *testing.T) {
+	orig := lookupHost
+	defer func() { lookupHost = orig }()
+	lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return nil, errors.New("lookup should not be called for " + host)
+	}
+	if issues := resolveTargets(context.Background(), []target{{addr: "127.0.0.1:1"}, {addr: "[::1]:2"}}); len(issues) != 0 {
+		t.Errorf("issues = %q, want none", issues)
+	}
+}
+
+func TestStartupTargetsStrict(t *testing.T) {
+	stubLookup(t, "nowhere")
+	defer func(v bool) { flagStrictTargets = v }(flagStrictTargets)
+
+	var logged []string
+	logf := func(format string, args ...any) { logged = append(logged, format) }
+
+	flagStrictTargets = false
+	targets, issues, err := startupTargets(context.Background(), "good:1,nowhere:2", logf)
+	if err != nil || len(targets) != 2 || len(issues) != 1 || len(logged) != 1 {
+		t.Errorf("lenient: targets=%v issues=%q err=%v logged=%d", targets, issues, err, len(logged))
+	}
+
+	flagStrictTargets = true
+	if _, _, err := startupTargets(context.Background(), "good:1,nowhere:2", logf); err == nil || !strings.Contains(err.Error(), "1 invalid target(s)") {
+		t.Errorf("strict err = %v, want invalid target list", err)
+	}
+}
+
+func TestStartupTargetsNoneValid(t *testing.T) {
+	stubLookup(t)
+	defer func(v bool) { flagStrictTargets = v }(flagStrictTargets)
+	flagStrictTargets = false
+	if _, _, err := startupTargets(context.Background(), "a:b,c:d", func(string, ...any) {}); err == nil {
+		t.Error("want error when no target is valid")
+	}
+}
+
+func TestRenderTargetsWithIssues(t *testing.T) {
+	st := newStore()
+	issues := []string{`target "x:y": invalid port "y"`}
+	st.setTargetIssues(issues)
+	if got := st.targetIssueList(); len(got) != 1 || got[0] != issues[0] {
+		t.Errorf("targetIssueList() = %q", got)
+	}
+	w, err := text.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderTargets(w, st, nil, time.Now())
+}
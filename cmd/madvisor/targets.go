@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	groupLabel    = "group"
+	instanceLabel = "instance"
+	jobLabel      = "job"
+
+	defaultTargetPort = "8080"
+)
+
+type target struct {
+	addr   string
+	group  string
+	scheme string
+	path   string
+	query  string
+}
+
+const defaultMetricsPath = "/metrics"
+
+// url is the address scraped for t; addr stays host:port for the instance
+// label and relabelling.
+func (t target) url() string {
+	if t.query != "" {
+		return "http://" + t.addr + promqlRangePath
+	}
+	scheme, path := t.scheme, t.path
+	if scheme == envoyScheme {
+		scheme = "http"
+		if path == "" {
+			path = envoyStatsPath
+		}
+	}
+	if scheme == "" {
+		scheme = "http"
+	}
+	if path == "" {
+		path = defaultMetricsPath
+	}
+	return scheme + "://" + t.addr + path
+}
+
+// spec is the inverse of parseTarget, omitting the parts that are defaults.
+func (t target) spec() string {
+	if t.query != "" {
+		return promqlScheme + "://" + t.addr + "/" + t.query
+	}
+	if t.scheme == "" && t.path == "" {
+		return t.addr
+	}
+	if t.scheme == envoyScheme {
+		return envoyScheme + "://" + t.addr + t.path
+	}
+	return t.url()
+}
+
+// key identifies t as the source of its series and in health and runtime
+// add/remove, so two paths or queries on one host:port stay apart.
+func (t target) key() string {
+	return t.spec()
+}
+
+// targetsFromFlag resolves --targets, falling back to METRIC_TARGETS and
+// then localhost:8080. The valid targets are returned alongside any errors.
+func targetsFromFlag(flagVal string) ([]target, error) {
+	val := flagVal
+	if val == "" {
+		val = os.Getenv("METRIC_TARGETS")
+	}
+	if val == "" {
+		val = "localhost:8080"
+	}
+	return parseTargetList(val)
+}
+
+// parseTargetList parses "[group=]spec,spec;[group=]spec". Invalid specs are
+// skipped and reported together in the returned error. A promql:// spec
+// takes the rest of its group, since queries may contain commas.
+func parseTargetList(val string) ([]target, error) {
+	var targets []target
+	var errs []error
+	for _, spec := range strings.Split(val, ";") {
+		group := ""
+		if eq := strings.Index(spec, "="); eq >= 0 && !strings.ContainsAny(spec[:eq], ":/[") {
+			group = strings.TrimSpace(spec[:eq])
+			spec = spec[eq+1:]
+		}
+		parts := []string{spec}
+		if i := strings.Index(spec, promqlScheme+"://"); i >= 0 {
+			parts = append(strings.Split(spec[:i], ","), spec[i:])
+		} else {
+			parts = strings.Split(spec, ",")
+		}
+		for _, p := range parts {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			t, err := parseTarget(p)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			t.group = group
+			targets = append(targets, t)
+		}
+	}
+	return targets, errors.Join(errs...)
+}
+
+// parseTarget accepts host, host:port, [v6]:port, a bare IPv6 literal, a
+// full http(s) URL, promql://host:port/<query> or envoy://host:port. A
+// missing port defaults to 8080 (80/443 when a scheme is given, 9090 for
+// promql, 15000 for the Envoy admin) and a missing path to /metrics, or
+// /stats?format=prometheus for envoy.
+func parseTarget(spec string) (target, error) {
+	var t target
+	rest := spec
+	if i := strings.Index(rest, "://"); i >= 0 {
+		t.scheme = strings.ToLower(rest[:i])
+		if t.scheme != "http" && t.scheme != "https" && t.scheme != promqlScheme && t.scheme != envoyScheme {
+			return target{}, fmt.Errorf("target %q: unsupported scheme %q", spec, t.scheme)
+		}
+		rest = rest[i+3:]
+	}
+	hostport := rest
+	if t.scheme == promqlScheme {
+		q, err := parsePromQLQuery(spec, rest)
+		if err != nil {
+			return target{}, err
+		}
+		hostport, t.query = rest[:strings.Index(rest, "/")], q
+	} else if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		hostport, t.path = rest[:i], rest[i:]
+		if !strings.HasPrefix(t.path, "/") {
+			t.path = "/" + t.path
+		}
+	}
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+			return target{}, fmt.Errorf("target %q: invalid host:port", spec)
+		}
+		switch t.scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		case promqlScheme:
+			port = defaultPromQLPort
+		case envoyScheme:
+			port = defaultEnvoyPort
+		default:
+			port = defaultTargetPort
+		}
+	}
+	if host == "" || strings.ContainsAny(host, " []@") {
+		return target{}, fmt.Errorf("target %q: invalid host", spec)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return target{}, fmt.Errorf("target %q: invalid port %q", spec, port)
+	}
+	t.addr = net.JoinHostPort(host, port)
+	if t.path == defaultMetricsPath && t.scheme != envoyScheme || t.path == envoyStatsPath && t.scheme == envoyScheme {
+		t.path = ""
+	}
+	if t.scheme == "http" || t.scheme == promqlScheme {
+		t.scheme = ""
+	}
+	return t, nil
+}
+
+// attachLabels adds t's group and job labels to a scraped sample's labels.
+// As in Prometheus, a label the exporter already set is kept as
+// exported_<name>, or wins when honor_labels is set.
+func (t target) attachLabels(labels map[string]string) map[string]string {
+	labels = attachTargetLabel(labels, groupLabel, t.group)
+	return attachTargetLabel(labels, jobLabel, t.job())
+}
+
+func attachTargetLabel(labels map[string]string, name, value string) map[string]string {
+	if value == "" {
+		return labels
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if v, ok := labels[name]; ok {
+		if globalHonorLabels {
+			return labels
+		}
+		labels["exported_"+name] = v
+	}
+	labels[name] = value
+	return labels
+}
+
+func targetGroups(targets []target) []string {
+	var groups []string
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if t.group != "" && !seen[t.group] {
+			seen[t.group] = true
+			groups = append(groups, t.group)
+		}
+	}
+	return groups
+}
+
+func formatTargets(targets []target) string {
+	var parts []string
+	idx := map[string]int{}
+	for _, t := range targets {
+		if t.group == "" {
+			parts = append(parts, t.addr)
+			continue
+		}
+		if i, ok := idx[t.group]; ok {
+			parts[i] = strings.TrimSuffix(parts[i], ")") + ", " + t.addr + ")"
+			continue
+		}
+		idx[t.group] = len(parts)
+		parts = append(parts, t.group+"("+t.addr+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func inGroup(s *metricSeries, group string) bool {
+	if src, ok := targetScope(group); ok {
+		return s.source == src
+	}
+	return group == "" || s.labels[groupLabel] == group
+}
+
+func filterGroup(list []*metricSeries, group string) []*metricSeries {
+	if group == "" {
+		return list
+	}
+	var out []*metricSeries
+	for _, s := range list {
+		if inGroup(s, group) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (st *store) addTarget(t target) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.removedTargets, t.key())
+	for _, have := range st.addedTargets {
+		if have.key() == t.key() {
+			return false
+		}
+	}
+	st.addedTargets = append(st.addedTargets, t)
+	return true
+}
+
+func (st *store) removeTarget(key string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i, t := range st.addedTargets {
+		if t.key() == key {
+			st.addedTargets = append(st.addedTargets[:i:i], st.addedTargets[i+1:]...)
+			return
+		}
+	}
+	if st.removedTargets == nil {
+		st.removedTargets = map[string]bool{}
+	}
+	st.removedTargets[key] = true
+}
+
+// activeTargets applies the targets added or removed at runtime to the
+// targets given on the command line.
+func (st *store) activeTargets(base []target) []target {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	out := make([]target, 0, len(base)+len(st.addedTargets))
+	seen := map[string]bool{}
+	for _, t := range base {
+		if !st.removedTargets[t.key()] && !seen[t.key()] {
+			seen[t.key()] = true
+			out = append(out, t)
+		}
+	}
+	for _, t := range st.addedTargets {
+		if !seen[t.key()] {
+			seen[t.key()] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
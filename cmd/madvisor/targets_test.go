@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- parseTargets tests ---
+
+// parseTargets resolves --targets like the CLI, dropping any errors.
+func parseTargets(flagVal string) []target {
+	targets, _ := targetsFromFlag(flagVal)
+	return targets
+}
+
+func TestParseTargets(t *testing.T) {
+	os.Setenv("METRIC_TARGETS", "host1:8080,host2:9090")
+	defer os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("")
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2", len(got))
+	}
+	if got[0].addr != "host1:8080" || got[1].addr != "host2:9090" {
+		t.Errorf("parseTargets() = %v", got)
+	}
+}
+
+func TestParseTargetsDefault(t *testing.T) {
+	os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("")
+	if len(got) != 1 || got[0].addr != "localhost:8080" {
+		t.Errorf("parseTargets() default = %v, want [localhost:8080]", got)
+	}
+}
+
+func TestParseTargetsTrimsWhitespace(t *testing.T) {
+	os.Setenv("METRIC_TARGETS", " host1:8080 , host2:9090 ")
+	defer os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("")
+	if len(got) != 2 || got[0].addr != "host1:8080" || got[1].addr != "host2:9090" {
+		t.Errorf("parseTargets() = %v", got)
+	}
+}
+
+func TestParseTargetsSkipsEmpty(t *testing.T) {
+	os.Setenv("METRIC_TARGETS", "host1:8080,,host2:9090,")
+	defer os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("")
+	if len(got) != 2 {
+		t.Errorf("parseTargets() len = %d, want 2 (skip empties)", len(got))
+	}
+}
+
+func TestParseTargetsFlagOverridesEnv(t *testing.T) {
+	os.Setenv("METRIC_TARGETS", "envhost:8080")
+	defer os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("flaghost:9090")
+	if len(got) != 1 || got[0].addr != "flaghost:9090" {
+		t.Errorf("parseTargets(flag) = %v, want [flaghost:9090]", got)
+	}
+}
+
+func TestParseTargetsFlagOnly(t *testing.T) {
+	os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("a:1,b:2")
+	if len(got) != 2 || got[0].addr != "a:1" || got[1].addr != "b:2" {
+		t.Errorf("parseTargets(flag) = %v, want [a:1 b:2]", got)
+	}
+}
+
+func TestParseTargetsGroups(t *testing.T) {
+	os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("api=host1:8080,host2:8080;db=host3:9187")
+	want := []target{
+		{addr: "host1:8080", group: "api"},
+		{addr: "host2:8080", group: "api"},
+		{addr: "host3:9187", group: "db"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseTargets()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseTargetsMixedGroups(t *testing.T) {
+	os.Unsetenv("METRIC_TARGETS")
+
+	got := parseTargets("a:1; web = b:2 ")
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2", len(got))
+	}
+	if got[0].group != "" || got[0].addr != "a:1" {
+		t.Errorf("got[0] = %+v, want ungrouped a:1", got[0])
+	}
+	if got[1].group != "web" || got[1].addr != "b:2" {
+		t.Errorf("got[1] = %+v, want web/b:2", got[1])
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		spec string
+		addr string
+		url  string
+	}{
+		{"host:9100", "host:9100", "http://host:9100/metrics"},
+		{"host", "host:8080", "http://host:8080/metrics"},
+		{"[::1]:9100", "[::1]:9100", "http://[::1]:9100/metrics"},
+		{"[::1]", "[::1]:8080", "http://[::1]:8080/metrics"},
+		{"::1", "[::1]:8080", "http://[::1]:8080/metrics"},
+		{"http://host", "host:80", "http://host:80/metrics"},
+		{"https://host", "host:443", "https://host:443/metrics"},
+		{"HTTPS://[fe80::1]:8443/metrics", "[fe80::1]:8443", "https://[fe80::1]:8443/metrics"},
+		{"host:9100/custom/path?x=1", "host:9100", "http://host:9100/custom/path?x=1"},
+		{"envoy://sidecar", "sidecar:15000", "http://sidecar:15000/stats?format=prometheus"},
+		{"envoy://pod:15090/stats/prometheus", "pod:15090", "http://pod:15090/stats/prometheus"},
+	}
+	for _, tt := range tests {
+		got, err := parseTarget(tt.spec)
+		if err != nil {
+			t.Errorf("parseTarget(%q) error: %v", tt.spec, err)
+			continue
+		}
+		if got.addr != tt.addr || got.url() != tt.url {
+			t.Errorf("parseTarget(%q) = %s %s, want %s %s", tt.spec, got.addr, got.url(), tt.addr, tt.url)
+		}
+		back, err := parseTarget(got.spec())
+		if err != nil || back != got {
+			t.Errorf("spec() round trip of %q = %+v (%v), want %+v", tt.spec, back, err, got)
+		}
+	}
+}
+
+func TestParseTargetInvalid(t *testing.T) {
+	for _, spec := range []string{"host:abc", "host:0", "host:70000", "ftp://host", "a:b:c", ":8080", "[::1", "my host:80"} {
+		if got, err := parseTarget(spec); err == nil {
+			t.Errorf("parseTarget(%q) = %+v, want error", spec, got)
+		}
+	}
+}
+
+func TestParseTargetListGroupsURLs(t *testing.T) {
+	got, err := parseTargetList("api=https://a/m,[::1]:1;http://b:2/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].group != "api" || got[1].group != "api" || got[2].group != "" {
+		t.Fatalf("parseTargetList() = %+v", got)
+	}
+	if got[0].url() != "https://a:443/m" || got[1].addr != "[::1]:1" || got[2].url() != "http://b:2/x" {
+		t.Errorf("parseTargetList() = %+v", got)
+	}
+}
+
+func TestTargetsFromFlagReportsErrors(t *testing.T) {
+	got, err := targetsFromFlag("good:1,bad:port,also:bad")
+	if err == nil || !strings.Contains(err.Error(), `"bad:port"`) || !strings.Contains(err.Error(), `"also:bad"`) {
+		t.Errorf("err = %v, want both bad targets listed", err)
+	}
+	if len(got) != 1 || got[0].addr != "good:1" {
+		t.Errorf("targets = %+v, want only good:1", got)
+	}
+}
+
+func TestTargetGroups(t *testing.T) {
+	targets := parseTargets("api=a:1,a:2;db=b:1;c:1")
+	got := targetGroups(targets)
+	if len(got) != 2 || got[0] != "api" || got[1] != "db" {
+		t.Errorf("targetGroups() = %v, want [api db]", got)
+	}
+}
+
+func TestFormatTargets(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"a:1,b:2", "a:1, b:2"},
+		{"api=a:1,a:2;db=b:1", "api(a:1, a:2), db(b:1)"},
+	}
+	for _, tt := range tests {
+		if got := formatTargets(parseTargets(tt.in)); got != tt.want {
+			t.Errorf("formatTargets(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTargetAttachLabels(t *testing.T) {
+	if got := (target{addr: "a:1"}).attachLabels(nil); got != nil {
+		t.Errorf("ungrouped attachLabels(nil) = %v, want nil", got)
+	}
+
+	got := (target{addr: "a:1", group: "api"}).attachLabels(map[string]string{"group": "x"})
+	if got["group"] != "api" {
+		t.Errorf("group = %q, want api", got["group"])
+	}
+	if got["exported_group"] != "x" {
+		t.Errorf("exported_group = %q, want x", got["exported_group"])
+	}
+}
+
+func TestFilterGroup(t *testing.T) {
+	a := newTestSeries("m", map[string]string{"group": "api"})
+	b := newTestSeries("m", map[string]string{"group": "db"})
+	c := newTestSeries("m", nil)
+	list := []*metricSeries{a, b, c}
+
+	if got := filterGroup(list, ""); len(got) != 3 {
+		t.Errorf("filterGroup(\"\") = %d, want 3", len(got))
+	}
+	if got := filterGroup(list, "db"); len(got) != 1 || got[0] != b {
+		t.Errorf("filterGroup(db) = %v, want [b]", got)
+	}
+}
+
+func TestScrapeTargetAttachesGroup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "up 1\n")
+	}))
+	defer srv.Close()
+
+	st := newStore()
+	scrapeTarget(&http.Client{}, target{addr: strings.TrimPrefix(srv.URL, "http://"), group: "api"}, st)
+
+	if st.get("up{group=api}") == nil {
+		t.Errorf("missing up{group=api}, got %v", st.snapshot())
+	}
+}
+
+func TestStoreNamesInGroup(t *testing.T) {
+	st := newStore()
+	st.update("cpu", map[string]string{"group": "api"}, "", "gauge", 1)
+	st.update("mem", map[string]string{"group": "db"}, "", "gauge", 1)
+	st.update("up", map[string]string{"group": "api"}, "", "gauge", 1)
+	st.update("up", map[string]string{"group": "db"}, "", "gauge", 1)
+
+	got := st.namesInGroup("api")
+	if len(got) != 2 || got[0] != "cpu" || got[1] != "up" {
+		t.Errorf("namesInGroup(api) = %v, want [cpu up]", got)
+	}
+}
+
+func TestUIStateCycleGroup(t *testing.T) {
+	u := &uiState{}
+	groups := []string{"api", "db"}
+
+	if got := u.cycleGroup(groups); got != "api" {
+		t.Errorf("cycle 1 = %q, want api", got)
+	}
+	if got := u.cycleGroup(groups); got != "db" {
+		t.Errorf("cycle 2 = %q, want db", got)
+	}
+	if got := u.cycleGroup(groups); got != "" {
+		t.Errorf("cycle 3 = %q, want all", got)
+	}
+	if got := u.cycleGroup(nil); got != "" {
+		t.Errorf("cycle with no groups = %q, want all", got)
+	}
+}
+
+func TestStoreIngestCollision(t *testing.T) {
+	st := newStore()
+	now := time.Now()
+	labels := map[string]string{"code": "200"}
+
+	st.ingest("a:1", "req_total", labels, "", "counter", 1, now)
+	if st.get("req_total{code=200}") == nil {
+		t.Fatal("first target should keep the plain series key")
+	}
+	if len(st.collisionList()) != 0 {
+		t.Fatal("no collision expected yet")
+	}
+
+	st.ingest("b:2", "req_total", map[string]string{"code": "200"}, "", "counter", 5, now)
+	if got := st.collisionList(); len(got) != 1 || !strings.Contains(got[0], "a:1") || !strings.Contains(got[0], "b:2") {
+		t.Errorf("collisions = %v, want one naming both targets", got)
+	}
+	if st.get("req_total{code=200}") != nil {
+		t.Error("colliding series should be re-keyed with an instance label")
+	}
+	a := st.get("req_total{code=200,instance=a:1}")
+	b := st.get("req_total{code=200,instance=b:2}")
+	if a == nil || b == nil {
+		t.Fatalf("missing instance-labelled series: %v", st.snapshot())
+	}
+	if a.last() != 1 || b.last() != 5 {
+		t.Errorf("values = %v / %v, want 1 / 5", a.last(), b.last())
+	}
+
+	st.ingest("a:1", "req_total", map[string]string{"code": "200"}, "", "counter", 2, now.Add(time.Second))
+	if a.count() != 2 || a.last() != 2 {
+		t.Errorf("later samples from a:1 should land in the re-keyed series, count=%d last=%v", a.count(), a.last())
+	}
+	if len(st.snapshot()) != 2 {
+		t.Errorf("series = %d, want 2", len(st.snapshot()))
+	}
+	if labels["instance"] != "" {
+		t.Error("ingest must not mutate the caller's labels")
+	}
+}
+
+func TestStoreIngestSameSource(t *testing.T) {
+	st := newStore()
+	st.ingest("a:1", "up", nil, "", "gauge", 1, time.Now())
+	st.ingest("a:1", "up", nil, "", "gauge", 1, time.Now())
+	if len(st.collisionList()) != 0 || st.get("up") == nil {
+		t.Error("repeated scrapes of one target are not collisions")
+	}
+}
+
+func TestWithInstance(t *testing.T) {
+	got := withInstance(map[string]string{"instance": "exporter"}, "a:1")
+	if got["instance"] != "a:1" || got["exported_instance"] != "exporter" {
+		t.Errorf("withInstance = %v", got)
+	}
+}
+
+func TestTargetsKeyedBySpec(t *testing.T) {
+	a, _ := parseTarget("http://h:9090/a")
+	b, _ := parseTarget("http://h:9090/b")
+	q1, _ := parseTarget("promql://prom:9090/up")
+	q2, _ := parseTarget("promql://prom:9090/rate(x[1m])")
+	base := []target{a, b, q1, q2}
+
+	st := newStore()
+	if got := st.activeTargets(base); len(got) != 4 {
+		t.Fatalf("active = %v, want paths and queries on one host:port kept apart", got)
+	}
+	st.removeTarget(a.key())
+	if got := st.activeTargets(base); len(got) != 3 || got[0].key() != b.key() {
+		t.Errorf("active after removing %s = %v, want only it dropped", a.key(), got)
+	}
+
+	st.scrapeDone(q1, nil, time.Now())
+	st.scrapeDone(q2, fmt.Errorf("bad query"), time.Now())
+	if h1, h2 := st.targetHealth(q1.key()), st.targetHealth(q2.key()); h1.ok != 1 || h1.failed != 0 || h2.failed != 1 {
+		t.Errorf("health = %+v / %+v, want each query tracked on its own", h1, h2)
+	}
+}
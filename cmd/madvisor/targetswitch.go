@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/widgets/text"
+)
+
+// targetScopePrefix marks a group filter that scopes the view to the series
+// scraped from (or pushed by) one source, rather than a target group.
+const targetScopePrefix = "@"
+
+const pickerWindow = 12
+
+func targetScope(group string) (string, bool) {
+	return strings.CutPrefix(group, targetScopePrefix)
+}
+
+func (st *store) sources() []string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range st.series {
+		if s.source != "" && !seen[s.source] {
+			seen[s.source] = true
+			out = append(out, s.source)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+type pickerRow struct {
+	label  string
+	source string
+	scrape bool
+}
+
+// pickerRows lists "all targets" followed by every target and any other
+// source (push, influx, SNMP) that fuzzily matches the query.
+func pickerRows(st *store, targets []target, query string) []pickerRow {
+	rows := []pickerRow{{label: "all targets"}}
+	seen := map[string]bool{}
+	for _, t := range targets {
+		seen[t.key()] = true
+		rows = append(rows, pickerRow{label: t.title(), source: t.key(), scrape: true})
+	}
+	for _, src := range st.sources() {
+		if !seen[src] {
+			rows = append(rows, pickerRow{label: src, source: src})
+		}
+	}
+	if query == "" {
+		return rows
+	}
+	var out []pickerRow
+	for _, r := range rows[1:] {
+		if fuzzyMatch(r.label, query) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// fuzzyMatch reports whether the runes of query appear in s in order,
+// ignoring case.
+func fuzzyMatch(s, query string) bool {
+	s, query = strings.ToLower(s), strings.ToLower(query)
+	for _, r := range query {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+func (u *uiState) openTargetPicker() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.bottom = panelTargets
+	u.picking = true
+	u.pickQuery = ""
+	u.pickIdx = 0
+}
+
+func (u *uiState) targetPicker() (bool, string, int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.picking, u.pickQuery, u.pickIdx
+}
+
+func (u *uiState) closeTargetPicker() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.picking = false
+}
+
+func (u *uiState) pickerType(r rune) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.pickQuery += string(r)
+	u.pickIdx = 0
+}
+
+func (u *uiState) pickerBackspace() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.pickQuery != "" {
+		u.pickQuery = u.pickQuery[:len(u.pickQuery)-1]
+	}
+	u.pickIdx = 0
+}
+
+func (u *uiState) pickerMove(delta, rows int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.pickIdx = max(0, min(u.pickIdx+delta, rows-1))
+}
+
+// pickTarget scopes the view to the chosen row and closes the picker.
+func (u *uiState) pickTarget(st *store, row pickerRow) {
+	u.mu.Lock()
+	u.picking = false
+	u.bottom = panelNone
+	u.mu.Unlock()
+	group := ""
+	if row.source != "" {
+		group = targetScopePrefix + row.source
+	}
+	u.setGroup(group)
+	u.setKeys(visibleNames(st, group))
+}
+
+func pickerMark(st *store, row pickerRow) (string, cell.Color) {
+	if !row.scrape {
+		return "·", cell.ColorNumber(245)
+	}
+	h := st.targetHealth(row.source)
+	switch {
+	case h.ok+h.failed == 0:
+		return "○", cell.ColorYellow
+	case h.degraded():
+		return "✗", cell.ColorRed
+	}
+	return "●", cell.ColorGreen
+}
+
+func renderTargetPicker(w *text.Text, st *store, targets []target, query string, cursor int, current string, now time.Time) {
+	w.Reset()
+	w.Write(" target: ", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+	w.Write(query+"█", text.WriteCellOpts(cell.FgColor(cell.ColorWhite)))
+	w.Write("   ↑↓ select │ Enter scope the sidebar │ Esc close\n", text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+
+	rows := pickerRows(st, targets, query)
+	if len(rows) == 0 {
+		w.Write("  no target matches", text.WriteCellOpts(cell.FgColor(cell.ColorRed)))
+		return
+	}
+	start := max(0, cursor-pickerWindow+1)
+	for i := start; i < len(rows) && i < start+pickerWindow; i++ {
+		r := rows[i]
+		prefix := "  "
+		fg := cell.ColorWhite
+		if i == cursor {
+			prefix, fg = "▸ ", cell.ColorCyan
+		}
+		mark, color := pickerMark(st, r)
+		w.Write(prefix, text.WriteCellOpts(cell.FgColor(fg)))
+		w.Write(mark+" ", text.WriteCellOpts(cell.FgColor(color)))
+		w.Write(fmt.Sprintf("%-40s", truncateText(r.label, 40)), text.WriteCellOpts(cell.FgColor(fg)))
+		if r.scrape {
+			w.Write(" "+st.targetHealth(r.source).summary(now), text.WriteCellOpts(cell.FgColor(cell.ColorNumber(245))))
+		}
+		if (r.source == "" && current == "") || (r.source != "" && current == targetScopePrefix+r.source) {
+			w.Write("  (current)", text.WriteCellOpts(cell.FgColor(cell.ColorYellow)))
+		}
+		w.Write("\n")
+	}
+}
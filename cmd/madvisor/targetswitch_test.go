@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		s, q string
+		want bool
+	}{
+		{"api/web-7d9f:8080", "web7", true},
+		{"api/web-7d9f:8080", "API", true},
+		{"api/web-7d9f:8080", "8080web", false},
+		{"db:5432", "", true},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.s, tt.q); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.s, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestStoreAttributesSource(t *testing.T) {
+	st := newStore()
+	now := time.Now()
+	st.ingest("a:1", "up", nil, "", "gauge", 1, now)
+	st.ingest("b:2", "up", nil, "", "gauge", 1, now)
+	st.ingest("b:2", "queue_depth", nil, "", "gauge", 3, now)
+
+	if got := st.sources(); !reflect.DeepEqual(got, []string{"a:1", "b:2"}) {
+		t.Errorf("sources() = %v", got)
+	}
+	if got := st.namesInGroup(targetScopePrefix + "a:1"); !reflect.DeepEqual(got, []string{"up"}) {
+		t.Errorf("names from a:1 = %v, want [up]", got)
+	}
+	if got := filterGroup(st.seriesForName("up"), targetScopePrefix+"b:2"); len(got) != 1 || got[0].labels[instanceLabel] != "b:2" {
+		t.Errorf("up from b:2 = %+v", got)
+	}
+}
+
+func TestPickerRows(t *testing.T) {
+	st := newStore()
+	st.ingest("push:job", "x", nil, "", "gauge", 1, time.Now())
+	targets := []target{{addr: "web-1:80", group: "api"}, {addr: "db:5432"}}
+
+	rows := pickerRows(st, targets, "")
+	var labels []string
+	for _, r := range rows {
+		labels = append(labels, r.label)
+	}
+	if !reflect.DeepEqual(labels, []string{"all targets", "api/web-1:80", "db:5432", "push:job"}) {
+		t.Errorf("rows = %v", labels)
+	}
+	if rows := pickerRows(st, targets, "apiweb"); len(rows) != 1 || rows[0].source != "web-1:80" {
+		t.Errorf("query rows = %+v", rows)
+	}
+}
+
+func TestPickTargetScopesView(t *testing.T) {
+	st := newStore()
+	now := time.Now()
+	st.ingest("a:1", "up", nil, "", "gauge", 1, now)
+	st.ingest("b:2", "queue_depth", nil, "", "gauge", 1, now)
+	ui := &uiState{}
+	ui.setKeys(st.names())
+
+	ui.openTargetPicker()
+	ui.pickerType('b')
+	picking, query, _ := ui.targetPicker()
+	if !picking || query != "b" || ui.panel() != panelTargets {
+		t.Fatalf("picker = %v %q", picking, query)
+	}
+	ui.pickTarget(st, pickerRow{label: "b:2", source: "b:2"})
+	if picking, _, _ := ui.targetPicker(); picking || ui.panel() == panelTargets {
+		t.Error("picking a target should close the picker")
+	}
+	if got := ui.group(); got != "@b:2" {
+		t.Errorf("group() = %q", got)
+	}
+	if filtered, _, _, _, _ := ui.snapshot(); !reflect.DeepEqual(filtered, []string{"queue_depth"}) {
+		t.Errorf("sidebar = %v", filtered)
+	}
+
+	ui.pickTarget(st, pickerRow{label: "all targets"})
+	if ui.group() != "" {
+		t.Errorf("all targets should clear the scope, got %q", ui.group())
+	}
+}
+
+func TestPickerMoveClamps(t *testing.T) {
+	ui := &uiState{}
+	ui.openTargetPicker()
+	ui.pickerMove(-1, 3)
+	ui.pickerMove(5, 3)
+	if _, _, cursor := ui.targetPicker(); cursor != 2 {
+		t.Errorf("cursor = %d, want 2", cursor)
+	}
+}
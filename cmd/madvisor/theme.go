@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mum4k/termdash/cell"
+)
+
+// themes are the palettes charts draw their series in, one color per
+// series in turn.
+var themes = map[string][]cell.Color{
+	"default": {
+		cell.ColorGreen,
+		cell.ColorCyan,
+		cell.ColorMagenta,
+		cell.ColorYellow,
+		cell.ColorBlue,
+		cell.ColorRed,
+		cell.ColorWhite,
+	},
+	// solarized uses the Solarized accent colors from the 256-color cube.
+	"solarized": {
+		cell.ColorNumber(136), // yellow
+		cell.ColorNumber(33),  // blue
+		cell.ColorNumber(166), // orange
+		cell.ColorNumber(37),  // cyan
+		cell.ColorNumber(160), // red
+		cell.ColorNumber(64),  // green
+		cell.ColorNumber(125), // magenta
+		cell.ColorNumber(61),  // violet
+	},
+	// colorblind is the Okabe-Ito palette, distinguishable with the common
+	// color vision deficiencies.
+	"colorblind": {
+		cell.ColorNumber(214), // orange
+		cell.ColorNumber(74),  // sky blue
+		cell.ColorNumber(36),  // bluish green
+		cell.ColorNumber(227), // yellow
+		cell.ColorNumber(25),  // blue
+		cell.ColorNumber(166), // vermillion
+		cell.ColorNumber(175), // reddish purple
+	},
+}
+
+type themeState struct {
+	mu      sync.Mutex
+	name    string
+	palette []cell.Color
+}
+
+var ts = themeState{name: "default", palette: themes["default"]}
+
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupTheme(name string) error {
+	if _, ok := themes[name]; !ok {
+		return fmt.Errorf("unknown theme %q (want %s)", name, strings.Join(themeNames(), ", "))
+	}
+	return nil
+}
+
+func setTheme(name string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.name, ts.palette = name, themes[name]
+}
+
+func themeName() string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.name
+}
+
+func colorForIndex(i int) cell.Color {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.palette[i%len(ts.palette)]
+}
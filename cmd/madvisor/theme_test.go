@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mum4k/termdash/cell"
+)
+
+func TestSetTheme(t *testing.T) {
+	defer setTheme("default")
+	if colorForIndex(0) != cell.ColorGreen || colorForIndex(7) != cell.ColorGreen {
+		t.Errorf("default palette should start with green and wrap after 7 colors")
+	}
+	setTheme("solarized")
+	if got := colorForIndex(1); got != cell.ColorNumber(33) {
+		t.Errorf("solarized colorForIndex(1) = %v, want blue (33)", got)
+	}
+	if err := lookupTheme("neon"); err == nil {
+		t.Error("lookupTheme(neon) should fail")
+	}
+	for _, name := range themeNames() {
+		if err := lookupTheme(name); err != nil || len(themes[name]) == 0 {
+			t.Errorf("theme %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/mum4k/termdash/cell"
+)
+
+var globalThresholds []compiledThreshold
+
+type ThresholdLine struct {
+	Value float64 `yaml:"value"`
+	Label string  `yaml:"label"`
+}
+
+type ThresholdRange struct {
+	From  float64 `yaml:"from"`
+	To    float64 `yaml:"to"`
+	Label string  `yaml:"label"`
+}
+
+type ThresholdConfig struct {
+	Metric string           `yaml:"metric"`
+	Lines  []ThresholdLine  `yaml:"lines"`
+	Ranges []ThresholdRange `yaml:"ranges"`
+}
+
+type compiledThreshold struct {
+	re     *regexp.Regexp
+	lines  []ThresholdLine
+	ranges []ThresholdRange
+}
+
+type referenceSeries struct {
+	label  string
+	values []float64
+	color  cell.Color
+}
+
+func compileThresholds(cfgs []ThresholdConfig) ([]compiledThreshold, error) {
+	out := make([]compiledThreshold, 0, len(cfgs))
+	for i, c := range cfgs {
+		if c.Metric == "" {
+			return nil, fmt.Errorf("thresholds[%d]: metric is required", i)
+		}
+		re, err := regexp.Compile(c.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("thresholds[%d]: compile metric pattern %q: %w", i, c.Metric, err)
+		}
+		for _, b := range c.Ranges {
+			if b.From >= b.To {
+				return nil, fmt.Errorf("thresholds[%d]: range %q needs from < to", i, b.Label)
+			}
+		}
+		out = append(out, compiledThreshold{re: re, lines: c.Lines, ranges: c.Ranges})
+	}
+	return out, nil
+}
+
+func thresholdsFor(rules []compiledThreshold, name string) ([]ThresholdLine, []ThresholdRange) {
+	var lines []ThresholdLine
+	var ranges []ThresholdRange
+	for _, r := range rules {
+		if r.re.MatchString(name) {
+			lines = append(lines, r.lines...)
+			ranges = append(ranges, r.ranges...)
+		}
+	}
+	return lines, ranges
+}
+
+func constantSeries(v float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
+
+// Reference series are named with a leading space: linechart draws series in
+// name order, so they are painted before (behind) the metric's own series.
+func referenceLines(rules []compiledThreshold, name string, n int) []referenceSeries {
+	lines, ranges := thresholdsFor(rules, name)
+	var out []referenceSeries
+	for i, b := range ranges {
+		label := b.Label
+		if label == "" {
+			label = "range" + strconv.Itoa(i)
+		}
+		out = append(out,
+			referenceSeries{label: " range " + label + " from", values: constantSeries(b.From, n), color: cell.ColorNumber(136)},
+			referenceSeries{label: " range " + label + " to", values: constantSeries(b.To, n), color: cell.ColorNumber(136)},
+		)
+	}
+	for i, l := range lines {
+		label := l.Label
+		if label == "" {
+			label = "line" + strconv.Itoa(i)
+		}
+		out = append(out, referenceSeries{label: " line " + label, values: constantSeries(l.Value, n), color: cell.ColorNumber(160)})
+	}
+	return out
+}
+
+func breachedThresholds(rules []compiledThreshold, name string, v float64) []string {
+	lines, ranges := thresholdsFor(rules, name)
+	var out []string
+	for _, l := range lines {
+		if v > l.Value {
+			out = append(out, thresholdLabel(l.Label, l.Value))
+		}
+	}
+	for _, b := range ranges {
+		if v >= b.From && v <= b.To {
+			out = append(out, thresholdLabel(b.Label, b.From))
+		}
+	}
+	return out
+}
+
+func thresholdLabel(label string, v float64) string {
+	if label != "" {
+		return label
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileThresholds(t *testing.T) {
+	rules, err := compileThresholds([]ThresholdConfig{{
+		Metric: "_duration_seconds$",
+		Lines:  []ThresholdLine{{Value: 0.3, Label: "SLO"}},
+		Ranges: []ThresholdRange{{From: 0.8, To: 1, Label: "warning"}},
+	}})
+	if err != nil {
+		t.Fatalf("compileThresholds: %v", err)
+	}
+	lines, ranges := thresholdsFor(rules, "http_request_duration_seconds")
+	if len(lines) != 1 || len(ranges) != 1 {
+		t.Errorf("thresholdsFor = %v, %v", lines, ranges)
+	}
+	if lines, ranges := thresholdsFor(rules, "http_requests_total"); lines != nil || ranges != nil {
+		t.Errorf("unrelated metric got %v, %v", lines, ranges)
+	}
+
+	bad := []ThresholdConfig{
+		{Lines: []ThresholdLine{{Value: 1}}},
+		{Metric: "("},
+		{Metric: "x", Ranges: []ThresholdRange{{From: 2, To: 1}}},
+	}
+	for _, b := range bad {
+		if _, err := compileThresholds([]ThresholdConfig{b}); err == nil {
+			t.Errorf("compileThresholds(%+v) should fail", b)
+		}
+	}
+}
+
+func TestReferenceLines(t *testing.T) {
+	rules, err := compileThresholds([]ThresholdConfig{
+		{Metric: "^cpu_usage_percent$", Lines: []ThresholdLine{{Value: 90}}},
+		{Metric: "^cpu_", Ranges: []ThresholdRange{{From: 80, To: 100, Label: "hot"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := referenceLines(rules, "cpu_usage_percent", 3)
+	var labels []string
+	for _, r := range refs {
+		if !strings.HasPrefix(r.label, " ") {
+			t.Errorf("reference %q must sort before metric series", r.label)
+		}
+		if len(r.values) != 3 {
+			t.Errorf("reference %q has %d points, want 3", r.label, len(r.values))
+		}
+		labels = append(labels, strings.TrimSpace(r.label))
+	}
+	want := []string{"range hot from", "range hot to", "line line0"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+	if refs[1].values[0] != 100 || refs[2].values[2] != 90 {
+		t.Errorf("unexpected values: %v %v", refs[1].values, refs[2].values)
+	}
+}
+
+func TestBreachedThresholds(t *testing.T) {
+	rules, err := compileThresholds([]ThresholdConfig{{
+		Metric: "latency",
+		Lines:  []ThresholdLine{{Value: 0.3, Label: "SLO"}, {Value: 1}},
+		Ranges: []ThresholdRange{{From: 0.8, To: 1, Label: "warning"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		v    float64
+		want []string
+	}{
+		{0.1, nil},
+		{0.5, []string{"SLO"}},
+		{0.9, []string{"SLO", "warning"}},
+		{2, []string{"SLO", "1"}},
+	}
+	for _, tt := range tests {
+		if got := breachedThresholds(rules, "latency", tt.v); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("breachedThresholds(%v) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
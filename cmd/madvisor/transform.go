@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type chartTransform int
+
+const (
+	transformNone chartTransform = iota
+	transformDerivative
+	transformNegate
+	transformInverse
+	transformCumsum
+	transformLog
+)
+
+var transformNames = map[chartTransform]string{
+	transformNone:       "none",
+	transformDerivative: "derivative",
+	transformNegate:     "negate",
+	transformInverse:    "inverse",
+	transformCumsum:     "cumsum",
+	transformLog:        "log10",
+}
+
+var transformKeys = map[rune]chartTransform{
+	'n': transformNone,
+	'd': transformDerivative,
+	'-': transformNegate,
+	'i': transformInverse,
+	'c': transformCumsum,
+	'l': transformLog,
+}
+
+const transformMenu = "transform: [n]one │ [d]erivative │ [-] negate │ [i]nverse 1/x │ [c]umulative sum │ [l]og10 │ Esc to cancel"
+
+func (t chartTransform) String() string {
+	return transformNames[t]
+}
+
+func parseTransform(s string) (chartTransform, error) {
+	for t, name := range transformNames {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return transformNone, fmt.Errorf("unknown transform %q, want none, derivative, negate, inverse, cumsum or log10", s)
+}
+
+func applyTransform(t chartTransform, values []float64, times []time.Time) ([]float64, []time.Time) {
+	switch t {
+	case transformDerivative:
+		if len(values) < 2 || len(values) != len(times) {
+			return nil, nil
+		}
+		out := make([]float64, len(values)-1)
+		for i := 1; i < len(values); i++ {
+			dt := times[i].Sub(times[i-1]).Seconds()
+			if dt <= 0 {
+				out[i-1] = math.NaN()
+				continue
+			}
+			out[i-1] = (values[i] - values[i-1]) / dt
+		}
+		return out, times[1:]
+	case transformNegate:
+		out := make([]float64, len(values))
+		for i, v := range values {
+			out[i] = -v
+		}
+		return out, times
+	case transformInverse:
+		out := make([]float64, len(values))
+		for i, v := range values {
+			if v == 0 {
+				out[i] = math.NaN()
+			} else {
+				out[i] = 1 / v
+			}
+		}
+		return out, times
+	case transformCumsum:
+		out := make([]float64, len(values))
+		sum := 0.0
+		for i, v := range values {
+			if !math.IsNaN(v) {
+				sum += v
+			}
+			out[i] = sum
+		}
+		return out, times
+	case transformLog:
+		out := make([]float64, len(values))
+		for i, v := range values {
+			if v > 0 {
+				out[i] = math.Log10(v)
+			} else {
+				out[i] = math.NaN()
+			}
+		}
+		return out, times
+	}
+	return values, times
+}
+
+func (u *uiState) startTransform() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.transformMode = true
+}
+
+func (u *uiState) transformPrompt() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.transformMode
+}
+
+func (u *uiState) cancelTransform() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.transformMode = false
+}
+
+func (u *uiState) setTransform(name string, t chartTransform) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.transformMode = false
+	if u.transforms == nil {
+		u.transforms = map[string]chartTransform{}
+	}
+	if t == defaultTransform(name) {
+		delete(u.transforms, name)
+		return
+	}
+	u.transforms[name] = t
+}
+
+func (u *uiState) transformFor(name string) chartTransform {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if t, ok := u.transforms[name]; ok {
+		return t
+	}
+	return defaultTransform(name)
+}
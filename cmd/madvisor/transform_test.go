@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestApplyTransform(t *testing.T) {
+	base := time.Unix(1000, 0)
+	times := []time.Time{base, base.Add(2 * time.Second), base.Add(4 * time.Second)}
+	values := []float64{10, 20, 0}
+
+	d, dt := applyTransform(transformDerivative, values, times)
+	if len(d) != 2 || d[0] != 5 || d[1] != -10 || !dt[0].Equal(times[1]) {
+		t.Errorf("derivative = %v %v", d, dt)
+	}
+	if d, _ := applyTransform(transformDerivative, values[:1], times[:1]); d != nil {
+		t.Errorf("derivative of one point = %v, want nil", d)
+	}
+
+	if n, _ := applyTransform(transformNegate, values, times); n[0] != -10 || n[2] != 0 {
+		t.Errorf("negate = %v", n)
+	}
+
+	inv, _ := applyTransform(transformInverse, values, times)
+	if inv[0] != 0.1 || !math.IsNaN(inv[2]) {
+		t.Errorf("inverse = %v", inv)
+	}
+
+	c, _ := applyTransform(transformCumsum, []float64{1, math.NaN(), 2}, times)
+	if c[0] != 1 || c[1] != 1 || c[2] != 3 {
+		t.Errorf("cumsum = %v", c)
+	}
+
+	if same, _ := applyTransform(transformNone, values, times); &same[0] != &values[0] {
+		t.Error("none should return the input unchanged")
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	for tf, name := range transformNames {
+		got, err := parseTransform(name)
+		if err != nil || got != tf {
+			t.Errorf("parseTransform(%q) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := parseTransform("log"); err == nil {
+		t.Error("parseTransform(log) should fail")
+	}
+}
+
+func TestUIStateTransform(t *testing.T) {
+	u := &uiState{}
+	u.startTransform()
+	if !u.transformPrompt() {
+		t.Fatal("startTransform should open the menu")
+	}
+	u.setTransform("disk_used_bytes", transformDerivative)
+	if u.transformPrompt() {
+		t.Error("choosing a transform should close the menu")
+	}
+	if got := u.transformFor("disk_used_bytes"); got != transformDerivative {
+		t.Errorf("transformFor = %s, want derivative", got)
+	}
+	if got := u.transformFor("other"); got != transformNone {
+		t.Errorf("untouched metric transform = %s, want none", got)
+	}
+	u.setTransform("disk_used_bytes", transformNone)
+	if got := u.transformFor("disk_used_bytes"); got != transformNone {
+		t.Errorf("reset transform = %s, want none", got)
+	}
+}
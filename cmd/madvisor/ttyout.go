@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// termEscapes queues the escape sequences that are not drawn as cells, such
+// as the OSC 52 clipboard, and writes them to the terminal between frames.
+// Written while tcell is drawing, they would land inside a frame and
+// corrupt it.
+type termEscapes struct {
+	mu      sync.Mutex
+	out     io.Writer
+	pending []string
+}
+
+var termOut = &termEscapes{}
+
+// openTTY attaches the controlling terminal, which tcell draws on too; the
+// returned func detaches and closes it.
+func (e *termEscapes) openTTY() func() {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		e.attach(os.Stdout)
+		return func() { e.attach(nil) }
+	}
+	e.attach(tty)
+	return func() {
+		e.attach(nil)
+		tty.Close()
+	}
+}
+
+func (e *termEscapes) attach(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.out = w
+	e.pending = nil
+}
+
+// send queues seq for the next flush, reporting false when no terminal is
+// attached.
+func (e *termEscapes) send(seq string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.out == nil {
+		return false
+	}
+	e.pending = append(e.pending, seq)
+	return true
+}
+
+// flush writes the queued sequences; the render loop calls it right after a
+// redraw has been flushed to the terminal.
+func (e *termEscapes) flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) == 0 {
+		return nil
+	}
+	seqs := strings.Join(e.pending, "")
+	e.pending = nil
+	_, err := io.WriteString(e.out, seqs)
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTermEscapes(t *testing.T) {
+	e := &termEscapes{}
+	if e.send("\a") {
+		t.Error("send without a terminal should report it was dropped")
+	}
+	var out bytes.Buffer
+	e.attach(&out)
+	e.send("\x1b]52;c;eA==\a")
+	e.send("\a")
+	if out.Len() != 0 {
+		t.Fatalf("wrote %q before the flush", out.String())
+	}
+	if err := e.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "\x1b]52;c;eA==\a\a" {
+		t.Errorf("flushed %q, want both sequences in order", got)
+	}
+	out.Reset()
+	if e.flush(); out.Len() != 0 {
+		t.Errorf("second flush wrote %q", out.String())
+	}
+}
+
+func TestRingBellBetweenFrames(t *testing.T) {
+	var out bytes.Buffer
+	termOut.attach(&out)
+	defer termOut.attach(nil)
+	ringBell()
+	if out.Len() != 0 {
+		t.Fatal("the bell should wait for the next redraw")
+	}
+	termOut.flush()
+	if out.String() != "\a" {
+		t.Errorf("flushed %q, want the bell", out.String())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+const typeAheadTimeout = time.Second
+
+// startsTypeAhead reports whether r starts a type-ahead jump: keys bound in
+// metricListKeys don't, but once a prefix is being typed they extend it like
+// any other character; ' starts an empty prefix for names beginning with one
+// of them.
+func startsTypeAhead(r rune) bool {
+	_, bound := keyHandlers[r]
+	return r > 0x20 && r < 0x7f && r != '\'' && !bound
+}
+
+func (u *uiState) typeAheadActive(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.typeAheadOn && now.Sub(u.typeAheadAt) < typeAheadTimeout
+}
+
+func (u *uiState) startTypeAhead(now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.typeAheadOn = true
+	u.typeAhead = ""
+	u.typeAheadAt = now
+}
+
+// typeAheadKey extends the prefix (or starts a new one after a pause) and
+// moves the selection to the first visible metric starting with it.
+func (u *uiState) typeAheadKey(r rune, now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.typeAheadOn || now.Sub(u.typeAheadAt) >= typeAheadTimeout {
+		u.typeAhead = ""
+	}
+	u.typeAheadOn = true
+	u.typeAhead += string(r)
+	u.typeAheadAt = now
+	return u.jumpToPrefix()
+}
+
+func (u *uiState) typeAheadBackspace(now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.typeAhead) > 0 {
+		u.typeAhead = u.typeAhead[:len(u.typeAhead)-1]
+	}
+	u.typeAheadAt = now
+	u.jumpToPrefix()
+}
+
+func (u *uiState) cancelTypeAhead() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.typeAheadOn = false
+	u.typeAhead = ""
+}
+
+func (u *uiState) jumpToPrefix() bool {
+	if u.typeAhead == "" {
+		return true
+	}
+	prefix := strings.ToLower(u.typeAhead)
+	for i, k := range u.filtered {
+		if strings.HasPrefix(strings.ToLower(k), prefix) {
+			if i != u.selectedIdx {
+				u.selectedIdx = i
+				u.seriesIdx = 0
+				u.seriesScroll = 0
+				u.adjustScroll()
+			}
+			u.typeAheadMiss = false
+			return true
+		}
+	}
+	u.typeAheadMiss = true
+	return false
+}
+
+// typeAheadEcho returns the prefix being typed while it is still live, and
+// whether any metric matched it.
+func (u *uiState) typeAheadEcho(now time.Time) (string, bool, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.typeAheadOn || now.Sub(u.typeAheadAt) >= typeAheadTimeout {
+		return "", false, false
+	}
+	return u.typeAhead, !u.typeAheadMiss, true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartsTypeAhead(t *testing.T) {
+	for _, r := range "abcnxzGJ_0" {
+		if !startsTypeAhead(r) {
+			t.Errorf("%q should start a type-ahead jump", r)
+		}
+	}
+	for _, r := range "qjkgpWuUCASBYy19/: '" {
+		if startsTypeAhead(r) {
+			t.Errorf("%q is a command key and must not start a jump", r)
+		}
+	}
+}
+
+func TestTypeAheadJump(t *testing.T) {
+	ui := &uiState{}
+	ui.setKeys([]string{"go_goroutines", "node_cpu_seconds_total", "node_load1", "process_open_fds"})
+	now := time.Now()
+
+	if !ui.typeAheadKey('n', now) || ui.selectedKey() != "node_cpu_seconds_total" {
+		t.Fatalf("after n: selected %q", ui.selectedKey())
+	}
+	for _, r := range "ode_l" {
+		now = now.Add(100 * time.Millisecond)
+		ui.typeAheadKey(r, now)
+	}
+	if ui.selectedKey() != "node_load1" {
+		t.Errorf("after node_l: selected %q", ui.selectedKey())
+	}
+	if prefix, found, live := ui.typeAheadEcho(now); prefix != "node_l" || !found || !live {
+		t.Errorf("echo = %q, %v, %v", prefix, found, live)
+	}
+
+	ui.typeAheadKey('x', now)
+	if _, found, _ := ui.typeAheadEcho(now); found {
+		t.Error("node_lx should not match")
+	}
+	if ui.selectedKey() != "node_load1" {
+		t.Errorf("a miss must keep the selection, got %q", ui.selectedKey())
+	}
+	ui.typeAheadBackspace(now)
+	if prefix, found, _ := ui.typeAheadEcho(now); prefix != "node_l" || !found {
+		t.Errorf("after backspace echo = %q, %v", prefix, found)
+	}
+
+	later := now.Add(typeAheadTimeout)
+	if ui.typeAheadActive(later) {
+		t.Error("type-ahead should expire after the timeout")
+	}
+	ui.typeAheadKey('P', later)
+	if ui.selectedKey() != "process_open_fds" {
+		t.Errorf("a new prefix after the timeout should start over, got %q", ui.selectedKey())
+	}
+}
+
+func TestTypeAheadQuoteStartsCommandLetter(t *testing.T) {
+	ui := &uiState{}
+	ui.setKeys([]string{"alpha", "go_goroutines"})
+	now := time.Now()
+	ui.startTypeAhead(now)
+	if !ui.typeAheadActive(now) {
+		t.Fatal("' should arm the type-ahead")
+	}
+	ui.typeAheadKey('g', now)
+	if ui.selectedKey() != "go_goroutines" {
+		t.Errorf("selected %q, want go_goroutines", ui.selectedKey())
+	}
+	ui.cancelTypeAhead()
+	if _, _, live := ui.typeAheadEcho(now); live {
+		t.Error("cancel should clear the echo")
+	}
+}
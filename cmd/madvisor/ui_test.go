@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"image"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mum4k/termdash/cell"
+	"github.com/mum4k/termdash/keyboard"
+	"github.com/mum4k/termdash/private/event/eventqueue"
+	"github.com/mum4k/termdash/private/faketerm"
+	"github.com/mum4k/termdash/terminal/terminalapi"
+)
+
+// uiTerm is a fake terminal that keeps a copy of the last flushed frame, so
+// tests never read a half-drawn screen.
+type uiTerm struct {
+	*faketerm.Terminal
+	mu    sync.Mutex
+	frame string
+}
+
+func (u *uiTerm) SetCell(p image.Point, r rune, opts ...cell.Option) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Terminal.SetCell(p, r, opts...)
+}
+
+func (u *uiTerm) Clear(opts ...cell.Option) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Terminal.Clear(opts...)
+}
+
+func (u *uiTerm) Flush() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.frame = u.Terminal.String()
+	return nil
+}
+
+func (u *uiTerm) screen() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.frame
+}
+
+type uiHarness struct {
+	t      *testing.T
+	term   *uiTerm
+	events *eventqueue.Unbound
+	done   chan error
+}
+
+// startUI runs the dashboard on a fake terminal against a single target
+// whose series are ingested by the feed below, and waits for the first
+// dashboard frame.
+func startUI(t *testing.T) *uiHarness {
+	t.Helper()
+	events := eventqueue.New()
+	h := &uiHarness{
+		t:      t,
+		term:   &uiTerm{Terminal: faketerm.MustNew(image.Pt(160, 50), faketerm.WithEventQueue(events))},
+		events: events,
+		done:   make(chan error, 1),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	// The feed ingests a fixed history and then idles: the render loop reads
+	// series without the store lock, so the tests keep writes out of its way.
+	feed := func(ctx context.Context, st *store) {
+		start := time.Now().Add(-20 * scrapeInterval)
+		for i := 0; i < 20; i++ {
+			now := start.Add(time.Duration(i) * scrapeInterval)
+			v := float64(i)
+			st.ingest("test:1", "alpha_requests_total", map[string]string{"code": "200"}, "Requests.", "counter", 10*v, now)
+			st.ingest("test:1", "alpha_requests_total", map[string]string{"code": "500"}, "Requests.", "counter", v, now)
+			st.ingest("test:1", "beta_queue_depth", nil, "Queue depth.", "gauge", v, now)
+			st.ingest("test:1", "gamma_temperature", map[string]string{"zone": "a"}, "Temperature.", "gauge", 20+v, now)
+		}
+		<-ctx.Done()
+	}
+	go func() { h.done <- runTerminal(ctx, h.term, []target{{addr: "test:1"}}, nil, feed) }()
+	t.Cleanup(func() {
+		cancel()
+		<-h.done
+		events.Close()
+	})
+	h.waitFor("dashboard", func(s string) bool { return strings.Contains(s, "metric names") })
+	return h
+}
+
+func (h *uiHarness) keys(keys ...keyboard.Key) {
+	for _, k := range keys {
+		h.events.Push(&terminalapi.Keyboard{Key: k})
+	}
+}
+
+func (h *uiHarness) typeText(s string) {
+	for _, r := range s {
+		h.keys(keyboard.Key(r))
+	}
+}
+
+func (h *uiHarness) waitFor(what string, ok func(screen string) bool) string {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := h.term.screen()
+		if ok(s) {
+			return s
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s; screen:\n%s", what, s)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// selectedLine is the first screen line carrying the ▶ selection marker
+// followed by want.
+func selectedLine(screen, want string) bool {
+	for _, line := range strings.Split(screen, "\n") {
+		if i := strings.Index(line, "▶ "); i >= 0 && strings.Contains(line[i:], want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUIMetricListNavigation(t *testing.T) {
+	h := startUI(t)
+	h.waitFor("first metric selected", func(s string) bool {
+		return selectedLine(s, "alpha_requests_total") && strings.Contains(s, "alpha_requests_total (2 series)")
+	})
+	h.keys(keyboard.Key('j'))
+	h.waitFor("second metric selected", func(s string) bool {
+		return selectedLine(s, "beta_queue_depth") && strings.Contains(s, "beta_queue_depth (1 series)")
+	})
+	h.keys(keyboard.KeyArrowDown, keyboard.KeyArrowUp, keyboard.KeyArrowUp)
+	h.waitFor("back on the first metric", func(s string) bool { return selectedLine(s, "alpha_requests_total") })
+}
+
+func TestUIHeldKeyNavigation(t *testing.T) {
+	h := startUI(t)
+	h.waitFor("first metric selected", func(s string) bool { return selectedLine(s, "alpha_requests_total") })
+	h.keys(keyboard.Key('j'), keyboard.Key('j'), keyboard.Key('j'), keyboard.Key('j'))
+	h.waitFor("list at the last metric", func(s string) bool { return selectedLine(s, "gamma_temperature") })
+	h.waitFor("chart caught up", func(s string) bool { return strings.Contains(s, "gamma_temperature (1 series)") })
+}
+
+func TestUIFilter(t *testing.T) {
+	h := startUI(t)
+	h.keys(keyboard.Key('/'))
+	h.typeText("gam")
+	h.waitFor("filter text", func(s string) bool { return strings.Contains(s, "Filter: gam█") })
+	h.waitFor("filtered list", func(s string) bool {
+		return selectedLine(s, "gamma_temperature") && !strings.Contains(s, "beta_queue_depth")
+	})
+	h.keys(keyboard.KeyEsc)
+	h.waitFor("filter cleared", func(s string) bool {
+		return !strings.Contains(s, "Filter:") && strings.Contains(s, "gamma_temperature")
+	})
+}
+
+func TestUISearchHighlights(t *testing.T) {
+	h := startUI(t)
+	h.keys(keyboard.Key('?'))
+	h.typeText("ue")
+	h.keys(keyboard.KeyEnter)
+	h.waitFor("search counter", func(s string) bool {
+		return strings.Contains(s, "Search: ue█  match 1/2") && selectedLine(s, "alpha_requests_total") && strings.Contains(s, "gamma_temperature")
+	})
+	h.keys(keyboard.Key('n'))
+	h.waitFor("next match", func(s string) bool {
+		return strings.Contains(s, "match 2/2") && selectedLine(s, "beta_queue_depth")
+	})
+}
+
+func TestUIPinYRange(t *testing.T) {
+	h := startUI(t)
+	h.keys(keyboard.Key('j'), keyboard.Key('j'))
+	h.waitFor("gauge chart", func(s string) bool { return strings.Contains(s, "gamma_temperature (1 series)") })
+	h.keys(keyboard.Key('Y'))
+	h.waitFor("pinned to the shown range", func(s string) bool {
+		return strings.Contains(s, "[pinned 20 – 3") && strings.Contains(s, ": 0 outside]")
+	})
+	h.keys(keyboard.Key('y'))
+	h.typeText("25 30")
+	h.keys(keyboard.KeyEnter)
+	h.waitFor("pinned to the typed range", func(s string) bool { return strings.Contains(s, "[pinned 25 – 30: ") })
+	h.keys(keyboard.Key('Y'))
+	h.waitFor("unpinned", func(s string) bool {
+		return !strings.Contains(s, "[pinned") && strings.Contains(s, "y axis: auto range")
+	})
+}
+
+func TestUISeriesTableFocus(t *testing.T) {
+	h := startUI(t)
+	h.waitFor("first metric selected", func(s string) bool { return selectedLine(s, "alpha_requests_total") })
+	h.keys(keyboard.KeyTab)
+	h.waitFor("series table focused", func(s string) bool {
+		return selectedLine(s, "200") && strings.Contains(s, "› [C] alpha_requests_total") && strings.Contains(s, "[rate/s]")
+	})
+	h.keys(keyboard.Key('j'))
+	h.waitFor("second series selected", func(s string) bool { return selectedLine(s, "500") })
+	h.keys(keyboard.KeyTab)
+	h.waitFor("metric list focused again", func(s string) bool {
+		return selectedLine(s, "alpha_requests_total") && strings.Contains(s, "(2 series)")
+	})
+}
+
+func TestUIQuit(t *testing.T) {
+	h := startUI(t)
+	h.keys(keyboard.Key('q'))
+	select {
+	case err := <-h.done:
+		if err != nil {
+			t.Errorf("runTerminal = %v", err)
+		}
+		h.done <- err
+	case <-time.After(5 * time.Second):
+		t.Fatal("q did not quit the dashboard")
+	}
+}
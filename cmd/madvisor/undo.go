@@ -0,0 +1,159 @@
+package main
+
+import (
+	"reflect"
+	"time"
+)
+
+const maxUndo = 50
+
+type viewState struct {
+	selected   string
+	seriesIdx  int
+	focus      focusPanel
+	filter     string
+	group      string
+	rateWindow time.Duration
+	transforms map[string]chartTransform
+	clip       map[string]bool
+	dual       bool
+	heatmap    bool
+	multiples  multiplesMode
+}
+
+func (u *uiState) captureView() viewState {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.captureViewLocked()
+}
+
+func (u *uiState) captureViewLocked() viewState {
+	v := viewState{
+		seriesIdx:  u.seriesIdx,
+		focus:      u.focus,
+		filter:     u.filterText,
+		group:      u.groupFilter,
+		rateWindow: rateWindowGet(),
+		dual:       u.dualView,
+		heatmap:    u.heatmap,
+		multiples:  u.multiples,
+	}
+	if u.selectedIdx >= 0 && u.selectedIdx < len(u.filtered) {
+		v.selected = u.filtered[u.selectedIdx]
+	}
+	for k, t := range u.transforms {
+		if v.transforms == nil {
+			v.transforms = map[string]chartTransform{}
+		}
+		v.transforms[k] = t
+	}
+	for k, on := range u.clipCharts {
+		if on {
+			if v.clip == nil {
+				v.clip = map[string]bool{}
+			}
+			v.clip[k] = true
+		}
+	}
+	return v
+}
+
+// undoKind classifies a change so that runs of the same kind of change,
+// like moving through the list or typing a filter, undo in one step.
+func undoKind(before, after viewState) string {
+	nav, filter := before, before
+	nav.selected, nav.seriesIdx = after.selected, after.seriesIdx
+	filter.filter, filter.selected, filter.seriesIdx = after.filter, after.selected, after.seriesIdx
+	switch {
+	case reflect.DeepEqual(nav, after):
+		return "nav"
+	case reflect.DeepEqual(filter, after):
+		return "filter"
+	}
+	return ""
+}
+
+// recordView pushes the view as it was before a key press onto the undo
+// history if the key press changed it.
+func (u *uiState) recordView(before viewState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.restoring {
+		u.restoring = false
+		return
+	}
+	after := u.captureViewLocked()
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	kind := undoKind(before, after)
+	u.redoStack = nil
+	if kind != "" && kind == u.undoKind && len(u.undoStack) > 0 {
+		return
+	}
+	u.undoKind = kind
+	u.undoStack = append(u.undoStack, before)
+	if len(u.undoStack) > maxUndo {
+		u.undoStack = u.undoStack[len(u.undoStack)-maxUndo:]
+	}
+}
+
+func (u *uiState) undo(st *store) bool {
+	return u.travel(st, &u.undoStack, &u.redoStack)
+}
+
+func (u *uiState) redo(st *store) bool {
+	return u.travel(st, &u.redoStack, &u.undoStack)
+}
+
+func (u *uiState) travel(st *store, from, to *[]viewState) bool {
+	u.mu.Lock()
+	if len(*from) == 0 {
+		u.mu.Unlock()
+		return false
+	}
+	v := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, u.captureViewLocked())
+	u.undoKind = ""
+	u.restoring = true
+	u.mu.Unlock()
+
+	u.applyView(st, v)
+	return true
+}
+
+func (u *uiState) applyView(st *store, v viewState) {
+	u.mu.Lock()
+	u.filterText = v.filter
+	u.filterMode = false
+	u.groupFilter = v.group
+	u.focus = v.focus
+	u.dualView = v.dual
+	u.heatmap = v.heatmap
+	u.multiples = v.multiples
+	u.transforms = map[string]chartTransform{}
+	for k, t := range v.transforms {
+		u.transforms[k] = t
+	}
+	u.clipCharts = map[string]bool{}
+	for k := range v.clip {
+		u.clipCharts[k] = true
+	}
+	u.applyFilter()
+	u.mu.Unlock()
+
+	names, _, _ := listKeys(st, u, v.group)
+	u.setKeys(names)
+	u.selectName(v.selected)
+
+	u.mu.Lock()
+	u.seriesIdx = v.seriesIdx
+	u.adjustSeriesScroll()
+	u.mu.Unlock()
+
+	changeRateWindow(st, func() time.Duration {
+		rateWindowSet(v.rateWindow)
+		return rateWindowGet()
+	})
+}
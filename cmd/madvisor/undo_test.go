@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func undoFixture() (*uiState, *store) {
+	st := newStore()
+	for _, n := range []string{"cpu_usage", "http_errors_total", "http_requests_total", "mem_bytes"} {
+		st.update(n, nil, "", "gauge", 1)
+	}
+	ui := &uiState{}
+	ui.setKeys(st.names())
+	return ui, st
+}
+
+func TestUndoRedoFilterAndSelection(t *testing.T) {
+	defer rateWindowSet(defaultRateWindow)
+	ui, st := undoFixture()
+
+	before := ui.captureView()
+	ui.selectName("http_requests_total")
+	ui.recordView(before)
+
+	before = ui.captureView()
+	ui.setFilter("mem")
+	ui.recordView(before)
+
+	before = ui.captureView()
+	changeRateWindow(st, func() time.Duration { rateWindowSet(30 * time.Second); return rateWindowGet() })
+	ui.recordView(before)
+
+	if !ui.undo(st) || rateWindowGet() != defaultRateWindow {
+		t.Fatalf("undo rate: window = %s", rateWindowGet())
+	}
+	ui.recordView(ui.captureView())
+	if !ui.undo(st) {
+		t.Fatal("undo filter failed")
+	}
+	if _, _, _, filter, _ := ui.snapshot(); filter != "" {
+		t.Errorf("filter = %q after undo, want cleared", filter)
+	}
+	if got := ui.selectedKey(); got != "http_requests_total" {
+		t.Errorf("selected = %q after undo, want http_requests_total", got)
+	}
+
+	if !ui.redo(st) {
+		t.Fatal("redo failed")
+	}
+	if _, _, _, filter, _ := ui.snapshot(); filter != "mem" {
+		t.Errorf("filter = %q after redo, want mem", filter)
+	}
+	if !ui.redo(st) || rateWindowGet() != 30*time.Second {
+		t.Errorf("redo rate: window = %s", rateWindowGet())
+	}
+	if ui.redo(st) {
+		t.Error("redo past the newest state should fail")
+	}
+}
+
+func TestUndoCoalescesNavigation(t *testing.T) {
+	ui, st := undoFixture()
+	for i := 0; i < 3; i++ {
+		before := ui.captureView()
+		ui.moveDown()
+		ui.recordView(before)
+	}
+	if got := ui.selectedKey(); got != "mem_bytes" {
+		t.Fatalf("selected = %q, want mem_bytes", got)
+	}
+	if !ui.undo(st) || ui.selectedKey() != "cpu_usage" {
+		t.Errorf("one undo should return to cpu_usage, got %q", ui.selectedKey())
+	}
+	if ui.undo(st) {
+		t.Error("navigation should have been a single undo step")
+	}
+}
+
+func TestRecordViewClearsRedo(t *testing.T) {
+	ui, st := undoFixture()
+	before := ui.captureView()
+	ui.setFilter("http")
+	ui.recordView(before)
+	ui.undo(st)
+	ui.recordView(ui.captureView())
+
+	before = ui.captureView()
+	ui.toggleDual()
+	ui.recordView(before)
+	if ui.redo(st) {
+		t.Error("a new change should drop the redo history")
+	}
+}
+
+func TestUndoHistoryIsBounded(t *testing.T) {
+	ui, _ := undoFixture()
+	for i := 0; i < maxUndo+10; i++ {
+		before := ui.captureView()
+		ui.toggleDual()
+		ui.recordView(before)
+	}
+	if len(ui.undoStack) != maxUndo {
+		t.Errorf("undo history = %d, want %d", len(ui.undoStack), maxUndo)
+	}
+}
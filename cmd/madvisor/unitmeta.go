@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// declaredUnits holds the units exporters announce with OpenMetrics
+// "# UNIT <family> <unit>" lines, keyed by family name. They take precedence
+// over the patterns file.
+var declaredUnits = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// omUnits maps OpenMetrics base units to the units formatValue knows.
+var omUnits = map[string]UnitMatch{
+	"seconds":      {Unit: "duration", Suffix: " [duration]"},
+	"milliseconds": {Unit: "duration_ms", Suffix: " [duration]"},
+	"bytes":        {Unit: "bytes", Suffix: " [bytes]"},
+	"ratio":        {Unit: "ratio", Suffix: " [%]"},
+	"percent":      {Unit: "percent", Suffix: " [%]"},
+}
+
+func declareUnit(family, unit string) {
+	declaredUnits.Lock()
+	defer declaredUnits.Unlock()
+	if unit == "" {
+		delete(declaredUnits.m, family)
+		return
+	}
+	declaredUnits.m[family] = unit
+}
+
+// declaredUnit looks up the unit of the family name belongs to. Histogram and
+// summary _count and _bucket series are counts, so only _total and _sum
+// inherit the family's unit.
+func declaredUnit(name string) string {
+	declaredUnits.RLock()
+	defer declaredUnits.RUnlock()
+	if u, ok := declaredUnits.m[name]; ok {
+		return u
+	}
+	for _, suffix := range []string{"_total", "_sum"} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			if u, ok := declaredUnits.m[base]; ok {
+				return u
+			}
+		}
+	}
+	return ""
+}
+
+// declaredUnitMatch turns an exporter-declared unit into a UnitMatch,
+// keeping a timestamp pattern match for seconds since both agree.
+func declaredUnitMatch(name string, pattern *UnitMatch) *UnitMatch {
+	u := declaredUnit(name)
+	if u == "" {
+		return nil
+	}
+	if u == "seconds" && pattern != nil && pattern.Unit == "timestamp" {
+		m := *pattern
+		m.Pattern, m.Declared = "", u
+		return &m
+	}
+	m, ok := omUnits[u]
+	if !ok {
+		m = UnitMatch{Unit: u, Suffix: " [" + u + "]"}
+	}
+	m.Declared = u
+	return &m
+}
+
+// unitOverrides holds the units set at runtime with the unit command, keyed
+// by metric name. They win over declared units and the patterns file.
+var unitOverrides = struct {
+	sync.RWMutex
+	m map[string]UnitMatch
+}{m: map[string]UnitMatch{}}
+
+// lookupUnit resolves a unit name as the patterns file or OpenMetrics name
+// it, e.g. bytes, duration, percent or seconds.
+func lookupUnit(unit string) (UnitMatch, error) {
+	if globalUnitMatcher != nil {
+		globalUnitMatcher.mu.RLock()
+		defer globalUnitMatcher.mu.RUnlock()
+		for _, cu := range globalUnitMatcher.units {
+			if cu.unit == unit {
+				return UnitMatch{Unit: cu.unit, Suffix: cu.suffix}, nil
+			}
+		}
+	}
+	if m, ok := omUnits[unit]; ok {
+		return m, nil
+	}
+	return UnitMatch{}, fmt.Errorf("unknown unit %q, want one from the patterns file such as bytes, duration or percent", unit)
+}
+
+// overrideUnit sets the unit of name, or clears the override for "clear".
+func overrideUnit(name, unit string) error {
+	unitOverrides.Lock()
+	defer unitOverrides.Unlock()
+	if unit == "clear" {
+		delete(unitOverrides.m, name)
+		return nil
+	}
+	m, err := lookupUnit(unit)
+	if err != nil {
+		return err
+	}
+	m.Override = true
+	unitOverrides.m[name] = m
+	return nil
+}
+
+func overriddenUnit(name string) *UnitMatch {
+	unitOverrides.RLock()
+	defer unitOverrides.RUnlock()
+	if m, ok := unitOverrides.m[name]; ok {
+		return &m
+	}
+	return nil
+}
+
+func unitMessage(what, unit string) string {
+	if unit == "clear" {
+		return "unit override cleared for " + what
+	}
+	return what + " shown in " + unit
+}
+
+// source describes where a unit came from, for the metadata panel.
+func (m *UnitMatch) source() string {
+	if m.Override {
+		return "(set with unit)"
+	}
+	if m.Declared != "" {
+		return "from # UNIT " + m.Declared
+	}
+	return "via /" + m.Pattern + "/ (patterns)"
+}
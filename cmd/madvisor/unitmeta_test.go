@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func resetDeclaredUnits(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		declaredUnits.Lock()
+		declaredUnits.m = map[string]string{}
+		declaredUnits.Unlock()
+	})
+}
+
+func TestParseUnitLines(t *testing.T) {
+	resetDeclaredUnits(t)
+	body := "# TYPE req_latency histogram\n# UNIT req_latency seconds\nreq_latency_sum 1.5\nreq_latency_count 3\n# UNIT temp celsius\ntemp 21.5\n"
+	var names []string
+	if err := parseExposition(strings.NewReader(body), func(name string, labels map[string]string, help, mtype string, val float64) {
+		names = append(names, name)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("samples = %v, want 3 (UNIT lines are not samples)", names)
+	}
+	if u := declaredUnit("req_latency_sum"); u != "seconds" {
+		t.Errorf("declaredUnit(_sum) = %q, want seconds", u)
+	}
+	if u := declaredUnit("req_latency_count"); u != "" {
+		t.Errorf("declaredUnit(_count) = %q, want none", u)
+	}
+	if u := declaredUnit("temp"); u != "celsius" {
+		t.Errorf("declaredUnit(temp) = %q, want celsius", u)
+	}
+}
+
+func TestDeclaredUnitOverridesPattern(t *testing.T) {
+	resetDeclaredUnits(t)
+	if m := matchUnit("cache_fill_bytes"); m == nil || m.Unit != "bytes" || m.Declared != "" {
+		t.Fatalf("pattern match = %+v, want bytes from patterns", m)
+	}
+	declareUnit("cache_fill_bytes", "ratio")
+	m := matchUnit("cache_fill_bytes")
+	if m == nil || m.Unit != "ratio" || m.Declared != "ratio" {
+		t.Fatalf("matchUnit = %+v, want declared ratio", m)
+	}
+	if got := formatValue("cache_fill_bytes", 0.25); got != "25.0%" {
+		t.Errorf("formatValue = %q, want 25.0%%", got)
+	}
+	if s := m.source(); s != "from # UNIT ratio" {
+		t.Errorf("source() = %q", s)
+	}
+	declareUnit("cache_fill_bytes", "")
+	if m := matchUnit("cache_fill_bytes"); m == nil || m.Declared != "" {
+		t.Errorf("after clearing, matchUnit = %+v, want pattern match", m)
+	}
+}
+
+func TestDeclaredUnitMappings(t *testing.T) {
+	resetDeclaredUnits(t)
+	declareUnit("proc_cpu", "seconds")
+	declareUnit("boot_time_seconds", "seconds")
+	declareUnit("psu_power", "watts")
+
+	tests := []struct {
+		name, unit, suffix string
+	}{
+		{"proc_cpu_total", "duration", " [duration]"},
+		{"boot_time_seconds", "timestamp", " [time]"},
+		{"psu_power", "watts", " [watts]"},
+	}
+	for _, tt := range tests {
+		m := matchUnit(tt.name)
+		if m == nil || m.Unit != tt.unit || m.Suffix != tt.suffix {
+			t.Errorf("matchUnit(%q) = %+v, want %s%s", tt.name, m, tt.unit, tt.suffix)
+		}
+	}
+	if got := (&UnitMatch{Pattern: "_bytes$"}).source(); got != "via /_bytes$/ (patterns)" {
+		t.Errorf("pattern source() = %q", got)
+	}
+}
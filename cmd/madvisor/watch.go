@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type watchKind int
+
+const (
+	watchAbove watchKind = iota
+	watchBelow
+	watchChange
+	watchAbsent
+)
+
+// defaultAbsentAfter is how long a watched series or metric may go without
+// a sample before an "absent" watch fires.
+const defaultAbsentAfter = 30 * time.Second
+
+// metricWatchPrefix marks a watch key naming a whole metric rather than one
+// series; only absent watches can be set on one.
+const metricWatchPrefix = "metric:"
+
+type watch struct {
+	key       string
+	label     string
+	kind      watchKind
+	threshold float64
+	base      float64
+	last      float64
+	primed    bool
+	missing   bool
+	// since is the last check while paused: nothing is stored then, so an
+	// absent watch measures the gap from no earlier than the resume.
+	since time.Time
+
+	firedAt       time.Time
+	lastMsg       string
+	silencedUntil time.Time
+}
+
+func parseWatch(expr string) (watchKind, float64, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return 0, 0, fmt.Errorf("empty condition, want >N, <N, N%% or absent [duration]")
+	}
+	if rest, ok := strings.CutPrefix(expr, "absent"); ok {
+		d := defaultAbsentAfter
+		if rest = strings.TrimSpace(rest); rest != "" {
+			var err error
+			if d, err = time.ParseDuration(rest); err != nil || d <= 0 {
+				return 0, 0, fmt.Errorf("invalid duration in %q", expr)
+			}
+		}
+		return watchAbsent, d.Seconds(), nil
+	}
+	kind := watchChange
+	num := expr
+	switch {
+	case strings.HasPrefix(expr, ">"):
+		kind, num = watchAbove, expr[1:]
+	case strings.HasPrefix(expr, "<"):
+		kind, num = watchBelow, expr[1:]
+	case strings.HasSuffix(expr, "%"):
+		num = strings.TrimPrefix(strings.TrimSuffix(expr, "%"), "±")
+	default:
+		return 0, 0, fmt.Errorf("invalid condition %q, want >N, <N, N%% or absent [duration]", expr)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, 0, fmt.Errorf("invalid number in %q", expr)
+	}
+	if kind == watchChange && v <= 0 {
+		return 0, 0, fmt.Errorf("change threshold must be positive, got %q", expr)
+	}
+	return kind, v, nil
+}
+
+func (w *watch) condition() string {
+	num := strconv.FormatFloat(w.threshold, 'f', -1, 64)
+	switch w.kind {
+	case watchAbove:
+		return ">" + num
+	case watchBelow:
+		return "<" + num
+	case watchAbsent:
+		return "absent " + w.absentAfter().String()
+	default:
+		return "±" + num + "%"
+	}
+}
+
+func (w *watch) check(v float64) (string, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", false
+	}
+	if !w.primed {
+		w.primed = true
+		w.base, w.last = v, v
+		return "", false
+	}
+	prev := w.last
+	w.last = v
+	switch w.kind {
+	case watchAbove:
+		if prev <= w.threshold && v > w.threshold {
+			return fmt.Sprintf("%s crossed above %s: %s", w.label, formatGeneric(w.threshold), formatGeneric(v)), true
+		}
+	case watchBelow:
+		if prev >= w.threshold && v < w.threshold {
+			return fmt.Sprintf("%s crossed below %s: %s", w.label, formatGeneric(w.threshold), formatGeneric(v)), true
+		}
+	case watchChange:
+		if v == w.base {
+			return "", false
+		}
+		if w.base == 0 {
+			w.base = v
+			return fmt.Sprintf("%s changed from 0 to %s", w.label, formatGeneric(v)), true
+		}
+		pct := (v - w.base) / math.Abs(w.base) * 100
+		if math.Abs(pct) > w.threshold {
+			msg := fmt.Sprintf("%s changed %+.1f%%: %s → %s", w.label, pct, formatGeneric(w.base), formatGeneric(v))
+			w.base = v
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+func (w *watch) absentAfter() time.Duration {
+	return time.Duration(w.threshold * float64(time.Second))
+}
+
+// checkPresence fires once when the watched data has not been reported for
+// absentAfter, and re-arms when it comes back. last is zero once the series
+// is gone altogether.
+func (w *watch) checkPresence(last, now time.Time) (string, bool) {
+	if !w.primed {
+		if last.IsZero() {
+			return "", false
+		}
+		w.primed = true
+	}
+	if !last.IsZero() && w.since.After(last) {
+		last = w.since
+	}
+	gone := last.IsZero() || now.Sub(last) > w.absentAfter()
+	switch {
+	case gone && !w.missing:
+		w.missing = true
+		if last.IsZero() {
+			return w.label + " disappeared", true
+		}
+		return fmt.Sprintf("%s stopped reporting, last sample %s ago", w.label, formatRelDuration(now.Sub(last))), true
+	case !gone:
+		w.missing = false
+	}
+	return "", false
+}
+
+// lastSeen is when the watched series, or any live series of the watched
+// metric, last had a sample; zero when there is none.
+func (st *store) lastSeen(key string) time.Time {
+	var list []*metricSeries
+	if name, ok := strings.CutPrefix(key, metricWatchPrefix); ok {
+		list = st.seriesForName(name)
+	} else if s := st.get(key); s != nil {
+		list = []*metricSeries{s}
+	}
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var last time.Time
+	for _, s := range list {
+		if !s.endedAt.IsZero() || s.count() == 0 {
+			continue
+		}
+		if t := s.lastTime(); t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+func watchValue(s *metricSeries) float64 {
+	if s.count() == 0 {
+		return math.NaN()
+	}
+	if s.shouldRate() {
+		return s.rate(rateWindowGet())
+	}
+	return s.last()
+}
+
+func (u *uiState) startWatch(key, label string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.watchMode = true
+	u.watchKey = key
+	u.watchLabel = label
+	u.watchInput = ""
+}
+
+func (u *uiState) watchPrompt() (bool, string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.watchMode, u.watchInput
+}
+
+func (u *uiState) addWatchChar(ch rune) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.watchInput += string(ch)
+}
+
+func (u *uiState) backspaceWatch() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.watchInput) > 0 {
+		u.watchInput = u.watchInput[:len(u.watchInput)-1]
+	}
+}
+
+func (u *uiState) cancelWatch() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.watchMode = false
+	u.watchInput = ""
+	u.watchKey = ""
+	u.watchLabel = ""
+}
+
+func (u *uiState) commitWatch() (*watch, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.watchMode = false
+	kind, threshold, err := parseWatch(u.watchInput)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(u.watchKey, metricWatchPrefix) && kind != watchAbsent {
+		return nil, fmt.Errorf("a whole metric can only be watched with absent; select a series for >N, <N or N%%")
+	}
+	w := &watch{key: u.watchKey, label: u.watchLabel, kind: kind, threshold: threshold}
+	for i, existing := range u.watches {
+		if existing.key == w.key {
+			u.watches[i] = w
+			return w, nil
+		}
+	}
+	u.watches = append(u.watches, w)
+	return w, nil
+}
+
+func (u *uiState) clearWatches() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	n := len(u.watches)
+	u.watches = nil
+	return n
+}
+
+func (u *uiState) watchCount() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.watches)
+}
+
+// watchReading is what the store holds for a watch's key.
+type watchReading struct {
+	lastSeen time.Time
+	value    float64
+	ok       bool
+}
+
+// checkWatches reads the store for every watch before taking the UI lock,
+// so a slow store never stalls key handling. A watch's key and kind are
+// fixed when it is created.
+func (u *uiState) checkWatches(st *store) []string {
+	u.mu.Lock()
+	watches := append([]*watch(nil), u.watches...)
+	u.mu.Unlock()
+	now, paused := time.Now(), st.isPaused()
+	readings := make([]watchReading, len(watches))
+	for i, w := range watches {
+		if w.kind == watchAbsent {
+			readings[i] = watchReading{lastSeen: st.lastSeen(w.key), ok: true}
+		} else if s := st.get(w.key); s != nil {
+			readings[i] = watchReading{value: watchValue(s), ok: true}
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var alerts []string
+	for i, w := range watches {
+		if !w.silencedUntil.IsZero() && !now.Before(w.silencedUntil) {
+			w.silencedUntil = time.Time{}
+		}
+		var msg string
+		var fired bool
+		switch r := readings[i]; {
+		case w.kind == watchAbsent && paused:
+			w.since = now
+		case w.kind == watchAbsent:
+			msg, fired = w.checkPresence(r.lastSeen, now)
+		case r.ok:
+			msg, fired = w.check(r.value)
+		}
+		if fired {
+			w.firedAt, w.lastMsg = now, msg
+			if w.silencedUntil.IsZero() {
+				alerts = append(alerts, msg)
+			}
+		}
+	}
+	return alerts
+}
+
+func selectedSeries(ui *uiState, st *store) *metricSeries {
+	name := ui.selectedKey()
+	if name == "" {
+		return nil
+	}
+	seriesList := displayedSeries(ui, st)
+	seriesIdx, _, focus, _ := ui.seriesSnapshot()
+	if focus == focusSeriesTable && seriesIdx >= 0 && seriesIdx < len(seriesList) {
+		return seriesList[seriesIdx]
+	}
+	if len(seriesList) == 1 {
+		return seriesList[0]
+	}
+	return nil
+}
+
+func ringBell() {
+	termOut.send("\a")
+}
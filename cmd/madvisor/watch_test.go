@@ -0,0 +1,223 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWatch(t *testing.T) {
+	tests := []struct {
+		in   string
+		kind watchKind
+		v    float64
+	}{
+		{">100", watchAbove, 100},
+		{"< 0.5", watchBelow, 0.5},
+		{"10%", watchChange, 10},
+		{"±2.5%", watchChange, 2.5},
+		{"absent", watchAbsent, 30},
+		{"absent 2m", watchAbsent, 120},
+	}
+	for _, tt := range tests {
+		kind, v, err := parseWatch(tt.in)
+		if err != nil {
+			t.Errorf("parseWatch(%q) error: %v", tt.in, err)
+			continue
+		}
+		if kind != tt.kind || v != tt.v {
+			t.Errorf("parseWatch(%q) = %v %v, want %v %v", tt.in, kind, v, tt.kind, tt.v)
+		}
+	}
+	for _, bad := range []string{"", "100", ">abc", "0%", "-5%", "absent soon", "absent -1s"} {
+		if _, _, err := parseWatch(bad); err == nil {
+			t.Errorf("parseWatch(%q) should fail", bad)
+		}
+	}
+}
+
+func TestWatchCrossAbove(t *testing.T) {
+	w := &watch{label: "cpu", kind: watchAbove, threshold: 10}
+	var fired []float64
+	for _, v := range []float64{5, 8, 12, 15, 9, 11} {
+		if _, ok := w.check(v); ok {
+			fired = append(fired, v)
+		}
+	}
+	if len(fired) != 2 || fired[0] != 12 || fired[1] != 11 {
+		t.Errorf("fired at %v, want [12 11]", fired)
+	}
+}
+
+func TestWatchStartsAboveDoesNotFire(t *testing.T) {
+	w := &watch{label: "cpu", kind: watchAbove, threshold: 10}
+	for _, v := range []float64{20, 25} {
+		if _, ok := w.check(v); ok {
+			t.Errorf("fired at %v without crossing", v)
+		}
+	}
+}
+
+func TestWatchCrossBelow(t *testing.T) {
+	w := &watch{label: "free", kind: watchBelow, threshold: 100}
+	w.check(150)
+	msg, ok := w.check(90)
+	if !ok || !strings.Contains(msg, "below") {
+		t.Errorf("check(90) = %q, %v", msg, ok)
+	}
+}
+
+func TestWatchChangePercent(t *testing.T) {
+	w := &watch{label: "rps", kind: watchChange, threshold: 10}
+	w.check(100)
+	if _, ok := w.check(105); ok {
+		t.Error("5% change should not fire")
+	}
+	msg, ok := w.check(115)
+	if !ok || !strings.Contains(msg, "+15.0%") {
+		t.Errorf("check(115) = %q, %v", msg, ok)
+	}
+	if _, ok := w.check(120); ok {
+		t.Error("baseline should move to 115 after firing")
+	}
+}
+
+func TestWatchChangeFromZero(t *testing.T) {
+	w := &watch{label: "errors", kind: watchChange, threshold: 50}
+	w.check(0)
+	if _, ok := w.check(0); ok {
+		t.Error("unchanged zero should not fire")
+	}
+	if _, ok := w.check(3); !ok {
+		t.Error("change from zero should fire")
+	}
+}
+
+func TestUIWatchLifecycle(t *testing.T) {
+	st := newStore()
+	st.update("temp", map[string]string{"zone": "a"}, "", "gauge", 10)
+	key := st.seriesForName("temp")[0].key
+
+	u := &uiState{}
+	u.startWatch(key, "temp{zone=a}")
+	for _, ch := range ">20" {
+		u.addWatchChar(ch)
+	}
+	if mode, input := u.watchPrompt(); !mode || input != ">20" {
+		t.Fatalf("watchPrompt = %v %q", mode, input)
+	}
+	w, err := u.commitWatch()
+	if err != nil {
+		t.Fatalf("commitWatch: %v", err)
+	}
+	if w.condition() != ">20" || u.watchCount() != 1 {
+		t.Errorf("condition=%q count=%d", w.condition(), u.watchCount())
+	}
+
+	if alerts := u.checkWatches(st); len(alerts) != 0 {
+		t.Errorf("first check should only prime, got %v", alerts)
+	}
+	st.update("temp", map[string]string{"zone": "a"}, "", "gauge", 25)
+	alerts := u.checkWatches(st)
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "temp{zone=a}") {
+		t.Errorf("alerts = %v", alerts)
+	}
+
+	u.startWatch(key, "temp{zone=a}")
+	u.addWatchChar('x')
+	if _, err := u.commitWatch(); err == nil {
+		t.Error("invalid condition should fail")
+	}
+	if u.watchCount() != 1 {
+		t.Errorf("invalid watch should not replace existing one")
+	}
+	if n := u.clearWatches(); n != 1 || u.watchCount() != 0 {
+		t.Errorf("clearWatches = %d, count = %d", n, u.watchCount())
+	}
+}
+
+func TestUIAlertMessage(t *testing.T) {
+	u := &uiState{}
+	u.setAlert("boom")
+	if !u.alertActive() || u.currentMessage() != "boom" {
+		t.Error("alert should be active")
+	}
+	u.setMessage("info")
+	if u.alertActive() {
+		t.Error("plain message should clear alert state")
+	}
+}
+
+func TestWatchAbsentSeries(t *testing.T) {
+	st := newStore()
+	t0 := time.Now()
+	st.ingest("a:1", "jobs_done", nil, "", "gauge", 1, t0)
+	key := seriesKey("jobs_done", nil)
+	w := &watch{key: key, label: "jobs_done", kind: watchAbsent, threshold: 30}
+
+	if _, fired := w.checkPresence(st.lastSeen(key), t0.Add(10*time.Second)); fired {
+		t.Error("fresh series should not fire")
+	}
+	msg, fired := w.checkPresence(st.lastSeen(key), t0.Add(45*time.Second))
+	if !fired || !strings.Contains(msg, "stopped reporting") || !w.firing(t0) {
+		t.Errorf("stale series: %q, %v", msg, fired)
+	}
+	if _, fired := w.checkPresence(st.lastSeen(key), t0.Add(50*time.Second)); fired {
+		t.Error("absent watch should fire once")
+	}
+
+	st.ingest("a:1", "jobs_done", nil, "", "gauge", 2, t0.Add(55*time.Second))
+	if _, fired := w.checkPresence(st.lastSeen(key), t0.Add(56*time.Second)); fired || w.firing(t0) {
+		t.Error("a new sample should clear the alarm")
+	}
+
+	st.ingest("a:1", "jobs_done", nil, "", "gauge", staleMarker, t0.Add(60*time.Second))
+	if msg, fired := w.checkPresence(st.lastSeen(key), t0.Add(61*time.Second)); !fired || !strings.Contains(msg, "disappeared") {
+		t.Errorf("stale marker: %q, %v", msg, fired)
+	}
+}
+
+func TestWatchAbsentWhilePaused(t *testing.T) {
+	st := newStore()
+	st.ingest("a:1", "jobs_done", nil, "", "gauge", 1, time.Now().Add(-time.Minute))
+	u := &uiState{}
+	u.watches = []*watch{{key: seriesKey("jobs_done", nil), label: "jobs_done", kind: watchAbsent, threshold: 30, primed: true}}
+
+	st.setPaused(true)
+	if alerts := u.checkWatches(st); len(alerts) != 0 {
+		t.Errorf("alerts while paused = %q, want none", alerts)
+	}
+	st.setPaused(false)
+	if alerts := u.checkWatches(st); len(alerts) != 0 {
+		t.Errorf("alerts on resume = %q, want the absence measured from the resume", alerts)
+	}
+}
+
+func TestWatchAbsentMetric(t *testing.T) {
+	st := newStore()
+	u := &uiState{}
+	u.startWatch(metricWatchPrefix+"build_info", "build_info")
+	u.addWatchChar('>')
+	u.addWatchChar('1')
+	if _, err := u.commitWatch(); err == nil {
+		t.Error("a whole-metric watch should only accept absent")
+	}
+
+	u.startWatch(metricWatchPrefix+"build_info", "build_info")
+	for _, r := range "absent 1ms" {
+		u.addWatchChar(r)
+	}
+	w, err := u.commitWatch()
+	if err != nil || w.condition() != "absent 1ms" {
+		t.Fatalf("commitWatch = %+v, %v", w, err)
+	}
+	if alerts := u.checkWatches(st); len(alerts) != 0 {
+		t.Errorf("never-seen metric should not fire, got %v", alerts)
+	}
+	st.update("build_info", map[string]string{"version": "1"}, "", "gauge", 1)
+	u.checkWatches(st)
+	time.Sleep(5 * time.Millisecond)
+	if alerts := u.checkWatches(st); len(alerts) != 1 || !strings.Contains(alerts[0], "build_info stopped reporting") {
+		t.Errorf("alerts = %v", alerts)
+	}
+}
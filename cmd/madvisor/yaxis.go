@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mum4k/termdash/widgets/linechart"
+)
+
+// flagYZero starts the dashboard with zero kept on the charts' Y axis.
+var flagYZero bool
+
+// yAxisScale is the charts' Y axis mode: by default it adapts to the range of
+// the data; with zero on it is stretched to include zero, so a series that
+// hovers just above or below it (a temperature delta, clock skew) is not
+// magnified into large swings and its sign stays visible.
+func yAxisScale(zero bool) []linechart.Option {
+	if zero {
+		return nil
+	}
+	return []linechart.Option{linechart.YAxisAdaptive()}
+}
+
+func (u *uiState) toggleYZero() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.yZero = !u.yZero
+	return u.yZero
+}
+
+func (u *uiState) yZeroEnabled() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.yZero
+}
+
+func yZeroMessage(zero bool) string {
+	if zero {
+		return "y axis: keep zero visible"
+	}
+	return "y axis: fit the data"
+}
+
+// yRange is a pinned Y axis range for one chart. Values outside it are drawn
+// at its edge and marked, as with outlier clipping.
+type yRange struct {
+	lo, hi float64
+}
+
+func (r yRange) String() string {
+	return strconv.FormatFloat(r.lo, 'g', 6, 64) + " – " + strconv.FormatFloat(r.hi, 'g', 6, 64)
+}
+
+// shownYRange is the data range of the chart last drawn, which Y pins.
+var shownYRange atomic.Pointer[namedYRange]
+
+type namedYRange struct {
+	chart string
+	yRange
+}
+
+// dataRange is the range of the finite values in datasets. A flat line is
+// widened so it can be pinned.
+func dataRange(datasets [][]float64) (yRange, bool) {
+	r, ok := yRange{lo: math.Inf(1), hi: math.Inf(-1)}, false
+	for _, data := range datasets {
+		for _, v := range data {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			r.lo, r.hi, ok = math.Min(r.lo, v), math.Max(r.hi, v), true
+		}
+	}
+	if ok && r.lo == r.hi {
+		pad := math.Max(math.Abs(r.lo)*0.1, 1)
+		r.lo, r.hi = r.lo-pad, r.hi+pad
+	}
+	return r, ok
+}
+
+// parseYRange parses "<min> <max>", or "auto" to unpin (reported as ok false).
+func parseYRange(s string) (r yRange, ok bool, err error) {
+	f := strings.Fields(s)
+	if len(f) == 1 && strings.EqualFold(f[0], "auto") {
+		return yRange{}, false, nil
+	}
+	if len(f) != 2 {
+		return yRange{}, false, fmt.Errorf("yrange expects <min> <max> or auto, got %q", s)
+	}
+	lo, errLo := strconv.ParseFloat(f[0], 64)
+	hi, errHi := strconv.ParseFloat(f[1], 64)
+	if errLo != nil || errHi != nil || math.IsNaN(lo) || math.IsNaN(hi) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return yRange{}, false, fmt.Errorf("yrange: invalid number in %q", s)
+	}
+	if lo >= hi {
+		return yRange{}, false, fmt.Errorf("yrange: min %s must be below max %s", f[0], f[1])
+	}
+	return yRange{lo: lo, hi: hi}, true, nil
+}
+
+// setYPin pins chart's Y axis to r, or unpins it when pin is false.
+func (u *uiState) setYPin(chart string, r yRange, pin bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !pin {
+		delete(u.yPins, chart)
+		return
+	}
+	if u.yPins == nil {
+		u.yPins = map[string]yRange{}
+	}
+	u.yPins[chart] = r
+}
+
+func (u *uiState) yPin(chart string) (yRange, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	r, ok := u.yPins[chart]
+	return r, ok
+}
+
+// toggleYPin unpins the shown chart's Y axis, or pins it to the range it is
+// showing. It returns the status bar message.
+func (u *uiState) toggleYPin() string {
+	shown := shownYRange.Load()
+	if shown == nil || shown.chart == "" {
+		return "y axis: no chart to pin"
+	}
+	if _, pinned := u.yPin(shown.chart); pinned {
+		u.setYPin(shown.chart, yRange{}, false)
+		return "y axis: auto range"
+	}
+	u.setYPin(shown.chart, shown.yRange, true)
+	return "y axis pinned to " + shown.yRange.String() + " (Y to unpin, y to edit)"
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestYAxisScale(t *testing.T) {
+	if got := yAxisScale(false); len(got) != 1 {
+		t.Errorf("fitted axis options = %d, want the adaptive option", len(got))
+	}
+	if got := yAxisScale(true); len(got) != 0 {
+		t.Errorf("zero axis options = %d, want none (termdash anchors at zero)", len(got))
+	}
+}
+
+func TestToggleYZero(t *testing.T) {
+	ui := &uiState{yZero: true}
+	if ui.toggleYZero() || ui.yZeroEnabled() {
+		t.Error("toggle from the --y-zero start should fit the data")
+	}
+	if !ui.toggleYZero() || yZeroMessage(true) != "y axis: keep zero visible" {
+		t.Error("second toggle should keep zero visible")
+	}
+
+	cmds, err := parseScript(strings.NewReader("zero\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, newStore()); len(errs) != 0 || ui.yZeroEnabled() {
+		t.Errorf("zero script command: errs %v, enabled %v; want it toggled off", errs, ui.yZeroEnabled())
+	}
+}
+
+func TestParseYRange(t *testing.T) {
+	if r, ok, err := parseYRange(" -5  12.5 "); err != nil || !ok || r != (yRange{lo: -5, hi: 12.5}) {
+		t.Errorf("parseYRange = %v, %v, %v", r, ok, err)
+	}
+	if _, ok, err := parseYRange("AUTO"); err != nil || ok {
+		t.Errorf("parseYRange(auto) = %v, %v; want unpin", ok, err)
+	}
+	for _, bad := range []string{"", "5", "1 2 3", "10 1", "3 3", "a 1", "NaN 1", "0 Inf"} {
+		if _, _, err := parseYRange(bad); err == nil {
+			t.Errorf("parseYRange(%q) should fail", bad)
+		}
+	}
+}
+
+func TestDataRange(t *testing.T) {
+	r, ok := dataRange([][]float64{{3, math.NaN(), -2}, {7, math.Inf(1)}})
+	if !ok || r != (yRange{lo: -2, hi: 7}) {
+		t.Errorf("dataRange = %v, %v", r, ok)
+	}
+	if r, ok := dataRange([][]float64{{50, 50}}); !ok || r != (yRange{lo: 45, hi: 55}) {
+		t.Errorf("flat dataRange = %v, %v; want widened by 10%%", r, ok)
+	}
+	if _, ok := dataRange([][]float64{{math.NaN()}}); ok {
+		t.Error("dataRange without finite values should not be ok")
+	}
+}
+
+func TestToggleYPin(t *testing.T) {
+	shownYRange.Store(nil)
+	defer shownYRange.Store(nil)
+	ui := &uiState{}
+	if got := ui.toggleYPin(); got != "y axis: no chart to pin" {
+		t.Errorf("no chart: %q", got)
+	}
+	shownYRange.Store(&namedYRange{chart: "cpu", yRange: yRange{lo: 0.5, hi: 1234.5678}})
+	if got := ui.toggleYPin(); got != "y axis pinned to 0.5 – 1234.57 (Y to unpin, y to edit)" {
+		t.Errorf("pin: %q", got)
+	}
+	if r, ok := ui.yPin("cpu"); !ok || r.hi != 1234.5678 {
+		t.Errorf("yPin = %v, %v", r, ok)
+	}
+	if got := ui.toggleYPin(); got != "y axis: auto range" {
+		t.Errorf("unpin: %q", got)
+	}
+	if _, ok := ui.yPin("cpu"); ok {
+		t.Error("chart should be unpinned")
+	}
+
+	st := newStore()
+	st.update("cpu", nil, "", "gauge", 1)
+	ui.setKeys(st.names())
+	cmds, err := parseScript(strings.NewReader("select cpu\nyrange -1 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := runScript(cmds, ui, st); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if r, ok := ui.yPin("cpu"); !ok || r != (yRange{lo: -1, hi: 1}) {
+		t.Errorf("yrange script: %v, %v", r, ok)
+	}
+	if _, err := parseScript(strings.NewReader("yrange 5 1\n")); err == nil {
+		t.Error("yrange with min above max should not parse")
+	}
+}
@@ -8,7 +8,9 @@ Uses 'kubectl debug' with ephemeral containers (requires Kubernetes 1.25+).
 
 Arguments:
   pod-name        Name of the target pod
-  metric-port(s)  Comma-separated metric ports (default: 8080)
+  metric-port(s)  Comma-separated metric ports (default: 8080), or "auto" to
+                  discover the pod and its replicas with --in-cluster (needs
+                  in-cluster-rbac.yaml applied for the pod's service account)
   namespace       Kubernetes namespace (default: current context namespace)
 
 Environment:
@@ -18,6 +20,7 @@ Examples:
   $0 my-app
   $0 my-app 9090
   $0 my-app 8080,9090 production
+  $0 my-app auto production
 "
 
 POD_NAME="${1:?$USAGE}"
@@ -28,7 +31,13 @@ IMAGE="${MADVISOR_IMAGE:-dcroche/madvisor:latest}"
 
 # Build comma-separated localhost:port targets
 TARGETS=""
-IFS=',' read -ra PORTS <<< "$METRIC_PORTS"
+MADVISOR_ARGS=()
+PORTS=()
+if [ "$METRIC_PORTS" = "auto" ]; then
+  MADVISOR_ARGS=(--in-cluster)
+else
+  IFS=',' read -ra PORTS <<< "$METRIC_PORTS"
+fi
 for port in "${PORTS[@]}"; do
   port=$(echo "$port" | tr -d ' ')
   if [ -n "$TARGETS" ]; then
@@ -48,7 +57,7 @@ echo " madVisor — ephemeral inject"
 echo "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
 echo "  Pod:     $POD_NAME"
 echo "  Image:   $IMAGE"
-echo "  Targets: $TARGETS"
+echo "  Targets: ${TARGETS:-in-cluster discovery}"
 [ -n "$NAMESPACE" ] && echo "  NS:      $NAMESPACE"
 echo ""
 echo "Attaching... (press Q or ESC to exit)"
@@ -59,4 +68,4 @@ exec kubectl debug "${NS_ARGS[@]}" -it "$POD_NAME" \
   --profile=general \
   --env="METRIC_TARGETS=$TARGETS" \
   --env="TERM=xterm-256color" \
-  -- /madvisor
+  -- /madvisor "${MADVISOR_ARGS[@]}"